@@ -0,0 +1,144 @@
+package balancer
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// accessLogStdout selects standard output as the access log destination
+const accessLogStdout = "-"
+
+// accessLogEntry is one line of the access log
+type accessLogEntry struct {
+	Time       string  `json:"time"`
+	Method     string  `json:"method"`
+	Path       string  `json:"path"`
+	Status     int     `json:"status"`
+	Bytes      int64   `json:"bytes"`
+	DurationMs float64 `json:"duration_ms"`
+	ClientIP   string  `json:"client_ip"`
+	RequestID  string  `json:"request_id"`
+	Backend    string  `json:"backend,omitempty"`
+}
+
+// accessLogger writes one JSON line per request. Only 1 in sampleRate
+// requests is logged, except that server errors and requests slower than
+// slowThreshold are always logged.
+type accessLogger struct {
+	sampleRate    uint64
+	slowThreshold time.Duration
+	seen          atomic.Uint64 // Requests considered for sampling
+
+	mu     sync.Mutex
+	out    io.Writer
+	closer io.Closer // nil when writing to stdout
+}
+
+// newAccessLogger opens the access log at path, or stdout for "-"
+func newAccessLogger(path string, sampleRate int, slowThreshold time.Duration) (*accessLogger, error) {
+	logger := &accessLogger{
+		sampleRate:    uint64(max(1, sampleRate)),
+		slowThreshold: slowThreshold,
+		out:           os.Stdout,
+	}
+	if path != accessLogStdout {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, err
+		}
+		logger.out = file
+		logger.closer = file
+	}
+	return logger, nil
+}
+
+// shouldLog applies sampling, letting errors and slow requests through
+func (l *accessLogger) shouldLog(status int, duration time.Duration) bool {
+	if status >= 500 {
+		return true
+	}
+	if l.slowThreshold > 0 && duration >= l.slowThreshold {
+		return true
+	}
+	return l.seen.Add(1)%l.sampleRate == 0
+}
+
+// Log writes the entry if it survives sampling
+func (l *accessLogger) Log(entry accessLogEntry, duration time.Duration) {
+	if !l.shouldLog(entry.Status, duration) {
+		return
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.out != nil {
+		l.out.Write(line)
+	}
+}
+
+// Close closes the log file; later entries are dropped
+func (l *accessLogger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.out = nil
+	if l.closer != nil {
+		return l.closer.Close()
+	}
+	return nil
+}
+
+// responseRecorder captures the status, size and backend of a response for
+// the access log
+type responseRecorder struct {
+	http.ResponseWriter
+	status  int
+	bytes   int64
+	backend string
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += int64(n)
+	return n, err
+}
+
+// Flush lets streamed responses through when the underlying writer supports it
+func (rec *responseRecorder) Flush() {
+	if flusher, ok := rec.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (rec *responseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// noteBackend records which backend served the request when access logging
+func noteBackend(w http.ResponseWriter, backendID string) {
+	if rec, ok := w.(*responseRecorder); ok {
+		rec.backend = backendID
+	}
+}
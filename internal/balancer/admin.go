@@ -0,0 +1,348 @@
+package balancer
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-balancer/internal/pool"
+)
+
+// adminBackendsPath is the prefix of the per-backend admin endpoints
+const adminBackendsPath = "/admin/backends/"
+
+// MaintenanceHandler exposes maintenance mode over HTTP.
+// GET returns the current state; POST/PUT with ?enabled=true|false toggles it.
+func (lb *LoadBalancer) MaintenanceHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			// Just report the current state below
+		case http.MethodPost, http.MethodPut:
+			enabled, err := strconv.ParseBool(r.URL.Query().Get("enabled"))
+			if err != nil {
+				http.Error(w, "enabled query parameter must be true or false", http.StatusBadRequest)
+				return
+			}
+			lb.SetMaintenance(enabled)
+		default:
+			w.Header().Set("Allow", "GET, POST, PUT")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]bool{"maintenance": lb.IsInMaintenance()})
+	})
+}
+
+// LivenessHandler reports that the process is up. It always returns 200.
+func (lb *LoadBalancer) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok\n"))
+	})
+}
+
+// ReadinessHandler returns 200 only when at least one backend can take traffic
+// (healthy and not draining) and shutdown hasn't begun
+func (lb *LoadBalancer) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+
+		if lb.IsDraining() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("shutting down\n"))
+			return
+		}
+
+		if lb.serverPool.GetAvailableBackendCount() == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("no healthy backends\n"))
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ready\n"))
+	})
+}
+
+// ConfigHandler returns the effective configuration as JSON, with sensitive
+// values redacted. It is read-only.
+func (lb *LoadBalancer) ConfigHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(lb.config.Redacted())
+	})
+}
+
+// backendStatus is one backend's entry in the status report
+type backendStatus struct {
+	ID                string  `json:"id"`
+	URL               string  `json:"url"`
+	Healthy           bool    `json:"healthy"`
+	Draining          bool    `json:"draining"`
+	Backup            bool    `json:"backup"`
+	Canary            bool    `json:"canary"`
+	BackingOff        bool    `json:"backing_off"`
+	OverErrorBudget   bool    `json:"over_error_budget"`
+	Damped            bool    `json:"damped"`
+	FlapCount         int     `json:"flap_count"`
+	Weight            int     `json:"weight"`
+	Zone              string  `json:"zone,omitempty"`
+	Protocol          string  `json:"protocol,omitempty"`
+	ActiveConnections int64   `json:"active_connections"`
+	HealthScore       float64 `json:"health_score"`
+	AdaptiveWeight    float64 `json:"adaptive_weight"`
+	ErrorRate         float64 `json:"error_rate"`
+}
+
+// newBackendStatus reports a backend's current state
+func newBackendStatus(backend *pool.Backend) backendStatus {
+	return backendStatus{
+		ID:                backend.ID,
+		URL:               backend.URL.String(),
+		Healthy:           backend.IsHealthy(),
+		Draining:          backend.IsDraining(),
+		Backup:            backend.Backup,
+		Canary:            backend.Canary,
+		BackingOff:        backend.BackingOff(),
+		OverErrorBudget:   backend.OverErrorBudget(),
+		Damped:            backend.Damped(),
+		FlapCount:         backend.FlapCount(),
+		Weight:            backend.Weight(),
+		Zone:              backend.Zone,
+		Protocol:          backend.Protocol(),
+		ActiveConnections: backend.ActiveConnections(),
+		HealthScore:       backend.HealthScore(),
+		AdaptiveWeight:    backend.AdaptiveWeight(),
+		ErrorRate:         backend.ErrorRate(),
+	}
+}
+
+// StatusHandler returns the state of every backend as JSON, including the
+// health score derived from recent latency and error rate and the adaptive
+// weight derived from observed capacity. It is read-only.
+func (lb *LoadBalancer) StatusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		backends := lb.serverPool.GetBackends()
+		statuses := make([]backendStatus, 0, len(backends))
+		for _, backend := range backends {
+			statuses = append(statuses, newBackendStatus(backend))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(map[string]interface{}{
+			"strategy": lb.strategy.Name(),
+			"backends": statuses,
+		})
+	})
+}
+
+// backendRouting is a backend's entry in the routing report: the inputs the
+// strategies weigh when picking it
+type backendRouting struct {
+	ID                string  `json:"id"`
+	URL               string  `json:"url"`
+	Available         bool    `json:"available"`
+	Weight            int     `json:"weight"`
+	HealthScore       float64 `json:"health_score"`
+	AdaptiveWeight    float64 `json:"adaptive_weight"`
+	LatencyMs         float64 `json:"latency_ms"`        // Moving average behind the health score
+	MeanLatencyMs     float64 `json:"mean_latency_ms"`   // Over every successful request
+	RecentErrorRate   float64 `json:"recent_error_rate"` // Moving average behind the health score
+	ErrorRate         float64 `json:"error_rate"`        // Over the error budget window
+	Requests          int64   `json:"requests"`
+	Failures          int64   `json:"failures"`
+	TrafficShare      float64 `json:"traffic_share"` // Share of all proxied requests, 0-1
+	ActiveConnections int64   `json:"active_connections"`
+}
+
+// RoutingHandler reports, for every backend, the score, weights, latency and
+// error rates the strategies route by, alongside the traffic each backend has
+// actually received, so operators can see why requests went where they did.
+// It is read-only.
+func (lb *LoadBalancer) RoutingHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		traffic := lb.metrics.BackendTraffic()
+		var total int64
+		for _, t := range traffic {
+			total += t.Requests + t.Failures
+		}
+
+		backends := lb.serverPool.GetBackends()
+		report := make([]backendRouting, 0, len(backends))
+		for _, backend := range backends {
+			t := traffic[backend.ID]
+			entry := backendRouting{
+				ID:                backend.ID,
+				URL:               backend.URL.String(),
+				Available:         backend.Available(),
+				Weight:            backend.Weight(),
+				HealthScore:       backend.HealthScore(),
+				AdaptiveWeight:    backend.AdaptiveWeight(),
+				LatencyMs:         durationMs(backend.Latency()),
+				MeanLatencyMs:     durationMs(t.MeanLatency),
+				RecentErrorRate:   backend.RecentErrorRate(),
+				ErrorRate:         backend.ErrorRate(),
+				Requests:          t.Requests + t.Failures,
+				Failures:          t.Failures,
+				ActiveConnections: backend.ActiveConnections(),
+			}
+			if total > 0 {
+				entry.TrafficShare = float64(entry.Requests) / float64(total)
+			}
+			report = append(report, entry)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(map[string]interface{}{
+			"strategy": lb.strategy.Name(),
+			"backends": report,
+		})
+	})
+}
+
+// durationMs converts d to fractional milliseconds
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// backendUpdate is the body of a PATCH to a backend's admin endpoint
+type backendUpdate struct {
+	Weight  *int  `json:"weight"`
+	Healthy *bool `json:"healthy"`
+}
+
+// BackendsHandler manages individual backends at /admin/backends/{id}.
+// GET returns the backend's status; PATCH with a JSON body such as
+// {"weight": 5} changes its weight, which weighted strategies pick up on
+// their next selection. Weights must be positive. With health checks
+// disabled, {"healthy": false} takes the backend out of rotation and
+// {"healthy": true} puts it back; otherwise probes own its health and the
+// update is refused with 409.
+func (lb *LoadBalancer) BackendsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, adminBackendsPath)
+		backend := lb.serverPool.GetBackendByID(id)
+		if id == "" || strings.Contains(id, "/") || backend == nil {
+			http.Error(w, "backend not found", http.StatusNotFound)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			// Just report the current state below
+		case http.MethodPatch:
+			var update backendUpdate
+			if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+				http.Error(w, "body must be a JSON object", http.StatusBadRequest)
+				return
+			}
+			if update.Weight == nil && update.Healthy == nil {
+				http.Error(w, "nothing to update", http.StatusBadRequest)
+				return
+			}
+			if update.Weight != nil && (*update.Weight <= 0 || *update.Weight > pool.MaxWeight) {
+				http.Error(w, fmt.Sprintf("weight must be between 1 and %d", pool.MaxWeight), http.StatusBadRequest)
+				return
+			}
+			if update.Healthy != nil && lb.healthChecker != nil {
+				http.Error(w, "backend health is managed by health checks", http.StatusConflict)
+				return
+			}
+			if update.Weight != nil {
+				lb.serverPool.SetBackendWeight(backend.ID, *update.Weight)
+			}
+			if update.Healthy != nil {
+				lb.serverPool.SetBackendHealth(backend.ID, *update.Healthy)
+			}
+		default:
+			w.Header().Set("Allow", "GET, PATCH")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(newBackendStatus(backend))
+	})
+}
+
+// trafficSplitUpdate is the body of a PUT to the traffic split endpoint
+type trafficSplitUpdate struct {
+	GreenPercent *float64 `json:"green_percent"`
+}
+
+// TrafficSplitHandler exposes the blue/green traffic split at
+// /admin/traffic-split. GET returns the current split; PUT with a JSON body
+// such as {"green_percent": 25} changes it for subsequent requests. It
+// returns 404 unless green backends are configured.
+func (lb *LoadBalancer) TrafficSplitHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if lb.split == nil {
+			http.Error(w, "no traffic split configured", http.StatusNotFound)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			// Just report the current split below
+		case http.MethodPut:
+			var update trafficSplitUpdate
+			if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+				http.Error(w, "body must be a JSON object", http.StatusBadRequest)
+				return
+			}
+			if update.GreenPercent == nil {
+				http.Error(w, "nothing to update", http.StatusBadRequest)
+				return
+			}
+			if *update.GreenPercent < 0 || *update.GreenPercent > 100 {
+				http.Error(w, "green_percent must be between 0 and 100", http.StatusBadRequest)
+				return
+			}
+			lb.split.setGreenPercent(*update.GreenPercent)
+			log.Printf("Traffic split changed to %g/%g (blue/green)", 100-*update.GreenPercent, *update.GreenPercent)
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		green := lb.split.GreenPercent()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]float64{
+			"blue_percent":  100 - green,
+			"green_percent": green,
+		})
+	})
+}
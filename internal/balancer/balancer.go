@@ -1,74 +1,496 @@
 package balancer
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/hex"
+	stderrors "errors"
 	"io"
 	"log"
+	"math"
 	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"go-balancer/internal/cache"
+	"go-balancer/internal/clientcert"
+	"go-balancer/internal/clientip"
 	"go-balancer/internal/config"
+	"go-balancer/internal/discovery"
 	"go-balancer/internal/errors"
 	"go-balancer/internal/healthcheck"
 	"go-balancer/internal/metrics"
 	"go-balancer/internal/pool"
+	"go-balancer/internal/ratelimit"
+	"go-balancer/internal/schedule"
 	"go-balancer/internal/strategy"
 )
 
 // LoadBalancer represents our load balancer
 type LoadBalancer struct {
-	config        *config.Config
-	client        *http.Client
-	serverPool    *pool.ServerPool
-	strategy      strategy.LoadBalancingStrategy
-	healthChecker *healthcheck.HealthChecker
-	metrics       *metrics.Metrics
+	config         *config.Config
+	client         *http.Client
+	h2cClient      *http.Client // Speaks HTTP/2 to plain-HTTP backends detected to support it; nil unless DetectBackendProtocol is set
+	serverPool     *pool.ServerPool
+	strategy       strategy.LoadBalancingStrategy
+	healthChecker  *healthcheck.HealthChecker
+	discoverer     *discovery.SRVDiscoverer // nil unless DiscoverySRV is set
+	metrics        *metrics.Metrics
+	clientIP       *clientip.Resolver
+	allowedIPs     *clientip.Allowlist    // Client ranges allowed through; empty allows all
+	staleCache     *cache.ResponseCache   // nil unless ServeStaleOnError is enabled
+	failureCodes   config.StatusCodeSet   // Backend status codes recorded as failures
+	deniedPaths    *config.PathMatcher    // Request paths rejected with 403
+	connectTargets *config.ConnectTargets // Targets CONNECT requests may tunnel to; nil unless ConnectTunnels is set
+	noKeepAlive    map[string]bool        // Backend URLs that get a fresh connection per request
+
+	upstreamSchemes map[string]string // Per-backend scheme overrides keyed by canonical backend URL
+
+	routes []*route // Most specific first; empty unless Routes are configured
+
+	canary      *pool.Backend       // nil unless CanaryBackend is set
+	canaryMatch *config.CanaryMatch // Requests always routed to the canary; nil if unset
+
+	split *trafficSplit // Blue/green traffic split; nil unless GreenBackends are set
 
 	metricsProvider metrics.MetricsProvider
+	jsonMetrics     *metrics.JSONMetricsProvider // Always served alongside metricsProvider
+	statsd          *metrics.StatsDEmitter       // nil unless StatsDAddress is set
+
+	drainScheduler *schedule.DrainScheduler // nil unless DrainWindows are configured
+
+	fileDiscoverer *discovery.FileDiscoverer // nil unless BackendsFile is set
+
+	rateLimiter *ratelimit.Limiter // nil unless RateLimit is set
+
+	requestSlots chan struct{} // Bounds concurrent requests; nil unless MaxConcurrentRequests is set
+
+	slotFreed *slotSignal // Wakes requests queued for a backend; nil unless MaxBackendConnections is set
+
+	shadowSlots chan struct{} // Bounds in-flight mirrored requests; nil unless ShadowBackend is set
+
+	accessLog *accessLogger // nil unless AccessLog is set
+
+	// usingBackups records whether the last selection fell back to backup
+	// backends, so the switch is logged once
+	usingBackups atomic.Bool
+
+	// maintenance rejects all traffic with 503 when set, regardless of backend health
+	maintenance atomic.Bool
+
+	// draining is set once shutdown begins; new requests are rejected while
+	// in-flight ones finish
+	draining atomic.Bool
+
+	stopOnce sync.Once // Makes Stop safe to call more than once
 }
 
-// NewLoadBalancer creates a new LoadBalancer instance
+// servedByHeader names the backend that served a response, when enabled
+const servedByHeader = "X-Served-By"
+
+// Headers carrying the client's TLS certificate to backends, when enabled
+const (
+	clientCertSubjectHeader = "X-Client-Cert-Subject"
+	clientCertIssuerHeader  = "X-Client-Cert-Issuer"
+	clientCertSerialHeader  = "X-Client-Cert-Serial"
+)
+
+var clientCertHeaders = []string{clientCertSubjectHeader, clientCertIssuerHeader, clientCertSerialHeader}
+
+// forceBackendHeader names the backend ID a request must be sent to, when enabled
+const forceBackendHeader = "X-Force-Backend"
+
+// Limits for the stale-on-error response cache
+const (
+	staleCacheMaxEntries   = 1000
+	staleCacheMaxBodyBytes = 1 << 20
+)
+
+// maintenanceRetryAfter is the Retry-After value sent to clients during maintenance
+const maintenanceRetryAfter = 30 * time.Second
+
+// NewLoadBalancer creates a new LoadBalancer instance using the metrics
+// provider named in the configuration
 func NewLoadBalancer(cfg *config.Config) (*LoadBalancer, error) {
+	newProvider, err := metrics.LookupProvider(cfg.MetricsProvider)
+	if err != nil {
+		return nil, errors.NewInvalidConfigError("invalid metrics provider", err).
+			WithContext("metrics_provider", cfg.MetricsProvider)
+	}
+	return NewLoadBalancerWithMetricsProvider(cfg, newProvider)
+}
+
+// NewLoadBalancerWithMetricsProvider creates a new LoadBalancer instance whose
+// metrics are exposed by the provider built from newProvider
+func NewLoadBalancerWithMetricsProvider(cfg *config.Config, newProvider metrics.ProviderFactory) (*LoadBalancer, error) {
+	// Resolve optional settings so the running config reflects what is in effect
+	cfg = cfg.WithDefaults()
+
 	serverPool := pool.NewServerPool()
+	serverPool.SetMaxBackends(cfg.MaxBackends)
+	serverPool.SetIgnoreDuplicates(cfg.DuplicateBackends == config.DuplicateBackendsIgnore)
+	serverPool.SetErrorBudget(cfg.ErrorBudgetThreshold, cfg.ErrorBudgetWindow)
+	serverPool.SetFlapDamping(cfg.HealthCheckFlapThreshold, cfg.HealthCheckFlapWindow, cfg.HealthCheckFlapPenalty)
+	serverPool.SetStartHealthy(cfg.DisableHealthChecks)
 
 	// Add all configured backends to the pool
 	for _, backend := range cfg.Backends {
-		if err := serverPool.AddBackend(backend); err != nil {
+		added, err := serverPool.Add(backend)
+		if err != nil {
 			return nil, errors.NewInvalidBackendError(backend, err)
 		}
+		if weight, ok := cfg.BackendWeights[backend]; ok {
+			added.SetWeight(weight)
+		}
+		if zone, ok := cfg.BackendZones[backend]; ok {
+			added.Zone = zone
+		}
+	}
+
+	// Discover backends from DNS, resolving once up front
+	var discoverer *discovery.SRVDiscoverer
+	if cfg.DiscoverySRV != "" {
+		discoverer = discovery.NewSRVDiscoverer(
+			serverPool,
+			nil, // system resolver
+			cfg.DiscoverySRV,
+			cfg.DiscoveryScheme,
+			cfg.DiscoveryInterval,
+		)
+	}
+
+	// Load backends from a file, failing fast if it is missing or invalid
+	var fileDiscoverer *discovery.FileDiscoverer
+	if cfg.BackendsFile != "" {
+		fileDiscoverer = discovery.NewFileDiscoverer(serverPool, cfg.BackendsFile, cfg.BackendsFileInterval)
+		if err := fileDiscoverer.Refresh(); err != nil {
+			return nil, errors.NewInvalidConfigError("invalid backends file", err).
+				WithContext("backends_file", cfg.BackendsFile)
+		}
+	}
+
+	// Add backup backends, used only while every primary is unavailable
+	for _, backend := range cfg.BackupBackends {
+		added, err := serverPool.Add(backend)
+		if err != nil {
+			return nil, errors.NewInvalidBackendError(backend, err)
+		}
+		serverPool.SetBackendBackup(added.ID, true)
+		if weight, ok := cfg.BackendWeights[backend]; ok {
+			added.SetWeight(weight)
+		}
+		if zone, ok := cfg.BackendZones[backend]; ok {
+			added.Zone = zone
+		}
+	}
+
+	// Add the green backends and split traffic between them and the blue
+	// (primary) backends
+	var split *trafficSplit
+	if len(cfg.GreenBackends) > 0 {
+		for _, backend := range cfg.GreenBackends {
+			added, err := serverPool.Add(backend)
+			if err != nil {
+				return nil, errors.NewInvalidBackendError(backend, err)
+			}
+			if weight, ok := cfg.BackendWeights[backend]; ok {
+				added.SetWeight(weight)
+			}
+			if zone, ok := cfg.BackendZones[backend]; ok {
+				added.Zone = zone
+			}
+		}
+		split = newTrafficSplit(serverPool.Subset(cfg.Backends), serverPool.Subset(cfg.GreenBackends), cfg.GreenPercent)
+	}
+
+	// Add the canary backend, which only gets traffic routed to it explicitly
+	var canary *pool.Backend
+	var canaryMatch *config.CanaryMatch
+	if cfg.CanaryBackend != "" {
+		added, err := serverPool.Add(cfg.CanaryBackend)
+		if err != nil {
+			return nil, errors.NewInvalidBackendError(cfg.CanaryBackend, err)
+		}
+		canary = added
+		serverPool.SetBackendCanary(canary.ID, true)
+
+		if cfg.CanaryMatch != "" {
+			match, err := config.ParseCanaryMatch(cfg.CanaryMatch)
+			if err != nil {
+				return nil, errors.NewInvalidConfigError("invalid canary match", err).
+					WithContext("canary_match", cfg.CanaryMatch)
+			}
+			canaryMatch = match
+		}
+	}
+
+	// Backends that misbehave on reused connections get a fresh one per request
+	noKeepAlive := make(map[string]bool, len(cfg.DisableKeepAliveBackends))
+	for _, backend := range cfg.DisableKeepAliveBackends {
+		noKeepAlive[pool.NormalizeURL(backend)] = true
+	}
+
+	// Per-backend scheme overrides, keyed like the pooled backends
+	upstreamSchemes := make(map[string]string, len(cfg.UpstreamSchemes))
+	for backend, scheme := range cfg.UpstreamSchemes {
+		upstreamSchemes[pool.NormalizeURL(backend)] = scheme
 	}
 
-	// Validate we have at least one backend
-	if serverPool.GetBackendCount() == 0 {
+	// Validate we have at least one backend; with discovery or a watched file
+	// they may arrive later
+	if serverPool.GetBackendCount() == 0 && discoverer == nil && fileDiscoverer == nil {
 		return nil, errors.NewPoolEmptyError()
 	}
 
-	// Create health checker
-	healthChecker := healthcheck.NewHealthChecker(
-		serverPool,
-		cfg.HealthCheckPath,
-		cfg.HealthCheckInterval,
-		cfg.HealthCheckTimeout,
-	)
+	// Select the load balancing strategy
+	lbStrategy, err := strategy.NewStrategy(cfg.Strategy)
+	if err != nil {
+		return nil, errors.NewStrategyFailureError(cfg.Strategy, err)
+	}
+
+	// Build the client IP resolver from the trusted proxy list
+	resolver, err := clientip.NewResolver(cfg.TrustedProxies)
+	if err != nil {
+		return nil, errors.NewInvalidConfigError("invalid trusted proxies", err)
+	}
+	allowedIPs, err := clientip.NewAllowlist(cfg.AllowedClientCIDRs)
+	if err != nil {
+		return nil, errors.NewInvalidConfigError("invalid allowed client CIDRs", err)
+	}
+
+	// Resolve which backend status codes count as failures
+	failureCodes := config.DefaultFailureStatusCodes
+	if len(cfg.FailureStatusCodes) > 0 {
+		failureCodes, err = config.ParseStatusCodes(cfg.FailureStatusCodes)
+		if err != nil {
+			return nil, errors.NewInvalidConfigError("invalid failure status codes", err)
+		}
+	}
+
+	// Load the CA bundle health probes verify https backends against
+	var healthRootCAs *x509.CertPool
+	if cfg.HealthCheckCAFile != "" {
+		healthRootCAs, err = healthcheck.LoadRootCAs(cfg.HealthCheckCAFile)
+		if err != nil {
+			return nil, errors.NewInvalidConfigError("invalid health check CA file", err).
+				WithContext("health_ca_file", cfg.HealthCheckCAFile)
+		}
+	}
+
+	// Load the client certificates presented to backends requiring mutual TLS
+	clientCerts, err := clientcert.Load(cfg)
+	if err != nil {
+		return nil, errors.NewInvalidConfigError("invalid upstream client certificate", err)
+	}
+
+	// Compile the path denylist
+	deniedPaths, err := config.ParsePathRules(cfg.DeniedPaths)
+	if err != nil {
+		return nil, errors.NewInvalidConfigError("invalid denied paths", err)
+	}
+
+	// Compile the CONNECT target allowlist
+	var connectTargets *config.ConnectTargets
+	if cfg.ConnectTunnels {
+		connectTargets, err = config.ParseConnectTargets(cfg.ConnectAllowedTargets)
+		if err != nil {
+			return nil, errors.NewInvalidConfigError("invalid CONNECT targets", err)
+		}
+	}
+
+	// Build per-route pools and strategies
+	routes, err := buildRoutes(cfg.Routes, serverPool)
+	if err != nil {
+		return nil, errors.NewInvalidConfigError("invalid route", err)
+	}
 
-	// Start health checks
-	healthChecker.Start()
+	// Parse scheduled maintenance windows
+	var drainWindows []schedule.DrainWindow
+	for _, spec := range cfg.DrainWindows {
+		window, err := schedule.ParseDrainWindow(spec)
+		if err != nil {
+			return nil, errors.NewInvalidConfigError("invalid drain window", err).
+				WithContext("drain_window", spec)
+		}
+		drainWindows = append(drainWindows, window)
+	}
+
+	// Open the access log
+	var accessLog *accessLogger
+	if cfg.AccessLog != "" {
+		accessLog, err = newAccessLogger(cfg.AccessLog, cfg.AccessLogSampleRate, cfg.AccessLogSlowThreshold)
+		if err != nil {
+			return nil, errors.NewInvalidConfigError("cannot open access log", err).
+				WithContext("access_log", cfg.AccessLog)
+		}
+	}
+
+	// Start discovery first so the initial SRV resolution is covered by the
+	// health checker's first round
+	if discoverer != nil {
+		discoverer.Start()
+	}
+	if fileDiscoverer != nil {
+		fileDiscoverer.Start()
+	}
 
 	m := metrics.NewMetrics()
-	return &LoadBalancer{
+	m.SetActiveConnectionsSource(func() map[string]int64 {
+		counts := make(map[string]int64)
+		for _, backend := range serverPool.GetBackends() {
+			counts[backend.ID] = backend.ActiveConnections()
+		}
+		return counts
+	})
+
+	// Start health checks; backends take traffic once a probe succeeds. With
+	// checks disabled, backends are healthy until marked down by the admin API.
+	var healthChecker *healthcheck.HealthChecker
+	if !cfg.DisableHealthChecks {
+		healthChecker = healthcheck.NewHealthChecker(serverPool, cfg)
+		healthChecker.SetMetrics(m)
+		if healthRootCAs != nil {
+			healthChecker.SetRootCAs(healthRootCAs)
+		}
+		healthChecker.SetClientCertificates(clientCerts)
+		healthChecker.Start()
+	}
+
+	// Drain backends during their maintenance windows
+	var drainScheduler *schedule.DrainScheduler
+	if len(drainWindows) > 0 {
+		drainScheduler = schedule.NewDrainScheduler(serverPool, drainWindows, schedule.DefaultCheckInterval)
+		drainScheduler.Start()
+	}
+
+	var rateLimiter *ratelimit.Limiter
+	if cfg.RateLimit > 0 {
+		rateLimiter = ratelimit.NewLimiter(cfg.RateLimit, cfg.RateLimitBurst)
+	}
+
+	var requestSlots chan struct{}
+	if cfg.MaxConcurrentRequests > 0 {
+		requestSlots = make(chan struct{}, cfg.MaxConcurrentRequests)
+	}
+
+	var shadowSlots chan struct{}
+	if cfg.ShadowBackend != "" {
+		shadowSlots = make(chan struct{}, shadowMaxInFlight)
+	}
+
+	var staleCache *cache.ResponseCache
+	if cfg.ServeStaleOnError {
+		staleCache = cache.NewResponseCache(staleCacheMaxEntries)
+	}
+
+	// Push metrics to StatsD alongside the pull-based provider
+	var statsd *metrics.StatsDEmitter
+	if cfg.StatsDAddress != "" {
+		statsd = metrics.NewStatsDEmitter(m, cfg.StatsDAddress, cfg.StatsDPrefix, cfg.StatsDInterval)
+		statsd.Start()
+	}
+
+	var slotFreed *slotSignal
+	if cfg.MaxBackendConnections > 0 {
+		serverPool.SetMaxConnections(cfg.MaxBackendConnections)
+		slotFreed = newSlotSignal()
+	}
+
+	lb := &LoadBalancer{
 		config:          cfg,
-		client:          &http.Client{},
+		client:          &http.Client{Transport: clientCerts.Transport(newTransport(cfg))},
 		serverPool:      serverPool,
-		strategy:        strategy.NewRoundRobinStrategy(),
+		strategy:        lbStrategy,
 		healthChecker:   healthChecker,
+		discoverer:      discoverer,
+		fileDiscoverer:  fileDiscoverer,
 		metrics:         m,
-		metricsProvider: metrics.NewPrometheusMetricsProvider(m),
-	}, nil
+		clientIP:        resolver,
+		staleCache:      staleCache,
+		failureCodes:    failureCodes,
+		deniedPaths:     deniedPaths,
+		connectTargets:  connectTargets,
+		allowedIPs:      allowedIPs,
+		noKeepAlive:     noKeepAlive,
+		upstreamSchemes: upstreamSchemes,
+		routes:          routes,
+		canary:          canary,
+		canaryMatch:     canaryMatch,
+		split:           split,
+		accessLog:       accessLog,
+		metricsProvider: newProvider(m, metrics.ProviderOptions{Prefix: cfg.MetricsPrefix, Labels: cfg.MetricsLabels}),
+		jsonMetrics:     metrics.NewJSONMetricsProvider(m),
+		statsd:          statsd,
+		drainScheduler:  drainScheduler,
+		rateLimiter:     rateLimiter,
+		requestSlots:    requestSlots,
+		slotFreed:       slotFreed,
+		shadowSlots:     shadowSlots,
+	}
+
+	// Learn which backends speak HTTP/2 before traffic arrives
+	if cfg.DetectBackendProtocol {
+		lb.h2cClient = &http.Client{Transport: newH2CTransport(cfg)}
+		for _, backend := range serverPool.GetBackends() {
+			lb.detectProtocol(backend)
+		}
+	}
+
+	// Pin requests with the same affinity header value to one backend; the
+	// configured strategy handles requests without it
+	if cfg.AffinityHeader != "" {
+		lb.strategy = strategy.NewAffinityStrategy(cfg.AffinityHeader, lbStrategy, lb.hashKey)
+	}
+
+	// Keep traffic in the local zone while it has an available backend
+	if cfg.LocalZone != "" {
+		lb.strategy = strategy.NewLocalityAwareStrategy(cfg.LocalZone, lb.strategy, lb.hashKey)
+	}
+
+	return lb, nil
 }
 
 // getNextHealthyBackend uses the configured strategy to get next backend
-func (lb *LoadBalancer) getNextHealthyBackend() (*pool.Backend, error) {
-	backend := lb.strategy.NextBackend(lb.serverPool)
+func (lb *LoadBalancer) getNextHealthyBackend(r *http.Request) (*pool.Backend, error) {
+	if lb.config.ForceBackendHeader {
+		if id := r.Header.Get(forceBackendHeader); id != "" {
+			return lb.forcedBackend(id)
+		}
+	}
+
+	if lb.routeToCanary(r) {
+		return lb.canary, nil
+	}
+
+	selectionPool := lb.selectionPool()
+	selector := lb.strategy
+
+	// A matching route selects with its own strategy, from its own backends
+	// when it lists any
+	if rt := lb.matchRoute(r); rt != nil {
+		selector = rt.strategy
+		if rt.pool != nil {
+			selectionPool = rt.pool
+		}
+	}
+
+	var backend *pool.Backend
+	if byRequest, ok := selector.(strategy.RequestStrategy); ok {
+		backend = byRequest.NextBackendForRequest(selectionPool, r)
+	} else if keyed, ok := selector.(strategy.KeyedStrategy); ok {
+		backend = keyed.NextBackendForKey(selectionPool, lb.hashKey(r))
+	} else {
+		backend = selector.NextBackend(selectionPool)
+	}
+
 	if backend == nil {
 		healthyCount := lb.serverPool.GetHealthyBackendCount()
 		totalCount := lb.serverPool.GetBackendCount()
@@ -84,91 +506,401 @@ func (lb *LoadBalancer) getNextHealthyBackend() (*pool.Backend, error) {
 	return backend, nil
 }
 
+// forcedBackend returns the backend a request named in X-Force-Backend,
+// bypassing the strategy. Unknown and unavailable backends are the client's
+// mistake, so they fail the request rather than falling back to the strategy.
+func (lb *LoadBalancer) forcedBackend(id string) (*pool.Backend, error) {
+	backend := lb.serverPool.GetBackendByID(id)
+	if backend == nil {
+		return nil, errors.NewInvalidForcedBackendError(id, "unknown backend")
+	}
+	if !backend.Available() {
+		return nil, errors.NewInvalidForcedBackendError(id, "backend is not available")
+	}
+	return backend, nil
+}
+
+// selectionPool returns the backends the strategy should choose from: the
+// primaries (or one of the blue/green groups, when traffic is split), or the
+// backups while no primary is available
+func (lb *LoadBalancer) selectionPool() *pool.ServerPool {
+	if len(lb.config.BackupBackends) == 0 {
+		if lb.split != nil {
+			return lb.split.pick()
+		}
+		if lb.canary != nil {
+			return lb.serverPool.Tier(false)
+		}
+		return lb.serverPool
+	}
+
+	useBackups := !lb.serverPool.HasAvailablePrimary()
+	if lb.usingBackups.Swap(useBackups) != useBackups {
+		if useBackups {
+			log.Println("No primary backends available, failing over to backup backends")
+		} else {
+			log.Println("Primary backends available again, leaving backup backends")
+		}
+	}
+	if !useBackups && lb.split != nil {
+		return lb.split.pick()
+	}
+	return lb.serverPool.Tier(useBackups)
+}
+
 // ServeHTTP implements the http.Handler interface
 func (lb *LoadBalancer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// Get next healthy backend using round-robin
-	backend, err := lb.getNextHealthyBackend()
+	if lb.accessLog == nil {
+		lb.serveHTTP(w, r)
+		return
+	}
+
+	start := time.Now()
+	rec := &responseRecorder{ResponseWriter: w}
+	lb.serveHTTP(rec, r)
+	duration := time.Since(start)
+
+	status := rec.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	lb.accessLog.Log(accessLogEntry{
+		Time:       start.UTC().Format(time.RFC3339Nano),
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		Status:     status,
+		Bytes:      rec.bytes,
+		DurationMs: float64(duration) / float64(time.Millisecond),
+		ClientIP:   lb.ClientIP(r),
+		RequestID:  rec.Header().Get(lb.config.RequestIDHeader),
+		Backend:    rec.backend,
+	}, duration)
+}
+
+// serveHTTP proxies a request to a backend
+func (lb *LoadBalancer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	// Configured headers apply to error responses too
+	lb.applyResponseHeaders(w.Header())
+
+	requestID := lb.requestID(w, r)
+
+	// Turn away clients outside the allowed ranges before doing anything else
+	if client := lb.ClientIP(r); !lb.allowedIPs.Allows(client) {
+		clientErr := errors.NewClientNotAllowedError(client)
+		log.Printf("Rejecting request: %v", clientErr)
+		lb.writeError(w, r, clientErr)
+		return
+	}
+
+	// Bound the whole request, including any wait for a request slot and any
+	// retries, with the global timeout. Backend attempts derive their contexts
+	// from this one.
+	if lb.config.RequestTimeout > 0 {
+		reqCtx, cancel := context.WithTimeout(r.Context(), lb.config.RequestTimeout)
+		defer cancel()
+		r = r.WithContext(reqCtx)
+	}
+
+	// Shed load once the balancer is handling as many requests as allowed
+	if lb.requestSlots != nil {
+		if !lb.acquireRequestSlot(r.Context()) {
+			// The request timeout ran out while queued for a slot
+			if r.Context().Err() == context.DeadlineExceeded {
+				timeoutErr := errors.NewRequestTimeoutError(r.Context().Err()).
+					WithContext("timeout", lb.config.RequestTimeout)
+				log.Printf("Request exceeded the request timeout waiting for a slot: %v", timeoutErr)
+				lb.metrics.RecordRequestTimeout()
+				lb.writeError(w, r, timeoutErr)
+				return
+			}
+
+			lb.metrics.RecordConcurrencyRejection()
+
+			overloadErr := errors.NewOverloadedError(cap(lb.requestSlots))
+			log.Printf("Rejecting request: %v", overloadErr)
+			lb.writeError(w, r, overloadErr)
+			return
+		}
+		defer func() { <-lb.requestSlots }()
+	}
+
+	// Reject methods and paths that must never reach backends
+	if len(lb.config.AllowedMethods) > 0 && !slices.Contains(lb.config.AllowedMethods, r.Method) {
+		methodErr := errors.NewMethodNotAllowedError(r.Method)
+		log.Printf("Rejecting request: %v", methodErr)
+		w.Header().Set("Allow", strings.Join(lb.config.AllowedMethods, ", "))
+		lb.writeError(w, r, methodErr)
+		return
+	}
+	if lb.deniedPaths.Matches(r.URL.Path) {
+		pathErr := errors.NewPathDeniedError(r.URL.Path)
+		log.Printf("Rejecting request: %v", pathErr)
+		lb.writeError(w, r, pathErr)
+		return
+	}
+
+	// Turn new requests away once shutdown has begun, closing the connection so
+	// keep-alive clients reconnect to another instance
+	if lb.IsDraining() {
+		drainErr := errors.NewShuttingDownError()
+		w.Header().Set("Connection", "close")
+		lb.writeError(w, r, drainErr)
+		return
+	}
+
+	// Answer configured paths locally without spending a backend request
+	if response, ok := lb.config.StaticResponses[r.URL.Path]; ok {
+		writeStaticResponse(w, r, response)
+		return
+	}
+
+	// Reject everything while in maintenance mode
+	if lb.IsInMaintenance() {
+		lb.metrics.RecordMaintenanceRejection()
+
+		maintErr := errors.NewMaintenanceModeError()
+		w.Header().Set("Retry-After", strconv.Itoa(int(maintenanceRetryAfter.Seconds())))
+		lb.writeError(w, r, maintErr)
+		return
+	}
+
+	// Turn away clients that exceed their rate limit
+	if lb.rateLimiter != nil {
+		client := lb.ClientIP(r)
+		if ok, wait := lb.rateLimiter.Allow(client); !ok {
+			limitErr := errors.NewRateLimitedError(client).WithContext("retry_after", wait)
+			log.Printf("Rejecting request: %v", limitErr)
+			lb.writeRateLimited(w, r, limitErr, wait)
+			return
+		}
+	}
+
+	// Tunnel CONNECT requests straight to their target, bypassing the backends
+	if r.Method == http.MethodConnect && lb.config.ConnectTunnels {
+		lb.serveConnect(w, r)
+		return
+	}
+
+	// Pick a backend and take one of its connection slots. Tracking in-flight
+	// requests feeds connection-aware strategies and the active connections
+	// gauge; the deferred release covers every exit path below.
+	backend, err := lb.acquireBackend(r)
 	if err != nil {
 		log.Printf("Failed to get healthy backend: %v", err)
 
+		// Fall back to a stale cached response if we have one
+		if lbErr, ok := err.(*errors.LoadBalancerError); ok && lbErr.Code == errors.ErrNoHealthyBackends {
+			if lb.serveStale(w, r) {
+				return
+			}
+
+			// Backends haven't been probed yet; tell the client when to retry
+			if lb.healthChecker != nil && !lb.healthChecker.Initialized() {
+				warmErr := errors.NewWarmingUpError()
+				w.Header().Set("Retry-After", strconv.Itoa(lb.warmUpRetryAfter()))
+				lb.writeError(w, r, warmErr)
+				return
+			}
+		}
+
 		// Convert structured error to appropriate HTTP response
 		if lbErr, ok := err.(*errors.LoadBalancerError); ok {
-			http.Error(w, lbErr.Message, lbErr.HTTPStatusCode())
+			lb.writeError(w, r, lbErr)
 		} else {
 			http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
 		}
 		return
 	}
+	defer lb.releaseBackend(backend)
 
 	log.Printf("Received %s request on %s from %s:",
-		r.Method, r.URL.Path, r.RemoteAddr)
+		r.Method, r.URL.Path, lb.ClientIP(r))
 	log.Printf("Host: %s", r.Host)
 	log.Printf("User-Agent: %s", r.Header.Get("User-Agent"))
 	log.Printf("Forwarding to backend: %s (%s)", backend.ID, backend.URL.String())
+	noteBackend(w, backend.ID)
 
-	// Create context with timeout for the backend request
-	ctx, cancel := context.WithTimeout(r.Context(), lb.config.BackendTimeout)
+	// Create context with timeout for the backend request. Deriving it from
+	// r.Context() means a shorter client deadline wins, and the server cancels
+	// it as soon as the client connection closes, aborting the upstream call.
+	ctx, cancel := context.WithTimeout(r.Context(), lb.backendTimeout(r.Method))
 	defer cancel()
 
+	// Remember read failures so a broken client body isn't blamed on the backend
+	clientReqBody := &clientBody{r: r.Body}
+
+	// Buffer the body when mirroring so the shadow gets its own copy
+	var reqBody io.Reader = clientReqBody
+	var shadowBody []byte
+	mirror := false
+	if lb.shadowSlots != nil {
+		reqBody, shadowBody, mirror = bufferBody(clientReqBody, shadowMaxBodyBytes)
+		if !mirror {
+			log.Printf("Not mirroring %s %s: request body too large or unreadable", r.Method, r.URL.Path)
+		}
+	}
+
+	// Backends that joined after startup are probed on their first request
+	if lb.config.DetectBackendProtocol {
+		lb.detectProtocol(backend)
+	}
+
 	// Create a new request to forward to the selected backend
-	backendReq, err := http.NewRequestWithContext(ctx, r.Method, backend.URL.String()+r.URL.Path, r.Body)
+	backendReq, err := http.NewRequestWithContext(ctx, r.Method, lb.upstreamURL(backend)+r.URL.Path, reqBody)
 	if err != nil {
 		log.Printf("Error creating backend request: %v", err)
 		reqErr := errors.NewRequestFailedError(err).WithContext("backend", backend.ID)
-		http.Error(w, reqErr.Message, reqErr.HTTPStatusCode())
+		lb.writeError(w, r, reqErr)
 		return
 	}
 
+	// Close the backend connection after the response when keep-alive is off
+	backendReq.Close = lb.config.DisableKeepAlive || lb.noKeepAlive[backend.URL.String()]
+
 	// Copy headers from original request
 	backendReq.Header = r.Header.Clone()
+	backendReq.Header.Set(lb.config.RequestIDHeader, requestID)
+	lb.applyRequestHeaders(backendReq.Header)
+	lb.applyUserAgent(backendReq.Header)
+	lb.applyClientCert(backendReq.Header, r)
+
+	// Copy query parameters, applying any configured rewriting
+	backendReq.URL.RawQuery = lb.rewriteQuery(r.URL.RawQuery)
 
-	// Copy query parameters
-	backendReq.URL.RawQuery = r.URL.RawQuery
+	// Send the shadow its copy; its outcome never reaches the client
+	if mirror {
+		lb.mirror(r.Method, r.URL.Path, backendReq.URL.RawQuery, backendReq.Header.Clone(), shadowBody)
+	}
 
 	// Make the request to the backend server
 	start := time.Now()
-	resp, err := lb.client.Do(backendReq)
+	resp, err := lb.send(lb.clientFor(backend), backendReq)
+	if err != nil && isUpstreamEOF(err) && r.Context().Err() == nil {
+		lb.metrics.RecordUpstreamEOF(backend.ID)
+
+		// Only a request without a body can be replayed as it was sent
+		if lb.config.RetryUpstreamEOF && (r.Body == nil || r.Body == http.NoBody) {
+			log.Printf("Backend %s closed the connection before completing its response; retrying once: %v", backend.ID, err)
+			lb.metrics.RecordRetry(backend.ID)
+			resp, err = lb.send(lb.clientFor(backend), backendReq.Clone(ctx))
+			if err != nil && isUpstreamEOF(err) && r.Context().Err() == nil {
+				lb.metrics.RecordUpstreamEOF(backend.ID)
+			}
+		}
+	}
 	duration := time.Since(start)
 
 	if err != nil {
+		// A client that gave up is not the backend's fault
+		if r.Context().Err() == context.Canceled {
+			cancelErr := errors.NewClientCanceledError(err).WithContext("backend", backend.ID)
+			log.Printf("Client canceled request to backend %s: %v", backend.ID, cancelErr)
+			lb.metrics.RecordClientCanceled()
+			w.WriteHeader(cancelErr.HTTPStatusCode())
+			return
+		}
+
+		// The global request deadline passed; the backend's own timeout didn't,
+		// so don't hold it against the backend's health
+		if r.Context().Err() == context.DeadlineExceeded {
+			timeoutErr := errors.NewRequestTimeoutError(err).
+				WithContext("backend", backend.ID).
+				WithContext("timeout", lb.config.RequestTimeout)
+			log.Printf("Request to backend %s exceeded the request timeout: %v", backend.ID, timeoutErr)
+			lb.metrics.RecordRequestTimeout()
+			lb.writeError(w, r, timeoutErr)
+			return
+		}
+
+		// The request body failed to read from the client, so the request
+		// never reached the backend intact
+		if readErr := clientReqBody.Err(); readErr != nil {
+			bodyErr := errors.NewClientBodyReadError(readErr).WithContext("backend", backend.ID)
+			log.Printf("Error reading request body for backend %s: %v", backend.ID, bodyErr)
+			lb.metrics.RecordClientReadError()
+			lb.writeError(w, r, bodyErr)
+			return
+		}
+
 		log.Printf("Error forwarding request to backend %s: %v", backend.ID, err)
 
+		// Oversized headers are a problem with this response rather than with
+		// reaching the backend, so it stays healthy
+		if isResponseHeaderTooLarge(err) {
+			headerErr := errors.NewBackendHeadersTooLargeError(backend.ID, lb.config.MaxResponseHeaderBytes, err)
+			lb.metrics.RecordFailure(backend.ID)
+			backend.RecordOutcome(duration, true)
+			lb.writeError(w, r, headerErr)
+			return
+		}
+
 		// Determine the type of error
 		var lbErr *errors.LoadBalancerError
 		if ctx.Err() == context.DeadlineExceeded {
 			lbErr = errors.NewBackendTimeoutError(backend.ID, err)
+		} else if isUpstreamEOF(err) {
+			lbErr = errors.NewBackendEOFError(backend.ID, err)
 		} else {
 			lbErr = errors.NewBackendConnectionError(backend.ID, err)
 		}
 
-		// Record failure in metrics
+		// Record failure in metrics and the backend's health score
 		lb.metrics.RecordFailure(backend.ID)
+		backend.RecordOutcome(duration, true)
 
-		// Mark backend as unhealthy for future requests
-		lb.serverPool.SetBackendHealth(backend.ID, false)
+		// Mark backend as unhealthy for future requests. Without health
+		// checks nothing would mark it healthy again, so leave it to the
+		// admin API.
+		if lb.healthChecker != nil {
+			lb.serverPool.SetBackendHealth(backend.ID, false)
+		}
 
-		http.Error(w, lbErr.Message, lbErr.HTTPStatusCode())
+		lb.writeError(w, r, lbErr)
 		return
 	}
 	defer resp.Body.Close()
 
+	// Record the outcome using the configured failure criteria
+	failed := lb.failureCodes.Contains(resp.StatusCode)
+	if failed {
+		lb.metrics.RecordFailure(backend.ID)
+	} else {
+		lb.metrics.RecordTracedRequest(backend.ID, duration, traceID(r))
+		backend.RecordCompletion(duration, backend.ActiveConnections())
+	}
+	backend.RecordOutcome(duration, failed)
+
+	// Flag slow backend responses; duration covers the time to response headers
+	if lb.config.SlowRequestThreshold > 0 && duration >= lb.config.SlowRequestThreshold {
+		log.Printf("Slow request: %s %s to backend %s took %s (threshold %s)",
+			r.Method, r.URL.Path, backend.ID, duration, lb.config.SlowRequestThreshold)
+		lb.metrics.RecordSlowRequest(backend.ID)
+	}
+
+	// Respect a backend asking to be left alone for a while
+	if lb.config.HonorRetryAfter && (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable) {
+		if delay, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			delay = min(delay, lb.config.MaxRetryAfterDelay)
+			backend.BackOff(time.Now().Add(delay))
+			log.Printf("Backend %s returned %d with Retry-After; skipping it for %s", backend.ID, resp.StatusCode, delay)
+		}
+	}
+
 	// Check for error status codes
 	if resp.StatusCode >= 500 {
 		log.Printf("Backend %s returned error status: %d", backend.ID, resp.StatusCode)
 
 		respErr := errors.NewBackendResponseError(backend.ID, resp.StatusCode)
-		lb.metrics.RecordFailure(backend.ID)
 
 		// Don't mark backend as unhealthy for 5xx errors - might be temporary
 		// Only health checks should determine backend health
 
-		http.Error(w, respErr.Message, respErr.HTTPStatusCode())
+		lb.setServedBy(w.Header(), backend)
+		lb.setServerTiming(w.Header(), duration)
+		lb.writeError(w, r, respErr)
 		return
 	}
 
-	// Record successful request in metrics
-	lb.metrics.RecordRequest(backend.ID, duration)
-
 	// Log the response from backend
 	log.Printf("Response from backend %s: %s", backend.ID, resp.Status)
 
@@ -179,17 +911,546 @@ func (lb *LoadBalancer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Apply configured overrides and removals on top of upstream headers
+	lb.applyResponseHeaders(w.Header())
+	lb.setServedBy(w.Header(), backend)
+	lb.setServerTiming(w.Header(), duration)
+	announceTrailers(w.Header(), resp.Trailer)
+
 	// Set the status code
 	w.WriteHeader(resp.StatusCode)
 
-	// Copy the response body back to client
-	_, err = io.Copy(w, resp.Body)
+	// Capture cacheable responses so they can be served stale later
+	var body io.Reader = resp.Body
+	var captured *cache.BoundedBuffer
+	if lb.staleCache != nil && cache.IsCacheable(r, resp) {
+		captured = cache.NewBoundedBuffer(staleCacheMaxBodyBytes)
+		body = io.TeeReader(resp.Body, captured)
+	}
+
+	// Copy the response body back to client, flushing as data arrives when
+	// the backend is streaming (no Content-Length) so clients see it promptly
+	var out io.Writer = w
+	if flusher, ok := w.(http.Flusher); ok && resp.ContentLength == -1 {
+		out = &flushWriter{w: w, flusher: flusher}
+	}
+
+	// Abort the copy when either side stalls for longer than its idle timeout
+	var idleBody *idleReader
+	if lb.config.StreamIdleReadTimeout > 0 {
+		idleBody = newIdleReader(body, lb.config.StreamIdleReadTimeout, cancel)
+		defer idleBody.stop()
+		body = idleBody
+	}
+	var idleClient *deadlineWriter
+	if lb.config.StreamIdleWriteTimeout > 0 {
+		idleClient = &deadlineWriter{w: out, rc: http.NewResponseController(w), timeout: lb.config.StreamIdleWriteTimeout}
+		defer idleClient.clear()
+		out = idleClient
+	}
+
+	// Count what reached the client even when the copy fails part way
+	written, err := io.Copy(out, body)
+	lb.metrics.RecordResponseBytes(backend.ID, written)
+	if err != nil {
+		if idleBody != nil && idleBody.Expired() {
+			idleErr := errors.NewBackendTimeoutError(backend.ID, err).
+				WithContext("idle_timeout", lb.config.StreamIdleReadTimeout).
+				WithContext("bytes_written", written)
+			log.Printf("Backend %s sent nothing for %s; aborting response: %v", backend.ID, lb.config.StreamIdleReadTimeout, idleErr)
+			lb.metrics.RecordFailure(backend.ID)
+			return
+		}
+		if idleClient != nil && idleClient.Expired() {
+			log.Printf("Client stopped reading the response from backend %s for %s; aborting after %d bytes",
+				backend.ID, lb.config.StreamIdleWriteTimeout, written)
+			lb.metrics.RecordResponseCopyError()
+			return
+		}
+		if r.Context().Err() == context.Canceled {
+			log.Printf("Client went away while copying response from backend %s", backend.ID)
+			lb.metrics.RecordClientCanceled()
+			return
+		}
+		if r.Context().Err() == context.DeadlineExceeded {
+			timeoutErr := errors.NewRequestTimeoutError(err).WithContext("backend", backend.ID)
+			log.Printf("Request timeout while copying response from backend %s: %v", backend.ID, timeoutErr)
+			lb.metrics.RecordRequestTimeout()
+			return
+		}
+
+		// The backend hung up part way through its body
+		if isUpstreamEOF(err) {
+			eofErr := errors.NewBackendEOFError(backend.ID, err).WithContext("bytes_written", written)
+			log.Printf("Backend closed the connection after %d response bytes: %v", written, eofErr)
+			lb.metrics.RecordUpstreamEOF(backend.ID)
+			return
+		}
+
+		// The status is already sent, so the failure can only be logged and
+		// counted. A short write against a declared Content-Length makes the
+		// server close the connection, so the client sees a truncated body.
+		copyErr := errors.NewResponseCopyError(err).
+			WithContext("backend", backend.ID).
+			WithContext("bytes_written", written)
+		log.Printf("Response copy error after %d bytes: %v", written, copyErr)
+		lb.metrics.RecordResponseCopyError()
+		return
+	}
+
+	// Trailer values are only known once the body has been read
+	copyTrailers(w.Header(), resp.Trailer)
+
+	if captured != nil && !captured.Overflowed() {
+		lb.staleCache.Set(cache.Key(r), &cache.Entry{
+			StatusCode: resp.StatusCode,
+			Header:     resp.Header.Clone(),
+			Body:       captured.Bytes(),
+			StoredAt:   time.Now(),
+			Vary:       cache.RequestVary(r, resp),
+		})
+	}
+}
+
+// backendTimeout returns how long a backend may take to answer a request with
+// the given method, falling back to BackendTimeout for unlisted methods
+func (lb *LoadBalancer) backendTimeout(method string) time.Duration {
+	if timeout, ok := lb.config.MethodTimeouts[method]; ok {
+		return timeout
+	}
+	return lb.config.BackendTimeout
+}
+
+// upstreamURL returns the base URL requests are sent to for a backend, with
+// any configured scheme override applied. A per-backend override wins over
+// the global one.
+func (lb *LoadBalancer) upstreamURL(backend *pool.Backend) string {
+	scheme := lb.upstreamScheme(backend)
+	if scheme == backend.URL.Scheme {
+		return backend.URL.String()
+	}
+
+	upstream := *backend.URL
+	upstream.Scheme = scheme
+	return upstream.String()
+}
+
+// upstreamScheme returns the scheme backend is reached with, after overrides
+func (lb *LoadBalancer) upstreamScheme(backend *pool.Backend) string {
+	if scheme := lb.upstreamSchemes[backend.URL.String()]; scheme != "" {
+		return scheme
+	}
+	if lb.config.UpstreamScheme != "" {
+		return lb.config.UpstreamScheme
+	}
+	return backend.URL.Scheme
+}
+
+// flushWriter flushes the response after every write, for streamed bodies
+type flushWriter struct {
+	w       io.Writer
+	flusher http.Flusher
+}
+
+func (fw *flushWriter) Write(p []byte) (int, error) {
+	n, err := fw.w.Write(p)
+	if err == nil {
+		fw.flusher.Flush()
+	}
+	return n, err
+}
+
+// send makes a backend request with client. With response buffering enabled, a small
+// response is read in full before send returns, so a failure reading its body
+// surfaces as the request's error while nothing has reached the client yet,
+// and the response goes out with a Content-Length. Larger responses, and ones
+// without a body, stream as usual.
+func (lb *LoadBalancer) send(client *http.Client, req *http.Request) (*http.Response, error) {
+	resp, err := client.Do(req)
+	limit := lb.config.ResponseBufferMaxBytes
+	// A buffered response goes out with a Content-Length, which leaves no
+	// room for trailers
+	if err != nil || limit <= 0 || resp.ContentLength > limit || !hasBody(req, resp) || len(resp.Trailer) > 0 {
+		return resp, err
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
 	if err != nil {
-		log.Printf("Error copying response body: %v", err)
-		// Note: We can't change status code after WriteHeader, but we can log the error
-		copyErr := errors.NewResponseCopyError(err).WithContext("backend", backend.ID)
-		log.Printf("Response copy error: %v", copyErr)
+		resp.Body.Close()
+		return nil, err
+	}
+	if int64(len(body)) > limit {
+		// A body of unknown length turned out too large; stream the rest
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return resp, nil
+	}
+
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return resp, nil
+}
+
+// hasBody reports whether a response to req carries a body
+func hasBody(req *http.Request, resp *http.Response) bool {
+	if req.Method == http.MethodHead {
+		return false
+	}
+	return resp.StatusCode >= 200 && resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotModified
+}
+
+// isUpstreamEOF reports whether err means the backend closed the connection
+// before its response was complete
+func isUpstreamEOF(err error) bool {
+	return stderrors.Is(err, io.EOF) || stderrors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// clientBody wraps a request body and remembers the first error reading it,
+// other than io.EOF. The transport reads it on its own goroutine.
+type clientBody struct {
+	r io.Reader
+
+	mu  sync.Mutex
+	err error
+}
+
+func (cb *clientBody) Read(p []byte) (int, error) {
+	n, err := cb.r.Read(p)
+	if err != nil && err != io.EOF {
+		cb.mu.Lock()
+		if cb.err == nil {
+			cb.err = err
+		}
+		cb.mu.Unlock()
+	}
+	return n, err
+}
+
+// Err returns the first error reading the body, or nil
+func (cb *clientBody) Err() error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.err
+}
+
+// warmUpRetryAfter returns the Retry-After seconds sent while the first round
+// of health probes runs, which finishes within the probe timeout
+func (lb *LoadBalancer) warmUpRetryAfter() int {
+	return max(1, int(math.Ceil(lb.config.HealthCheckTimeout.Seconds())))
+}
+
+// parseRetryAfter reads a Retry-After value given either as delay seconds or
+// as an HTTP date, returning how long to wait from now
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if !date.After(now) {
+			return 0, false
+		}
+		return date.Sub(now), true
+	}
+	return 0, false
+}
+
+// writeStaticResponse writes a configured static response. HEAD requests and
+// bodiless statuses get the headers only.
+func writeStaticResponse(w http.ResponseWriter, r *http.Request, response config.StaticResponse) {
+	bodyAllowed := r.Method != http.MethodHead &&
+		response.Status != http.StatusNoContent && response.Status != http.StatusNotModified
+
+	w.Header().Set("Content-Type", response.ContentType)
+	if bodyAllowed || r.Method == http.MethodHead {
+		w.Header().Set("Content-Length", strconv.Itoa(len(response.Body)))
+	}
+	w.WriteHeader(response.Status)
+	if bodyAllowed {
+		io.WriteString(w, response.Body)
+	}
+}
+
+// acquireRequestSlot takes a concurrency slot, waiting up to the configured
+// queue timeout for one to free up. It reports false if none became free or
+// the client went away while waiting.
+func (lb *LoadBalancer) acquireRequestSlot(ctx context.Context) bool {
+	select {
+	case lb.requestSlots <- struct{}{}:
+		return true
+	default:
+	}
+
+	if lb.config.ConcurrencyQueueTimeout <= 0 {
+		return false
+	}
+
+	timer := time.NewTimer(lb.config.ConcurrencyQueueTimeout)
+	defer timer.Stop()
+
+	select {
+	case lb.requestSlots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// writeRateLimited writes the configured 429 response, telling the client
+// when its bucket will next have a token
+func (lb *LoadBalancer) writeRateLimited(w http.ResponseWriter, r *http.Request, limitErr *errors.LoadBalancerError, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(ratelimit.RetryAfterSeconds(wait)))
+
+	// A configured body takes precedence over the error format
+	body := lb.config.RateLimitResponseBody
+	if body == "" {
+		if lb.wantsJSONError(r) {
+			lb.writeError(w, r, limitErr)
+			return
+		}
+		body = limitErr.Message + "\n"
+	}
+
+	w.Header().Set("Content-Type", lb.config.RateLimitResponseContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(limitErr.HTTPStatusCode())
+	io.WriteString(w, body)
+}
+
+// serveStale writes a cached response for r, marked stale, if one exists
+func (lb *LoadBalancer) serveStale(w http.ResponseWriter, r *http.Request) bool {
+	if lb.staleCache == nil || r.Method != http.MethodGet {
+		return false
+	}
+
+	// A response varying on request headers only answers requests that send
+	// the same values, e.g. the same Accept-Encoding
+	entry, ok := lb.staleCache.Get(cache.Key(r))
+	if !ok || !entry.Matches(r) {
+		return false
+	}
+
+	log.Printf("Serving stale cached response for %s (age %s)", r.URL.Path, entry.Age().Round(time.Second))
+
+	for name, values := range entry.Header {
+		for _, value := range values {
+			w.Header().Add(name, value)
+		}
+	}
+	lb.applyResponseHeaders(w.Header())
+	w.Header().Set("Warning", `110 - "Response is Stale"`)
+	w.Header().Set("Age", strconv.Itoa(int(entry.Age().Seconds())))
+
+	w.WriteHeader(entry.StatusCode)
+	w.Write(entry.Body)
+	return true
+}
+
+// applyResponseHeaders strips configured headers and sets injected ones
+func (lb *LoadBalancer) applyResponseHeaders(header http.Header) {
+	for _, name := range lb.config.RemoveResponseHeaders {
+		header.Del(name)
+	}
+	for name, value := range lb.config.ResponseHeaders {
+		header.Set(name, value)
+	}
+}
+
+// announceTrailers declares the backend's trailers in the Trailer header, so
+// they can be sent after the body. The body is then sent chunked, as a
+// Content-Length (which an HTTP/2 backend may send alongside trailers) would
+// leave no room for them.
+func announceTrailers(header http.Header, trailer http.Header) {
+	if len(trailer) > 0 {
+		header.Del("Content-Length")
+	}
+	for name := range trailer {
+		header.Add("Trailer", name)
+	}
+}
+
+// copyTrailers sets the backend's trailer values once its body has been
+// copied. Trailers the backend sent without announcing them are passed on
+// with http.TrailerPrefix.
+func copyTrailers(header http.Header, trailer http.Header) {
+	announced := make(map[string]bool)
+	for _, names := range header.Values("Trailer") {
+		for _, name := range strings.Split(names, ",") {
+			announced[http.CanonicalHeaderKey(strings.TrimSpace(name))] = true
+		}
+	}
+	for name, values := range trailer {
+		key := name
+		if !announced[name] {
+			key = http.TrailerPrefix + name
+		}
+		for _, value := range values {
+			header.Add(key, value)
+		}
+	}
+}
+
+// setServedBy names the backend in X-Served-By when enabled, replacing any
+// value the backend sent itself
+func (lb *LoadBalancer) setServedBy(header http.Header, backend *pool.Backend) {
+	if lb.config.ServedByHeader {
+		header.Set(servedByHeader, backend.ID)
+	}
+}
+
+// setServerTiming reports the backend's latency as an upstream Server-Timing
+// metric when enabled, alongside any metrics the backend reported itself
+func (lb *LoadBalancer) setServerTiming(header http.Header, duration time.Duration) {
+	if lb.config.ServerTimingHeader {
+		header.Add("Server-Timing", "upstream;dur="+strconv.FormatFloat(float64(duration)/float64(time.Millisecond), 'f', 1, 64))
+	}
+}
+
+// applyRequestHeaders injects configured headers into a backend request,
+// leaving caller-provided values alone unless overriding is enabled
+func (lb *LoadBalancer) applyRequestHeaders(header http.Header) {
+	for name, value := range lb.config.RequestHeaders {
+		if lb.config.OverrideRequestHeaders || header.Get(name) == "" {
+			header.Set(name, value)
+		}
+	}
+}
+
+// applyUserAgent sets the configured upstream User-Agent, or appends it to
+// the client's so backends see both. Without one the client's is kept.
+func (lb *LoadBalancer) applyUserAgent(header http.Header) {
+	userAgent := lb.config.UpstreamUserAgent
+	if userAgent == "" {
+		return
+	}
+	if client := header.Get("User-Agent"); lb.config.AppendUserAgent && client != "" {
+		userAgent = client + " " + userAgent
+	}
+	header.Set("User-Agent", userAgent)
+}
+
+// applyClientCert passes the client's verified TLS certificate to backends when
+// enabled. Values the client sent in these headers itself are always dropped,
+// so a backend can trust them.
+func (lb *LoadBalancer) applyClientCert(header http.Header, r *http.Request) {
+	if !lb.config.ForwardClientCert {
+		return
+	}
+	for _, name := range clientCertHeaders {
+		header.Del(name)
 	}
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return
+	}
+
+	cert := r.TLS.VerifiedChains[0][0]
+	header.Set(clientCertSubjectHeader, cert.Subject.String())
+	header.Set(clientCertIssuerHeader, cert.Issuer.String())
+	header.Set(clientCertSerialHeader, cert.SerialNumber.Text(16))
+}
+
+// rewriteQuery drops configured query parameters and appends default ones the
+// caller didn't send. Parameters that are kept retain their original order and
+// encoding; without rules the query is returned unchanged.
+func (lb *LoadBalancer) rewriteQuery(rawQuery string) string {
+	if len(lb.config.RemoveQueryParams) == 0 && len(lb.config.DefaultQueryParams) == 0 {
+		return rawQuery
+	}
+
+	present := make(map[string]bool)
+	var kept []string
+	for _, part := range strings.Split(rawQuery, "&") {
+		if part == "" {
+			continue
+		}
+		rawName, _, _ := strings.Cut(part, "=")
+		name, err := url.QueryUnescape(rawName)
+		if err != nil {
+			// Leave parameters we can't decode untouched
+			name = rawName
+		}
+		if slices.Contains(lb.config.RemoveQueryParams, name) {
+			continue
+		}
+		present[name] = true
+		kept = append(kept, part)
+	}
+
+	// Append defaults in a stable order so backends see deterministic URLs
+	names := make([]string, 0, len(lb.config.DefaultQueryParams))
+	for name := range lb.config.DefaultQueryParams {
+		if !present[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		kept = append(kept, url.QueryEscape(name)+"="+url.QueryEscape(lb.config.DefaultQueryParams[name]))
+	}
+
+	return strings.Join(kept, "&")
+}
+
+// requestID returns the caller's request ID, or a new one if it didn't send
+// any, and echoes it back on the response
+func (lb *LoadBalancer) requestID(w http.ResponseWriter, r *http.Request) string {
+	requestID := r.Header.Get(lb.config.RequestIDHeader)
+	if requestID == "" {
+		requestID = newRequestID()
+	}
+	w.Header().Set(lb.config.RequestIDHeader, requestID)
+	return requestID
+}
+
+// newRequestID generates a random request ID
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// Fall back to a timestamp-based ID if the random source fails
+		return hex.EncodeToString([]byte(time.Now().Format(time.RFC3339Nano)))
+	}
+	return hex.EncodeToString(b)
+}
+
+// traceID returns the trace ID from a W3C traceparent header
+// ("version-traceid-parentid-flags"), or "" if the request isn't traced
+func traceID(r *http.Request) string {
+	parts := strings.Split(r.Header.Get("traceparent"), "-")
+	if len(parts) < 4 || len(parts[1]) != 32 {
+		return ""
+	}
+	if _, err := hex.DecodeString(parts[1]); err != nil || parts[1] == strings.Repeat("0", 32) {
+		return ""
+	}
+	return parts[1]
+}
+
+// hashKey extracts the key used by hash-based strategies. A missing header
+// falls back to the client IP so such requests still spread across backends.
+func (lb *LoadBalancer) hashKey(r *http.Request) string {
+	if name, ok := strings.CutPrefix(lb.config.HashKey, config.HashKeyHeaderPrefix); ok {
+		if value := r.Header.Get(name); value != "" {
+			return value
+		}
+	}
+	return lb.ClientIP(r)
+}
+
+// ClientIP returns the originating client IP, honoring trusted proxies
+func (lb *LoadBalancer) ClientIP(r *http.Request) string {
+	return lb.clientIP.ClientIP(r)
 }
 
 // AddBackend dynamically adds a new backend server
@@ -207,14 +1468,66 @@ func (lb *LoadBalancer) GetBackends() []*pool.Backend {
 	return lb.serverPool.GetBackends()
 }
 
-// Stop gracefully shuts down the load balancer
+// SetMaintenance enables or disables maintenance mode
+func (lb *LoadBalancer) SetMaintenance(enabled bool) {
+	lb.maintenance.Store(enabled)
+	log.Printf("Maintenance mode set to %v", enabled)
+}
+
+// IsInMaintenance reports whether the load balancer is in maintenance mode
+func (lb *LoadBalancer) IsInMaintenance() bool {
+	return lb.maintenance.Load()
+}
+
+// BeginShutdown starts draining: new requests get 503 and readiness fails so
+// upstream load balancers stop routing here, while in-flight requests finish.
+// It is called before the HTTP server is shut down and cannot be undone.
+func (lb *LoadBalancer) BeginShutdown() {
+	if lb.draining.CompareAndSwap(false, true) {
+		log.Println("Shutdown drain started, rejecting new requests")
+	}
+}
+
+// IsDraining reports whether shutdown has begun
+func (lb *LoadBalancer) IsDraining() bool {
+	return lb.draining.Load()
+}
+
+// Stop gracefully shuts down the load balancer. It is safe to call more than
+// once.
 func (lb *LoadBalancer) Stop() {
+	lb.stopOnce.Do(lb.stop)
+}
+
+// stop shuts down every background component
+func (lb *LoadBalancer) stop() {
 	if lb.healthChecker != nil {
 		lb.healthChecker.Stop()
 	}
+	if lb.discoverer != nil {
+		lb.discoverer.Stop()
+	}
+	if lb.fileDiscoverer != nil {
+		lb.fileDiscoverer.Stop()
+	}
+	if lb.statsd != nil {
+		lb.statsd.Stop()
+	}
+	if lb.drainScheduler != nil {
+		lb.drainScheduler.Stop()
+	}
+	if lb.accessLog != nil {
+		lb.accessLog.Close()
+	}
 }
 
 // GetMetricsProvider returns the metrics provider
 func (lb *LoadBalancer) GetMetricsProvider() metrics.MetricsProvider {
 	return lb.metricsProvider
 }
+
+// GetJSONMetricsProvider returns a JSON provider reporting on the same
+// metrics as GetMetricsProvider, whatever format that one uses
+func (lb *LoadBalancer) GetJSONMetricsProvider() metrics.MetricsProvider {
+	return lb.jsonMetrics
+}
@@ -1,17 +1,46 @@
 package balancer
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"go-balancer/internal/config"
 	"go-balancer/internal/errors"
+	"go-balancer/internal/metrics"
+	"go-balancer/internal/pool"
 )
 
+// waitForHealthChecks blocks until the first round of health probes has
+// finished, so reachable backends are marked healthy
+func waitForHealthChecks(t *testing.T, lb *LoadBalancer) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !lb.healthChecker.Initialized() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for the first health check round")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
 func TestNewLoadBalancer(t *testing.T) {
 	// Test with valid configuration
 	cfg := &config.Config{
@@ -67,6 +96,196 @@ func TestNewLoadBalancerWithInvalidBackend(t *testing.T) {
 	}
 }
 
+func TestLoadBalancerMaxBackends(t *testing.T) {
+	cfg := &config.Config{
+		Port:                8000,
+		Backends:            []string{"http://localhost:8080", "http://localhost:8081"},
+		MaxBackends:         3,
+		HealthCheckPath:     "/",
+		HealthCheckInterval: 10 * time.Second,
+		HealthCheckTimeout:  2 * time.Second,
+		BackendTimeout:      30 * time.Second,
+	}
+
+	lb, err := NewLoadBalancer(cfg)
+	if err != nil {
+		t.Fatalf("Load balancer creation failed: %v", err)
+	}
+	defer lb.Stop()
+	waitForHealthChecks(t, lb)
+
+	if err := lb.AddBackend("http://localhost:8082"); err != nil {
+		t.Fatalf("Expected room for a third backend, got error: %v", err)
+	}
+
+	err = lb.AddBackend("http://localhost:8083")
+	if err == nil {
+		t.Fatalf("Expected an error adding a backend past the cap")
+	}
+	if lbErr, ok := err.(*errors.LoadBalancerError); !ok || lbErr.Code != errors.ErrPoolFull {
+		t.Errorf("Expected ErrPoolFull, got %v", err)
+	}
+	if count := len(lb.GetBackends()); count != 3 {
+		t.Errorf("Expected 3 backends after the rejected add, got %d", count)
+	}
+
+	// Removing a backend makes room again
+	lb.RemoveBackend("backend-1")
+	if err := lb.AddBackend("http://localhost:8083"); err != nil {
+		t.Errorf("Expected the add to succeed after a removal, got error: %v", err)
+	}
+}
+
+func TestLoadBalancerDuplicateBackends(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	// The same server as mockServer.URL, spelled differently
+	port := mockServer.Listener.Addr().(*net.TCPAddr).Port
+	duplicate := fmt.Sprintf("http://127.0.0.1:%d/api", port)
+
+	t.Run("Rejected", func(t *testing.T) {
+		cfg := &config.Config{
+			Port:                8000,
+			Backends:            []string{mockServer.URL, duplicate},
+			HealthCheckPath:     "/",
+			HealthCheckInterval: 10 * time.Second,
+			HealthCheckTimeout:  2 * time.Second,
+			BackendTimeout:      30 * time.Second,
+		}
+
+		lb, err := NewLoadBalancer(cfg)
+		if err == nil {
+			lb.Stop()
+			t.Fatalf("Expected an error for a duplicate backend")
+		}
+		lbErr, ok := err.(*errors.LoadBalancerError)
+		if !ok {
+			t.Fatalf("Expected LoadBalancerError, got %T", err)
+		}
+		if cause, ok := lbErr.Unwrap().(*errors.LoadBalancerError); !ok || cause.Code != errors.ErrDuplicateBackend {
+			t.Errorf("Expected ErrDuplicateBackend as the cause, got %v", lbErr.Unwrap())
+		}
+
+		// Adding a duplicate at runtime is rejected too
+		cfg.Backends = []string{mockServer.URL}
+		lb, err = NewLoadBalancer(cfg)
+		if err != nil {
+			t.Fatalf("Load balancer creation failed: %v", err)
+		}
+		defer lb.Stop()
+		waitForHealthChecks(t, lb)
+
+		err = lb.AddBackend(mockServer.URL + "/")
+		if lbErr, ok := err.(*errors.LoadBalancerError); !ok || lbErr.Code != errors.ErrDuplicateBackend {
+			t.Errorf("Expected ErrDuplicateBackend, got %v", err)
+		}
+		if count := len(lb.GetBackends()); count != 1 {
+			t.Errorf("Expected 1 backend after the rejected add, got %d", count)
+		}
+	})
+
+	t.Run("Ignored", func(t *testing.T) {
+		cfg := &config.Config{
+			Port:                8000,
+			Backends:            []string{mockServer.URL, duplicate},
+			DuplicateBackends:   config.DuplicateBackendsIgnore,
+			HealthCheckPath:     "/",
+			HealthCheckInterval: 10 * time.Second,
+			HealthCheckTimeout:  2 * time.Second,
+			BackendTimeout:      30 * time.Second,
+		}
+
+		lb, err := NewLoadBalancer(cfg)
+		if err != nil {
+			t.Fatalf("Load balancer creation failed: %v", err)
+		}
+		defer lb.Stop()
+		waitForHealthChecks(t, lb)
+
+		if err := lb.AddBackend(duplicate); err != nil {
+			t.Errorf("Expected an ignored duplicate to succeed, got error: %v", err)
+		}
+		if count := len(lb.GetBackends()); count != 1 {
+			t.Fatalf("Expected duplicates to collapse into 1 backend, got %d", count)
+		}
+
+		for i := 0; i < 4; i++ {
+			lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://localhost:8000/api", nil))
+		}
+
+		// Metrics only carry the one real backend
+		durations := lb.metrics.RequestDurations()
+		if len(durations) != 1 || durations["backend-1"].Count != 4 {
+			t.Errorf("Expected 4 requests all labelled backend-1, got %v", durations)
+		}
+	})
+}
+
+func TestNormalizeBackendURL(t *testing.T) {
+	tests := []struct {
+		canonical  string
+		equivalent []string
+	}{
+		{"http://example.com", []string{"http://example.com:80", "http://EXAMPLE.com/", "HTTP://Example.COM:80//"}},
+		{"https://example.com", []string{"https://example.com:443", "https://Example.com:443/"}},
+		{"http://example.com:8080/api", []string{"http://example.com:8080/api/", "http://EXAMPLE.COM:8080/api"}},
+		{"https://example.com:80", []string{"https://example.com:80/"}},
+		{"http://[::1]:8080", []string{"http://[::1]:8080/"}},
+		{"http://[::1]", []string{"http://[::1]:80"}},
+	}
+
+	for _, tt := range tests {
+		if got := pool.NormalizeURL(tt.canonical); got != tt.canonical {
+			t.Errorf("Expected %q to already be canonical, got %q", tt.canonical, got)
+		}
+		for _, u := range tt.equivalent {
+			if got := pool.NormalizeURL(u); got != tt.canonical {
+				t.Errorf("NormalizeURL(%q) = %q, expected %q", u, got, tt.canonical)
+			}
+		}
+	}
+}
+
+func TestLoadBalancerStoresCanonicalBackendURLs(t *testing.T) {
+	cfg := &config.Config{
+		Port:                8000,
+		Backends:            []string{"HTTP://Backend.Example:80/"},
+		BackendWeights:      map[string]int{"HTTP://Backend.Example:80/": 3},
+		HealthCheckPath:     "/",
+		HealthCheckInterval: 10 * time.Second,
+		HealthCheckTimeout:  2 * time.Second,
+		BackendTimeout:      30 * time.Second,
+	}
+
+	lb, err := NewLoadBalancer(cfg)
+	if err != nil {
+		t.Fatalf("Load balancer creation failed: %v", err)
+	}
+	defer lb.Stop()
+
+	backends := lb.GetBackends()
+	if len(backends) != 1 {
+		t.Fatalf("Expected 1 backend, got %d", len(backends))
+	}
+	if got := backends[0].URL.String(); got != "http://backend.example" {
+		t.Errorf("Expected canonical URL http://backend.example, got %s", got)
+	}
+	if backends[0].Port != 80 {
+		t.Errorf("Expected port 80, got %d", backends[0].Port)
+	}
+	if backends[0].Weight() != 3 {
+		t.Errorf("Expected the configured weight to apply, got %d", backends[0].Weight())
+	}
+
+	// Equivalent spellings resolve to the same backend
+	if err := lb.AddBackend("http://backend.example"); err == nil {
+		t.Errorf("Expected an equivalent URL to be rejected as a duplicate")
+	}
+}
+
 func TestLoadBalancerNoHealthyBackends(t *testing.T) {
 	// Create a load balancer with a non-existent backend
 	cfg := &config.Config{
@@ -215,3 +434,4940 @@ func TestLoadBalancerRoundRobin(t *testing.T) {
 		}
 	}
 }
+
+func TestLoadBalancerStopIsIdempotent(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	backendsFile := filepath.Join(t.TempDir(), "backends.txt")
+	if err := os.WriteFile(backendsFile, []byte(mockServer.URL+"\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write backends file: %v", err)
+	}
+
+	cfg := &config.Config{
+		Port:                 8000,
+		BackendsFile:         backendsFile,
+		BackendsFileInterval: time.Second,
+		HealthCheckPath:      "/",
+		HealthCheckInterval:  10 * time.Second,
+		HealthCheckTimeout:   2 * time.Second,
+		BackendTimeout:       30 * time.Second,
+	}
+
+	lb, err := NewLoadBalancer(cfg)
+	if err != nil {
+		t.Fatalf("Load balancer creation failed: %v", err)
+	}
+	waitForHealthChecks(t, lb)
+
+	// Shutdown sequences may stop the balancer from more than one place
+	lb.Stop()
+	lb.Stop()
+}
+
+func TestLoadBalancerUpstreamSchemeOverride(t *testing.T) {
+	tlsServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("tls"))
+	}))
+	defer tlsServer.Close()
+	plainServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("plain"))
+	}))
+	defer plainServer.Close()
+
+	// The same address written with the other scheme
+	tlsAsHTTP := strings.Replace(tlsServer.URL, "https://", "http://", 1)
+	plainAsHTTPS := strings.Replace(plainServer.URL, "http://", "https://", 1)
+
+	tests := []struct {
+		name         string
+		backend      string
+		global       string
+		perBackend   map[string]string
+		expectedBody string
+	}{
+		{"Global https over an http URL", tlsAsHTTP, "https", nil, "tls"},
+		{"Global http over an https URL", plainAsHTTPS, "http", nil, "plain"},
+		{"Per-backend override", tlsAsHTTP, "", map[string]string{tlsAsHTTP: "https"}, "tls"},
+		{"Per-backend override wins over global", plainAsHTTPS, "https", map[string]string{plainAsHTTPS: "http"}, "plain"},
+		{"No override keeps the URL scheme", plainServer.URL, "", nil, "plain"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				Port:                8000,
+				Backends:            []string{tt.backend},
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      30 * time.Second,
+				UpstreamScheme:      tt.global,
+				UpstreamSchemes:     tt.perBackend,
+			}
+
+			lb, err := NewLoadBalancer(cfg)
+			if err != nil {
+				t.Fatalf("Load balancer creation failed: %v", err)
+			}
+			// Health checks use the URL as written, so stop them and mark the
+			// backend healthy; trust the test server's certificate
+			lb.Stop()
+			lb.serverPool.SetBackendHealth("backend-1", true)
+			lb.client = tlsServer.Client()
+
+			recorder := httptest.NewRecorder()
+			lb.ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8000/", nil))
+
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("Expected status %d, got %d", http.StatusOK, recorder.Code)
+			}
+			if body := recorder.Body.String(); body != tt.expectedBody {
+				t.Errorf("Expected body %q, got %q", tt.expectedBody, body)
+			}
+		})
+	}
+}
+
+func TestTraceID(t *testing.T) {
+	tests := []struct {
+		name        string
+		traceparent string
+		expected    string
+	}{
+		{"Valid", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{"Missing", "", ""},
+		{"Too few fields", "00-4bf92f3577b34da6a3ce929d0e0e4736", ""},
+		{"Short trace ID", "00-4bf92f35-00f067aa0ba902b7-01", ""},
+		{"Not hex", "00-zzf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", ""},
+		{"All zero", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://localhost:8000/", nil)
+			if tt.traceparent != "" {
+				req.Header.Set("traceparent", tt.traceparent)
+			}
+			if got := traceID(req); got != tt.expected {
+				t.Errorf("Expected trace ID %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestLoadBalancerRecordsTraceExemplars(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	cfg := &config.Config{
+		Port:                8000,
+		Backends:            []string{mockServer.URL},
+		HealthCheckPath:     "/",
+		HealthCheckInterval: 10 * time.Second,
+		HealthCheckTimeout:  2 * time.Second,
+		BackendTimeout:      30 * time.Second,
+	}
+
+	lb, err := NewLoadBalancer(cfg)
+	if err != nil {
+		t.Fatalf("Load balancer creation failed: %v", err)
+	}
+	defer lb.Stop()
+	waitForHealthChecks(t, lb)
+
+	req := httptest.NewRequest("GET", "http://localhost:8000/api", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	lb.ServeHTTP(httptest.NewRecorder(), req)
+
+	snapshot := lb.metrics.RequestDurations()["backend-1"]
+	if snapshot.Count != 1 {
+		t.Fatalf("Expected 1 request duration observation, got %d", snapshot.Count)
+	}
+	var traced []string
+	for _, exemplar := range snapshot.Exemplars {
+		if exemplar != nil {
+			traced = append(traced, exemplar.TraceID)
+		}
+	}
+	if len(traced) != 1 || traced[0] != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Expected one exemplar with the request's trace ID, got %v", traced)
+	}
+}
+
+func TestLoadBalancerMaintenanceMode(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Hello from mock backend"))
+	}))
+	defer mockServer.Close()
+
+	cfg := &config.Config{
+		Port:                8000,
+		Backends:            []string{mockServer.URL},
+		HealthCheckPath:     "/",
+		HealthCheckInterval: 10 * time.Second,
+		HealthCheckTimeout:  2 * time.Second,
+		BackendTimeout:      30 * time.Second,
+	}
+
+	lb, err := NewLoadBalancer(cfg)
+	if err != nil {
+		t.Fatalf("Load balancer creation failed: %v", err)
+	}
+	defer lb.Stop()
+	waitForHealthChecks(t, lb)
+
+	lb.SetMaintenance(true)
+
+	// All requests should be rejected even though the backend is healthy
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest("GET", "http://localhost:8000/", nil)
+		recorder := httptest.NewRecorder()
+
+		lb.ServeHTTP(recorder, req)
+
+		if recorder.Code != http.StatusServiceUnavailable {
+			t.Errorf("Expected status %d during maintenance, got %d", http.StatusServiceUnavailable, recorder.Code)
+		}
+		if recorder.Header().Get("Retry-After") == "" {
+			t.Errorf("Expected Retry-After header during maintenance")
+		}
+	}
+
+	snapshot := lb.metrics.GetSnapshot()
+	if snapshot.MaintenanceRejections != 3 {
+		t.Errorf("Expected 3 maintenance rejections, got %d", snapshot.MaintenanceRejections)
+	}
+
+	// Disabling maintenance should restore normal traffic
+	lb.SetMaintenance(false)
+
+	req := httptest.NewRequest("GET", "http://localhost:8000/", nil)
+	recorder := httptest.NewRecorder()
+	lb.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Expected status %d after maintenance, got %d", http.StatusOK, recorder.Code)
+	}
+}
+
+func TestMaintenanceHandler(t *testing.T) {
+	cfg := &config.Config{
+		Port:                8000,
+		Backends:            []string{"http://localhost:8080"},
+		HealthCheckPath:     "/",
+		HealthCheckInterval: 10 * time.Second,
+		HealthCheckTimeout:  2 * time.Second,
+		BackendTimeout:      30 * time.Second,
+	}
+
+	lb, err := NewLoadBalancer(cfg)
+	if err != nil {
+		t.Fatalf("Load balancer creation failed: %v", err)
+	}
+	defer lb.Stop()
+
+	handler := lb.MaintenanceHandler()
+
+	req := httptest.NewRequest("POST", "http://localhost:8000/admin/maintenance?enabled=true", nil)
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, recorder.Code)
+	}
+	if !lb.IsInMaintenance() {
+		t.Errorf("Expected maintenance mode to be enabled")
+	}
+
+	req = httptest.NewRequest("POST", "http://localhost:8000/admin/maintenance?enabled=maybe", nil)
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for invalid value, got %d", http.StatusBadRequest, recorder.Code)
+	}
+}
+
+func TestLoadBalancerResponseHeaders(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "mock-backend/1.0")
+		w.Header().Set("X-Frame-Options", "ALLOWALL")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Hello from mock backend"))
+	}))
+	defer mockServer.Close()
+
+	cfg := &config.Config{
+		Port:                8000,
+		Backends:            []string{mockServer.URL},
+		HealthCheckPath:     "/",
+		HealthCheckInterval: 10 * time.Second,
+		HealthCheckTimeout:  2 * time.Second,
+		BackendTimeout:      30 * time.Second,
+		ResponseHeaders: map[string]string{
+			"Strict-Transport-Security": "max-age=63072000",
+			"X-Frame-Options":           "DENY",
+		},
+		RemoveResponseHeaders: []string{"Server"},
+	}
+
+	lb, err := NewLoadBalancer(cfg)
+	if err != nil {
+		t.Fatalf("Load balancer creation failed: %v", err)
+	}
+	defer lb.Stop()
+	waitForHealthChecks(t, lb)
+
+	req := httptest.NewRequest("GET", "http://localhost:8000/", nil)
+	recorder := httptest.NewRecorder()
+	lb.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, recorder.Code)
+	}
+
+	if got := recorder.Header().Get("Strict-Transport-Security"); got != "max-age=63072000" {
+		t.Errorf("Expected injected Strict-Transport-Security header, got %q", got)
+	}
+
+	// Injected values override upstream ones rather than being appended
+	if got := recorder.Header().Values("X-Frame-Options"); len(got) != 1 || got[0] != "DENY" {
+		t.Errorf("Expected X-Frame-Options to be overridden to DENY, got %v", got)
+	}
+
+	if got := recorder.Header().Get("Server"); got != "" {
+		t.Errorf("Expected upstream Server header to be removed, got %q", got)
+	}
+
+	// Error responses carry the injected headers too
+	lb.SetMaintenance(true)
+	recorder = httptest.NewRecorder()
+	lb.ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8000/", nil))
+
+	if got := recorder.Header().Get("Strict-Transport-Security"); got != "max-age=63072000" {
+		t.Errorf("Expected injected header on error response, got %q", got)
+	}
+}
+
+func TestLoadBalancerRequestHeaders(t *testing.T) {
+	var received http.Header
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Ignore health check probes, which hit "/"
+		if r.URL.Path == "/api" {
+			received = r.Header.Clone()
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	tests := []struct {
+		name            string
+		override        bool
+		callerAPIKey    string
+		expectedAPIKey  string
+		callerRequestID string
+	}{
+		{"Injects missing header", false, "", "secret", ""},
+		{"Keeps caller value", false, "caller-key", "caller-key", "req-123"},
+		{"Overrides caller value", true, "caller-key", "secret", "req-456"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				Port:                   8000,
+				Backends:               []string{mockServer.URL},
+				HealthCheckPath:        "/",
+				HealthCheckInterval:    10 * time.Second,
+				HealthCheckTimeout:     2 * time.Second,
+				BackendTimeout:         30 * time.Second,
+				RequestHeaders:         map[string]string{"X-Api-Key": "secret"},
+				OverrideRequestHeaders: tt.override,
+			}
+
+			lb, err := NewLoadBalancer(cfg)
+			if err != nil {
+				t.Fatalf("Load balancer creation failed: %v", err)
+			}
+			defer lb.Stop()
+			waitForHealthChecks(t, lb)
+
+			req := httptest.NewRequest("GET", "http://localhost:8000/api", nil)
+			if tt.callerAPIKey != "" {
+				req.Header.Set("X-Api-Key", tt.callerAPIKey)
+			}
+			if tt.callerRequestID != "" {
+				req.Header.Set("X-Request-ID", tt.callerRequestID)
+			}
+			recorder := httptest.NewRecorder()
+			lb.ServeHTTP(recorder, req)
+
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("Expected status %d, got %d", http.StatusOK, recorder.Code)
+			}
+
+			if got := received.Get("X-Api-Key"); got != tt.expectedAPIKey {
+				t.Errorf("Expected backend to receive X-Api-Key %q, got %q", tt.expectedAPIKey, got)
+			}
+
+			// The request ID must reach the backend and round-trip to the client
+			backendID := received.Get("X-Request-ID")
+			if backendID == "" {
+				t.Errorf("Expected backend to receive an X-Request-ID")
+			}
+			if tt.callerRequestID != "" && backendID != tt.callerRequestID {
+				t.Errorf("Expected caller request ID %q to be preserved, got %q", tt.callerRequestID, backendID)
+			}
+			if echoed := recorder.Header().Get("X-Request-ID"); echoed != backendID {
+				t.Errorf("Expected echoed request ID %q, got %q", backendID, echoed)
+			}
+		})
+	}
+}
+
+func TestLoadBalancerForceBackendHeader(t *testing.T) {
+	var backends []string
+	for i := 1; i <= 3; i++ {
+		id := fmt.Sprintf("backend-%d", i)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(id))
+		}))
+		defer server.Close()
+		backends = append(backends, server.URL)
+	}
+
+	newLB := func(t *testing.T, enabled bool) *LoadBalancer {
+		t.Helper()
+		cfg := &config.Config{
+			Port:                8000,
+			Backends:            backends,
+			HealthCheckPath:     "/",
+			HealthCheckInterval: 10 * time.Second,
+			HealthCheckTimeout:  2 * time.Second,
+			BackendTimeout:      30 * time.Second,
+			ForceBackendHeader:  enabled,
+		}
+		lb, err := NewLoadBalancer(cfg)
+		if err != nil {
+			t.Fatalf("Load balancer creation failed: %v", err)
+		}
+		t.Cleanup(lb.Stop)
+		waitForHealthChecks(t, lb)
+		return lb
+	}
+
+	forced := func(lb *LoadBalancer, id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "http://localhost:8000/api", nil)
+		req.Header.Set("X-Force-Backend", id)
+		recorder := httptest.NewRecorder()
+		lb.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	t.Run("Forced routing", func(t *testing.T) {
+		lb := newLB(t, true)
+		for i := 0; i < 4; i++ {
+			recorder := forced(lb, "backend-2")
+			if recorder.Code != http.StatusOK || recorder.Body.String() != "backend-2" {
+				t.Errorf("Expected backend-2 to serve the request, got %d %q", recorder.Code, recorder.Body.String())
+			}
+		}
+	})
+
+	t.Run("Unknown ID", func(t *testing.T) {
+		lb := newLB(t, true)
+		recorder := forced(lb, "backend-9")
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d for an unknown backend, got %d", http.StatusBadRequest, recorder.Code)
+		}
+	})
+
+	t.Run("Unhealthy backend", func(t *testing.T) {
+		lb := newLB(t, true)
+		lb.serverPool.SetBackendHealth("backend-3", false)
+		recorder := forced(lb, "backend-3")
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d for an unhealthy backend, got %d", http.StatusBadRequest, recorder.Code)
+		}
+	})
+
+	t.Run("Disabled by default", func(t *testing.T) {
+		lb := newLB(t, false)
+		served := make(map[string]bool)
+		for i := 0; i < 3; i++ {
+			recorder := forced(lb, "backend-2")
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("Expected the header to be ignored, got status %d", recorder.Code)
+			}
+			served[recorder.Body.String()] = true
+		}
+		if len(served) != 3 {
+			t.Errorf("Expected the strategy to spread requests across all backends, got %v", served)
+		}
+
+		// An unknown ID is no error when the header isn't honored
+		if recorder := forced(lb, "backend-9"); recorder.Code != http.StatusOK {
+			t.Errorf("Expected status %d with the header ignored, got %d", http.StatusOK, recorder.Code)
+		}
+	})
+}
+
+func TestLoadBalancerJSONErrors(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Fail proxied requests; health probes hit "/"
+		if r.URL.Path == "/api" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	tests := []struct {
+		name           string
+		format         string
+		includeContext bool
+		accept         string
+		expectJSON     bool
+	}{
+		{"Text by default", "", false, "application/json", false},
+		{"JSON", config.ErrorFormatJSON, false, "", true},
+		{"JSON with context", config.ErrorFormatJSON, true, "", true},
+		{"Auto accepting JSON", config.ErrorFormatAuto, false, "text/html, application/json;q=0.9", true},
+		{"Auto accepting anything", config.ErrorFormatAuto, false, "*/*", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				Port:                8000,
+				Backends:            []string{mockServer.URL},
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      30 * time.Second,
+				ErrorFormat:         tt.format,
+				ErrorIncludeContext: tt.includeContext,
+			}
+
+			lb, err := NewLoadBalancer(cfg)
+			if err != nil {
+				t.Fatalf("Load balancer creation failed: %v", err)
+			}
+			defer lb.Stop()
+			waitForHealthChecks(t, lb)
+
+			req := httptest.NewRequest("GET", "http://localhost:8000/api", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			recorder := httptest.NewRecorder()
+			lb.ServeHTTP(recorder, req)
+
+			expected := errors.NewBackendResponseError("backend-1", http.StatusInternalServerError)
+			if recorder.Code != expected.HTTPStatusCode() {
+				t.Errorf("Expected status %d, got %d", expected.HTTPStatusCode(), recorder.Code)
+			}
+
+			contentType := recorder.Header().Get("Content-Type")
+			if !tt.expectJSON {
+				if !strings.HasPrefix(contentType, "text/plain") {
+					t.Errorf("Expected a plain text error, got Content-Type %q", contentType)
+				}
+				return
+			}
+			if contentType != "application/json" {
+				t.Errorf("Expected Content-Type application/json, got %q", contentType)
+			}
+
+			var body struct {
+				Error struct {
+					Code    errors.ErrorCode  `json:"code"`
+					Message string            `json:"message"`
+					Context map[string]string `json:"context"`
+				} `json:"error"`
+			}
+			if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+				t.Fatalf("Expected a JSON error body, got %q: %v", recorder.Body.String(), err)
+			}
+			if body.Error.Code != errors.ErrBackendResponse {
+				t.Errorf("Expected code %d, got %d", errors.ErrBackendResponse, body.Error.Code)
+			}
+			if body.Error.Message != expected.Message {
+				t.Errorf("Expected message %q, got %q", expected.Message, body.Error.Message)
+			}
+
+			if !tt.includeContext {
+				if body.Error.Context != nil {
+					t.Errorf("Expected no context, got %v", body.Error.Context)
+				}
+				return
+			}
+			if body.Error.Context["backend"] != "backend-1" || body.Error.Context["status_code"] != "500" {
+				t.Errorf("Expected backend and status code context, got %v", body.Error.Context)
+			}
+		})
+	}
+}
+
+func TestLoadBalancerServerTimingHeader(t *testing.T) {
+	const delay = 50 * time.Millisecond
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Delay proxied requests; health probes hit "/"
+		if r.URL.Path == "/api" {
+			time.Sleep(delay)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	tests := []struct {
+		name    string
+		enabled bool
+	}{
+		{"Enabled", true},
+		{"Disabled", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				Port:                8000,
+				Backends:            []string{mockServer.URL},
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      30 * time.Second,
+				ServerTimingHeader:  tt.enabled,
+			}
+
+			lb, err := NewLoadBalancer(cfg)
+			if err != nil {
+				t.Fatalf("Load balancer creation failed: %v", err)
+			}
+			defer lb.Stop()
+			waitForHealthChecks(t, lb)
+
+			recorder := httptest.NewRecorder()
+			lb.ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8000/api", nil))
+
+			timing := recorder.Header().Get("Server-Timing")
+			if !tt.enabled {
+				if timing != "" {
+					t.Errorf("Expected no Server-Timing header when disabled, got %q", timing)
+				}
+				return
+			}
+
+			dur, found := strings.CutPrefix(timing, "upstream;dur=")
+			if !found {
+				t.Fatalf("Expected an upstream Server-Timing metric, got %q", timing)
+			}
+			ms, err := strconv.ParseFloat(dur, 64)
+			if err != nil {
+				t.Fatalf("Expected a numeric duration, got %q", dur)
+			}
+			if ms < float64(delay/time.Millisecond) || ms > 1000 {
+				t.Errorf("Expected a duration near %s, got %sms", delay, dur)
+			}
+		})
+	}
+}
+
+func TestLoadBalancerServedByHeader(t *testing.T) {
+	var backends []string
+	for i := 1; i <= 2; i++ {
+		id := fmt.Sprintf("backend-%d", i)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// The balancer replaces a backend's own X-Served-By when enabled
+			w.Header().Set("X-Served-By", "internal-host")
+			if r.URL.Path == "/error" {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.Write([]byte(id))
+		}))
+		defer server.Close()
+		backends = append(backends, server.URL)
+	}
+
+	tests := []struct {
+		name    string
+		enabled bool
+	}{
+		{"Enabled", true},
+		{"Disabled", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				Port:                8000,
+				Backends:            backends,
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      30 * time.Second,
+				ServedByHeader:      tt.enabled,
+			}
+
+			lb, err := NewLoadBalancer(cfg)
+			if err != nil {
+				t.Fatalf("Load balancer creation failed: %v", err)
+			}
+			defer lb.Stop()
+			waitForHealthChecks(t, lb)
+
+			for i := 0; i < 4; i++ {
+				recorder := httptest.NewRecorder()
+				lb.ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8000/api", nil))
+
+				servedBy := recorder.Header().Values("X-Served-By")
+				if !tt.enabled {
+					// Upstream headers pass through untouched
+					if len(servedBy) != 1 || servedBy[0] != "internal-host" {
+						t.Errorf("Expected only the backend's X-Served-By when disabled, got %v", servedBy)
+					}
+					continue
+				}
+				// Each backend writes its own ID as the body
+				if len(servedBy) != 1 || servedBy[0] != recorder.Body.String() {
+					t.Errorf("Expected X-Served-By %q, got %v", recorder.Body.String(), servedBy)
+				}
+			}
+
+			// Error responses name the backend too
+			recorder := httptest.NewRecorder()
+			lb.ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8000/error", nil))
+			servedBy := recorder.Header().Get("X-Served-By")
+			if tt.enabled && !strings.HasPrefix(servedBy, "backend-") {
+				t.Errorf("Expected X-Served-By on an error response, got %q", servedBy)
+			}
+			if !tt.enabled && servedBy != "" {
+				t.Errorf("Expected no X-Served-By on an error response when disabled, got %q", servedBy)
+			}
+		})
+	}
+}
+
+func TestLoadBalancerUpstreamUserAgent(t *testing.T) {
+	var received string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api" {
+			received = r.Header.Get("User-Agent")
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	tests := []struct {
+		name            string
+		userAgent       string
+		appendUserAgent bool
+		clientUserAgent string
+		expected        string
+	}{
+		{"Client User-Agent kept by default", "", false, "curl/8.0", "curl/8.0"},
+		{"User-Agent replaced", "go-balancer/1.0", false, "curl/8.0", "go-balancer/1.0"},
+		{"User-Agent appended", "go-balancer/1.0", true, "curl/8.0", "curl/8.0 go-balancer/1.0"},
+		{"Appended without a client User-Agent", "go-balancer/1.0", true, "", "go-balancer/1.0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				Port:                8000,
+				Backends:            []string{mockServer.URL},
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      30 * time.Second,
+				UpstreamUserAgent:   tt.userAgent,
+				AppendUserAgent:     tt.appendUserAgent,
+			}
+
+			lb, err := NewLoadBalancer(cfg)
+			if err != nil {
+				t.Fatalf("Load balancer creation failed: %v", err)
+			}
+			defer lb.Stop()
+			waitForHealthChecks(t, lb)
+
+			req := httptest.NewRequest("GET", "http://localhost:8000/api", nil)
+			if tt.clientUserAgent != "" {
+				req.Header.Set("User-Agent", tt.clientUserAgent)
+			}
+			recorder := httptest.NewRecorder()
+			lb.ServeHTTP(recorder, req)
+
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("Expected status %d, got %d", http.StatusOK, recorder.Code)
+			}
+			if received != tt.expected {
+				t.Errorf("Expected backend to see User-Agent %q, got %q", tt.expected, received)
+			}
+		})
+	}
+}
+
+func TestLoadBalancerQueryRewriting(t *testing.T) {
+	var received string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Ignore health check probes, which hit "/"
+		if r.URL.Path == "/api" {
+			received = r.URL.RawQuery
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	tests := []struct {
+		name          string
+		defaults      map[string]string
+		remove        []string
+		query         string
+		expectedQuery string
+	}{
+		{"No rules keeps query verbatim", nil, nil, "b=2&a=%2F&a=3", "b=2&a=%2F&a=3"},
+		{"Adds missing default", map[string]string{"version": "v2"}, nil, "q=go", "q=go&version=v2"},
+		{"Keeps caller value over default", map[string]string{"version": "v2"}, nil, "version=v1", "version=v1"},
+		{"Escapes default value", map[string]string{"tag": "a b&c"}, nil, "", "tag=a+b%26c"},
+		{"Drops removed params", nil, []string{"debug"}, "debug=1&q=go&debug=2", "q=go"},
+		{"Matches encoded names", nil, []string{"access token"}, "access%20token=x&q=go", "q=go"},
+		{"Preserves encoding of kept params", nil, []string{"debug"}, "q=caf%C3%A9&debug=1&path=%2Fa%2Fb", "q=caf%C3%A9&path=%2Fa%2Fb"},
+		{"Remove then default", map[string]string{"key": "server"}, []string{"key"}, "key=client&q=go", "q=go&key=server"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				Port:                8000,
+				Backends:            []string{mockServer.URL},
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      30 * time.Second,
+				DefaultQueryParams:  tt.defaults,
+				RemoveQueryParams:   tt.remove,
+			}
+
+			lb, err := NewLoadBalancer(cfg)
+			if err != nil {
+				t.Fatalf("Load balancer creation failed: %v", err)
+			}
+			defer lb.Stop()
+			waitForHealthChecks(t, lb)
+
+			target := "http://localhost:8000/api"
+			if tt.query != "" {
+				target += "?" + tt.query
+			}
+			recorder := httptest.NewRecorder()
+			lb.ServeHTTP(recorder, httptest.NewRequest("GET", target, nil))
+
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("Expected status %d, got %d", http.StatusOK, recorder.Code)
+			}
+			if received != tt.expectedQuery {
+				t.Errorf("Expected backend to receive query %q, got %q", tt.expectedQuery, received)
+			}
+		})
+	}
+}
+
+func TestLoadBalancerMetricsFormatsAgree(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	cfg := &config.Config{
+		Port:                8000,
+		Backends:            []string{mockServer.URL},
+		HealthCheckPath:     "/",
+		HealthCheckInterval: 10 * time.Second,
+		HealthCheckTimeout:  2 * time.Second,
+		BackendTimeout:      30 * time.Second,
+	}
+
+	lb, err := NewLoadBalancer(cfg)
+	if err != nil {
+		t.Fatalf("Load balancer creation failed: %v", err)
+	}
+	defer lb.Stop()
+	waitForHealthChecks(t, lb)
+
+	for i := 0; i < 7; i++ {
+		lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://localhost:8000/api", nil))
+	}
+
+	recorder := httptest.NewRecorder()
+	lb.GetMetricsProvider().ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8000/metrics", nil))
+	var prometheusTotal int64 = -1
+	for _, line := range strings.Split(recorder.Body.String(), "\n") {
+		if value, ok := strings.CutPrefix(line, "go_balancer_requests_total "); ok {
+			fmt.Sscan(value, &prometheusTotal)
+		}
+	}
+
+	recorder = httptest.NewRecorder()
+	lb.GetJSONMetricsProvider().ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8000/metrics.json", nil))
+	var report struct {
+		Requests struct {
+			Total int64 `json:"total"`
+		} `json:"requests"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &report); err != nil {
+		t.Fatalf("Expected JSON body, got error: %v", err)
+	}
+
+	if prometheusTotal != 7 || report.Requests.Total != 7 {
+		t.Errorf("Expected both formats to report 7 requests, got %d (Prometheus) and %d (JSON)", prometheusTotal, report.Requests.Total)
+	}
+}
+
+func TestLivenessAndReadiness(t *testing.T) {
+	cfg := &config.Config{
+		Port:                8000,
+		Backends:            []string{"http://localhost:8080", "http://localhost:8081"},
+		HealthCheckPath:     "/",
+		HealthCheckInterval: 10 * time.Second,
+		HealthCheckTimeout:  2 * time.Second,
+		BackendTimeout:      30 * time.Second,
+	}
+
+	lb, err := NewLoadBalancer(cfg)
+	if err != nil {
+		t.Fatalf("Load balancer creation failed: %v", err)
+	}
+	lb.Stop()
+
+	// Give the initial health check time to finish, then pin health explicitly
+	time.Sleep(100 * time.Millisecond)
+
+	probe := func(handler http.Handler, path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8000"+path, nil))
+		return recorder
+	}
+
+	// One healthy backend is enough to be ready
+	lb.serverPool.SetBackendHealth("backend-1", true)
+	lb.serverPool.SetBackendHealth("backend-2", false)
+
+	if rec := probe(lb.LivenessHandler(), "/livez"); rec.Code != http.StatusOK {
+		t.Errorf("Expected livez status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if rec := probe(lb.ReadinessHandler(), "/readyz"); rec.Code != http.StatusOK {
+		t.Errorf("Expected readyz status %d with a healthy backend, got %d", http.StatusOK, rec.Code)
+	}
+
+	// With every backend down, liveness holds but readiness fails
+	lb.serverPool.SetBackendHealth("backend-1", false)
+
+	if rec := probe(lb.LivenessHandler(), "/livez"); rec.Code != http.StatusOK {
+		t.Errorf("Expected livez status %d with no healthy backends, got %d", http.StatusOK, rec.Code)
+	}
+	rec := probe(lb.ReadinessHandler(), "/readyz")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected readyz status %d with no healthy backends, got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "no healthy backends") {
+		t.Errorf("Expected readyz reason in body, got %q", rec.Body.String())
+	}
+}
+
+func TestLoadBalancerServeStaleOnError(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("fresh content for " + r.URL.Path))
+	}))
+	defer mockServer.Close()
+
+	cfg := &config.Config{
+		Port:                8000,
+		Backends:            []string{mockServer.URL},
+		HealthCheckPath:     "/",
+		HealthCheckInterval: 10 * time.Second,
+		HealthCheckTimeout:  2 * time.Second,
+		BackendTimeout:      30 * time.Second,
+		ServeStaleOnError:   true,
+	}
+
+	lb, err := NewLoadBalancer(cfg)
+	if err != nil {
+		t.Fatalf("Load balancer creation failed: %v", err)
+	}
+	lb.Stop()
+	time.Sleep(100 * time.Millisecond)
+
+	// Populate the cache while the backend is healthy
+	recorder := httptest.NewRecorder()
+	lb.ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8000/page", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, recorder.Code)
+	}
+
+	// Take every backend down
+	lb.serverPool.SetBackendHealth("backend-1", false)
+
+	recorder = httptest.NewRecorder()
+	lb.ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8000/page", nil))
+
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Expected stale response with status %d, got %d", http.StatusOK, recorder.Code)
+	}
+	if body := recorder.Body.String(); body != "fresh content for /page" {
+		t.Errorf("Expected cached body, got %q", body)
+	}
+	if warning := recorder.Header().Get("Warning"); !strings.HasPrefix(warning, "110") {
+		t.Errorf("Expected Warning: 110 header, got %q", warning)
+	}
+
+	// Uncached paths and non-GET requests still fail
+	recorder = httptest.NewRecorder()
+	lb.ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8000/other", nil))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d for uncached path, got %d", http.StatusServiceUnavailable, recorder.Code)
+	}
+
+	recorder = httptest.NewRecorder()
+	lb.ServeHTTP(recorder, httptest.NewRequest("POST", "http://localhost:8000/page", nil))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d for POST, got %d", http.StatusServiceUnavailable, recorder.Code)
+	}
+}
+
+func TestLoadBalancerSlowRequestThreshold(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(100 * time.Millisecond)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	cfg := &config.Config{
+		Port:                 8000,
+		Backends:             []string{mockServer.URL},
+		HealthCheckPath:      "/",
+		HealthCheckInterval:  10 * time.Second,
+		HealthCheckTimeout:   2 * time.Second,
+		BackendTimeout:       30 * time.Second,
+		SlowRequestThreshold: 50 * time.Millisecond,
+	}
+
+	var logs strings.Builder
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	lb, err := NewLoadBalancer(cfg)
+	if err != nil {
+		t.Fatalf("Load balancer creation failed: %v", err)
+	}
+	defer lb.Stop()
+	waitForHealthChecks(t, lb)
+
+	lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://localhost:8000/slow", nil))
+	lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://localhost:8000/fast", nil))
+
+	recorder := httptest.NewRecorder()
+	lb.GetMetricsProvider().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	line := `go_balancer_slow_requests_total{backend="backend-1"} 1`
+	if !strings.Contains(recorder.Body.String(), line) {
+		t.Errorf("Expected metrics output to contain %q, got:\n%s", line, recorder.Body.String())
+	}
+
+	// Stop background logging before reading what was logged
+	lb.Stop()
+	output := logs.String()
+	if !strings.Contains(output, "Slow request: GET /slow to backend backend-1") {
+		t.Errorf("Expected a slow request warning for /slow, got:\n%s", output)
+	}
+	if strings.Contains(output, "Slow request: GET /fast") {
+		t.Errorf("Expected no slow request warning for /fast, got:\n%s", output)
+	}
+}
+
+func TestLoadBalancerClientCancellation(t *testing.T) {
+	backendCanceled := make(chan struct{})
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/slow" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		// Block until the upstream request is canceled
+		select {
+		case <-r.Context().Done():
+			close(backendCanceled)
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer mockServer.Close()
+
+	cfg := &config.Config{
+		Port:                8000,
+		Backends:            []string{mockServer.URL},
+		HealthCheckPath:     "/",
+		HealthCheckInterval: 10 * time.Second,
+		HealthCheckTimeout:  2 * time.Second,
+		BackendTimeout:      30 * time.Second,
+	}
+
+	lb, err := NewLoadBalancer(cfg)
+	if err != nil {
+		t.Fatalf("Load balancer creation failed: %v", err)
+	}
+	defer lb.Stop()
+	waitForHealthChecks(t, lb)
+
+	// Simulate the client disconnecting shortly after sending the request
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest("GET", "http://localhost:8000/slow", nil).WithContext(ctx)
+	recorder := httptest.NewRecorder()
+
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	lb.ServeHTTP(recorder, req)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected ServeHTTP to return promptly after cancellation, took %s", elapsed)
+	}
+
+	select {
+	case <-backendCanceled:
+	case <-time.After(2 * time.Second):
+		t.Errorf("Expected upstream request to be canceled")
+	}
+
+	snapshot := lb.metrics.GetSnapshot()
+	if snapshot.ClientCanceled != 1 {
+		t.Errorf("Expected 1 client canceled request, got %d", snapshot.ClientCanceled)
+	}
+	if snapshot.FailedRequests != 0 {
+		t.Errorf("Expected client cancellation not to count as a failure, got %d failures", snapshot.FailedRequests)
+	}
+	if backend := lb.serverPool.GetBackendByIndex(0); !backend.IsHealthy() {
+		t.Errorf("Expected backend to stay healthy after client cancellation")
+	}
+}
+
+// failingBody returns some data and then an error, like a client connection
+// that breaks while uploading
+type failingBody struct {
+	sent bool
+}
+
+func (fb *failingBody) Read(p []byte) (int, error) {
+	if !fb.sent {
+		fb.sent = true
+		return copy(p, "partial upload"), nil
+	}
+	return 0, fmt.Errorf("connection reset by peer")
+}
+
+func TestLoadBalancerClientBodyReadError(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	cfg := &config.Config{
+		Port:                8000,
+		Backends:            []string{mockServer.URL},
+		HealthCheckPath:     "/",
+		HealthCheckInterval: 10 * time.Second,
+		HealthCheckTimeout:  2 * time.Second,
+		BackendTimeout:      30 * time.Second,
+	}
+
+	lb, err := NewLoadBalancer(cfg)
+	if err != nil {
+		t.Fatalf("Load balancer creation failed: %v", err)
+	}
+	defer lb.Stop()
+	waitForHealthChecks(t, lb)
+
+	req := httptest.NewRequest("POST", "http://localhost:8000/upload", &failingBody{})
+	recorder := httptest.NewRecorder()
+	lb.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, recorder.Code)
+	}
+
+	snapshot := lb.metrics.GetSnapshot()
+	if snapshot.ClientReadErrors != 1 {
+		t.Errorf("Expected 1 client read error, got %d", snapshot.ClientReadErrors)
+	}
+	if snapshot.FailedRequests != 0 {
+		t.Errorf("Expected client read error not to count as a failure, got %d failures", snapshot.FailedRequests)
+	}
+	if backend := lb.serverPool.GetBackendByIndex(0); !backend.IsHealthy() {
+		t.Errorf("Expected backend to stay healthy after a client read error")
+	}
+}
+
+// failingWriter accepts up to limit body bytes and then fails, like a client
+// connection that breaks while the response is being written
+type failingWriter struct {
+	*httptest.ResponseRecorder
+	limit int
+}
+
+func (fw *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > fw.limit {
+		n, _ := fw.ResponseRecorder.Write(p[:fw.limit])
+		fw.limit = 0
+		return n, fmt.Errorf("broken pipe")
+	}
+	fw.limit -= len(p)
+	return fw.ResponseRecorder.Write(p)
+}
+
+func TestLoadBalancerResponseCopyError(t *testing.T) {
+	body := strings.Repeat("x", 64*1024)
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		io.WriteString(w, body)
+	}))
+	defer mockServer.Close()
+
+	cfg := &config.Config{
+		Port:                8000,
+		Backends:            []string{mockServer.URL},
+		HealthCheckPath:     "/",
+		HealthCheckInterval: 10 * time.Second,
+		HealthCheckTimeout:  2 * time.Second,
+		BackendTimeout:      30 * time.Second,
+	}
+
+	lb, err := NewLoadBalancer(cfg)
+	if err != nil {
+		t.Fatalf("Load balancer creation failed: %v", err)
+	}
+	defer lb.Stop()
+	waitForHealthChecks(t, lb)
+
+	writer := &failingWriter{ResponseRecorder: httptest.NewRecorder(), limit: 1000}
+	lb.ServeHTTP(writer, httptest.NewRequest("GET", "http://localhost:8000/download", nil))
+
+	if writer.Code != http.StatusOK {
+		t.Errorf("Expected the backend's status %d to have been sent, got %d", http.StatusOK, writer.Code)
+	}
+
+	snapshot := lb.metrics.GetSnapshot()
+	if snapshot.ResponseCopyErrors != 1 {
+		t.Errorf("Expected 1 response copy error, got %d", snapshot.ResponseCopyErrors)
+	}
+
+	// The partial write is accounted for
+	if written := lb.metrics.ResponseBytes()["backend-1"]; written != 1000 {
+		t.Errorf("Expected 1000 response bytes for backend-1, got %d", written)
+	}
+
+	// A complete response counts all of its bytes and no copy error
+	lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://localhost:8000/download", nil))
+	if written := lb.metrics.ResponseBytes()["backend-1"]; written != int64(1000+len(body)) {
+		t.Errorf("Expected %d response bytes for backend-1, got %d", 1000+len(body), written)
+	}
+	if snapshot := lb.metrics.GetSnapshot(); snapshot.ResponseCopyErrors != 1 {
+		t.Errorf("Expected the copy error count to stay at 1, got %d", snapshot.ResponseCopyErrors)
+	}
+}
+
+// hangUp closes the client connection without a complete response, after
+// writing raw (which may be empty)
+func hangUp(t *testing.T, w http.ResponseWriter, raw string) {
+	t.Helper()
+	conn, buf, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		t.Errorf("Hijack failed: %v", err)
+		return
+	}
+	buf.WriteString(raw)
+	buf.Flush()
+	conn.Close()
+}
+
+func TestLoadBalancerUpstreamEOF(t *testing.T) {
+	var flakyCalls atomic.Int32
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/close":
+			hangUp(t, w, "")
+		case "/truncated":
+			hangUp(t, w, "HTTP/1.1 200 OK\r\nContent-Length: 100\r\n\r\npartial")
+		case "/flaky":
+			// Hang up on the first attempt only
+			if flakyCalls.Add(1) == 1 {
+				hangUp(t, w, "")
+				return
+			}
+			w.Write([]byte("recovered"))
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer mockServer.Close()
+
+	newLB := func(t *testing.T, retry bool) *LoadBalancer {
+		t.Helper()
+		cfg := &config.Config{
+			Port:                8000,
+			Backends:            []string{mockServer.URL},
+			HealthCheckPath:     "/",
+			HealthCheckInterval: 10 * time.Second,
+			HealthCheckTimeout:  2 * time.Second,
+			BackendTimeout:      30 * time.Second,
+			DisableKeepAlive:    true, // Fresh connections, so the transport never retries on its own
+			RetryUpstreamEOF:    retry,
+		}
+		lb, err := NewLoadBalancer(cfg)
+		if err != nil {
+			t.Fatalf("Load balancer creation failed: %v", err)
+		}
+		t.Cleanup(lb.Stop)
+		waitForHealthChecks(t, lb)
+		return lb
+	}
+
+	upstreamEOFs := func(lb *LoadBalancer) int64 {
+		var report struct {
+			ByBackend map[string]struct {
+				UpstreamEOFs int64 `json:"upstream_eofs"`
+			} `json:"by_backend"`
+		}
+		recorder := httptest.NewRecorder()
+		metrics.NewJSONMetricsProvider(lb.metrics).ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics.json", nil))
+		if err := json.Unmarshal(recorder.Body.Bytes(), &report); err != nil {
+			t.Fatalf("Invalid metrics JSON: %v", err)
+		}
+		return report.ByBackend["backend-1"].UpstreamEOFs
+	}
+
+	t.Run("Closed before responding", func(t *testing.T) {
+		lb := newLB(t, false)
+		recorder := httptest.NewRecorder()
+		lb.ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8000/close", nil))
+
+		if recorder.Code != http.StatusBadGateway {
+			t.Errorf("Expected status %d, got %d", http.StatusBadGateway, recorder.Code)
+		}
+		if !strings.Contains(recorder.Body.String(), "closed connection early") {
+			t.Errorf("Expected an early close error, got %q", recorder.Body.String())
+		}
+		if count := upstreamEOFs(lb); count != 1 {
+			t.Errorf("Expected 1 upstream EOF, got %d", count)
+		}
+	})
+
+	t.Run("Closed mid-response", func(t *testing.T) {
+		lb := newLB(t, false)
+		recorder := httptest.NewRecorder()
+		lb.ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8000/truncated", nil))
+
+		if recorder.Code != http.StatusOK {
+			t.Errorf("Expected the backend's status %d to have been sent, got %d", http.StatusOK, recorder.Code)
+		}
+		if count := upstreamEOFs(lb); count != 1 {
+			t.Errorf("Expected 1 upstream EOF, got %d", count)
+		}
+		if snapshot := lb.metrics.GetSnapshot(); snapshot.ResponseCopyErrors != 0 {
+			t.Errorf("Expected an upstream EOF not to count as a response copy error, got %d", snapshot.ResponseCopyErrors)
+		}
+	})
+
+	t.Run("Retried when enabled", func(t *testing.T) {
+		flakyCalls.Store(0)
+		lb := newLB(t, true)
+		recorder := httptest.NewRecorder()
+		lb.ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8000/flaky", nil))
+
+		if recorder.Code != http.StatusOK || recorder.Body.String() != "recovered" {
+			t.Errorf("Expected the retry to succeed, got %d %q", recorder.Code, recorder.Body.String())
+		}
+		if calls := flakyCalls.Load(); calls != 2 {
+			t.Errorf("Expected 2 attempts, got %d", calls)
+		}
+		if count := upstreamEOFs(lb); count != 1 {
+			t.Errorf("Expected 1 upstream EOF, got %d", count)
+		}
+	})
+
+	t.Run("Requests with a body are not retried", func(t *testing.T) {
+		flakyCalls.Store(0)
+		lb := newLB(t, true)
+		recorder := httptest.NewRecorder()
+		lb.ServeHTTP(recorder, httptest.NewRequest("POST", "http://localhost:8000/flaky", strings.NewReader("payload")))
+
+		if recorder.Code != http.StatusBadGateway {
+			t.Errorf("Expected status %d, got %d", http.StatusBadGateway, recorder.Code)
+		}
+		if calls := flakyCalls.Load(); calls != 1 {
+			t.Errorf("Expected a single attempt, got %d", calls)
+		}
+	})
+}
+
+func TestLoadBalancerStreamIdleTimeout(t *testing.T) {
+	const idleTimeout = 200 * time.Millisecond
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/stalled":
+			// Send one chunk, then go quiet until the proxy gives up
+			io.WriteString(w, "first")
+			w.(http.Flusher).Flush()
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+		case "/steady":
+			// Chunks arrive more often than the idle timeout
+			for i := 0; i < 5; i++ {
+				io.WriteString(w, "chunk")
+				w.(http.Flusher).Flush()
+				time.Sleep(idleTimeout / 4)
+			}
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer mockServer.Close()
+
+	cfg := &config.Config{
+		Port:                   8000,
+		Backends:               []string{mockServer.URL},
+		HealthCheckPath:        "/",
+		HealthCheckInterval:    10 * time.Second,
+		HealthCheckTimeout:     2 * time.Second,
+		BackendTimeout:         30 * time.Second,
+		StreamIdleReadTimeout:  idleTimeout,
+		StreamIdleWriteTimeout: idleTimeout,
+	}
+
+	lb, err := NewLoadBalancer(cfg)
+	if err != nil {
+		t.Fatalf("Load balancer creation failed: %v", err)
+	}
+	defer lb.Stop()
+	waitForHealthChecks(t, lb)
+
+	t.Run("Stalled stream aborted", func(t *testing.T) {
+		start := time.Now()
+		recorder := httptest.NewRecorder()
+		lb.ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8000/stalled", nil))
+		elapsed := time.Since(start)
+
+		if elapsed < idleTimeout || elapsed > 2*time.Second {
+			t.Errorf("Expected the stream to be cut off after about %s, took %s", idleTimeout, elapsed)
+		}
+		if recorder.Body.String() != "first" {
+			t.Errorf("Expected the chunk sent before the stall, got %q", recorder.Body.String())
+		}
+	})
+
+	t.Run("Steady stream completes", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		lb.ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8000/steady", nil))
+
+		if expected := strings.Repeat("chunk", 5); recorder.Body.String() != expected {
+			t.Errorf("Expected the full stream %q, got %q", expected, recorder.Body.String())
+		}
+	})
+
+	t.Run("Fast response unaffected", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		lb.ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8000/fast", nil))
+
+		if recorder.Code != http.StatusOK {
+			t.Errorf("Expected status 200, got %d", recorder.Code)
+		}
+	})
+}
+
+func TestLoadBalancerResponseBuffering(t *testing.T) {
+	small := strings.Repeat("s", 512)
+	large := strings.Repeat("l", 8*1024)
+	var lateCalls atomic.Int32
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/small", "/large":
+			// Flush first so the backend streams without a Content-Length
+			body := small
+			if r.URL.Path == "/large" {
+				body = large
+			}
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			io.WriteString(w, body)
+		case "/late-failure":
+			// Send a 200 and then hang up part way through the body, once
+			if lateCalls.Add(1) == 1 {
+				hangUp(t, w, "HTTP/1.1 200 OK\r\nContent-Length: 512\r\n\r\npartial")
+				return
+			}
+			io.WriteString(w, small)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer mockServer.Close()
+
+	cfg := &config.Config{
+		Port:                   8000,
+		Backends:               []string{mockServer.URL},
+		HealthCheckPath:        "/",
+		HealthCheckInterval:    10 * time.Second,
+		HealthCheckTimeout:     2 * time.Second,
+		BackendTimeout:         30 * time.Second,
+		DisableKeepAlive:       true, // Fresh connections, so the transport never retries on its own
+		RetryUpstreamEOF:       true,
+		ResponseBufferMaxBytes: 4096,
+	}
+
+	lb, err := NewLoadBalancer(cfg)
+	if err != nil {
+		t.Fatalf("Load balancer creation failed: %v", err)
+	}
+	defer lb.Stop()
+	waitForHealthChecks(t, lb)
+
+	t.Run("Small response buffered", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		lb.ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8000/small", nil))
+
+		if recorder.Body.String() != small {
+			t.Errorf("Expected the full %d-byte body, got %d bytes", len(small), recorder.Body.Len())
+		}
+		if got := recorder.Header().Get("Content-Length"); got != strconv.Itoa(len(small)) {
+			t.Errorf("Expected Content-Length %d on a buffered response, got %q", len(small), got)
+		}
+	})
+
+	t.Run("Large response streamed", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		lb.ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8000/large", nil))
+
+		if recorder.Body.String() != large {
+			t.Errorf("Expected the full %d-byte body, got %d bytes", len(large), recorder.Body.Len())
+		}
+		if got := recorder.Header().Get("Content-Length"); got != "" {
+			t.Errorf("Expected a streamed response without Content-Length, got %q", got)
+		}
+	})
+
+	t.Run("Late failure retried", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		lb.ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8000/late-failure", nil))
+
+		if recorder.Code != http.StatusOK || recorder.Body.String() != small {
+			t.Errorf("Expected the retry to deliver the full body, got %d with %d bytes", recorder.Code, recorder.Body.Len())
+		}
+		if calls := lateCalls.Load(); calls != 2 {
+			t.Errorf("Expected 2 attempts, got %d", calls)
+		}
+	})
+}
+
+func TestRetryingDial(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("connected"))
+	}))
+	defer backend.Close()
+
+	// flakyDialer fails its first failures attempts, like a momentary
+	// connect blip, then dials for real
+	flakyDialer := func(failures int32) (dialFunc, *atomic.Int32) {
+		var attempts atomic.Int32
+		dialer := &net.Dialer{}
+		return func(ctx context.Context, network, address string) (net.Conn, error) {
+			if attempts.Add(1) <= failures {
+				return nil, fmt.Errorf("connection refused")
+			}
+			return dialer.DialContext(ctx, network, address)
+		}, &attempts
+	}
+
+	t.Run("Succeeds on the second attempt", func(t *testing.T) {
+		dial, attempts := flakyDialer(1)
+		transport := &http.Transport{DialContext: retryingDial(dial, 2, time.Millisecond)}
+		client := &http.Client{Transport: transport}
+
+		resp, err := client.Get(backend.URL)
+		if err != nil {
+			t.Fatalf("Expected the retried dial to succeed, got %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if string(body) != "connected" {
+			t.Errorf("Expected the backend's response, got %q", body)
+		}
+		if n := attempts.Load(); n != 2 {
+			t.Errorf("Expected 2 dial attempts, got %d", n)
+		}
+	})
+
+	t.Run("Gives up after the configured retries", func(t *testing.T) {
+		dial, attempts := flakyDialer(10)
+		_, err := retryingDial(dial, 2, time.Millisecond)(context.Background(), "tcp", backend.Listener.Addr().String())
+		if err == nil {
+			t.Fatalf("Expected the dial to fail")
+		}
+		if n := attempts.Load(); n != 3 {
+			t.Errorf("Expected 1 attempt and 2 retries, got %d attempts", n)
+		}
+	})
+
+	t.Run("Stops when the context ends", func(t *testing.T) {
+		dial, attempts := flakyDialer(10)
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		_, err := retryingDial(dial, 100, 20*time.Millisecond)(ctx, "tcp", backend.Listener.Addr().String())
+		if err == nil {
+			t.Fatalf("Expected the dial to fail")
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("Expected retries to stop with the context, took %s", elapsed)
+		}
+		if n := attempts.Load(); n >= 100 {
+			t.Errorf("Expected the context to cut retries short, got %d attempts", n)
+		}
+	})
+}
+
+func TestLoadBalancerConsistentHashByHeader(t *testing.T) {
+	backendURLs := make([]string, 3)
+	for i := 0; i < 3; i++ {
+		id := i
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(fmt.Sprintf("Backend %d", id)))
+		}))
+		defer server.Close()
+		backendURLs[i] = server.URL
+	}
+
+	cfg := &config.Config{
+		Port:                8000,
+		Backends:            backendURLs,
+		HealthCheckPath:     "/",
+		HealthCheckInterval: 10 * time.Second,
+		HealthCheckTimeout:  2 * time.Second,
+		BackendTimeout:      30 * time.Second,
+		Strategy:            "consistent-hash",
+		HashKey:             "header:X-Session-ID",
+	}
+
+	lb, err := NewLoadBalancer(cfg)
+	if err != nil {
+		t.Fatalf("Load balancer creation failed: %v", err)
+	}
+	defer lb.Stop()
+	waitForHealthChecks(t, lb)
+
+	// Every request for a session should land on the same backend
+	for _, session := range []string{"alice", "bob", "carol"} {
+		var first string
+		for i := 0; i < 5; i++ {
+			req := httptest.NewRequest("GET", "http://localhost:8000/", nil)
+			req.Header.Set("X-Session-ID", session)
+			recorder := httptest.NewRecorder()
+			lb.ServeHTTP(recorder, req)
+
+			if i == 0 {
+				first = recorder.Body.String()
+			} else if recorder.Body.String() != first {
+				t.Errorf("Session %s moved from %q to %q", session, first, recorder.Body.String())
+			}
+		}
+	}
+}
+
+func TestBackendsHandlerWeight(t *testing.T) {
+	var backends []string
+	for i := 0; i < 2; i++ {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+		backends = append(backends, server.URL)
+	}
+
+	cfg := &config.Config{
+		Port:                8000,
+		Backends:            backends,
+		HealthCheckPath:     "/",
+		HealthCheckInterval: 10 * time.Second,
+		HealthCheckTimeout:  2 * time.Second,
+		BackendTimeout:      30 * time.Second,
+		Strategy:            "weighted-round-robin",
+	}
+
+	lb, err := NewLoadBalancer(cfg)
+	if err != nil {
+		t.Fatalf("Load balancer creation failed: %v", err)
+	}
+	defer lb.Stop()
+	waitForHealthChecks(t, lb)
+
+	// served sends n requests and returns how many each backend handled
+	served := func(n int) map[string]int64 {
+		before := lb.metrics.RequestDurations()
+		for i := 0; i < n; i++ {
+			lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://localhost:8000/api", nil))
+		}
+		counts := make(map[string]int64)
+		for id, h := range lb.metrics.RequestDurations() {
+			counts[id] = h.Count - before[id].Count
+		}
+		return counts
+	}
+
+	if counts := served(100); counts["backend-2"] != 50 {
+		t.Fatalf("Expected an even split before the update, got %v", counts)
+	}
+
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest("PATCH", "http://localhost:8000/admin/backends/backend-2", strings.NewReader(`{"weight": 9}`))
+	lb.BackendsHandler().ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body.String())
+	}
+	var status struct {
+		ID     string `json:"id"`
+		Weight int    `json:"weight"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &status); err != nil {
+		t.Fatalf("Expected JSON body, got error: %v", err)
+	}
+	if status.ID != "backend-2" || status.Weight != 9 {
+		t.Errorf("Expected backend-2 with weight 9, got %s with weight %d", status.ID, status.Weight)
+	}
+
+	// Weights 1 and 9 take effect on the next selection
+	if counts := served(100); counts["backend-2"] != 90 {
+		t.Errorf("Expected backend-2 to serve 90 of 100 requests after the update, got %v", counts)
+	}
+}
+
+func TestBackendsHandlerWeightUpdateUnderLoad(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+	otherServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer otherServer.Close()
+
+	// Run with -race: weighted strategies read weights while PATCHes change them
+	for _, name := range []string{"weighted-round-robin", "weighted-random", "weighted-least-connections", "health-score", "consistent-hash", "adaptive-weight"} {
+		t.Run(name, func(t *testing.T) {
+			cfg := &config.Config{
+				Port:                8000,
+				Backends:            []string{mockServer.URL, otherServer.URL},
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      30 * time.Second,
+				Strategy:            name,
+			}
+
+			lb, err := NewLoadBalancer(cfg)
+			if err != nil {
+				t.Fatalf("Load balancer creation failed: %v", err)
+			}
+			defer lb.Stop()
+			waitForHealthChecks(t, lb)
+
+			stop := make(chan struct{})
+			var wg sync.WaitGroup
+			for i := 0; i < 4; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for {
+						select {
+						case <-stop:
+							return
+						default:
+						}
+						recorder := httptest.NewRecorder()
+						lb.ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8000/api", nil))
+						if recorder.Code != http.StatusOK {
+							t.Errorf("Expected status %d, got %d", http.StatusOK, recorder.Code)
+							return
+						}
+					}
+				}()
+			}
+
+			for weight := 1; weight <= 20; weight++ {
+				recorder := httptest.NewRecorder()
+				body := strings.NewReader(fmt.Sprintf(`{"weight": %d}`, weight))
+				lb.BackendsHandler().ServeHTTP(recorder, httptest.NewRequest("PATCH", "http://localhost:8000/admin/backends/backend-2", body))
+				if recorder.Code != http.StatusOK {
+					t.Errorf("Expected status %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body.String())
+				}
+				time.Sleep(time.Millisecond)
+			}
+			close(stop)
+			wg.Wait()
+
+			if weight := lb.serverPool.GetBackendByID("backend-2").Weight(); weight != 20 {
+				t.Errorf("Expected weight 20 after the updates, got %d", weight)
+			}
+		})
+	}
+}
+
+func TestBackendsHandlerErrors(t *testing.T) {
+	cfg := &config.Config{
+		Port:                8000,
+		Backends:            []string{"http://localhost:8080"},
+		HealthCheckPath:     "/",
+		HealthCheckInterval: 10 * time.Second,
+		HealthCheckTimeout:  2 * time.Second,
+		BackendTimeout:      30 * time.Second,
+	}
+
+	lb, err := NewLoadBalancer(cfg)
+	if err != nil {
+		t.Fatalf("Load balancer creation failed: %v", err)
+	}
+	defer lb.Stop()
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           string
+		expectedStatus int
+	}{
+		{"Get backend", "GET", "/admin/backends/backend-1", "", http.StatusOK},
+		{"Unknown backend", "PATCH", "/admin/backends/backend-9", `{"weight": 2}`, http.StatusNotFound},
+		{"Missing ID", "GET", "/admin/backends/", "", http.StatusNotFound},
+		{"Zero weight", "PATCH", "/admin/backends/backend-1", `{"weight": 0}`, http.StatusBadRequest},
+		{"Negative weight", "PATCH", "/admin/backends/backend-1", `{"weight": -3}`, http.StatusBadRequest},
+		{"Weight above maximum", "PATCH", "/admin/backends/backend-1", `{"weight": 10000000}`, http.StatusBadRequest},
+		{"No weight", "PATCH", "/admin/backends/backend-1", `{}`, http.StatusBadRequest},
+		{"Invalid JSON", "PATCH", "/admin/backends/backend-1", `weight=2`, http.StatusBadRequest},
+		{"Unsupported method", "DELETE", "/admin/backends/backend-1", "", http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, "http://localhost:8000"+tt.path, strings.NewReader(tt.body))
+			lb.BackendsHandler().ServeHTTP(recorder, req)
+
+			if recorder.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, recorder.Code)
+			}
+		})
+	}
+
+	if weight := lb.serverPool.GetBackendByIndex(0).Weight(); weight != 1 {
+		t.Errorf("Expected rejected updates to leave weight 1, got %d", weight)
+	}
+}
+
+func TestLoadBalancerHealthChecksDisabled(t *testing.T) {
+	var probes atomic.Int64
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			probes.Add(1)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	cfg := &config.Config{
+		Port:                8000,
+		Backends:            []string{mockServer.URL, "http://127.0.0.1:1"},
+		HealthCheckPath:     "/health",
+		HealthCheckInterval: 10 * time.Millisecond,
+		HealthCheckTimeout:  5 * time.Millisecond,
+		BackendTimeout:      30 * time.Second,
+		DisableHealthChecks: true,
+	}
+
+	lb, err := NewLoadBalancer(cfg)
+	if err != nil {
+		t.Fatalf("Load balancer creation failed: %v", err)
+	}
+	defer lb.Stop()
+
+	// Backends are healthy from the start, including the unreachable one
+	for _, backend := range lb.GetBackends() {
+		if !backend.IsHealthy() {
+			t.Errorf("Expected %s to start healthy with health checks disabled", backend.ID)
+		}
+	}
+
+	// A failed request doesn't take the unreachable backend out of rotation
+	for i := 0; i < 4; i++ {
+		lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://localhost:8000/api", nil))
+	}
+	if !lb.serverPool.GetBackendByID("backend-2").IsHealthy() {
+		t.Errorf("Expected a failed request not to mark the backend unhealthy")
+	}
+
+	// Only the admin API changes a backend's health
+	patch := func(body string) int {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("PATCH", "http://localhost:8000/admin/backends/backend-2", strings.NewReader(body))
+		lb.BackendsHandler().ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+	if code := patch(`{"healthy": false}`); code != http.StatusOK {
+		t.Fatalf("Expected status %d marking the backend down, got %d", http.StatusOK, code)
+	}
+	for i := 0; i < 4; i++ {
+		recorder := httptest.NewRecorder()
+		lb.ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8000/api", nil))
+		if recorder.Code != http.StatusOK {
+			t.Errorf("Expected only the reachable backend to be used, got status %d", recorder.Code)
+		}
+	}
+	if code := patch(`{"healthy": true}`); code != http.StatusOK {
+		t.Fatalf("Expected status %d marking the backend up, got %d", http.StatusOK, code)
+	}
+	if !lb.serverPool.GetBackendByID("backend-2").IsHealthy() {
+		t.Errorf("Expected the backend to be healthy again")
+	}
+
+	// Several intervals pass without a single probe
+	time.Sleep(50 * time.Millisecond)
+	if got := probes.Load(); got != 0 {
+		t.Errorf("Expected no health probes with health checks disabled, got %d", got)
+	}
+}
+
+func TestBackendsHandlerHealthManagedByProbes(t *testing.T) {
+	cfg := &config.Config{
+		Port:                8000,
+		Backends:            []string{"http://localhost:8080"},
+		HealthCheckPath:     "/",
+		HealthCheckInterval: 10 * time.Second,
+		HealthCheckTimeout:  2 * time.Second,
+		BackendTimeout:      30 * time.Second,
+	}
+
+	lb, err := NewLoadBalancer(cfg)
+	if err != nil {
+		t.Fatalf("Load balancer creation failed: %v", err)
+	}
+	defer lb.Stop()
+
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest("PATCH", "http://localhost:8000/admin/backends/backend-1", strings.NewReader(`{"healthy": true}`))
+	lb.BackendsHandler().ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusConflict {
+		t.Errorf("Expected status %d while health checks run, got %d", http.StatusConflict, recorder.Code)
+	}
+}
+
+func TestRoutingHandler(t *testing.T) {
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer fast.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			w.WriteHeader(http.StatusOK) // Pass health checks
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	cfg := &config.Config{
+		Port:                8000,
+		Backends:            []string{fast.URL, failing.URL},
+		HealthCheckPath:     "/",
+		HealthCheckInterval: 10 * time.Second,
+		HealthCheckTimeout:  2 * time.Second,
+		BackendTimeout:      30 * time.Second,
+	}
+
+	lb, err := NewLoadBalancer(cfg)
+	if err != nil {
+		t.Fatalf("Load balancer creation failed: %v", err)
+	}
+	defer lb.Stop()
+	waitForHealthChecks(t, lb)
+
+	// Round robin sends half the traffic to each backend
+	for i := 0; i < 4; i++ {
+		lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://localhost:8000/api", nil))
+	}
+
+	recorder := httptest.NewRecorder()
+	lb.RoutingHandler().ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8000/admin/routing", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, recorder.Code)
+	}
+
+	var report struct {
+		Strategy string           `json:"strategy"`
+		Backends []backendRouting `json:"backends"`
+	}
+	if err := json.NewDecoder(recorder.Body).Decode(&report); err != nil {
+		t.Fatalf("Failed to decode routing report: %v", err)
+	}
+	if report.Strategy != "round-robin" {
+		t.Errorf("Expected strategy round-robin, got %q", report.Strategy)
+	}
+	if len(report.Backends) != 2 {
+		t.Fatalf("Expected 2 backends in the report, got %d", len(report.Backends))
+	}
+
+	healthy, broken := report.Backends[0], report.Backends[1]
+	for _, entry := range report.Backends {
+		if entry.Requests != 2 || entry.TrafficShare != 0.5 || entry.Weight != 1 {
+			t.Errorf("Expected %s to have 2 requests, half the traffic and weight 1, got %+v", entry.ID, entry)
+		}
+	}
+	if healthy.Failures != 0 || healthy.RecentErrorRate != 0 || healthy.MeanLatencyMs <= 0 || healthy.LatencyMs <= 0 {
+		t.Errorf("Expected the healthy backend to have latency and no errors, got %+v", healthy)
+	}
+	if healthy.AdaptiveWeight <= 0 {
+		t.Errorf("Expected the healthy backend to have an adaptive weight, got %+v", healthy)
+	}
+	if broken.Failures != 2 || broken.RecentErrorRate != 1 {
+		t.Errorf("Expected the failing backend to have 2 failures and an error rate of 1, got %+v", broken)
+	}
+	if broken.HealthScore >= healthy.HealthScore {
+		t.Errorf("Expected the failing backend to score below the healthy one, got %g and %g", broken.HealthScore, healthy.HealthScore)
+	}
+
+	recorder = httptest.NewRecorder()
+	lb.RoutingHandler().ServeHTTP(recorder, httptest.NewRequest("POST", "http://localhost:8000/admin/routing", nil))
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status %d for POST, got %d", http.StatusMethodNotAllowed, recorder.Code)
+	}
+}
+
+func TestConfigHandler(t *testing.T) {
+	cfg := &config.Config{
+		Port:                8000,
+		Backends:            []string{"http://localhost:8080"},
+		HealthCheckPath:     "/health",
+		HealthCheckInterval: 10 * time.Second,
+		HealthCheckTimeout:  2 * time.Second,
+		BackendTimeout:      30 * time.Second,
+		RequestHeaders:      map[string]string{"X-Api-Key": "super-secret"},
+	}
+
+	lb, err := NewLoadBalancer(cfg)
+	if err != nil {
+		t.Fatalf("Load balancer creation failed: %v", err)
+	}
+	defer lb.Stop()
+
+	recorder := httptest.NewRecorder()
+	lb.ConfigHandler().ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8000/admin/config", nil))
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, recorder.Code)
+	}
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Expected JSON body, got error: %v", err)
+	}
+
+	expected := map[string]interface{}{
+		"HealthCheckPath":     "/health",
+		"HealthCheckMethod":   "GET",         // resolved default
+		"Strategy":            "round-robin", // resolved default
+		"BackendTimeout":      "30s",
+		"HealthCheckInterval": "10s",
+	}
+	for field, want := range expected {
+		if body[field] != want {
+			t.Errorf("Expected %s=%v, got %v", field, want, body[field])
+		}
+	}
+
+	if strings.Contains(recorder.Body.String(), "super-secret") {
+		t.Errorf("Expected sensitive header value to be redacted, got: %s", recorder.Body.String())
+	}
+	if !strings.Contains(recorder.Body.String(), "X-Api-Key") {
+		t.Errorf("Expected redacted header name to remain visible")
+	}
+
+	recorder = httptest.NewRecorder()
+	lb.ConfigHandler().ServeHTTP(recorder, httptest.NewRequest("POST", "http://localhost:8000/admin/config", nil))
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status %d for POST, got %d", http.StatusMethodNotAllowed, recorder.Code)
+	}
+}
+
+func TestLoadBalancerFailureStatusCodes(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/throttled":
+			w.WriteHeader(http.StatusTooManyRequests)
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		case "/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer mockServer.Close()
+
+	tests := []struct {
+		name             string
+		failureCodes     []string
+		path             string
+		expectedFailures int64
+	}{
+		{"Default counts 5xx", nil, "/broken", 1},
+		{"Default ignores 429", nil, "/throttled", 0},
+		{"Custom counts 429", []string{"5xx", "429"}, "/throttled", 1},
+		{"Custom ignores unlisted 4xx", []string{"5xx", "429"}, "/missing", 0},
+		{"Custom excluding 5xx", []string{"429"}, "/broken", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				Port:                8000,
+				Backends:            []string{mockServer.URL},
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      30 * time.Second,
+				FailureStatusCodes:  tt.failureCodes,
+			}
+
+			lb, err := NewLoadBalancer(cfg)
+			if err != nil {
+				t.Fatalf("Load balancer creation failed: %v", err)
+			}
+			defer lb.Stop()
+			waitForHealthChecks(t, lb)
+
+			recorder := httptest.NewRecorder()
+			lb.ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8000"+tt.path, nil))
+
+			snapshot := lb.metrics.GetSnapshot()
+			if snapshot.FailedRequests != tt.expectedFailures {
+				t.Errorf("Expected %d failures, got %d", tt.expectedFailures, snapshot.FailedRequests)
+			}
+			if snapshot.TotalRequests != 1 {
+				t.Errorf("Expected 1 total request, got %d", snapshot.TotalRequests)
+			}
+		})
+	}
+}
+
+// fakeMetricsProvider records the metrics it was built with and serves a fixed body
+type fakeMetricsProvider struct {
+	metrics *metrics.Metrics
+}
+
+func (p *fakeMetricsProvider) Name() string { return "fake" }
+
+func (p *fakeMetricsProvider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintf(w, "requests %d\n", p.metrics.GetSnapshot().TotalRequests)
+}
+
+func TestNewLoadBalancerWithMetricsProvider(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	cfg := &config.Config{
+		Port:                8000,
+		Backends:            []string{mockServer.URL},
+		HealthCheckPath:     "/",
+		HealthCheckInterval: 10 * time.Second,
+		HealthCheckTimeout:  2 * time.Second,
+		BackendTimeout:      30 * time.Second,
+	}
+
+	var fake *fakeMetricsProvider
+	lb, err := NewLoadBalancerWithMetricsProvider(cfg, func(m *metrics.Metrics, _ metrics.ProviderOptions) metrics.MetricsProvider {
+		fake = &fakeMetricsProvider{metrics: m}
+		return fake
+	})
+	if err != nil {
+		t.Fatalf("Failed to create load balancer: %v", err)
+	}
+	defer lb.Stop()
+	waitForHealthChecks(t, lb)
+
+	provider := lb.GetMetricsProvider()
+	if provider != fake {
+		t.Fatalf("Expected the fake provider, got %T", provider)
+	}
+	if provider.Name() != "fake" {
+		t.Errorf("Expected provider name fake, got %q", provider.Name())
+	}
+
+	// The fake provider must report on the balancer's own metrics
+	lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	recorder := httptest.NewRecorder()
+	provider.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	if body := recorder.Body.String(); body != "requests 1\n" {
+		t.Errorf("Expected fake provider to report 1 request, got %q", body)
+	}
+}
+
+func TestNewLoadBalancerMetricsProviderName(t *testing.T) {
+	tests := []struct {
+		name         string
+		provider     string
+		expectedName string
+		expectError  bool
+	}{
+		{"Default", "", metrics.PrometheusProvider, false},
+		{"Prometheus", "prometheus", metrics.PrometheusProvider, false},
+		{"Unknown", "graphite", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				Port:                8000,
+				Backends:            []string{"http://localhost:8080"},
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      30 * time.Second,
+				MetricsProvider:     tt.provider,
+			}
+
+			lb, err := NewLoadBalancer(cfg)
+			if tt.expectError {
+				if err == nil {
+					lb.Stop()
+					t.Errorf("Expected error for metrics provider %q", tt.provider)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Failed to create load balancer: %v", err)
+			}
+			defer lb.Stop()
+
+			if name := lb.GetMetricsProvider().Name(); name != tt.expectedName {
+				t.Errorf("Expected provider %q, got %q", tt.expectedName, name)
+			}
+		})
+	}
+}
+
+func TestLoadBalancerActiveConnectionsGauge(t *testing.T) {
+	const inFlight = 3
+
+	release := make(chan struct{})
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Hold proxied requests open; health probes hit "/"
+		if r.URL.Path == "/hold" {
+			<-release
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	cfg := &config.Config{
+		Port:                8000,
+		Backends:            []string{mockServer.URL},
+		HealthCheckPath:     "/",
+		HealthCheckInterval: 10 * time.Second,
+		HealthCheckTimeout:  2 * time.Second,
+		BackendTimeout:      30 * time.Second,
+	}
+
+	lb, err := NewLoadBalancer(cfg)
+	if err != nil {
+		t.Fatalf("Load balancer creation failed: %v", err)
+	}
+	defer lb.Stop()
+	waitForHealthChecks(t, lb)
+
+	scrape := func() string {
+		recorder := httptest.NewRecorder()
+		lb.GetMetricsProvider().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+		return recorder.Body.String()
+	}
+	waitForGauge := func(expected int) {
+		t.Helper()
+		line := fmt.Sprintf(`go_balancer_backend_active_connections{backend="backend-1"} %d`, expected)
+		deadline := time.Now().Add(2 * time.Second)
+		for time.Now().Before(deadline) {
+			if strings.Contains(scrape(), line) {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("Expected metrics output to contain %q, got:\n%s", line, scrape())
+	}
+
+	done := make(chan struct{})
+	for i := 0; i < inFlight; i++ {
+		go func() {
+			lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/hold", nil))
+			done <- struct{}{}
+		}()
+	}
+
+	waitForGauge(inFlight)
+
+	close(release)
+	for i := 0; i < inFlight; i++ {
+		<-done
+	}
+
+	waitForGauge(0)
+}
+
+func TestLoadBalancerShutdownDrain(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Hold the in-flight request open; health probes hit "/"
+		if r.URL.Path == "/slow" {
+			started <- struct{}{}
+			<-release
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	cfg := &config.Config{
+		Port:                8000,
+		Backends:            []string{mockServer.URL},
+		HealthCheckPath:     "/",
+		HealthCheckInterval: 10 * time.Second,
+		HealthCheckTimeout:  2 * time.Second,
+		BackendTimeout:      30 * time.Second,
+	}
+
+	lb, err := NewLoadBalancer(cfg)
+	if err != nil {
+		t.Fatalf("Load balancer creation failed: %v", err)
+	}
+	defer lb.Stop()
+	waitForHealthChecks(t, lb)
+
+	// Start a request before shutdown begins
+	inFlight := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		lb.ServeHTTP(inFlight, httptest.NewRequest("GET", "http://localhost:8000/slow", nil))
+		close(done)
+	}()
+	<-started
+
+	lb.BeginShutdown()
+	if !lb.IsDraining() {
+		t.Fatalf("Expected load balancer to be draining")
+	}
+
+	// New requests are rejected and told to close the connection
+	recorder := httptest.NewRecorder()
+	lb.ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8000/", nil))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d while draining, got %d", http.StatusServiceUnavailable, recorder.Code)
+	}
+	if got := recorder.Header().Get("Connection"); got != "close" {
+		t.Errorf("Expected Connection: close while draining, got %q", got)
+	}
+
+	// Readiness flips immediately; liveness is unaffected
+	readiness := httptest.NewRecorder()
+	lb.ReadinessHandler().ServeHTTP(readiness, httptest.NewRequest("GET", "/readyz", nil))
+	if readiness.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected readyz status %d while draining, got %d", http.StatusServiceUnavailable, readiness.Code)
+	}
+	liveness := httptest.NewRecorder()
+	lb.LivenessHandler().ServeHTTP(liveness, httptest.NewRequest("GET", "/livez", nil))
+	if liveness.Code != http.StatusOK {
+		t.Errorf("Expected livez status %d while draining, got %d", http.StatusOK, liveness.Code)
+	}
+
+	// The request that was already in flight still completes
+	close(release)
+	<-done
+	if inFlight.Code != http.StatusOK {
+		t.Errorf("Expected in-flight request to complete with %d, got %d", http.StatusOK, inFlight.Code)
+	}
+}
+
+func TestLoadBalancerRequestTimeout(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Stall proxied requests; health probes hit "/"
+		if r.URL.Path == "/slow" {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(2 * time.Second):
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	tests := []struct {
+		name             string
+		requestTimeout   time.Duration
+		expectedMessage  string
+		expectHealthy    bool
+		expectedFailures int64
+		expectedTimeouts int64
+	}{
+		{"Global bound cuts request off", 100 * time.Millisecond, "request timeout", true, 0, 1},
+		{"Backend timeout without global bound", 0, "backend timeout", false, 1, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				Port:                8000,
+				Backends:            []string{mockServer.URL},
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      100 * time.Millisecond,
+				RequestTimeout:      tt.requestTimeout,
+			}
+
+			lb, err := NewLoadBalancer(cfg)
+			if err != nil {
+				t.Fatalf("Load balancer creation failed: %v", err)
+			}
+			defer lb.Stop()
+			waitForHealthChecks(t, lb)
+
+			start := time.Now()
+			recorder := httptest.NewRecorder()
+			lb.ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8000/slow", nil))
+			elapsed := time.Since(start)
+
+			if recorder.Code != http.StatusGatewayTimeout {
+				t.Errorf("Expected status %d, got %d", http.StatusGatewayTimeout, recorder.Code)
+			}
+			if !strings.Contains(recorder.Body.String(), tt.expectedMessage) {
+				t.Errorf("Expected body to contain %q, got %q", tt.expectedMessage, recorder.Body.String())
+			}
+			if elapsed > time.Second {
+				t.Errorf("Expected request to be cut off near 100ms, took %s", elapsed)
+			}
+
+			// Only the backend's own timeout counts against its health
+			backend := lb.serverPool.GetBackendByIndex(0)
+			if backend.IsHealthy() != tt.expectHealthy {
+				t.Errorf("Expected backend healthy=%v, got %v", tt.expectHealthy, backend.IsHealthy())
+			}
+			snapshot := lb.metrics.GetSnapshot()
+			if snapshot.FailedRequests != tt.expectedFailures || snapshot.RequestTimeouts != tt.expectedTimeouts {
+				t.Errorf("Expected %d failures and %d request timeouts, got %d and %d",
+					tt.expectedFailures, tt.expectedTimeouts, snapshot.FailedRequests, snapshot.RequestTimeouts)
+			}
+		})
+	}
+}
+
+func TestLoadBalancerMethodTimeouts(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Proxied requests take longer than the global timeout; health probes hit "/"
+		if r.URL.Path == "/slow" {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(300 * time.Millisecond):
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	tests := []struct {
+		method         string
+		expectedStatus int
+	}{
+		{"POST", http.StatusOK},
+		{"PUT", http.StatusOK},
+		{"GET", http.StatusGatewayTimeout},
+		{"DELETE", http.StatusGatewayTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			cfg := &config.Config{
+				Port:                8000,
+				Backends:            []string{mockServer.URL},
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      100 * time.Millisecond,
+				MethodTimeouts: map[string]time.Duration{
+					"POST": 2 * time.Second,
+					"PUT":  2 * time.Second,
+				},
+			}
+
+			lb, err := NewLoadBalancer(cfg)
+			if err != nil {
+				t.Fatalf("Load balancer creation failed: %v", err)
+			}
+			defer lb.Stop()
+			waitForHealthChecks(t, lb)
+
+			recorder := httptest.NewRecorder()
+			lb.ServeHTTP(recorder, httptest.NewRequest(tt.method, "http://localhost:8000/slow", nil))
+
+			if recorder.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d for %s, got %d", tt.expectedStatus, tt.method, recorder.Code)
+			}
+		})
+	}
+}
+
+func TestLoadBalancerRateLimitResponse(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	tests := []struct {
+		name                string
+		rateLimit           float64
+		body                string
+		contentType         string
+		expectedBody        string
+		expectedContentType string
+		expectedRetryAfter  string
+	}{
+		{
+			name:                "Default response",
+			rateLimit:           1,
+			expectedBody:        "rate limit exceeded\n",
+			expectedContentType: "text/plain; charset=utf-8",
+			expectedRetryAfter:  "1",
+		},
+		{
+			name:                "Custom response",
+			rateLimit:           0.25,
+			body:                `{"error":"slow down"}`,
+			contentType:         "application/json",
+			expectedBody:        `{"error":"slow down"}`,
+			expectedContentType: "application/json",
+			expectedRetryAfter:  "4",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				Port:                         8000,
+				Backends:                     []string{mockServer.URL},
+				HealthCheckPath:              "/",
+				HealthCheckInterval:          10 * time.Second,
+				HealthCheckTimeout:           2 * time.Second,
+				BackendTimeout:               30 * time.Second,
+				RateLimit:                    tt.rateLimit,
+				RateLimitBurst:               1,
+				RateLimitResponseBody:        tt.body,
+				RateLimitResponseContentType: tt.contentType,
+			}
+
+			lb, err := NewLoadBalancer(cfg)
+			if err != nil {
+				t.Fatalf("Load balancer creation failed: %v", err)
+			}
+			defer lb.Stop()
+
+			time.Sleep(100 * time.Millisecond)
+
+			// The burst allows the first request through
+			recorder := httptest.NewRecorder()
+			lb.ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8000/api", nil))
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("Expected status %d, got %d", http.StatusOK, recorder.Code)
+			}
+
+			recorder = httptest.NewRecorder()
+			lb.ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8000/api", nil))
+
+			if recorder.Code != http.StatusTooManyRequests {
+				t.Errorf("Expected status %d, got %d", http.StatusTooManyRequests, recorder.Code)
+			}
+			if recorder.Body.String() != tt.expectedBody {
+				t.Errorf("Expected body %q, got %q", tt.expectedBody, recorder.Body.String())
+			}
+			if got := recorder.Header().Get("Content-Type"); got != tt.expectedContentType {
+				t.Errorf("Expected Content-Type %q, got %q", tt.expectedContentType, got)
+			}
+			if got := recorder.Header().Get("Retry-After"); got != tt.expectedRetryAfter {
+				t.Errorf("Expected Retry-After %q, got %q", tt.expectedRetryAfter, got)
+			}
+
+			// A different client has its own bucket
+			req := httptest.NewRequest("GET", "http://localhost:8000/api", nil)
+			req.RemoteAddr = "198.51.100.7:4321"
+			recorder = httptest.NewRecorder()
+			lb.ServeHTTP(recorder, req)
+			if recorder.Code != http.StatusOK {
+				t.Errorf("Expected other client to get status %d, got %d", http.StatusOK, recorder.Code)
+			}
+		})
+	}
+}
+
+func TestLoadBalancerConcurrencyLimit(t *testing.T) {
+	entered := make(chan struct{}, 10)
+	release := make(chan struct{})
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			entered <- struct{}{}
+			<-release
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	cfg := &config.Config{
+		Port:                  8000,
+		Backends:              []string{mockServer.URL},
+		HealthCheckPath:       "/",
+		HealthCheckInterval:   10 * time.Second,
+		HealthCheckTimeout:    2 * time.Second,
+		BackendTimeout:        30 * time.Second,
+		MaxConcurrentRequests: 2,
+		StaticResponses:       map[string]config.StaticResponse{"/ping": {Status: http.StatusOK, Body: "pong"}},
+	}
+
+	lb, err := NewLoadBalancer(cfg)
+	if err != nil {
+		t.Fatalf("Load balancer creation failed: %v", err)
+	}
+	defer lb.Stop()
+	waitForHealthChecks(t, lb)
+
+	// Requests answered without a backend must release their slot too
+	for i := 0; i < 5; i++ {
+		recorder := httptest.NewRecorder()
+		lb.ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8000/ping", nil))
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Expected static response status %d, got %d", http.StatusOK, recorder.Code)
+		}
+	}
+
+	// Saturate the limiter with two requests held by the backend
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://localhost:8000/slow", nil))
+		}()
+		<-entered
+	}
+
+	recorder := httptest.NewRecorder()
+	lb.ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8000/api", nil))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d when saturated, got %d", http.StatusServiceUnavailable, recorder.Code)
+	}
+	if got := lb.metrics.GetSnapshot().ConcurrencyRejections; got != 1 {
+		t.Errorf("Expected 1 concurrency rejection, got %d", got)
+	}
+
+	// Once the held requests finish, new requests are served again
+	close(release)
+	wg.Wait()
+
+	recorder = httptest.NewRecorder()
+	lb.ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8000/api", nil))
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Expected status %d after recovery, got %d", http.StatusOK, recorder.Code)
+	}
+}
+
+func TestLoadBalancerConcurrencyQueue(t *testing.T) {
+	entered := make(chan struct{}, 10)
+	release := make(chan struct{})
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			entered <- struct{}{}
+			<-release
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	tests := []struct {
+		name         string
+		queueTimeout time.Duration
+		releaseAfter time.Duration
+		expectedCode int
+	}{
+		{"Slot frees within the queue timeout", 2 * time.Second, 50 * time.Millisecond, http.StatusOK},
+		{"Queue timeout expires", 50 * time.Millisecond, 500 * time.Millisecond, http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				Port:                    8000,
+				Backends:                []string{mockServer.URL},
+				HealthCheckPath:         "/",
+				HealthCheckInterval:     10 * time.Second,
+				HealthCheckTimeout:      2 * time.Second,
+				BackendTimeout:          30 * time.Second,
+				MaxConcurrentRequests:   1,
+				ConcurrencyQueueTimeout: tt.queueTimeout,
+			}
+
+			lb, err := NewLoadBalancer(cfg)
+			if err != nil {
+				t.Fatalf("Load balancer creation failed: %v", err)
+			}
+			defer lb.Stop()
+			waitForHealthChecks(t, lb)
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://localhost:8000/slow", nil))
+			}()
+			<-entered
+
+			timer := time.AfterFunc(tt.releaseAfter, func() { release <- struct{}{} })
+			defer timer.Stop()
+
+			recorder := httptest.NewRecorder()
+			lb.ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8000/api", nil))
+			if recorder.Code != tt.expectedCode {
+				t.Errorf("Expected status %d, got %d", tt.expectedCode, recorder.Code)
+			}
+			<-done
+		})
+	}
+}
+
+func TestLoadBalancerRequestTimeoutWhileQueued(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	cfg := &config.Config{
+		Port:                    8000,
+		Backends:                []string{mockServer.URL},
+		HealthCheckPath:         "/",
+		HealthCheckInterval:     10 * time.Second,
+		HealthCheckTimeout:      2 * time.Second,
+		BackendTimeout:          100 * time.Millisecond,
+		RequestTimeout:          100 * time.Millisecond,
+		MaxConcurrentRequests:   1,
+		ConcurrencyQueueTimeout: 2 * time.Second,
+	}
+
+	lb, err := NewLoadBalancer(cfg)
+	if err != nil {
+		t.Fatalf("Load balancer creation failed: %v", err)
+	}
+	defer lb.Stop()
+	waitForHealthChecks(t, lb)
+
+	// Hold the only slot so the request has to queue
+	lb.requestSlots <- struct{}{}
+	defer func() { <-lb.requestSlots }()
+
+	start := time.Now()
+	recorder := httptest.NewRecorder()
+	lb.ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8000/api", nil))
+	elapsed := time.Since(start)
+
+	if recorder.Code != http.StatusGatewayTimeout {
+		t.Errorf("Expected status %d, got %d", http.StatusGatewayTimeout, recorder.Code)
+	}
+	if elapsed > time.Second {
+		t.Errorf("Expected queued request to be cut off near 100ms, took %s", elapsed)
+	}
+
+	snapshot := lb.metrics.GetSnapshot()
+	if snapshot.RequestTimeouts != 1 || snapshot.ConcurrencyRejections != 0 {
+		t.Errorf("Expected 1 request timeout and no concurrency rejections, got %d and %d",
+			snapshot.RequestTimeouts, snapshot.ConcurrencyRejections)
+	}
+}
+
+func TestLoadBalancerBackendConnectionQueue(t *testing.T) {
+	entered := make(chan struct{}, 10)
+	release := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			entered <- struct{}{}
+			<-release
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	first := httptest.NewServer(handler)
+	defer first.Close()
+	second := httptest.NewServer(handler)
+	defer second.Close()
+
+	tests := []struct {
+		name             string
+		queueTimeout     time.Duration
+		releaseAfter     time.Duration
+		expectedCode     int
+		expectedServed   int64
+		expectedTimeouts int64
+	}{
+		{"No queue", 0, 50 * time.Millisecond, http.StatusServiceUnavailable, 0, 0},
+		{"Slot frees within the queue timeout", 2 * time.Second, 50 * time.Millisecond, http.StatusOK, 1, 0},
+		{"Queue timeout expires", 50 * time.Millisecond, 500 * time.Millisecond, http.StatusServiceUnavailable, 0, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				Port:                  8000,
+				Backends:              []string{first.URL, second.URL},
+				HealthCheckPath:       "/",
+				HealthCheckInterval:   10 * time.Second,
+				HealthCheckTimeout:    2 * time.Second,
+				BackendTimeout:        30 * time.Second,
+				MaxBackendConnections: 1,
+				BackendQueueTimeout:   tt.queueTimeout,
+			}
+
+			lb, err := NewLoadBalancer(cfg)
+			if err != nil {
+				t.Fatalf("Load balancer creation failed: %v", err)
+			}
+			defer lb.Stop()
+			waitForHealthChecks(t, lb)
+
+			// Saturate both backends; the second request must skip the
+			// backend already at its limit
+			var wg sync.WaitGroup
+			for i := 0; i < 2; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://localhost:8000/slow", nil))
+				}()
+				<-entered
+			}
+			for _, backend := range lb.GetBackends() {
+				if !backend.Saturated() {
+					t.Fatalf("Expected %s to be at its connection limit", backend.ID)
+				}
+			}
+
+			time.AfterFunc(tt.releaseAfter, func() { release <- struct{}{} })
+
+			recorder := httptest.NewRecorder()
+			lb.ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8000/api", nil))
+			if recorder.Code != tt.expectedCode {
+				t.Errorf("Expected status %d, got %d", tt.expectedCode, recorder.Code)
+			}
+
+			snapshot := lb.metrics.GetSnapshot()
+			if snapshot.BackendQueueServed != tt.expectedServed {
+				t.Errorf("Expected %d queued requests served, got %d", tt.expectedServed, snapshot.BackendQueueServed)
+			}
+			if snapshot.BackendQueueTimeouts != tt.expectedTimeouts {
+				t.Errorf("Expected %d queue timeouts, got %d", tt.expectedTimeouts, snapshot.BackendQueueTimeouts)
+			}
+
+			// The delayed release frees one held request; free the other
+			release <- struct{}{}
+			wg.Wait()
+		})
+	}
+}
+
+func TestLoadBalancerBackendConnectionQueueWithBackups(t *testing.T) {
+	entered := make(chan struct{}, 1)
+	release := make(chan struct{})
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			entered <- struct{}{}
+			<-release
+		}
+		w.Write([]byte("primary"))
+	}))
+	defer primary.Close()
+	backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("backup"))
+	}))
+	defer backup.Close()
+
+	cfg := &config.Config{
+		Port:                  8000,
+		Backends:              []string{primary.URL},
+		BackupBackends:        []string{backup.URL},
+		HealthCheckPath:       "/",
+		HealthCheckInterval:   10 * time.Second,
+		HealthCheckTimeout:    2 * time.Second,
+		BackendTimeout:        30 * time.Second,
+		MaxBackendConnections: 1,
+		BackendQueueTimeout:   2 * time.Second,
+	}
+
+	lb, err := NewLoadBalancer(cfg)
+	if err != nil {
+		t.Fatalf("Load balancer creation failed: %v", err)
+	}
+	defer lb.Stop()
+	waitForHealthChecks(t, lb)
+
+	// Fill the primary's only slot
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://localhost:8000/slow", nil))
+	}()
+	<-entered
+	time.AfterFunc(50*time.Millisecond, func() { close(release) })
+
+	// A primary that is merely full is still up, so the request waits for
+	// it instead of failing over to the backup
+	recorder := httptest.NewRecorder()
+	lb.ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8000/api", nil))
+	if recorder.Code != http.StatusOK || recorder.Body.String() != "primary" {
+		t.Errorf("Expected the queued request to be served by the primary, got %d %q", recorder.Code, recorder.Body.String())
+	}
+	if snapshot := lb.metrics.GetSnapshot(); snapshot.BackendQueueServed != 1 {
+		t.Errorf("Expected 1 queued request served, got %d", snapshot.BackendQueueServed)
+	}
+	<-done
+}
+
+func TestLoadBalancerBackupBackends(t *testing.T) {
+	newNamedServer := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(name))
+		}))
+	}
+	primary1 := newNamedServer("primary1")
+	defer primary1.Close()
+	primary2 := newNamedServer("primary2")
+	defer primary2.Close()
+	backup := newNamedServer("backup")
+	defer backup.Close()
+
+	cfg := &config.Config{
+		Port:                8000,
+		Backends:            []string{primary1.URL, primary2.URL},
+		BackupBackends:      []string{backup.URL},
+		HealthCheckPath:     "/",
+		HealthCheckInterval: 10 * time.Second,
+		HealthCheckTimeout:  2 * time.Second,
+		BackendTimeout:      30 * time.Second,
+	}
+
+	lb, err := NewLoadBalancer(cfg)
+	if err != nil {
+		t.Fatalf("Load balancer creation failed: %v", err)
+	}
+	defer lb.Stop()
+
+	time.Sleep(100 * time.Millisecond)
+
+	// responders returns which servers answered a handful of requests
+	responders := func() map[string]int {
+		seen := make(map[string]int)
+		for i := 0; i < 6; i++ {
+			recorder := httptest.NewRecorder()
+			lb.ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8000/api", nil))
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("Expected status %d, got %d", http.StatusOK, recorder.Code)
+			}
+			seen[recorder.Body.String()]++
+		}
+		return seen
+	}
+
+	var primaryIDs []string
+	for _, backend := range lb.GetBackends() {
+		if !backend.Backup {
+			primaryIDs = append(primaryIDs, backend.ID)
+		}
+	}
+	if len(primaryIDs) != 2 {
+		t.Fatalf("Expected 2 primary backends, got %d", len(primaryIDs))
+	}
+
+	if seen := responders(); seen["backup"] != 0 || seen["primary1"] == 0 || seen["primary2"] == 0 {
+		t.Errorf("Expected only primaries to serve while healthy, got %v", seen)
+	}
+
+	// One primary down: the other still takes everything
+	lb.serverPool.SetBackendHealth(primaryIDs[0], false)
+	if seen := responders(); seen["primary2"] != 6 {
+		t.Errorf("Expected remaining primary to serve all requests, got %v", seen)
+	}
+
+	// All primaries down: fail over to the backup
+	lb.serverPool.SetBackendHealth(primaryIDs[1], false)
+	if seen := responders(); seen["backup"] != 6 {
+		t.Errorf("Expected backup to serve all requests with no primaries, got %v", seen)
+	}
+
+	// A primary recovers: the backup stops receiving traffic
+	lb.serverPool.SetBackendHealth(primaryIDs[0], true)
+	if seen := responders(); seen["primary1"] != 6 {
+		t.Errorf("Expected recovered primary to serve all requests, got %v", seen)
+	}
+}
+
+func TestLoadBalancerAccessRules(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	cfg := &config.Config{
+		Port:                8000,
+		Backends:            []string{mockServer.URL},
+		HealthCheckPath:     "/",
+		HealthCheckInterval: 10 * time.Second,
+		HealthCheckTimeout:  2 * time.Second,
+		BackendTimeout:      30 * time.Second,
+		AllowedMethods:      []string{"GET", "POST"},
+		DeniedPaths:         []string{"/admin", `regex:\.php$`},
+	}
+
+	lb, err := NewLoadBalancer(cfg)
+	if err != nil {
+		t.Fatalf("Load balancer creation failed: %v", err)
+	}
+	defer lb.Stop()
+
+	time.Sleep(100 * time.Millisecond)
+
+	tests := []struct {
+		method       string
+		path         string
+		expectedCode int
+	}{
+		{"GET", "/api/users", http.StatusOK},
+		{"POST", "/api/users", http.StatusOK},
+		{"TRACE", "/api/users", http.StatusMethodNotAllowed},
+		{"DELETE", "/api/users", http.StatusMethodNotAllowed},
+		{"GET", "/admin", http.StatusForbidden},
+		{"GET", "/admin/settings", http.StatusForbidden},
+		{"GET", "/api/../admin", http.StatusForbidden},
+		{"GET", "/wp-login.php", http.StatusForbidden},
+		{"GET", "/php/info", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "http://localhost:8000/", nil)
+			req.URL.Path = tt.path
+			recorder := httptest.NewRecorder()
+
+			lb.ServeHTTP(recorder, req)
+
+			if recorder.Code != tt.expectedCode {
+				t.Errorf("Expected status %d, got %d", tt.expectedCode, recorder.Code)
+			}
+			if tt.expectedCode == http.StatusMethodNotAllowed {
+				if allow := recorder.Header().Get("Allow"); allow != "GET, POST" {
+					t.Errorf("Expected Allow header %q, got %q", "GET, POST", allow)
+				}
+			}
+		})
+	}
+}
+
+// connectThrough sends a CONNECT request for target through the balancer at
+// addr, returning the connection and the balancer's response
+func connectThrough(t *testing.T, addr, target string) (net.Conn, *bufio.Reader, *http.Response) {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Failed to dial balancer: %v", err)
+	}
+	fmt.Fprintf(conn, "CONNECT %s HTTP/1.1\r\nHost: %s\r\n\r\n", target, target)
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, &http.Request{Method: http.MethodConnect})
+	if err != nil {
+		conn.Close()
+		t.Fatalf("Failed to read CONNECT response: %v", err)
+	}
+	return conn, reader, resp
+}
+
+func TestLoadBalancerConnectTunnel(t *testing.T) {
+	// A plain TCP echo server stands in for the tunnel target
+	echo, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer echo.Close()
+	go func() {
+		for {
+			conn, err := echo.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	// An allowed target nothing listens on
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	unreachable := closed.Addr().String()
+	closed.Close()
+
+	var backendHits atomic.Int64
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			backendHits.Add(1)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	cfg := &config.Config{
+		Port:                  8000,
+		Backends:              []string{backend.URL},
+		HealthCheckPath:       "/",
+		HealthCheckInterval:   10 * time.Second,
+		HealthCheckTimeout:    2 * time.Second,
+		BackendTimeout:        30 * time.Second,
+		ConnectTunnels:        true,
+		ConnectAllowedTargets: []string{echo.Addr().String(), unreachable},
+	}
+
+	lb, err := NewLoadBalancer(cfg)
+	if err != nil {
+		t.Fatalf("Load balancer creation failed: %v", err)
+	}
+	defer lb.Stop()
+	waitForHealthChecks(t, lb)
+
+	server := httptest.NewServer(lb)
+	defer server.Close()
+	addr := server.Listener.Addr().String()
+
+	t.Run("Allowed target", func(t *testing.T) {
+		conn, reader, resp := connectThrough(t, addr, echo.Addr().String())
+		defer conn.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, resp.StatusCode)
+		}
+
+		conn.SetDeadline(time.Now().Add(2 * time.Second))
+		if _, err := io.WriteString(conn, "ping"); err != nil {
+			t.Fatalf("Failed to write through tunnel: %v", err)
+		}
+		reply := make([]byte, 4)
+		if _, err := io.ReadFull(reader, reply); err != nil {
+			t.Fatalf("Failed to read through tunnel: %v", err)
+		}
+		if string(reply) != "ping" {
+			t.Errorf("Expected echoed %q, got %q", "ping", reply)
+		}
+	})
+
+	t.Run("Target not allowed", func(t *testing.T) {
+		_, port, _ := net.SplitHostPort(echo.Addr().String())
+		conn, _, resp := connectThrough(t, addr, "localhost:"+port)
+		defer conn.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("Expected status %d, got %d", http.StatusForbidden, resp.StatusCode)
+		}
+	})
+
+	t.Run("Unreachable target", func(t *testing.T) {
+		conn, _, resp := connectThrough(t, addr, unreachable)
+		defer conn.Close()
+		if resp.StatusCode != http.StatusBadGateway {
+			t.Errorf("Expected status %d, got %d", http.StatusBadGateway, resp.StatusCode)
+		}
+	})
+
+	if backendHits.Load() != 0 {
+		t.Errorf("Expected tunnels to bypass the backends, got %d backend requests", backendHits.Load())
+	}
+}
+
+func TestLoadBalancerAllowedClientCIDRs(t *testing.T) {
+	var proxied atomic.Int64
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api" {
+			proxied.Add(1)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	cfg := &config.Config{
+		Port:                8000,
+		Backends:            []string{mockServer.URL},
+		HealthCheckPath:     "/",
+		HealthCheckInterval: 10 * time.Second,
+		HealthCheckTimeout:  2 * time.Second,
+		BackendTimeout:      30 * time.Second,
+		TrustedProxies:      []string{"172.16.0.1"},
+		AllowedClientCIDRs:  []string{"10.0.0.0/8", "192.168.1.7"},
+	}
+
+	lb, err := NewLoadBalancer(cfg)
+	if err != nil {
+		t.Fatalf("Load balancer creation failed: %v", err)
+	}
+	defer lb.Stop()
+	waitForHealthChecks(t, lb)
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		xff          string
+		expectedCode int
+	}{
+		{"Client in range", "10.1.2.3:1234", "", http.StatusOK},
+		{"Allowed single IP", "192.168.1.7:1234", "", http.StatusOK},
+		{"Client out of range", "203.0.113.5:1234", "", http.StatusForbidden},
+		{"Spoofed XFF from untrusted peer", "203.0.113.5:1234", "10.1.2.3", http.StatusForbidden},
+		{"In-range client via trusted proxy", "172.16.0.1:1234", "10.1.2.3", http.StatusOK},
+		{"Out-of-range client via trusted proxy", "172.16.0.1:1234", "203.0.113.5", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := proxied.Load()
+
+			req := httptest.NewRequest("GET", "http://localhost:8000/api", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.xff != "" {
+				req.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			recorder := httptest.NewRecorder()
+			lb.ServeHTTP(recorder, req)
+
+			if recorder.Code != tt.expectedCode {
+				t.Errorf("Expected status %d, got %d", tt.expectedCode, recorder.Code)
+			}
+			if forwarded := proxied.Load() - before; tt.expectedCode == http.StatusForbidden && forwarded != 0 {
+				t.Errorf("Expected a rejected client not to reach the backend, got %d requests", forwarded)
+			}
+		})
+	}
+}
+
+func TestLoadBalancerMirroring(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("from primary"))
+	}))
+	defer primary.Close()
+
+	type mirrored struct {
+		method, path, query, body, requestID string
+	}
+	received := make(chan mirrored, 1)
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- mirrored{r.Method, r.URL.Path, r.URL.RawQuery, string(body), r.Header.Get("X-Request-ID")}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("from shadow"))
+	}))
+	defer shadow.Close()
+
+	cfg := &config.Config{
+		Port:                8000,
+		Backends:            []string{primary.URL},
+		ShadowBackend:       shadow.URL,
+		HealthCheckPath:     "/",
+		HealthCheckInterval: 10 * time.Second,
+		HealthCheckTimeout:  2 * time.Second,
+		BackendTimeout:      30 * time.Second,
+	}
+
+	lb, err := NewLoadBalancer(cfg)
+	if err != nil {
+		t.Fatalf("Load balancer creation failed: %v", err)
+	}
+	defer lb.Stop()
+
+	time.Sleep(100 * time.Millisecond)
+
+	req := httptest.NewRequest("POST", "http://localhost:8000/api/orders?id=7", strings.NewReader(`{"item":"book"}`))
+	req.Header.Set("X-Request-ID", "mirror-test")
+	recorder := httptest.NewRecorder()
+	lb.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusOK || recorder.Body.String() != "from primary" {
+		t.Errorf("Expected primary response, got %d %q", recorder.Code, recorder.Body.String())
+	}
+
+	select {
+	case m := <-received:
+		expected := mirrored{"POST", "/api/orders", "id=7", `{"item":"book"}`, "mirror-test"}
+		if m != expected {
+			t.Errorf("Expected shadow to receive %+v, got %+v", expected, m)
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("Expected shadow to receive the mirrored request")
+	}
+}
+
+func TestLoadBalancerMirroringShadowFailures(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+		w.Write(body)
+	}))
+	defer primary.Close()
+
+	release := make(chan struct{})
+	slowShadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer slowShadow.Close()
+	defer close(release)
+
+	// Nothing listens on a closed server's address
+	deadShadow := httptest.NewServer(http.NotFoundHandler())
+	deadShadow.Close()
+
+	for name, shadowURL := range map[string]string{"slow shadow": slowShadow.URL, "unreachable shadow": deadShadow.URL} {
+		t.Run(name, func(t *testing.T) {
+			cfg := &config.Config{
+				Port:                8000,
+				Backends:            []string{primary.URL},
+				ShadowBackend:       shadowURL,
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      30 * time.Second,
+			}
+
+			lb, err := NewLoadBalancer(cfg)
+			if err != nil {
+				t.Fatalf("Load balancer creation failed: %v", err)
+			}
+			defer lb.Stop()
+
+			time.Sleep(100 * time.Millisecond)
+
+			start := time.Now()
+			req := httptest.NewRequest("POST", "http://localhost:8000/api/echo", strings.NewReader("payload"))
+			recorder := httptest.NewRecorder()
+			lb.ServeHTTP(recorder, req)
+			elapsed := time.Since(start)
+
+			if recorder.Code != http.StatusOK || recorder.Body.String() != "payload" {
+				t.Errorf("Expected primary to echo the body, got %d %q", recorder.Code, recorder.Body.String())
+			}
+			if elapsed > 500*time.Millisecond {
+				t.Errorf("Expected the shadow not to delay the client, took %s", elapsed)
+			}
+		})
+	}
+}
+
+func TestLoadBalancerColdStart(t *testing.T) {
+	// Hold the first health probe until the test has made its early request
+	probeGate := make(chan struct{})
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			<-probeGate
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	cfg := &config.Config{
+		Port:                8000,
+		Backends:            []string{mockServer.URL},
+		HealthCheckPath:     "/",
+		HealthCheckInterval: 10 * time.Second,
+		HealthCheckTimeout:  2 * time.Second,
+		BackendTimeout:      30 * time.Second,
+	}
+
+	lb, err := NewLoadBalancer(cfg)
+	if err != nil {
+		t.Fatalf("Load balancer creation failed: %v", err)
+	}
+	defer lb.Stop()
+
+	// Before the first probe completes the backend is unverified
+	recorder := httptest.NewRecorder()
+	lb.ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8000/api", nil))
+
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d during cold start, got %d", http.StatusServiceUnavailable, recorder.Code)
+	}
+	if retryAfter := recorder.Header().Get("Retry-After"); retryAfter != "2" {
+		t.Errorf("Expected Retry-After %q, got %q", "2", retryAfter)
+	}
+
+	close(probeGate)
+	waitForHealthChecks(t, lb)
+
+	recorder = httptest.NewRecorder()
+	lb.ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8000/api", nil))
+
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Expected status %d after the first probe, got %d", http.StatusOK, recorder.Code)
+	}
+	if retryAfter := recorder.Header().Get("Retry-After"); retryAfter != "" {
+		t.Errorf("Expected no Retry-After once warmed up, got %q", retryAfter)
+	}
+}
+
+func TestLoadBalancerStartupGrace(t *testing.T) {
+	// Reserve an address for a backend that only starts listening after the
+	// load balancer's first probe round has failed against it
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to reserve an address: %v", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	cfg := &config.Config{
+		Port:                    8000,
+		Backends:                []string{"http://" + address},
+		HealthCheckPath:         "/",
+		HealthCheckInterval:     10 * time.Second,
+		HealthCheckTimeout:      2 * time.Second,
+		BackendTimeout:          30 * time.Second,
+		HealthCheckStartupGrace: 5 * time.Second,
+	}
+
+	lb, err := NewLoadBalancer(cfg)
+	if err != nil {
+		t.Fatalf("Load balancer creation failed: %v", err)
+	}
+	defer lb.Stop()
+
+	slowStarter := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer slowStarter.Close()
+	startAt := time.Now().Add(300 * time.Millisecond)
+
+	// Until the backend comes up clients are told to retry shortly, and it
+	// joins the rotation long before the regular interval would re-probe it
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if slowStarter.URL == "" && time.Now().After(startAt) {
+			ln, err := net.Listen("tcp", address)
+			if err != nil {
+				t.Fatalf("Failed to listen on the reserved address: %v", err)
+			}
+			slowStarter.Listener.Close()
+			slowStarter.Listener = ln
+			slowStarter.Start()
+		}
+
+		recorder := httptest.NewRecorder()
+		lb.ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8000/api", nil))
+		if recorder.Code == http.StatusOK {
+			break
+		}
+		if recorder.Code != http.StatusServiceUnavailable || recorder.Header().Get("Retry-After") == "" {
+			t.Fatalf("Expected pending backend to give 503 with Retry-After, got %d", recorder.Code)
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected backend to be in rotation soon after it started")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if !lb.healthChecker.Initialized() {
+		t.Errorf("Expected health checker to be initialized once every backend passed")
+	}
+}
+
+func TestLoadBalancerHealthScoreStatus(t *testing.T) {
+	var failing atomic.Bool
+	flakyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" && failing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer flakyServer.Close()
+
+	steadyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer steadyServer.Close()
+
+	cfg := &config.Config{
+		Port:                8000,
+		Backends:            []string{flakyServer.URL, steadyServer.URL},
+		HealthCheckPath:     "/",
+		HealthCheckInterval: 10 * time.Second,
+		HealthCheckTimeout:  2 * time.Second,
+		BackendTimeout:      30 * time.Second,
+		Strategy:            "health-score",
+	}
+
+	lb, err := NewLoadBalancer(cfg)
+	if err != nil {
+		t.Fatalf("Load balancer creation failed: %v", err)
+	}
+	defer lb.Stop()
+	waitForHealthChecks(t, lb)
+
+	// Once its errors show up in its score the flaky backend should get far
+	// less than the even split it started with
+	failing.Store(true)
+	for i := 0; i < 200; i++ {
+		lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://localhost:8000/api", nil))
+	}
+	snapshot := lb.metrics.GetSnapshot()
+	if snapshot.FailedRequests == 0 {
+		t.Fatalf("Expected the flaky backend to receive some requests")
+	}
+	if snapshot.FailedRequests > 50 {
+		t.Errorf("Expected the flaky backend to get under 50 of 200 requests, got %d", snapshot.FailedRequests)
+	}
+
+	recorder := httptest.NewRecorder()
+	lb.StatusHandler().ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8000/status", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, recorder.Code)
+	}
+
+	var status struct {
+		Strategy string `json:"strategy"`
+		Backends []struct {
+			URL         string  `json:"url"`
+			Healthy     bool    `json:"healthy"`
+			HealthScore float64 `json:"health_score"`
+		} `json:"backends"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &status); err != nil {
+		t.Fatalf("Expected JSON body, got error: %v", err)
+	}
+	if status.Strategy != "health-score" {
+		t.Errorf("Expected strategy health-score, got %s", status.Strategy)
+	}
+	if len(status.Backends) != 2 {
+		t.Fatalf("Expected 2 backends, got %d", len(status.Backends))
+	}
+
+	scores := make(map[string]float64)
+	for _, backend := range status.Backends {
+		scores[backend.URL] = backend.HealthScore
+	}
+	if scores[flakyServer.URL] >= scores[steadyServer.URL] {
+		t.Errorf("Expected flaky backend to score below steady backend, got %.3f and %.3f",
+			scores[flakyServer.URL], scores[steadyServer.URL])
+	}
+
+	recorder = httptest.NewRecorder()
+	lb.StatusHandler().ServeHTTP(recorder, httptest.NewRequest("POST", "http://localhost:8000/status", nil))
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status %d for POST, got %d", http.StatusMethodNotAllowed, recorder.Code)
+	}
+}
+
+func TestLoadBalancerAdaptiveWeightStatus(t *testing.T) {
+	slowServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			time.Sleep(20 * time.Millisecond)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer slowServer.Close()
+
+	fastServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer fastServer.Close()
+
+	cfg := &config.Config{
+		Port:                8000,
+		Backends:            []string{slowServer.URL, fastServer.URL},
+		HealthCheckPath:     "/",
+		HealthCheckInterval: 10 * time.Second,
+		HealthCheckTimeout:  2 * time.Second,
+		BackendTimeout:      30 * time.Second,
+		Strategy:            "adaptive-weight",
+	}
+
+	lb, err := NewLoadBalancer(cfg)
+	if err != nil {
+		t.Fatalf("Load balancer creation failed: %v", err)
+	}
+	defer lb.Stop()
+	waitForHealthChecks(t, lb)
+
+	for i := 0; i < 60; i++ {
+		lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://localhost:8000/api", nil))
+	}
+
+	recorder := httptest.NewRecorder()
+	lb.StatusHandler().ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8000/status", nil))
+
+	var status struct {
+		Strategy string `json:"strategy"`
+		Backends []struct {
+			URL            string  `json:"url"`
+			AdaptiveWeight float64 `json:"adaptive_weight"`
+		} `json:"backends"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &status); err != nil {
+		t.Fatalf("Expected JSON body, got error: %v", err)
+	}
+	if status.Strategy != "adaptive-weight" {
+		t.Errorf("Expected strategy adaptive-weight, got %s", status.Strategy)
+	}
+
+	weights := make(map[string]float64)
+	for _, backend := range status.Backends {
+		weights[backend.URL] = backend.AdaptiveWeight
+	}
+	if weights[slowServer.URL] <= 0 {
+		t.Fatalf("Expected slow backend to have been measured, got adaptive weight %.1f", weights[slowServer.URL])
+	}
+	if weights[fastServer.URL] <= weights[slowServer.URL] {
+		t.Errorf("Expected fast backend to accrue a higher adaptive weight, got slow=%.1f fast=%.1f",
+			weights[slowServer.URL], weights[fastServer.URL])
+	}
+
+	// The fast backend's higher weight should have drawn most of the traffic
+	durations := lb.metrics.RequestDurations()
+	slow := durations[lb.serverPool.GetBackendByIndex(0).ID].Count
+	fast := durations[lb.serverPool.GetBackendByIndex(1).ID].Count
+	if fast <= slow {
+		t.Errorf("Expected fast backend to serve more requests, got slow=%d fast=%d", slow, fast)
+	}
+}
+
+func TestLoadBalancerDisableKeepAlive(t *testing.T) {
+	tests := []struct {
+		name              string
+		disableAll        bool
+		disableForBackend bool
+		expectedConns     int
+	}{
+		{"Keep-alive by default", false, false, 1},
+		{"Disabled globally", true, false, 5},
+		{"Disabled for backend", false, true, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Count the distinct client connections proxied requests arrive on
+			var mu sync.Mutex
+			conns := make(map[string]bool)
+			mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/api" {
+					mu.Lock()
+					conns[r.RemoteAddr] = true
+					mu.Unlock()
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer mockServer.Close()
+
+			cfg := &config.Config{
+				Port:                8000,
+				Backends:            []string{mockServer.URL},
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      30 * time.Second,
+				DisableKeepAlive:    tt.disableAll,
+			}
+			if tt.disableForBackend {
+				cfg.DisableKeepAliveBackends = []string{mockServer.URL}
+			}
+
+			lb, err := NewLoadBalancer(cfg)
+			if err != nil {
+				t.Fatalf("Load balancer creation failed: %v", err)
+			}
+			defer lb.Stop()
+			waitForHealthChecks(t, lb)
+
+			for i := 0; i < 5; i++ {
+				recorder := httptest.NewRecorder()
+				lb.ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8000/api", nil))
+				if recorder.Code != http.StatusOK {
+					t.Fatalf("Expected status %d, got %d", http.StatusOK, recorder.Code)
+				}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if len(conns) != tt.expectedConns {
+				t.Errorf("Expected %d backend connections, got %d", tt.expectedConns, len(conns))
+			}
+		})
+	}
+}
+
+func TestLoadBalancerHonorRetryAfter(t *testing.T) {
+	tests := []struct {
+		name          string
+		honor         bool
+		status        int
+		expectSkipped bool
+	}{
+		{"503 with Retry-After", true, http.StatusServiceUnavailable, true},
+		{"429 with Retry-After", true, http.StatusTooManyRequests, true},
+		{"Other status ignored", true, http.StatusInternalServerError, false},
+		{"Disabled", false, http.StatusServiceUnavailable, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The overloaded backend rejects its first proxied request only
+			var overloadedHits atomic.Int64
+			overloaded := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/api" && overloadedHits.Add(1) == 1 {
+					w.Header().Set("Retry-After", "1")
+					w.WriteHeader(tt.status)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer overloaded.Close()
+
+			steady := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer steady.Close()
+
+			cfg := &config.Config{
+				Port:                8000,
+				Backends:            []string{overloaded.URL, steady.URL},
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      30 * time.Second,
+				HonorRetryAfter:     tt.honor,
+			}
+
+			lb, err := NewLoadBalancer(cfg)
+			if err != nil {
+				t.Fatalf("Load balancer creation failed: %v", err)
+			}
+			defer lb.Stop()
+			waitForHealthChecks(t, lb)
+
+			// Round-robin sends the first request to the overloaded backend
+			lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://localhost:8000/api", nil))
+			if overloadedHits.Load() != 1 {
+				t.Fatalf("Expected the first request to reach the overloaded backend, got %d hits", overloadedHits.Load())
+			}
+
+			for i := 0; i < 4; i++ {
+				lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://localhost:8000/api", nil))
+			}
+			hits := overloadedHits.Load() - 1
+			if tt.expectSkipped && hits != 0 {
+				t.Errorf("Expected the backend to be skipped during Retry-After, got %d requests", hits)
+			}
+			if !tt.expectSkipped && hits == 0 {
+				t.Errorf("Expected the backend to keep receiving requests")
+			}
+			if !tt.expectSkipped {
+				return
+			}
+
+			// Once Retry-After elapses the backend is back in rotation
+			time.Sleep(1100 * time.Millisecond)
+			for i := 0; i < 4; i++ {
+				lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://localhost:8000/api", nil))
+			}
+			if overloadedHits.Load() == 1 {
+				t.Errorf("Expected the backend to receive requests after Retry-After elapsed")
+			}
+		})
+	}
+}
+
+func TestLoadBalancerErrorBudget(t *testing.T) {
+	var failing atomic.Bool
+	var flakyHits atomic.Int64
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api" {
+			flakyHits.Add(1)
+			if failing.Load() {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer flaky.Close()
+
+	steady := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer steady.Close()
+
+	window := 500 * time.Millisecond
+	cfg := &config.Config{
+		Port:                 8000,
+		Backends:             []string{flaky.URL, steady.URL},
+		HealthCheckPath:      "/",
+		HealthCheckInterval:  10 * time.Second,
+		HealthCheckTimeout:   2 * time.Second,
+		BackendTimeout:       30 * time.Second,
+		ErrorBudgetThreshold: 0.5,
+		ErrorBudgetWindow:    window,
+	}
+
+	lb, err := NewLoadBalancer(cfg)
+	if err != nil {
+		t.Fatalf("Load balancer creation failed: %v", err)
+	}
+	defer lb.Stop()
+	waitForHealthChecks(t, lb)
+
+	// Round-robin alternates, so the flaky backend fails every other request
+	// until it has served enough of them to be judged
+	failing.Store(true)
+	for i := 0; i < 2*pool.ErrorBudgetMinRequests; i++ {
+		lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://localhost:8000/api", nil))
+	}
+	if flakyHits.Load() != pool.ErrorBudgetMinRequests {
+		t.Fatalf("Expected the flaky backend to get %d requests, got %d", pool.ErrorBudgetMinRequests, flakyHits.Load())
+	}
+
+	// Past its budget, the flaky backend is skipped while it stays healthy
+	for i := 0; i < 10; i++ {
+		recorder := httptest.NewRecorder()
+		lb.ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8000/api", nil))
+		if recorder.Code != http.StatusOK {
+			t.Errorf("Expected status %d while over budget, got %d", http.StatusOK, recorder.Code)
+		}
+	}
+	if hits := flakyHits.Load() - pool.ErrorBudgetMinRequests; hits != 0 {
+		t.Errorf("Expected the backend over its error budget to be skipped, got %d requests", hits)
+	}
+
+	recorder := httptest.NewRecorder()
+	lb.StatusHandler().ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8000/status", nil))
+	var status struct {
+		Backends []struct {
+			URL             string  `json:"url"`
+			Healthy         bool    `json:"healthy"`
+			OverErrorBudget bool    `json:"over_error_budget"`
+			ErrorRate       float64 `json:"error_rate"`
+		} `json:"backends"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &status); err != nil {
+		t.Fatalf("Expected JSON body, got error: %v", err)
+	}
+	for _, backend := range status.Backends {
+		switch backend.URL {
+		case flaky.URL:
+			if !backend.Healthy || !backend.OverErrorBudget || backend.ErrorRate != 1 {
+				t.Errorf("Expected flaky backend healthy, over budget with error rate 1, got %+v", backend)
+			}
+		case steady.URL:
+			if backend.OverErrorBudget || backend.ErrorRate != 0 {
+				t.Errorf("Expected steady backend within budget with error rate 0, got %+v", backend)
+			}
+		}
+	}
+
+	// Once the failures age out of the window the backend is back in rotation
+	failing.Store(false)
+	time.Sleep(window + 100*time.Millisecond)
+	for i := 0; i < 4; i++ {
+		lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://localhost:8000/api", nil))
+	}
+	if flakyHits.Load() == pool.ErrorBudgetMinRequests {
+		t.Errorf("Expected the backend to receive requests after its failures aged out")
+	}
+}
+
+func TestLoadBalancerProxiesTrailers(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("body"))
+		w.Header().Set("Grpc-Status", "0")
+		w.Header().Set(http.TrailerPrefix+"Grpc-Message", "done")
+	}))
+	defer backend.Close()
+
+	for _, buffered := range []bool{false, true} {
+		t.Run(fmt.Sprintf("buffered=%v", buffered), func(t *testing.T) {
+			cfg := &config.Config{
+				Port:                8000,
+				Backends:            []string{backend.URL},
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      30 * time.Second,
+			}
+			if buffered {
+				cfg.ResponseBufferMaxBytes = 1 << 20
+			}
+
+			lb, err := NewLoadBalancer(cfg)
+			if err != nil {
+				t.Fatalf("Load balancer creation failed: %v", err)
+			}
+			defer lb.Stop()
+			waitForHealthChecks(t, lb)
+
+			server := httptest.NewServer(lb)
+			defer server.Close()
+
+			resp, err := http.Get(server.URL + "/")
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("Failed to read body: %v", err)
+			}
+			if string(body) != "body" {
+				t.Errorf("Expected body %q, got %q", "body", body)
+			}
+			if got := resp.Trailer.Get("Grpc-Status"); got != "0" {
+				t.Errorf("Expected announced trailer Grpc-Status: 0, got %q", got)
+			}
+			if got := resp.Trailer.Get("Grpc-Message"); got != "done" {
+				t.Errorf("Expected unannounced trailer Grpc-Message: done, got %q", got)
+			}
+		})
+	}
+}
+
+func TestLoadBalancerMaxResponseHeaderBytes(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/big" {
+			w.Header().Set("X-Large", strings.Repeat("a", 8<<10))
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	cfg := &config.Config{
+		Port:                   8000,
+		Backends:               []string{backend.URL},
+		HealthCheckPath:        "/",
+		HealthCheckInterval:    10 * time.Second,
+		HealthCheckTimeout:     2 * time.Second,
+		BackendTimeout:         30 * time.Second,
+		MaxResponseHeaderBytes: 4 << 10,
+	}
+
+	lb, err := NewLoadBalancer(cfg)
+	if err != nil {
+		t.Fatalf("Load balancer creation failed: %v", err)
+	}
+	defer lb.Stop()
+	waitForHealthChecks(t, lb)
+
+	recorder := httptest.NewRecorder()
+	lb.ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8000/big", nil))
+	if recorder.Code != http.StatusBadGateway {
+		t.Errorf("Expected status %d for oversized response headers, got %d", http.StatusBadGateway, recorder.Code)
+	}
+
+	// The backend stays in rotation for responses within the limit
+	if !lb.GetBackends()[0].IsHealthy() {
+		t.Errorf("Expected the backend to stay healthy after oversized response headers")
+	}
+	recorder = httptest.NewRecorder()
+	lb.ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8000/small", nil))
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Expected status %d for small response headers, got %d", http.StatusOK, recorder.Code)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value    string
+		expected time.Duration
+		ok       bool
+	}{
+		{"5", 5 * time.Second, true},
+		{" 0 ", 0, true},
+		{now.Add(30 * time.Second).Format(http.TimeFormat), 30 * time.Second, true},
+		{now.Add(-30 * time.Second).Format(http.TimeFormat), 0, false},
+		{"-1", 0, false},
+		{"soon", 0, false},
+		{"", 0, false},
+	}
+
+	for _, tt := range tests {
+		delay, ok := parseRetryAfter(tt.value, now)
+		if ok != tt.ok || delay != tt.expected {
+			t.Errorf("parseRetryAfter(%q): expected (%s, %v), got (%s, %v)", tt.value, tt.expected, tt.ok, delay, ok)
+		}
+	}
+}
+
+func TestLoadBalancerCanaryPercentage(t *testing.T) {
+	stable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer stable.Close()
+
+	canary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer canary.Close()
+
+	tests := []struct {
+		name    string
+		percent float64
+	}{
+		{"No sampled traffic", 0},
+		{"Ten percent", 10},
+		{"Half", 50},
+		{"All traffic", 100},
+	}
+
+	const iterations = 20000
+	const tolerance = 0.02
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				Port:                8000,
+				Backends:            []string{stable.URL},
+				CanaryBackend:       canary.URL,
+				CanaryPercent:       tt.percent,
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      30 * time.Second,
+			}
+
+			lb, err := NewLoadBalancer(cfg)
+			if err != nil {
+				t.Fatalf("Load balancer creation failed: %v", err)
+			}
+			defer lb.Stop()
+			waitForHealthChecks(t, lb)
+
+			req := httptest.NewRequest("GET", "http://localhost:8000/api", nil)
+			canaryCount := 0
+			for i := 0; i < iterations; i++ {
+				backend, err := lb.getNextHealthyBackend(req)
+				if err != nil {
+					t.Fatalf("Expected a backend, got error: %v", err)
+				}
+				if backend.URL.String() == canary.URL {
+					canaryCount++
+				}
+			}
+
+			share := float64(canaryCount) / iterations
+			if math.Abs(share-tt.percent/100) > tolerance {
+				t.Errorf("Expected canary share %.2f, got %.3f", tt.percent/100, share)
+			}
+		})
+	}
+}
+
+func TestLoadBalancerCanaryMatch(t *testing.T) {
+	var stableHits, canaryHits atomic.Int64
+	stable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api" {
+			stableHits.Add(1)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer stable.Close()
+
+	canary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api" {
+			canaryHits.Add(1)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer canary.Close()
+
+	cfg := &config.Config{
+		Port:                8000,
+		Backends:            []string{stable.URL},
+		CanaryBackend:       canary.URL,
+		CanaryMatch:         "header:X-Canary=always",
+		HealthCheckPath:     "/",
+		HealthCheckInterval: 10 * time.Second,
+		HealthCheckTimeout:  2 * time.Second,
+		BackendTimeout:      30 * time.Second,
+	}
+
+	lb, err := NewLoadBalancer(cfg)
+	if err != nil {
+		t.Fatalf("Load balancer creation failed: %v", err)
+	}
+	defer lb.Stop()
+	waitForHealthChecks(t, lb)
+
+	send := func(headerValue string) {
+		req := httptest.NewRequest("GET", "http://localhost:8000/api", nil)
+		if headerValue != "" {
+			req.Header.Set("X-Canary", headerValue)
+		}
+		recorder := httptest.NewRecorder()
+		lb.ServeHTTP(recorder, req)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, recorder.Code)
+		}
+	}
+
+	// Matching requests always reach the canary
+	for i := 0; i < 5; i++ {
+		send("always")
+	}
+	if canaryHits.Load() != 5 || stableHits.Load() != 0 {
+		t.Errorf("Expected 5 canary and 0 stable requests, got %d and %d", canaryHits.Load(), stableHits.Load())
+	}
+
+	// Everything else stays on the stable backends
+	for i := 0; i < 5; i++ {
+		send("")
+		send("never")
+	}
+	if canaryHits.Load() != 5 || stableHits.Load() != 10 {
+		t.Errorf("Expected 5 canary and 10 stable requests, got %d and %d", canaryHits.Load(), stableHits.Load())
+	}
+
+	// An unavailable canary's matching traffic falls back to the stable backends
+	lb.canary.BackOff(time.Now().Add(time.Minute))
+	send("always")
+	if canaryHits.Load() != 5 || stableHits.Load() != 11 {
+		t.Errorf("Expected fallback to stable backend, got %d canary and %d stable requests", canaryHits.Load(), stableHits.Load())
+	}
+}
+
+// readAccessLog parses every line of a JSON access log
+func readAccessLog(t *testing.T, path string) []accessLogEntry {
+	t.Helper()
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read access log: %v", err)
+	}
+
+	var entries []accessLogEntry
+	for _, line := range strings.Split(strings.TrimSpace(string(contents)), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry accessLogEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Expected JSON access log line, got %q: %v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestLoadBalancerTrafficSplit(t *testing.T) {
+	var blue, green []string
+	for i := 0; i < 2; i++ {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+		blue = append(blue, server.URL)
+	}
+	for i := 0; i < 2; i++ {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+		green = append(green, server.URL)
+	}
+
+	tests := []struct {
+		name    string
+		percent float64
+	}{
+		{"All blue", 0},
+		{"90/10", 10},
+		{"50/50", 50},
+		{"All green", 100},
+	}
+
+	const iterations = 20000
+	const tolerance = 0.02
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				Port:                8000,
+				Backends:            blue,
+				GreenBackends:       green,
+				GreenPercent:        tt.percent,
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      30 * time.Second,
+			}
+
+			lb, err := NewLoadBalancer(cfg)
+			if err != nil {
+				t.Fatalf("Load balancer creation failed: %v", err)
+			}
+			defer lb.Stop()
+			waitForHealthChecks(t, lb)
+
+			share := greenShare(t, lb, green, iterations)
+			if math.Abs(share-tt.percent/100) > tolerance {
+				t.Errorf("Expected green share %.2f, got %.3f", tt.percent/100, share)
+			}
+		})
+	}
+}
+
+func TestTrafficSplitHandler(t *testing.T) {
+	blue := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer blue.Close()
+
+	green := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer green.Close()
+
+	cfg := &config.Config{
+		Port:                8000,
+		Backends:            []string{blue.URL},
+		GreenBackends:       []string{green.URL},
+		GreenPercent:        10,
+		HealthCheckPath:     "/",
+		HealthCheckInterval: 10 * time.Second,
+		HealthCheckTimeout:  2 * time.Second,
+		BackendTimeout:      30 * time.Second,
+	}
+
+	lb, err := NewLoadBalancer(cfg)
+	if err != nil {
+		t.Fatalf("Load balancer creation failed: %v", err)
+	}
+	defer lb.Stop()
+	waitForHealthChecks(t, lb)
+
+	const iterations = 20000
+	const tolerance = 0.02
+
+	if share := greenShare(t, lb, []string{green.URL}, iterations); math.Abs(share-0.1) > tolerance {
+		t.Fatalf("Expected green share 0.10 before the update, got %.3f", share)
+	}
+
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest("PUT", "http://localhost:8000/admin/traffic-split", strings.NewReader(`{"green_percent": 60}`))
+	lb.TrafficSplitHandler().ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body.String())
+	}
+	var split struct {
+		Blue  float64 `json:"blue_percent"`
+		Green float64 `json:"green_percent"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &split); err != nil {
+		t.Fatalf("Expected JSON body, got error: %v", err)
+	}
+	if split.Blue != 40 || split.Green != 60 {
+		t.Errorf("Expected a 40/60 split, got %g/%g", split.Blue, split.Green)
+	}
+
+	// The new ratio applies to the next selection
+	if share := greenShare(t, lb, []string{green.URL}, iterations); math.Abs(share-0.6) > tolerance {
+		t.Errorf("Expected green share 0.60 after the update, got %.3f", share)
+	}
+
+	// Invalid updates are rejected and leave the split alone
+	for _, body := range []string{`{"green_percent": 150}`, `{"green_percent": -1}`, `{}`, `not json`} {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("PUT", "http://localhost:8000/admin/traffic-split", strings.NewReader(body))
+		lb.TrafficSplitHandler().ServeHTTP(recorder, req)
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d for %s, got %d", http.StatusBadRequest, body, recorder.Code)
+		}
+	}
+	if percent := lb.split.GreenPercent(); percent != 60 {
+		t.Errorf("Expected green percent 60 after rejected updates, got %g", percent)
+	}
+}
+
+// greenShare returns the fraction of n selections that went to the green backends
+func greenShare(t *testing.T, lb *LoadBalancer, green []string, n int) float64 {
+	t.Helper()
+
+	req := httptest.NewRequest("GET", "http://localhost:8000/api", nil)
+	greenCount := 0
+	for i := 0; i < n; i++ {
+		backend, err := lb.getNextHealthyBackend(req)
+		if err != nil {
+			t.Fatalf("Expected a backend, got error: %v", err)
+		}
+		for _, u := range green {
+			if backend.URL.String() == u {
+				greenCount++
+			}
+		}
+	}
+	return float64(greenCount) / float64(n)
+}
+
+func TestLoadBalancerAccessLogSampling(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "/slow":
+			time.Sleep(50 * time.Millisecond)
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.Write([]byte("hello"))
+		}
+	}))
+	defer mockServer.Close()
+
+	tests := []struct {
+		name            string
+		sampleRate      int
+		path            string
+		requests        int
+		expectedEntries int
+	}{
+		{"Every request by default", 0, "/api", 10, 10},
+		{"One in five", 5, "/api", 100, 20},
+		{"Errors bypass sampling", 100, "/broken", 10, 10},
+		{"Slow requests bypass sampling", 100, "/slow", 3, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logPath := filepath.Join(t.TempDir(), "access.log")
+			cfg := &config.Config{
+				Port:                   8000,
+				Backends:               []string{mockServer.URL},
+				HealthCheckPath:        "/",
+				HealthCheckInterval:    10 * time.Second,
+				HealthCheckTimeout:     2 * time.Second,
+				BackendTimeout:         30 * time.Second,
+				AccessLog:              logPath,
+				AccessLogSampleRate:    tt.sampleRate,
+				AccessLogSlowThreshold: 40 * time.Millisecond,
+			}
+
+			lb, err := NewLoadBalancer(cfg)
+			if err != nil {
+				t.Fatalf("Load balancer creation failed: %v", err)
+			}
+			defer lb.Stop()
+			waitForHealthChecks(t, lb)
+
+			for i := 0; i < tt.requests; i++ {
+				lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://localhost:8000"+tt.path, nil))
+			}
+
+			entries := readAccessLog(t, logPath)
+			if len(entries) != tt.expectedEntries {
+				t.Errorf("Expected %d access log entries, got %d", tt.expectedEntries, len(entries))
+			}
+			for _, entry := range entries {
+				if entry.Path != tt.path || entry.Method != "GET" {
+					t.Errorf("Expected GET %s entry, got %s %s", tt.path, entry.Method, entry.Path)
+				}
+				if entry.Backend == "" || entry.RequestID == "" {
+					t.Errorf("Expected backend and request ID in entry, got %+v", entry)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadBalancerAccessLogEntry(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	defer mockServer.Close()
+
+	logPath := filepath.Join(t.TempDir(), "access.log")
+	cfg := &config.Config{
+		Port:                8000,
+		Backends:            []string{mockServer.URL},
+		HealthCheckPath:     "/",
+		HealthCheckInterval: 10 * time.Second,
+		HealthCheckTimeout:  2 * time.Second,
+		BackendTimeout:      30 * time.Second,
+		AccessLog:           logPath,
+		DeniedPaths:         []string{"/admin"},
+	}
+
+	lb, err := NewLoadBalancer(cfg)
+	if err != nil {
+		t.Fatalf("Load balancer creation failed: %v", err)
+	}
+	defer lb.Stop()
+	waitForHealthChecks(t, lb)
+
+	req := httptest.NewRequest("POST", "http://localhost:8000/api", nil)
+	req.Header.Set("X-Request-ID", "req-123")
+	lb.ServeHTTP(httptest.NewRecorder(), req)
+	lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://localhost:8000/admin", nil))
+
+	entries := readAccessLog(t, logPath)
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 access log entries, got %d", len(entries))
+	}
+
+	proxied := entries[0]
+	if proxied.Method != "POST" || proxied.Path != "/api" || proxied.Status != http.StatusOK {
+		t.Errorf("Expected POST /api 200, got %s %s %d", proxied.Method, proxied.Path, proxied.Status)
+	}
+	if proxied.Bytes != int64(len("hello")) {
+		t.Errorf("Expected %d bytes, got %d", len("hello"), proxied.Bytes)
+	}
+	if proxied.RequestID != "req-123" {
+		t.Errorf("Expected request ID req-123, got %q", proxied.RequestID)
+	}
+	if proxied.Backend != "backend-1" {
+		t.Errorf("Expected backend backend-1, got %q", proxied.Backend)
+	}
+
+	// Rejected requests are logged without a backend
+	denied := entries[1]
+	if denied.Status != http.StatusForbidden || denied.Backend != "" {
+		t.Errorf("Expected 403 without backend, got %d from %q", denied.Status, denied.Backend)
+	}
+}
+
+func TestLoadBalancerCustomRequestIDHeader(t *testing.T) {
+	received := make(chan http.Header, 2)
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api" {
+			received <- r.Header.Clone()
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	logPath := filepath.Join(t.TempDir(), "access.log")
+	cfg := &config.Config{
+		Port:                8000,
+		Backends:            []string{mockServer.URL},
+		HealthCheckPath:     "/",
+		HealthCheckInterval: 10 * time.Second,
+		HealthCheckTimeout:  2 * time.Second,
+		BackendTimeout:      30 * time.Second,
+		AccessLog:           logPath,
+		RequestIDHeader:     "X-Correlation-ID",
+	}
+
+	lb, err := NewLoadBalancer(cfg)
+	if err != nil {
+		t.Fatalf("Load balancer creation failed: %v", err)
+	}
+	defer lb.Stop()
+	waitForHealthChecks(t, lb)
+
+	tests := []struct {
+		name     string
+		callerID string
+	}{
+		{"Generated", ""},
+		{"Propagated", "corr-42"},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://localhost:8000/api", nil)
+			if tt.callerID != "" {
+				req.Header.Set("X-Correlation-ID", tt.callerID)
+			}
+			recorder := httptest.NewRecorder()
+			lb.ServeHTTP(recorder, req)
+
+			backendHeader := <-received
+			backendID := backendHeader.Get("X-Correlation-ID")
+			if backendID == "" {
+				t.Fatalf("Expected backend to receive an X-Correlation-ID")
+			}
+			if tt.callerID != "" && backendID != tt.callerID {
+				t.Errorf("Expected caller request ID %q to be preserved, got %q", tt.callerID, backendID)
+			}
+			if got := backendHeader.Get("X-Request-ID"); got != "" {
+				t.Errorf("Expected no X-Request-ID with a custom header configured, got %q", got)
+			}
+			if echoed := recorder.Header().Get("X-Correlation-ID"); echoed != backendID {
+				t.Errorf("Expected echoed request ID %q, got %q", backendID, echoed)
+			}
+
+			entries := readAccessLog(t, logPath)
+			if len(entries) != i+1 {
+				t.Fatalf("Expected %d access log entries, got %d", i+1, len(entries))
+			}
+			if logged := entries[i].RequestID; logged != backendID {
+				t.Errorf("Expected logged request ID %q, got %q", backendID, logged)
+			}
+		})
+	}
+}
+
+func TestLoadBalancerAffinityHeader(t *testing.T) {
+	var backends []string
+	for i := 0; i < 3; i++ {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+		backends = append(backends, server.URL)
+	}
+
+	cfg := &config.Config{
+		Port:                8000,
+		Backends:            backends,
+		HealthCheckPath:     "/",
+		HealthCheckInterval: 10 * time.Second,
+		HealthCheckTimeout:  2 * time.Second,
+		BackendTimeout:      30 * time.Second,
+		AffinityHeader:      "X-Tenant-ID",
+	}
+
+	lb, err := NewLoadBalancer(cfg)
+	if err != nil {
+		t.Fatalf("Load balancer creation failed: %v", err)
+	}
+	defer lb.Stop()
+	waitForHealthChecks(t, lb)
+
+	selectFor := func(tenant string) string {
+		req := httptest.NewRequest("GET", "http://localhost:8000/api", nil)
+		if tenant != "" {
+			req.Header.Set("X-Tenant-ID", tenant)
+		}
+		backend, err := lb.getNextHealthyBackend(req)
+		if err != nil {
+			t.Fatalf("Expected a backend, got error: %v", err)
+		}
+		return backend.ID
+	}
+
+	// The same tenant always lands on the same backend
+	for _, tenant := range []string{"acme", "globex", "initech"} {
+		first := selectFor(tenant)
+		for i := 0; i < 10; i++ {
+			if got := selectFor(tenant); got != first {
+				t.Errorf("Expected tenant %s to stay on %s, got %s", tenant, first, got)
+			}
+		}
+	}
+
+	// Requests without the header use round-robin
+	seen := make(map[string]bool)
+	for i := 0; i < 3; i++ {
+		seen[selectFor("")] = true
+	}
+	if len(seen) != 3 {
+		t.Errorf("Expected requests without the header to rotate across 3 backends, got %d", len(seen))
+	}
+}
+
+func TestLoadBalancerPerRouteStrategy(t *testing.T) {
+	var backends []string
+	for i := 0; i < 3; i++ {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+		backends = append(backends, server.URL)
+	}
+
+	cfg := &config.Config{
+		Port:                8000,
+		Backends:            backends,
+		HealthCheckPath:     "/",
+		HealthCheckInterval: 10 * time.Second,
+		HealthCheckTimeout:  2 * time.Second,
+		BackendTimeout:      30 * time.Second,
+		Strategy:            "consistent-hash",
+		Routes: []string{
+			"/api=weighted-least-connections@" + backends[0] + "," + backends[1],
+			"/static=round-robin@" + backends[1] + "," + backends[2],
+			"/static/v2=weighted-random",
+		},
+	}
+
+	lb, err := NewLoadBalancer(cfg)
+	if err != nil {
+		t.Fatalf("Load balancer creation failed: %v", err)
+	}
+	defer lb.Stop()
+	waitForHealthChecks(t, lb)
+
+	strategyTests := []struct {
+		path     string
+		expected string
+	}{
+		{"/api/users", "weighted-least-connections"},
+		{"/static/app.js", "round-robin"},
+		{"/static/v2/app.js", "weighted-random"}, // the longer prefix wins
+		{"/other", ""},                           // no route, default strategy
+	}
+	for _, tt := range strategyTests {
+		rt := lb.matchRoute(httptest.NewRequest("GET", "http://localhost:8000"+tt.path, nil))
+		got := ""
+		if rt != nil {
+			got = rt.strategy.Name()
+		}
+		if got != tt.expected {
+			t.Errorf("Expected route strategy %q for %s, got %q", tt.expected, tt.path, got)
+		}
+	}
+
+	selectFor := func(path string) *pool.Backend {
+		req := httptest.NewRequest("GET", "http://localhost:8000"+path, nil)
+		backend, err := lb.getNextHealthyBackend(req)
+		if err != nil {
+			t.Fatalf("Expected a backend for %s, got error: %v", path, err)
+		}
+		return backend
+	}
+
+	// Least-connections avoids the busy backend among the route's own backends
+	busy := lb.serverPool.GetBackendByIndex(0)
+	busy.IncrementConnections()
+	for i := 0; i < 5; i++ {
+		if got := selectFor("/api/users").URL.String(); got != backends[1] {
+			t.Errorf("Expected /api to use idle route backend %s, got %s", backends[1], got)
+		}
+	}
+	busy.DecrementConnections()
+
+	// Round-robin alternates over the route's own backends
+	seen := make(map[string]int)
+	for i := 0; i < 4; i++ {
+		seen[selectFor("/static/app.js").URL.String()]++
+	}
+	if seen[backends[1]] != 2 || seen[backends[2]] != 2 {
+		t.Errorf("Expected /static to alternate between its 2 backends, got %v", seen)
+	}
+
+	// Unrouted requests keep the default consistent-hash strategy
+	first := selectFor("/other")
+	for i := 0; i < 5; i++ {
+		if got := selectFor("/other"); got != first {
+			t.Errorf("Expected unrouted requests from one client to stick to %s, got %s", first.ID, got.ID)
+		}
+	}
+}
+
+func TestLoadBalancerStaticResponses(t *testing.T) {
+	var proxied atomic.Int64
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			proxied.Add(1)
+		}
+		w.Write([]byte("from backend"))
+	}))
+	defer mockServer.Close()
+
+	cfg := &config.Config{
+		Port:                8000,
+		Backends:            []string{mockServer.URL},
+		HealthCheckPath:     "/",
+		HealthCheckInterval: 10 * time.Second,
+		HealthCheckTimeout:  2 * time.Second,
+		BackendTimeout:      30 * time.Second,
+		StaticResponses: map[string]config.StaticResponse{
+			"/ping":        {Status: http.StatusOK, Body: "pong"},
+			"/favicon.ico": {Status: http.StatusNoContent},
+			"/info":        {Status: http.StatusOK, ContentType: "application/json", Body: `{"ok":true}`},
+		},
+	}
+
+	lb, err := NewLoadBalancer(cfg)
+	if err != nil {
+		t.Fatalf("Load balancer creation failed: %v", err)
+	}
+	defer lb.Stop()
+	waitForHealthChecks(t, lb)
+
+	tests := []struct {
+		method              string
+		path                string
+		expectedStatus      int
+		expectedContentType string
+		expectedBody        string
+	}{
+		{"GET", "/ping", http.StatusOK, "text/plain; charset=utf-8", "pong"},
+		{"HEAD", "/ping", http.StatusOK, "text/plain; charset=utf-8", ""},
+		{"GET", "/favicon.ico", http.StatusNoContent, "text/plain; charset=utf-8", ""},
+		{"GET", "/info", http.StatusOK, "application/json", `{"ok":true}`},
+	}
+
+	for _, tt := range tests {
+		recorder := httptest.NewRecorder()
+		lb.ServeHTTP(recorder, httptest.NewRequest(tt.method, "http://localhost:8000"+tt.path, nil))
+
+		if recorder.Code != tt.expectedStatus {
+			t.Errorf("%s %s: expected status %d, got %d", tt.method, tt.path, tt.expectedStatus, recorder.Code)
+		}
+		if got := recorder.Header().Get("Content-Type"); got != tt.expectedContentType {
+			t.Errorf("%s %s: expected Content-Type %q, got %q", tt.method, tt.path, tt.expectedContentType, got)
+		}
+		if recorder.Body.String() != tt.expectedBody {
+			t.Errorf("%s %s: expected body %q, got %q", tt.method, tt.path, tt.expectedBody, recorder.Body.String())
+		}
+	}
+	if proxied.Load() != 0 {
+		t.Errorf("Expected static paths not to reach the backend, got %d requests", proxied.Load())
+	}
+
+	// Other paths, including ones that merely start with a static path, are proxied
+	for _, path := range []string{"/api", "/ping/deep"} {
+		recorder := httptest.NewRecorder()
+		lb.ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8000"+path, nil))
+		if recorder.Body.String() != "from backend" {
+			t.Errorf("Expected %s to be proxied, got body %q", path, recorder.Body.String())
+		}
+	}
+	if proxied.Load() != 2 {
+		t.Errorf("Expected 2 proxied requests, got %d", proxied.Load())
+	}
+}
+
+// newH2CServer starts a plain-HTTP test server that also accepts HTTP/2 with
+// prior knowledge
+func newH2CServer(handler http.Handler) *httptest.Server {
+	server := httptest.NewUnstartedServer(handler)
+	server.Config.Protocols = new(http.Protocols)
+	server.Config.Protocols.SetHTTP1(true)
+	server.Config.Protocols.SetUnencryptedHTTP2(true)
+	server.Start()
+	return server
+}
+
+func TestProbeProtocol(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	h2cServer := newH2CServer(handler)
+	defer h2cServer.Close()
+	http1Server := httptest.NewServer(handler)
+	defer http1Server.Close()
+	h2TLSServer := httptest.NewUnstartedServer(handler)
+	h2TLSServer.EnableHTTP2 = true
+	h2TLSServer.StartTLS()
+	defer h2TLSServer.Close()
+	http1TLSServer := httptest.NewTLSServer(handler)
+	defer http1TLSServer.Close()
+
+	tests := []struct {
+		name        string
+		url         string
+		expected    string
+		expectError bool
+	}{
+		{"HTTP/2 with prior knowledge", h2cServer.URL, pool.ProtocolHTTP2, false},
+		{"HTTP/1.1-only plain server", http1Server.URL, pool.ProtocolHTTP1, false},
+		{"HTTP/2 over TLS", h2TLSServer.URL, pool.ProtocolHTTP2, false},
+		{"HTTP/1.1-only TLS server", http1TLSServer.URL, pool.ProtocolHTTP1, false},
+		{"Unreachable server", "http://127.0.0.1:1", "", true},
+		{"Unreachable TLS server", "https://127.0.0.1:1", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.url)
+			if err != nil {
+				t.Fatalf("Invalid URL %q: %v", tt.url, err)
+			}
+			got, err := probeProtocol(u, 2*time.Second)
+			if (err != nil) != tt.expectError {
+				t.Errorf("Expected error=%v, got %v", tt.expectError, err)
+			}
+			if got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestLoadBalancerDetectsBackendProtocol(t *testing.T) {
+	// Each backend answers with the protocol the request reached it over
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	})
+	h2cServer := newH2CServer(handler)
+	defer h2cServer.Close()
+	http1Server := httptest.NewServer(handler)
+	defer http1Server.Close()
+
+	tests := []struct {
+		name             string
+		backend          string
+		detect           bool
+		expectedProtocol string
+		expectedProto    string
+	}{
+		{"HTTP/2-capable backend", h2cServer.URL, true, pool.ProtocolHTTP2, "HTTP/2.0"},
+		{"HTTP/1.1-only backend", http1Server.URL, true, pool.ProtocolHTTP1, "HTTP/1.1"},
+		{"Detection disabled", h2cServer.URL, false, "", "HTTP/1.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				Port:                  8000,
+				Backends:              []string{tt.backend},
+				HealthCheckPath:       "/",
+				HealthCheckInterval:   10 * time.Second,
+				HealthCheckTimeout:    2 * time.Second,
+				BackendTimeout:        30 * time.Second,
+				DetectBackendProtocol: tt.detect,
+			}
+
+			lb, err := NewLoadBalancer(cfg)
+			if err != nil {
+				t.Fatalf("Load balancer creation failed: %v", err)
+			}
+			defer lb.Stop()
+			waitForHealthChecks(t, lb)
+
+			backend := lb.GetBackends()[0]
+			if tt.detect {
+				deadline := time.Now().Add(2 * time.Second)
+				for backend.Protocol() == "" {
+					if time.Now().After(deadline) {
+						t.Fatalf("Timed out waiting for protocol detection")
+					}
+					time.Sleep(5 * time.Millisecond)
+				}
+			}
+			if got := backend.Protocol(); got != tt.expectedProtocol {
+				t.Errorf("Expected detected protocol %q, got %q", tt.expectedProtocol, got)
+			}
+
+			recorder := httptest.NewRecorder()
+			lb.ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8000/", nil))
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("Expected status %d, got %d", http.StatusOK, recorder.Code)
+			}
+			if got := recorder.Body.String(); got != tt.expectedProto {
+				t.Errorf("Expected the backend to be reached over %s, got %s", tt.expectedProto, got)
+			}
+		})
+	}
+}
+
+func TestLoadBalancerRetriesProtocolDetection(t *testing.T) {
+	// Reserve an address for a backend that isn't up yet
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	cfg := &config.Config{
+		Port:                  8000,
+		Backends:              []string{"http://" + addr},
+		HealthCheckPath:       "/",
+		HealthCheckInterval:   10 * time.Second,
+		HealthCheckTimeout:    2 * time.Second,
+		BackendTimeout:        30 * time.Second,
+		DisableHealthChecks:   true,
+		DetectBackendProtocol: true,
+	}
+
+	lb, err := NewLoadBalancer(cfg)
+	if err != nil {
+		t.Fatalf("Load balancer creation failed: %v", err)
+	}
+	defer lb.Stop()
+
+	// The startup probe can't reach the backend, so it records nothing and
+	// gives detection back
+	backend := lb.GetBackends()[0]
+	deadline := time.Now().Add(2 * time.Second)
+	for !backend.ClaimProtocolDetection() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for the startup probe to give up")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	backend.ReleaseProtocolDetection()
+	if got := backend.Protocol(); got != "" {
+		t.Fatalf("Expected no protocol recorded for an unreachable backend, got %q", got)
+	}
+
+	// The backend comes up speaking HTTP/2
+	ln, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatalf("Failed to listen on %s: %v", addr, err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}))
+	server.Listener.Close()
+	server.Listener = ln
+	server.Config.Protocols = new(http.Protocols)
+	server.Config.Protocols.SetHTTP1(true)
+	server.Config.Protocols.SetUnencryptedHTTP2(true)
+	server.Start()
+	defer server.Close()
+
+	// Its first request probes it again
+	lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://localhost:8000/", nil))
+	deadline = time.Now().Add(2 * time.Second)
+	for backend.Protocol() == "" {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for protocol detection")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := backend.Protocol(); got != pool.ProtocolHTTP2 {
+		t.Errorf("Expected detected protocol %q, got %q", pool.ProtocolHTTP2, got)
+	}
+
+	recorder := httptest.NewRecorder()
+	lb.ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8000/", nil))
+	if got := recorder.Body.String(); got != "HTTP/2.0" {
+		t.Errorf("Expected the backend to be reached over HTTP/2.0, got %q", got)
+	}
+}
@@ -0,0 +1,19 @@
+package balancer
+
+import (
+	"math/rand"
+	"net/http"
+)
+
+// routeToCanary reports whether the request should go to the canary: it
+// matches the canary rule or falls in the sampled percentage. An unavailable
+// canary gets nothing, so its traffic goes to the regular backends.
+func (lb *LoadBalancer) routeToCanary(r *http.Request) bool {
+	if lb.canary == nil || !lb.canary.Available() {
+		return false
+	}
+	if lb.canaryMatch.Matches(r) {
+		return true
+	}
+	return lb.config.CanaryPercent > 0 && rand.Float64()*100 < lb.config.CanaryPercent
+}
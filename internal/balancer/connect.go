@@ -0,0 +1,80 @@
+package balancer
+
+import (
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+
+	"go-balancer/internal/errors"
+)
+
+// serveConnect tunnels a CONNECT request to the host:port it names, if the
+// allowlist permits it. Once the target is reached the client connection is
+// hijacked and bytes are copied both ways until either side closes, so the
+// tunnel never touches the backend pool.
+func (lb *LoadBalancer) serveConnect(w http.ResponseWriter, r *http.Request) {
+	target := r.Host
+	if !lb.connectTargets.Allows(target) {
+		deniedErr := errors.NewTunnelDeniedError(target)
+		log.Printf("Rejecting CONNECT: %v", deniedErr)
+		lb.writeError(w, r, deniedErr)
+		return
+	}
+
+	dialer := net.Dialer{Timeout: lb.config.BackendTimeout}
+	upstream, err := dialer.DialContext(r.Context(), "tcp", target)
+	if err != nil {
+		dialErr := errors.NewTunnelFailedError(target, err)
+		log.Printf("Failed to open CONNECT tunnel: %v", dialErr)
+		lb.writeError(w, r, dialErr)
+		return
+	}
+	defer upstream.Close()
+
+	// HTTP/2 connections carry other streams and can't be handed over
+	client, buffered, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		hijackErr := errors.NewTunnelFailedError(target, err)
+		log.Printf("Failed to open CONNECT tunnel: %v", hijackErr)
+		lb.writeError(w, r, hijackErr)
+		return
+	}
+	defer client.Close()
+
+	if _, err := io.WriteString(client, "HTTP/1.1 200 Connection Established\r\n\r\n"); err != nil {
+		log.Printf("CONNECT tunnel to %s closed before it was established: %v", target, err)
+		return
+	}
+	log.Printf("CONNECT tunnel opened from %s to %s", lb.ClientIP(r), target)
+
+	// Bytes the client sent after its request headers are already buffered,
+	// so read through the buffer
+	var sent, received int64
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		sent, _ = io.Copy(upstream, buffered.Reader)
+		closeWrite(upstream)
+	}()
+	go func() {
+		defer wg.Done()
+		received, _ = io.Copy(client, upstream)
+		closeWrite(client)
+	}()
+	wg.Wait()
+
+	log.Printf("CONNECT tunnel to %s closed after %d bytes sent and %d received", target, sent, received)
+}
+
+// closeWrite half-closes conn so the peer sees EOF while replies can still
+// arrive, or closes it outright if it can't be half-closed
+func closeWrite(conn net.Conn) {
+	if tcp, ok := conn.(interface{ CloseWrite() error }); ok {
+		tcp.CloseWrite()
+		return
+	}
+	conn.Close()
+}
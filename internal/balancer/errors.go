@@ -0,0 +1,80 @@
+package balancer
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"go-balancer/internal/config"
+	"go-balancer/internal/errors"
+)
+
+// errorBody is the JSON form of a proxy error
+type errorBody struct {
+	Error errorDetail `json:"error"`
+}
+
+// errorDetail describes a LoadBalancerError to API clients
+type errorDetail struct {
+	Code    errors.ErrorCode  `json:"code"`
+	Message string            `json:"message"`
+	Context map[string]string `json:"context,omitempty"`
+}
+
+// writeError answers the client with lbErr, as JSON when the configured error
+// format (and, in auto mode, the request's Accept header) calls for it and as
+// plain text otherwise
+func (lb *LoadBalancer) writeError(w http.ResponseWriter, r *http.Request, lbErr *errors.LoadBalancerError) {
+	if !lb.wantsJSONError(r) {
+		http.Error(w, lbErr.Message, lbErr.HTTPStatusCode())
+		return
+	}
+
+	body, _ := json.Marshal(lb.errorBody(lbErr))
+	w.Header().Del("Content-Length")
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(lbErr.HTTPStatusCode())
+	w.Write(append(body, '\n'))
+}
+
+// errorBody builds the JSON body for lbErr. Context values are rendered as
+// strings and only included when configured, since they can name backends.
+func (lb *LoadBalancer) errorBody(lbErr *errors.LoadBalancerError) errorBody {
+	detail := errorDetail{Code: lbErr.Code, Message: lbErr.Message}
+	if lb.config.ErrorIncludeContext && len(lbErr.Context) > 0 {
+		detail.Context = make(map[string]string, len(lbErr.Context))
+		for key, value := range lbErr.Context {
+			detail.Context[key] = fmt.Sprint(value)
+		}
+	}
+	return errorBody{Error: detail}
+}
+
+// wantsJSONError reports whether errors for r are written as JSON
+func (lb *LoadBalancer) wantsJSONError(r *http.Request) bool {
+	switch lb.config.ErrorFormat {
+	case config.ErrorFormatJSON:
+		return true
+	case config.ErrorFormatAuto:
+		return acceptsJSON(r.Header.Get("Accept"))
+	}
+	return false
+}
+
+// acceptsJSON reports whether an Accept header explicitly lists
+// application/json; wildcards alone keep the plain text default
+func acceptsJSON(accept string) bool {
+	for _, entry := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(entry))
+		if err != nil || params["q"] == "0" {
+			continue
+		}
+		if mediaType == "application/json" {
+			return true
+		}
+	}
+	return false
+}
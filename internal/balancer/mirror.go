@@ -0,0 +1,69 @@
+package balancer
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"net/http"
+)
+
+// Limits for mirroring requests to the shadow backend
+const (
+	shadowMaxBodyBytes = 1 << 20
+	shadowMaxInFlight  = 100
+)
+
+// shadowMaxDrainBytes bounds how much of a shadow response is read so the
+// connection can be reused
+const shadowMaxDrainBytes = 64 << 10
+
+// bufferBody reads up to limit bytes of a request body so it can be sent to
+// both the selected backend and the shadow. It returns the body to forward
+// and, when the whole body fit, a copy for the shadow. Larger or unreadable
+// bodies are forwarded as they are and not mirrored.
+func bufferBody(body io.Reader, limit int) (io.Reader, []byte, bool) {
+	buf, err := io.ReadAll(io.LimitReader(body, int64(limit)+1))
+	if err != nil || len(buf) > limit {
+		return io.MultiReader(bytes.NewReader(buf), body), nil, false
+	}
+	return bytes.NewReader(buf), buf, true
+}
+
+// mirror sends a copy of a request to the shadow backend in the background.
+// The response is discarded and failures are only logged, so the shadow can
+// never affect the client. Mirrors are dropped once shadowMaxInFlight are
+// outstanding, so a slow shadow can't pile up goroutines.
+func (lb *LoadBalancer) mirror(method, path, rawQuery string, header http.Header, body []byte) {
+	select {
+	case lb.shadowSlots <- struct{}{}:
+	default:
+		log.Printf("Skipping mirror of %s %s: too many shadow requests in flight", method, path)
+		return
+	}
+
+	go func() {
+		defer func() { <-lb.shadowSlots }()
+
+		ctx, cancel := context.WithTimeout(context.Background(), lb.config.BackendTimeout)
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(ctx, method, lb.config.ShadowBackend+path, bytes.NewReader(body))
+		if err != nil {
+			log.Printf("Error creating shadow request: %v", err)
+			return
+		}
+		req.URL.RawQuery = rawQuery
+		req.Header = header
+
+		resp, err := lb.client.Do(req)
+		if err != nil {
+			log.Printf("Shadow request %s %s failed: %v", method, path, err)
+			return
+		}
+		defer resp.Body.Close()
+
+		io.Copy(io.Discard, io.LimitReader(resp.Body, shadowMaxDrainBytes))
+		log.Printf("Shadow response for %s %s: %s", method, path, resp.Status)
+	}()
+}
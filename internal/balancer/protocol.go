@@ -0,0 +1,147 @@
+package balancer
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"go-balancer/internal/config"
+	"go-balancer/internal/pool"
+)
+
+// h2Preface opens an HTTP/2 connection with prior knowledge: the client
+// connection preface followed by an empty SETTINGS frame
+var h2Preface = []byte("PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n\x00\x00\x00\x04\x00\x00\x00\x00\x00")
+
+// h2FrameSettings is the HTTP/2 frame type of a SETTINGS frame
+const h2FrameSettings = 0x4
+
+// newH2CTransport builds the transport used for plain-HTTP backends detected
+// to speak HTTP/2, which sends requests over HTTP/2 without TLS
+func newH2CTransport(cfg *config.Config) *http.Transport {
+	transport := newTransport(cfg)
+	transport.Protocols = new(http.Protocols)
+	transport.Protocols.SetUnencryptedHTTP2(true)
+	return transport
+}
+
+// clientFor returns the client requests to backend are sent with
+func (lb *LoadBalancer) clientFor(backend *pool.Backend) *http.Client {
+	if lb.h2cClient != nil && backend.Protocol() == pool.ProtocolHTTP2 && lb.upstreamScheme(backend) == "http" {
+		return lb.h2cClient
+	}
+	return lb.client
+}
+
+// detectProtocol probes backend in the background unless it was detected
+// already or a probe is under way, recording whether the backend speaks
+// HTTP/2. A probe that can't reach the backend records nothing, so the next
+// request to it probes again.
+func (lb *LoadBalancer) detectProtocol(backend *pool.Backend) {
+	if !backend.ClaimProtocolDetection() {
+		return
+	}
+
+	go func() {
+		upstream, err := url.Parse(lb.upstreamURL(backend))
+		if err != nil {
+			backend.SetProtocol(pool.ProtocolHTTP1)
+			return
+		}
+
+		protocol, err := probeProtocol(upstream, lb.config.HealthCheckTimeout)
+		if err != nil {
+			log.Printf("Could not detect the protocol of backend %s, retrying on its next request: %v", backend.ID, err)
+			backend.ReleaseProtocolDetection()
+			return
+		}
+		backend.SetProtocol(protocol)
+		log.Printf("Backend %s speaks %s", backend.ID, protocol)
+	}()
+}
+
+// probeProtocol reports whether the server at u speaks HTTP/2, or HTTP/1.1
+// when it gives no HTTP/2 answer. It fails when the server can't be reached,
+// since that says nothing about its protocol.
+func probeProtocol(u *url.URL, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var ok bool
+	var err error
+	if u.Scheme == "https" {
+		ok, err = negotiatesH2(ctx, u)
+	} else {
+		ok, err = acceptsPriorKnowledge(ctx, u)
+	}
+	if err != nil {
+		return "", err
+	}
+	if ok {
+		return pool.ProtocolHTTP2, nil
+	}
+	return pool.ProtocolHTTP1, nil
+}
+
+// negotiatesH2 reports whether a TLS server selects h2 with ALPN, failing if
+// the handshake doesn't complete. The probe sends no request and its
+// connection is thrown away, so the certificate is left to be checked by the
+// connections that carry requests.
+func negotiatesH2(ctx context.Context, u *url.URL) (bool, error) {
+	dialer := &tls.Dialer{Config: &tls.Config{
+		ServerName:         u.Hostname(),
+		NextProtos:         []string{"h2", "http/1.1"},
+		InsecureSkipVerify: true,
+	}}
+	conn, err := dialer.DialContext(ctx, "tcp", hostPort(u))
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	return conn.(*tls.Conn).ConnectionState().NegotiatedProtocol == "h2", nil
+}
+
+// acceptsPriorKnowledge reports whether a plain-HTTP server answers the HTTP/2
+// connection preface with a SETTINGS frame, failing if it can't be connected
+// to. An HTTP/1.1 server answers with a 400 response or closes the connection
+// instead.
+func acceptsPriorKnowledge(ctx context.Context, u *url.URL) (bool, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", hostPort(u))
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if _, err := conn.Write(h2Preface); err != nil {
+		return false, nil
+	}
+
+	// A frame header is a 3-byte length, then the type, flags and stream ID
+	header := make([]byte, 9)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return false, nil
+	}
+	return header[3] == h2FrameSettings && bytes.Equal(header[5:], []byte{0, 0, 0, 0}), nil
+}
+
+// hostPort returns u's host with its scheme's default port filled in
+func hostPort(u *url.URL) string {
+	if port := u.Port(); port != "" {
+		return u.Host
+	}
+	if u.Scheme == "https" {
+		return net.JoinHostPort(u.Hostname(), "443")
+	}
+	return net.JoinHostPort(u.Hostname(), "80")
+}
@@ -0,0 +1,99 @@
+package balancer
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"go-balancer/internal/errors"
+	"go-balancer/internal/pool"
+)
+
+// slotSignal wakes requests queued for a backend below its connection limit
+type slotSignal struct {
+	mu    sync.Mutex
+	freed chan struct{} // Closed, then replaced, whenever a backend finishes a request
+}
+
+func newSlotSignal() *slotSignal {
+	return &slotSignal{freed: make(chan struct{})}
+}
+
+// wait returns a channel closed the next time a backend finishes a request
+func (s *slotSignal) wait() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.freed
+}
+
+// broadcast wakes every queued request
+func (s *slotSignal) broadcast() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	close(s.freed)
+	s.freed = make(chan struct{})
+}
+
+// acquireBackend selects a backend for r and takes one of its connection
+// slots, to be given back with releaseBackend. When every backend is at its
+// connection limit, it waits up to the backend queue timeout for one to
+// finish a request, then fails with ErrBackendsSaturated.
+func (lb *LoadBalancer) acquireBackend(r *http.Request) (*pool.Backend, error) {
+	if lb.slotFreed == nil {
+		backend, err := lb.getNextHealthyBackend(r)
+		if err == nil {
+			backend.IncrementConnections()
+		}
+		return backend, err
+	}
+
+	var timer *time.Timer
+	for {
+		// Watch for a freed slot before selecting, so one freed in between
+		// isn't missed
+		freed := lb.slotFreed.wait()
+
+		backend, err := lb.getNextHealthyBackend(r)
+		if err == nil && backend.TryIncrementConnections() {
+			if timer != nil {
+				timer.Stop()
+				lb.metrics.RecordBackendQueuedRequest(true)
+			}
+			return backend, nil
+		}
+		// A backend chosen but already full lost its last slot to another
+		// request; anything else only queues when backends are merely full
+		if err != nil && !lb.serverPool.HasSaturatedBackend() {
+			return nil, err
+		}
+
+		saturatedErr := errors.NewBackendsSaturatedError(lb.config.MaxBackendConnections)
+		if lb.config.BackendQueueTimeout <= 0 {
+			return nil, saturatedErr
+		}
+		if timer == nil {
+			timer = time.NewTimer(lb.config.BackendQueueTimeout)
+		}
+
+		select {
+		case <-freed:
+		case <-timer.C:
+			lb.metrics.RecordBackendQueuedRequest(false)
+			return nil, saturatedErr.WithContext("queue_timeout", lb.config.BackendQueueTimeout)
+		case <-r.Context().Done():
+			timer.Stop()
+			return nil, saturatedErr
+		}
+	}
+}
+
+// releaseBackend gives back the connection slot taken by acquireBackend,
+// waking requests queued for one
+func (lb *LoadBalancer) releaseBackend(backend *pool.Backend) {
+	backend.DecrementConnections()
+	if lb.slotFreed != nil {
+		lb.slotFreed.broadcast()
+	}
+}
@@ -0,0 +1,57 @@
+package balancer
+
+import (
+	"net/http"
+	"sort"
+
+	"go-balancer/internal/config"
+	"go-balancer/internal/pool"
+	"go-balancer/internal/strategy"
+)
+
+// route is a configured route with its own backends and strategy instance
+type route struct {
+	config.Route
+	pool     *pool.ServerPool // nil selects from the regular pool
+	strategy strategy.LoadBalancingStrategy
+}
+
+// buildRoutes parses the route specs, ordering them so the most specific
+// route is tried first: host-specific routes, then longer prefixes
+func buildRoutes(specs []string, serverPool *pool.ServerPool) ([]*route, error) {
+	routes := make([]*route, 0, len(specs))
+	for _, spec := range specs {
+		parsed, err := config.ParseRoute(spec)
+		if err != nil {
+			return nil, err
+		}
+		routeStrategy, err := strategy.NewStrategy(parsed.Strategy)
+		if err != nil {
+			return nil, err
+		}
+
+		rt := &route{Route: parsed, strategy: routeStrategy}
+		if len(parsed.Backends) > 0 {
+			rt.pool = serverPool.Subset(parsed.Backends)
+		}
+		routes = append(routes, rt)
+	}
+
+	sort.SliceStable(routes, func(i, j int) bool {
+		if (routes[i].Host != "") != (routes[j].Host != "") {
+			return routes[i].Host != ""
+		}
+		return len(routes[i].PathPrefix) > len(routes[j].PathPrefix)
+	})
+	return routes, nil
+}
+
+// matchRoute returns the most specific route matching the request, or nil
+func (lb *LoadBalancer) matchRoute(r *http.Request) *route {
+	for _, rt := range lb.routes {
+		if rt.Matches(r) {
+			return rt
+		}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package balancer
+
+import (
+	"math"
+	"math/rand"
+	"sync/atomic"
+
+	"go-balancer/internal/pool"
+)
+
+// trafficSplit divides traffic between the blue (Backends) and green
+// (GreenBackends) groups. The green share can be changed at runtime.
+type trafficSplit struct {
+	blue  *pool.ServerPool
+	green *pool.ServerPool
+
+	greenPercent atomic.Uint64 // math.Float64bits of the green percentage
+}
+
+// newTrafficSplit creates a split sending greenPercent of requests to green
+func newTrafficSplit(blue, green *pool.ServerPool, greenPercent float64) *trafficSplit {
+	split := &trafficSplit{blue: blue, green: green}
+	split.setGreenPercent(greenPercent)
+	return split
+}
+
+// GreenPercent returns the percentage of requests, 0-100, sent to green
+func (s *trafficSplit) GreenPercent() float64 {
+	return math.Float64frombits(s.greenPercent.Load())
+}
+
+// setGreenPercent changes the green share for subsequent requests
+func (s *trafficSplit) setGreenPercent(percent float64) {
+	s.greenPercent.Store(math.Float64bits(percent))
+}
+
+// pick returns the group the next request should be served from. A group
+// with no available backend gets nothing, so its share goes to the other one.
+func (s *trafficSplit) pick() *pool.ServerPool {
+	chosen, other := s.blue, s.green
+	if rand.Float64()*100 < s.GreenPercent() {
+		chosen, other = s.green, s.blue
+	}
+	if chosen.GetAvailableBackendCount() == 0 {
+		return other
+	}
+	return chosen
+}
@@ -0,0 +1,79 @@
+package balancer
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// idleReader aborts a backend response body that goes quiet: each read
+// pushes back a timer, and if it fires before the next read the backend
+// request is canceled, failing the read in progress.
+type idleReader struct {
+	r       io.Reader
+	timer   *time.Timer
+	timeout time.Duration
+	expired atomic.Bool
+}
+
+// newIdleReader wraps r so that cancel is called once timeout passes without
+// a read returning. Call stop when done reading.
+func newIdleReader(r io.Reader, timeout time.Duration, cancel context.CancelFunc) *idleReader {
+	ir := &idleReader{r: r, timeout: timeout}
+	ir.timer = time.AfterFunc(timeout, func() {
+		ir.expired.Store(true)
+		cancel()
+	})
+	return ir
+}
+
+func (ir *idleReader) Read(p []byte) (int, error) {
+	n, err := ir.r.Read(p)
+	if n > 0 {
+		ir.timer.Reset(ir.timeout)
+	}
+	return n, err
+}
+
+// stop disarms the timer
+func (ir *idleReader) stop() {
+	ir.timer.Stop()
+}
+
+// Expired reports whether the body was aborted for being idle
+func (ir *idleReader) Expired() bool {
+	return ir.expired.Load()
+}
+
+// deadlineWriter gives every write to the client its own deadline, so a
+// client that stops reading fails the copy instead of holding the backend
+// connection open
+type deadlineWriter struct {
+	w       io.Writer
+	rc      *http.ResponseController
+	timeout time.Duration
+	expired bool
+}
+
+func (dw *deadlineWriter) Write(p []byte) (int, error) {
+	deadline := time.Now().Add(dw.timeout)
+	dw.rc.SetWriteDeadline(deadline) // Unsupported writers just write without one
+	n, err := dw.w.Write(p)
+	if err != nil && !time.Now().Before(deadline) {
+		dw.expired = true
+	}
+	return n, err
+}
+
+// clear removes the write deadline so later responses on a keep-alive
+// connection aren't bound by it
+func (dw *deadlineWriter) clear() {
+	dw.rc.SetWriteDeadline(time.Time{})
+}
+
+// Expired reports whether a write failed because its deadline passed
+func (dw *deadlineWriter) Expired() bool {
+	return dw.expired
+}
@@ -0,0 +1,67 @@
+package balancer
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"go-balancer/internal/config"
+)
+
+// dialRetryBaseDelay is the backoff before the first dial retry; later
+// retries double it
+const dialRetryBaseDelay = 50 * time.Millisecond
+
+// dialFunc opens a connection, as net.Dialer.DialContext does
+type dialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// newTransport builds the transport used to reach backends. With dial retries
+// configured, a failed connection attempt is retried before the request is
+// given up on, smoothing over momentary DNS or connect failures without
+// replaying the request itself.
+func newTransport(cfg *config.Config) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxResponseHeaderBytes = cfg.MaxResponseHeaderBytes
+	if cfg.DialRetries > 0 {
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+		transport.DialContext = retryingDial(dialer.DialContext, cfg.DialRetries, dialRetryBaseDelay)
+	}
+	return transport
+}
+
+// isResponseHeaderTooLarge reports whether err means the backend's response
+// headers exceeded the transport's MaxResponseHeaderBytes. net/http reports
+// this with an unexported error, so it is recognized by its message.
+func isResponseHeaderTooLarge(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "server response headers exceeded")
+}
+
+// retryingDial wraps dial so a failed attempt is retried up to retries times,
+// sleeping a jittered, doubling backoff in between. The request context bounds
+// the retries, so they never outlast the backend timeout.
+func retryingDial(dial dialFunc, retries int, baseDelay time.Duration) dialFunc {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		delay := baseDelay
+		for attempt := 0; ; attempt++ {
+			conn, err := dial(ctx, network, address)
+			if err == nil || attempt >= retries || ctx.Err() != nil {
+				return conn, err
+			}
+			log.Printf("Dial to %s failed, retrying (%d/%d): %v", address, attempt+1, retries, err)
+
+			// Full jitter keeps many requests from retrying in lockstep
+			timer := time.NewTimer(time.Duration(rand.Int63n(int64(delay)) + 1))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, err
+			case <-timer.C:
+			}
+			delay *= 2
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package cache
+
+import (
+	"container/list"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Entry is a cached backend response
+type Entry struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	StoredAt   time.Time
+
+	// Vary holds what the stored request sent for each request header named
+	// in the response's Vary header, see RequestVary
+	Vary http.Header
+}
+
+// Age returns how long ago the entry was stored
+func (e *Entry) Age() time.Duration {
+	return time.Since(e.StoredAt)
+}
+
+// Matches reports whether the entry may answer r: r must send the same
+// values as the stored request for every header the response varies on
+func (e *Entry) Matches(r *http.Request) bool {
+	for name, values := range e.Vary {
+		if strings.Join(r.Header.Values(name), ",") != strings.Join(values, ",") {
+			return false
+		}
+	}
+	return true
+}
+
+// ResponseCache is a bounded, thread-safe cache of responses keyed by request.
+// When full, the least recently stored entry is evicted.
+type ResponseCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[string]*list.Element
+	order      *list.List // front = most recently stored
+}
+
+type cacheItem struct {
+	key   string
+	entry *Entry
+}
+
+// NewResponseCache creates a cache holding at most maxEntries responses
+func NewResponseCache(maxEntries int) *ResponseCache {
+	return &ResponseCache{
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// Get returns the entry stored under key, if any
+func (c *ResponseCache) Get(key string) (*Entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	return elem.Value.(*cacheItem).entry, true
+}
+
+// Set stores an entry under key, replacing any existing one
+func (c *ResponseCache) Set(key string, entry *Entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		elem.Value.(*cacheItem).entry = entry
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&cacheItem{key: key, entry: entry})
+
+	for c.maxEntries > 0 && c.order.Len() > c.maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheItem).key)
+	}
+}
+
+// Len returns the number of cached entries
+func (c *ResponseCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// Key builds the cache key for a request
+func Key(r *http.Request) string {
+	return r.Host + r.URL.RequestURI()
+}
+
+// IsCacheable reports whether a request/response pair may be stored.
+// Only plain, successful GETs without per-user or no-store semantics qualify,
+// and not responses with Vary: *, which no later request can be known to match.
+func IsCacheable(r *http.Request, resp *http.Response) bool {
+	if r.Method != http.MethodGet || resp.StatusCode != http.StatusOK {
+		return false
+	}
+	if r.Header.Get("Authorization") != "" {
+		return false
+	}
+	for _, name := range varyNames(resp.Header) {
+		if name == "*" {
+			return false
+		}
+	}
+
+	for _, directive := range resp.Header.Values("Cache-Control") {
+		if containsToken(directive, "no-store") || containsToken(directive, "private") {
+			return false
+		}
+	}
+	return resp.Header.Get("Set-Cookie") == ""
+}
+
+// RequestVary returns the values r sent for each request header resp varies
+// on, for Entry.Vary. A header r didn't send is recorded as empty.
+func RequestVary(r *http.Request, resp *http.Response) http.Header {
+	names := varyNames(resp.Header)
+	if len(names) == 0 {
+		return nil
+	}
+
+	vary := make(http.Header, len(names))
+	for _, name := range names {
+		vary[http.CanonicalHeaderKey(name)] = r.Header.Values(name)
+	}
+	return vary
+}
+
+// varyNames returns the header names listed in a response's Vary headers
+func varyNames(header http.Header) []string {
+	var names []string
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// containsToken reports whether a comma-separated header value contains token
+func containsToken(value, token string) bool {
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if name, _, _ := strings.Cut(part, "="); strings.EqualFold(name, token) {
+			return true
+		}
+	}
+	return false
+}
+
+// BoundedBuffer accumulates written bytes up to a limit. Writes never fail, so
+// it can sit behind an io.TeeReader; once the limit is exceeded the buffer is
+// dropped and Overflowed reports true.
+type BoundedBuffer struct {
+	limit      int
+	buf        []byte
+	overflowed bool
+}
+
+// NewBoundedBuffer creates a buffer that holds at most limit bytes
+func NewBoundedBuffer(limit int) *BoundedBuffer {
+	return &BoundedBuffer{limit: limit}
+}
+
+// Write implements io.Writer
+func (b *BoundedBuffer) Write(p []byte) (int, error) {
+	if b.overflowed {
+		return len(p), nil
+	}
+	if len(b.buf)+len(p) > b.limit {
+		b.overflowed = true
+		b.buf = nil
+		return len(p), nil
+	}
+	b.buf = append(b.buf, p...)
+	return len(p), nil
+}
+
+// Bytes returns the buffered data
+func (b *BoundedBuffer) Bytes() []byte {
+	return b.buf
+}
+
+// Overflowed reports whether more than limit bytes were written
+func (b *BoundedBuffer) Overflowed() bool {
+	return b.overflowed
+}
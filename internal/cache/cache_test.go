@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestResponseCacheEviction(t *testing.T) {
+	c := NewResponseCache(2)
+
+	c.Set("a", &Entry{StatusCode: 200, Body: []byte("a"), StoredAt: time.Now()})
+	c.Set("b", &Entry{StatusCode: 200, Body: []byte("b"), StoredAt: time.Now()})
+	c.Set("a", &Entry{StatusCode: 200, Body: []byte("a2"), StoredAt: time.Now()}) // refresh a
+	c.Set("c", &Entry{StatusCode: 200, Body: []byte("c"), StoredAt: time.Now()})  // evicts b
+
+	if c.Len() != 2 {
+		t.Errorf("Expected 2 entries, got %d", c.Len())
+	}
+	if _, ok := c.Get("b"); ok {
+		t.Errorf("Expected oldest entry b to be evicted")
+	}
+	if entry, ok := c.Get("a"); !ok || string(entry.Body) != "a2" {
+		t.Errorf("Expected refreshed entry a2, got %v (exists: %v)", entry, ok)
+	}
+}
+
+func TestIsCacheable(t *testing.T) {
+	tests := []struct {
+		name          string
+		method        string
+		status        int
+		authorization string
+		cacheControl  string
+		vary          string
+		expected      bool
+	}{
+		{"Plain GET 200", "GET", 200, "", "", "", true},
+		{"Public max-age", "GET", 200, "", "public, max-age=60", "", true},
+		{"POST", "POST", 200, "", "", "", false},
+		{"Non-200", "GET", 404, "", "", "", false},
+		{"Authorized request", "GET", 200, "Bearer token", "", "", false},
+		{"No-store", "GET", 200, "", "no-store", "", false},
+		{"Private", "GET", 200, "", "private, max-age=60", "", false},
+		{"Vary on a header", "GET", 200, "", "", "Accept-Encoding", true},
+		{"Vary on anything", "GET", 200, "", "", "Accept-Encoding, *", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "http://localhost:8000/", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			resp := &http.Response{StatusCode: tt.status, Header: http.Header{}}
+			if tt.cacheControl != "" {
+				resp.Header.Set("Cache-Control", tt.cacheControl)
+			}
+			if tt.vary != "" {
+				resp.Header.Set("Vary", tt.vary)
+			}
+
+			if got := IsCacheable(req, resp); got != tt.expected {
+				t.Errorf("Expected cacheable=%v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestEntryMatchesVary(t *testing.T) {
+	stored := httptest.NewRequest("GET", "http://localhost:8000/", nil)
+	stored.Header.Set("Accept-Encoding", "gzip")
+	resp := &http.Response{StatusCode: 200, Header: http.Header{}}
+	resp.Header.Add("Vary", "Accept-Encoding")
+	resp.Header.Add("Vary", "cookie")
+	entry := &Entry{StatusCode: 200, StoredAt: time.Now(), Vary: RequestVary(stored, resp)}
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		cookie         string
+		expected       bool
+	}{
+		{"Same headers", "gzip", "", true},
+		{"Different encoding", "identity", "", false},
+		{"No encoding", "", "", false},
+		{"Cookie the stored request lacked", "gzip", "session=abc", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://localhost:8000/", nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			if tt.cookie != "" {
+				req.Header.Set("Cookie", tt.cookie)
+			}
+
+			if got := entry.Matches(req); got != tt.expected {
+				t.Errorf("Expected match=%v, got %v", tt.expected, got)
+			}
+		})
+	}
+
+	// Responses without Vary match every request
+	if !(&Entry{}).Matches(httptest.NewRequest("GET", "http://localhost:8000/", nil)) {
+		t.Errorf("Expected an entry without Vary to match any request")
+	}
+}
+
+func TestBoundedBuffer(t *testing.T) {
+	b := NewBoundedBuffer(5)
+	b.Write([]byte("abc"))
+	if b.Overflowed() || string(b.Bytes()) != "abc" {
+		t.Errorf("Expected buffered 'abc', got %q (overflowed: %v)", b.Bytes(), b.Overflowed())
+	}
+
+	if n, err := b.Write([]byte("def")); n != 3 || err != nil {
+		t.Errorf("Expected overflowing write to report success, got n=%d err=%v", n, err)
+	}
+	if !b.Overflowed() || b.Bytes() != nil {
+		t.Errorf("Expected buffer to overflow and drop data")
+	}
+}
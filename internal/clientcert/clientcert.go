@@ -0,0 +1,112 @@
+package clientcert
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"go-balancer/internal/config"
+	"go-balancer/internal/pool"
+)
+
+// Certificates are the TLS client certificates presented to backends that
+// require mutual TLS: one for every backend, and overrides for some of them
+type Certificates struct {
+	fallback *tls.Certificate
+	byHost   map[string]tls.Certificate // Keyed by the canonical backend URL's host
+}
+
+// Load reads and checks the configured key pairs. It returns nil when no
+// client certificate is configured.
+func Load(cfg *config.Config) (*Certificates, error) {
+	if cfg.UpstreamClientCert.CertFile == "" && len(cfg.UpstreamClientCerts) == 0 {
+		return nil, nil
+	}
+
+	certs := &Certificates{byHost: make(map[string]tls.Certificate, len(cfg.UpstreamClientCerts))}
+	if cfg.UpstreamClientCert.CertFile != "" {
+		cert, err := loadKeyPair(cfg.UpstreamClientCert)
+		if err != nil {
+			return nil, err
+		}
+		certs.fallback = &cert
+	}
+	for backendURL, files := range cfg.UpstreamClientCerts {
+		cert, err := loadKeyPair(files)
+		if err != nil {
+			return nil, fmt.Errorf("backend %s: %w", backendURL, err)
+		}
+		parsed, err := url.Parse(pool.NormalizeURL(backendURL))
+		if err != nil {
+			return nil, fmt.Errorf("invalid backend URL %q: %w", backendURL, err)
+		}
+		certs.byHost[parsed.Host] = cert
+	}
+	return certs, nil
+}
+
+// loadKeyPair loads a certificate and checks its key matches
+func loadKeyPair(files config.ClientCert) (tls.Certificate, error) {
+	cert, err := tls.LoadX509KeyPair(files.CertFile, files.KeyFile)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("loading client certificate %s: %w", files.CertFile, err)
+	}
+	return cert, nil
+}
+
+// Transport returns a round tripper that presents the right certificate to
+// each backend. Backends with their own certificate get a clone of base
+// presenting it; the rest go through base, which presents the fallback
+// certificate if there is one. A nil c returns base unchanged.
+func (c *Certificates) Transport(base *http.Transport) http.RoundTripper {
+	if c == nil {
+		return base
+	}
+
+	if c.fallback != nil {
+		presentCertificate(base, *c.fallback)
+	}
+	if len(c.byHost) == 0 {
+		return base
+	}
+
+	router := &hostRouter{base: base, byHost: make(map[string]*http.Transport, len(c.byHost))}
+	for host, cert := range c.byHost {
+		transport := base.Clone()
+		presentCertificate(transport, cert)
+		router.byHost[host] = transport
+	}
+	return router
+}
+
+// presentCertificate makes transport offer cert when a server asks for one
+func presentCertificate(transport *http.Transport, cert tls.Certificate) {
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	transport.TLSClientConfig.Certificates = []tls.Certificate{cert}
+}
+
+// hostRouter sends each request through the transport for its host. Each
+// transport keeps its own connections, so a connection made with one
+// backend's certificate is never reused for another.
+type hostRouter struct {
+	base   *http.Transport
+	byHost map[string]*http.Transport
+}
+
+func (r *hostRouter) RoundTrip(req *http.Request) (*http.Response, error) {
+	if transport, ok := r.byHost[req.URL.Host]; ok {
+		return transport.RoundTrip(req)
+	}
+	return r.base.RoundTrip(req)
+}
+
+// CloseIdleConnections closes idle connections on every transport
+func (r *hostRouter) CloseIdleConnections() {
+	r.base.CloseIdleConnections()
+	for _, transport := range r.byHost {
+		transport.CloseIdleConnections()
+	}
+}
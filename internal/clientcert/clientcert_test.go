@@ -0,0 +1,158 @@
+package clientcert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go-balancer/internal/config"
+)
+
+func TestTransportPresentsClientCertificates(t *testing.T) {
+	global := writeClientCert(t, "global")
+	billing := writeClientCert(t, "billing")
+
+	clientCAs := x509.NewCertPool()
+	for _, files := range []config.ClientCert{global, billing} {
+		data, err := os.ReadFile(files.CertFile)
+		if err != nil {
+			t.Fatalf("Failed to read certificate: %v", err)
+		}
+		clientCAs.AppendCertsFromPEM(data)
+	}
+	newBackend := func() *httptest.Server {
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+		}))
+		server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+		server.StartTLS()
+		t.Cleanup(server.Close)
+		return server
+	}
+	billingBackend := newBackend()
+	otherBackend := newBackend()
+
+	tests := []struct {
+		name            string
+		global          config.ClientCert
+		perBackend      map[string]config.ClientCert
+		backend         *httptest.Server
+		expectedSubject string // Empty when the handshake should fail
+	}{
+		{"No certificate", config.ClientCert{}, map[string]config.ClientCert{billingBackend.URL: billing}, otherBackend, ""},
+		{"Global certificate", global, nil, otherBackend, "global"},
+		{"Per-backend certificate", global, map[string]config.ClientCert{billingBackend.URL: billing}, billingBackend, "billing"},
+		{"Global certificate for other backends", global, map[string]config.ClientCert{billingBackend.URL: billing}, otherBackend, "global"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			certs, err := Load(&config.Config{UpstreamClientCert: tt.global, UpstreamClientCerts: tt.perBackend})
+			if err != nil {
+				t.Fatalf("Failed to load client certificates: %v", err)
+			}
+
+			// Both backends share one httptest certificate, so either
+			// client's transport trusts both
+			base := billingBackend.Client().Transport.(*http.Transport).Clone()
+			client := &http.Client{Transport: certs.Transport(base), Timeout: 5 * time.Second}
+
+			resp, err := client.Get(tt.backend.URL)
+			if tt.expectedSubject == "" {
+				if err == nil {
+					resp.Body.Close()
+					t.Fatalf("Expected the handshake to fail without a client certificate")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+			defer resp.Body.Close()
+
+			buf := make([]byte, 64)
+			n, _ := resp.Body.Read(buf)
+			if got := string(buf[:n]); got != tt.expectedSubject {
+				t.Errorf("Expected the backend to see certificate %q, got %q", tt.expectedSubject, got)
+			}
+		})
+	}
+}
+
+func TestLoadInvalidKeyPair(t *testing.T) {
+	first := writeClientCert(t, "first")
+	second := writeClientCert(t, "second")
+
+	tests := []struct {
+		name string
+		cfg  *config.Config
+	}{
+		{"Mismatched key", &config.Config{UpstreamClientCert: config.ClientCert{CertFile: first.CertFile, KeyFile: second.KeyFile}}},
+		{"Missing file", &config.Config{UpstreamClientCert: config.ClientCert{CertFile: first.CertFile, KeyFile: filepath.Join(t.TempDir(), "missing.pem")}}},
+		{"Per-backend mismatched key", &config.Config{UpstreamClientCerts: map[string]config.ClientCert{
+			"https://backend:8443": {CertFile: second.CertFile, KeyFile: first.KeyFile},
+		}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Load(tt.cfg); err == nil {
+				t.Errorf("Expected an error loading the key pair")
+			}
+		})
+	}
+
+	if certs, err := Load(&config.Config{}); certs != nil || err != nil {
+		t.Errorf("Expected no certificates without configuration, got %v, %v", certs, err)
+	}
+}
+
+// writeClientCert writes a self-signed client certificate named cn and its
+// key to PEM files
+func writeClientCert(t *testing.T, cn string) config.ClientCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+
+	dir := t.TempDir()
+	files := config.ClientCert{CertFile: filepath.Join(dir, "cert.pem"), KeyFile: filepath.Join(dir, "key.pem")}
+	if err := os.WriteFile(files.CertFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+	if err := os.WriteFile(files.KeyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+	return files
+}
@@ -0,0 +1,160 @@
+package clientip
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Resolver extracts the real client IP from a request, trusting
+// X-Forwarded-For only when the request arrived via a trusted proxy
+type Resolver struct {
+	trusted []*net.IPNet
+}
+
+// NewResolver creates a resolver for the given trusted proxy CIDRs.
+// Bare IP addresses are accepted and treated as single-host ranges.
+func NewResolver(trustedProxies []string) (*Resolver, error) {
+	resolver := &Resolver{trusted: make([]*net.IPNet, 0, len(trustedProxies))}
+
+	for _, proxy := range trustedProxies {
+		network, err := ParseCIDR(proxy)
+		if err != nil {
+			return nil, err
+		}
+		resolver.trusted = append(resolver.trusted, network)
+	}
+
+	return resolver, nil
+}
+
+// ParseCIDR parses a CIDR or a bare IP address into a network
+func ParseCIDR(value string) (*net.IPNet, error) {
+	value = strings.TrimSpace(value)
+
+	if !strings.Contains(value, "/") {
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address or CIDR: %q", value)
+		}
+		bits := 8 * net.IPv6len
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+			bits = 8 * net.IPv4len
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+
+	_, network, err := net.ParseCIDR(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CIDR %q: %w", value, err)
+	}
+	return network, nil
+}
+
+// ClientIP returns the originating client IP for the request.
+// X-Forwarded-For is walked right to left, skipping trusted proxies; the
+// first untrusted hop is the client. Without a trusted peer the
+// connection's remote address is used and the header is ignored.
+func (res *Resolver) ClientIP(r *http.Request) string {
+	remoteIP := hostOnly(r.RemoteAddr)
+
+	if !res.isTrusted(remoteIP) {
+		return remoteIP
+	}
+
+	hops := forwardedHops(r.Header.Values("X-Forwarded-For"))
+	clientIP := remoteIP
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := hops[i]
+		if net.ParseIP(hop) == nil {
+			// Malformed entry - don't trust anything beyond it
+			break
+		}
+		clientIP = hop
+		if !res.isTrusted(hop) {
+			break
+		}
+	}
+
+	return clientIP
+}
+
+// isTrusted checks whether ip falls within any trusted proxy range
+func (res *Resolver) isTrusted(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+
+	for _, network := range res.trusted {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// Allowlist matches client IPs against a set of allowed ranges
+type Allowlist struct {
+	allowed []*net.IPNet
+}
+
+// NewAllowlist creates an allowlist for the given CIDRs. Bare IP addresses
+// are accepted and treated as single-host ranges.
+func NewAllowlist(cidrs []string) (*Allowlist, error) {
+	allowlist := &Allowlist{allowed: make([]*net.IPNet, 0, len(cidrs))}
+
+	for _, cidr := range cidrs {
+		network, err := ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		allowlist.allowed = append(allowlist.allowed, network)
+	}
+
+	return allowlist, nil
+}
+
+// Allows reports whether ip falls within an allowed range. An empty
+// allowlist allows every client; an unparseable IP is never allowed by a
+// non-empty one.
+func (a *Allowlist) Allows(ip string) bool {
+	if len(a.allowed) == 0 {
+		return true
+	}
+
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range a.allowed {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedHops flattens X-Forwarded-For header values into individual addresses
+func forwardedHops(values []string) []string {
+	var hops []string
+	for _, value := range values {
+		for _, hop := range strings.Split(value, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	return hops
+}
+
+// hostOnly strips the port from an address, if present
+func hostOnly(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
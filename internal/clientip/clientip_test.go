@@ -0,0 +1,89 @@
+package clientip
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	resolver, err := NewResolver([]string{"10.0.0.0/8", "192.168.1.1"})
+	if err != nil {
+		t.Fatalf("Failed to create resolver: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xff        string
+		expectedIP string
+	}{
+		{"No XFF", "203.0.113.5:1234", "", "203.0.113.5"},
+		{"Spoofed XFF from untrusted peer", "203.0.113.5:1234", "1.2.3.4", "203.0.113.5"},
+		{"XFF from trusted peer", "10.0.0.1:1234", "198.51.100.7", "198.51.100.7"},
+		{"XFF from trusted single IP", "192.168.1.1:1234", "198.51.100.7", "198.51.100.7"},
+		{"Chain of trusted proxies", "10.0.0.1:1234", "198.51.100.7, 10.0.0.2, 10.0.0.3", "198.51.100.7"},
+		{"Spoofed leftmost entry", "10.0.0.1:1234", "1.2.3.4, 198.51.100.7", "198.51.100.7"},
+		{"Malformed entry stops walk", "10.0.0.1:1234", "198.51.100.7, garbage", "10.0.0.1"},
+		{"Trusted peer without XFF", "10.0.0.1:1234", "", "10.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://localhost:8000/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.xff != "" {
+				req.Header.Set("X-Forwarded-For", tt.xff)
+			}
+
+			if ip := resolver.ClientIP(req); ip != tt.expectedIP {
+				t.Errorf("Expected client IP %s, got %s", tt.expectedIP, ip)
+			}
+		})
+	}
+}
+
+func TestNewResolverInvalidCIDR(t *testing.T) {
+	if _, err := NewResolver([]string{"10.0.0.0/33"}); err == nil {
+		t.Errorf("Expected error for invalid CIDR")
+	}
+
+	if _, err := NewResolver([]string{"not-an-ip"}); err == nil {
+		t.Errorf("Expected error for invalid IP")
+	}
+}
+
+func TestAllowlist(t *testing.T) {
+	allowlist, err := NewAllowlist([]string{"10.0.0.0/8", "192.168.1.1", "fd00::/8"})
+	if err != nil {
+		t.Fatalf("Failed to create allowlist: %v", err)
+	}
+
+	tests := []struct {
+		ip      string
+		allowed bool
+	}{
+		{"10.1.2.3", true},
+		{"192.168.1.1", true},
+		{"192.168.1.2", false},
+		{"fd00::1", true},
+		{"203.0.113.5", false},
+		{"garbage", false},
+	}
+	for _, tt := range tests {
+		if allowed := allowlist.Allows(tt.ip); allowed != tt.allowed {
+			t.Errorf("Allows(%q): expected %v, got %v", tt.ip, tt.allowed, allowed)
+		}
+	}
+
+	empty, err := NewAllowlist(nil)
+	if err != nil {
+		t.Fatalf("Failed to create empty allowlist: %v", err)
+	}
+	if !empty.Allows("203.0.113.5") {
+		t.Errorf("Expected an empty allowlist to allow every client")
+	}
+
+	if _, err := NewAllowlist([]string{"10.0.0.0/33"}); err == nil {
+		t.Errorf("Expected error for invalid CIDR")
+	}
+}
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Request attributes that can force routing to the canary backend
+const (
+	CanaryMatchHeaderPrefix = "header:"
+	CanaryMatchCookiePrefix = "cookie:"
+)
+
+// CanaryMatch selects requests that always go to the canary backend
+type CanaryMatch struct {
+	Cookie bool   // Match a cookie rather than a header
+	Name   string // Header or cookie name
+	Value  string // Required value; empty matches any value
+}
+
+// ParseCanaryMatch parses "header:<Name>[=<value>]" or "cookie:<Name>[=<value>]"
+func ParseCanaryMatch(spec string) (*CanaryMatch, error) {
+	m := &CanaryMatch{}
+
+	rest, isHeader := strings.CutPrefix(spec, CanaryMatchHeaderPrefix)
+	if !isHeader {
+		var isCookie bool
+		if rest, isCookie = strings.CutPrefix(spec, CanaryMatchCookiePrefix); !isCookie {
+			return nil, fmt.Errorf("canary match must start with %q or %q: %q",
+				CanaryMatchHeaderPrefix, CanaryMatchCookiePrefix, spec)
+		}
+		m.Cookie = true
+	}
+
+	name, value, _ := strings.Cut(rest, "=")
+	m.Name = strings.TrimSpace(name)
+	m.Value = strings.TrimSpace(value)
+
+	// Cookie names follow the same token rules as header names
+	if !isValidHeaderName(m.Name) {
+		return nil, fmt.Errorf("invalid canary match name %q", m.Name)
+	}
+	return m, nil
+}
+
+// Matches reports whether the request carries the header or cookie, with the
+// required value if one was given
+func (m *CanaryMatch) Matches(r *http.Request) bool {
+	if m == nil {
+		return false
+	}
+
+	var values []string
+	if m.Cookie {
+		for _, cookie := range r.Cookies() {
+			if cookie.Name == m.Name {
+				values = append(values, cookie.Value)
+			}
+		}
+	} else {
+		values = r.Header.Values(m.Name)
+	}
+
+	for _, value := range values {
+		if m.Value == "" || value == m.Value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseCanaryMatch(t *testing.T) {
+	tests := []struct {
+		spec      string
+		expected  CanaryMatch
+		expectErr bool
+	}{
+		{"header:X-Canary", CanaryMatch{Name: "X-Canary"}, false},
+		{"header:X-Canary=always", CanaryMatch{Name: "X-Canary", Value: "always"}, false},
+		{"cookie:canary=1", CanaryMatch{Cookie: true, Name: "canary", Value: "1"}, false},
+		{"cookie:canary", CanaryMatch{Cookie: true, Name: "canary"}, false},
+		{"X-Canary", CanaryMatch{}, true},
+		{"header:", CanaryMatch{}, true},
+		{"cookie:bad name=1", CanaryMatch{}, true},
+	}
+
+	for _, tt := range tests {
+		m, err := ParseCanaryMatch(tt.spec)
+		if tt.expectErr {
+			if err == nil {
+				t.Errorf("Expected error for %q", tt.spec)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %v", tt.spec, err)
+			continue
+		}
+		if *m != tt.expected {
+			t.Errorf("ParseCanaryMatch(%q): expected %+v, got %+v", tt.spec, tt.expected, *m)
+		}
+	}
+}
+
+func TestCanaryMatchMatches(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     string
+		header   map[string]string
+		cookie   *http.Cookie
+		expected bool
+	}{
+		{"Header present", "header:X-Canary", map[string]string{"X-Canary": "yes"}, nil, true},
+		{"Header absent", "header:X-Canary", nil, nil, false},
+		{"Header value matches", "header:X-Canary=always", map[string]string{"X-Canary": "always"}, nil, true},
+		{"Header value differs", "header:X-Canary=always", map[string]string{"X-Canary": "never"}, nil, false},
+		{"Cookie matches", "cookie:canary=1", nil, &http.Cookie{Name: "canary", Value: "1"}, true},
+		{"Cookie value differs", "cookie:canary=1", nil, &http.Cookie{Name: "canary", Value: "0"}, false},
+		{"Header doesn't satisfy cookie rule", "cookie:canary", map[string]string{"canary": "1"}, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := ParseCanaryMatch(tt.spec)
+			if err != nil {
+				t.Fatalf("Failed to parse %q: %v", tt.spec, err)
+			}
+
+			req := httptest.NewRequest("GET", "/api", nil)
+			for name, value := range tt.header {
+				req.Header.Set(name, value)
+			}
+			if tt.cookie != nil {
+				req.AddCookie(tt.cookie)
+			}
+
+			if got := m.Matches(req); got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
@@ -2,12 +2,227 @@ package config
 
 import "time"
 
+// Health check types
+const (
+	HealthCheckHTTP = "http"
+	HealthCheckTCP  = "tcp"
+	HealthCheckGRPC = "grpc"
+)
+
+// How adding a backend URL that is already in the pool is handled
+const (
+	DuplicateBackendsReject = "reject"
+	DuplicateBackendsIgnore = "ignore"
+)
+
+// How proxy errors are written to clients
+const (
+	ErrorFormatText = "text" // Plain text message
+	ErrorFormatJSON = "json" // JSON {"error":{"code":...,"message":...}}
+	ErrorFormatAuto = "auto" // JSON when the request's Accept header lists application/json, text otherwise
+)
+
+// Hash key sources for hash-based strategies
+const (
+	HashKeyClientIP     = "client-ip"
+	HashKeyHeaderPrefix = "header:"
+)
+
+// ClientCert names the PEM files of a TLS client certificate and its key
+type ClientCert struct {
+	CertFile string
+	KeyFile  string
+}
+
 // Config holds the configuration for our load balancer
 type Config struct {
 	Port                int
 	Backends            []string      // List of backend server URLs
 	HealthCheckPath     string        // Path to use for health checks
+	HealthCheckMethod   string        // HTTP method to use for health checks (defaults to GET)
 	HealthCheckInterval time.Duration // Interval between health checks
 	HealthCheckTimeout  time.Duration // Timeout for health check requests
 	BackendTimeout      time.Duration // Timeout for backend requests
+	TrustedProxies      []string      // CIDRs of proxies whose X-Forwarded-For is trusted
+	AllowedClientCIDRs  []string      // CIDRs of clients allowed to use the balancer; others get 403 (empty allows all)
+
+	DisableHealthChecks bool // Never probe backends; they start healthy and only change state through the admin API
+
+	HealthCheckBody        string // Request body sent with HTTP health probes; needs a method that allows one, e.g. POST (optional)
+	HealthCheckContentType string // Content-Type of HealthCheckBody (defaults to application/json)
+
+	MethodTimeouts map[string]time.Duration // Backend timeouts keyed by HTTP method (defaults to BackendTimeout)
+
+	BackupBackends []string // Backend URLs used only while no primary backend is healthy
+
+	ShadowBackend string // Backend URL that receives a copy of every request; its responses are discarded (optional)
+
+	CanaryBackend string  // Backend URL that receives a share of traffic for canary releases (optional)
+	CanaryPercent float64 // Percentage of requests, 0-100, sent to the canary
+	CanaryMatch   string  // Requests always sent to the canary: "header:<Name>[=<value>]" or "cookie:<Name>[=<value>]"
+
+	GreenBackends []string // Second backend group for blue/green traffic splits; Backends form the blue group
+	GreenPercent  float64  // Percentage of requests, 0-100, sent to the green group; the rest go to blue
+
+	MaxBackends int // Upper bound on backends in the pool, including discovered ones (0 = unlimited)
+
+	DuplicateBackends string // Adding a URL already in the pool (same scheme, host and port): "reject" (default) or "ignore"
+
+	UpstreamScheme  string            // Scheme used to reach every backend, overriding its URL ("http" or "https"; empty keeps the URL's)
+	UpstreamSchemes map[string]string // Per-backend scheme overrides keyed by backend URL, taking precedence over UpstreamScheme
+
+	UpstreamClientCert  ClientCert            // Client certificate presented to https backends that require mutual TLS (optional)
+	UpstreamClientCerts map[string]ClientCert // Per-backend client certificates keyed by backend URL, taking precedence over UpstreamClientCert
+
+	DetectBackendProtocol bool // Probe each backend once for HTTP/2 support and speak HTTP/2 to plain-HTTP backends that have it
+
+	DisableKeepAlive         bool     // Open a fresh connection to the backend for every request
+	DisableKeepAliveBackends []string // Backend URLs that get a fresh connection for every request
+	DialRetries              int      // Extra attempts to connect to a backend, with jittered backoff, before the request fails (0 disables)
+
+	RetryUpstreamEOF       bool  // Retry a request without a body once when the backend closes the connection before anything reached the client
+	ResponseBufferMaxBytes int64 // Responses up to this size are read in full before being sent, so late failures can still get an error status (0 streams everything)
+
+	StreamIdleReadTimeout  time.Duration // Abort a response body when the backend sends nothing for this long (0 disables)
+	StreamIdleWriteTimeout time.Duration // Abort a response body when a write to the client makes no progress for this long (0 disables)
+
+	HonorRetryAfter    bool          // Skip backends that answer 429/503 with Retry-After until it elapses
+	MaxRetryAfterDelay time.Duration // Longest backoff a backend's Retry-After can request (defaults to 1 minute)
+
+	ErrorBudgetThreshold float64       // Share of failed requests, 0-1, above which a backend gets no new requests until failures age out (0 disables)
+	ErrorBudgetWindow    time.Duration // Rolling window the error budget is measured over (defaults to 1 minute)
+
+	StaticResponses map[string]StaticResponse // Responses served locally for exact request paths, without a backend
+
+	AllowedMethods []string // Request methods forwarded to backends; others get 405 (empty allows all)
+	DeniedPaths    []string // Path prefixes, or "regex:<pattern>", rejected with 403
+
+	ConnectTunnels        bool     // Act as a forward proxy for CONNECT requests, tunneling TCP to allowed targets (off by default)
+	ConnectAllowedTargets []string // host:port targets CONNECT may reach; "*.example.com:443" matches subdomains and "host:*" any port
+
+	RequestTimeout time.Duration // Bound on handling a whole client request, including queueing and retries (0 = unbounded)
+
+	SlowRequestThreshold time.Duration // Backend responses taking at least this long are logged and counted as slow (0 disables)
+
+	HealthCheckIdleTimeout time.Duration // Idle connection timeout for health probes (0 = twice the interval)
+	HealthCheckConcurrency int           // Maximum concurrent health probes (0 = default)
+	HealthCheckType        string        // Probe type: "http" (default), "tcp" or "grpc"
+
+	HealthCheckGRPCService string // Service name sent in grpc health checks (empty asks about the server as a whole)
+
+	HealthCheckJitter float64 // Fraction of the interval by which each probe is randomly delayed (0 disables)
+
+	HealthCheckIntervals map[string]time.Duration // Per-backend probe intervals keyed by backend URL (defaults to HealthCheckInterval)
+	HealthCheckTimeouts  map[string]time.Duration // Per-backend probe timeouts keyed by backend URL (defaults to HealthCheckTimeout)
+
+	HealthCheckRequireHeader      string // Response header a probe must carry to count as healthy (optional)
+	HealthCheckRequireHeaderValue string // Required value of that header (empty accepts any value)
+
+	HealthCheckFollowRedirects bool // Follow redirects from the health endpoint instead of judging the 3xx response itself
+
+	HealthCheckSNI    string // TLS server name sent and verified by probes to https backends (defaults to the URL host)
+	HealthCheckCAFile string // PEM CA bundle probes verify https backends against (defaults to the system roots)
+
+	HealthCheckStartupGrace time.Duration // After startup, how long backends that have never passed a probe stay pending and are retried quickly (0 disables)
+
+	HealthCheckStartupBurst         int           // Probes of every backend in quick succession at startup before the regular interval applies (0 or 1 probes once)
+	HealthCheckStartupBurstInterval time.Duration // Time between startup burst probes (defaults to 500ms)
+
+	HealthCheckHealthyMaxInterval time.Duration // Longest interval a backend that keeps passing probes backs off to (0 disables adaptive intervals)
+
+	HealthCheckFlapThreshold int           // Times a backend may go down within the flap window before it is held unhealthy (0 disables damping)
+	HealthCheckFlapWindow    time.Duration // Window flaps are counted over (defaults to 5 minutes)
+	HealthCheckFlapPenalty   time.Duration // How long a flapping backend is held unhealthy, even while probes pass (defaults to 10 minutes)
+
+	Strategy       string         // Load balancing strategy name (defaults to round-robin)
+	BackendWeights map[string]int // Per-backend weights keyed by backend URL (defaults to 1)
+	HashKey        string         // Key for hash-based strategies: "client-ip" (default) or "header:<Name>"
+
+	AffinityHeader string // Requests with the same value of this header go to the same backend (optional)
+
+	LocalZone    string            // Zone this balancer runs in; backends in it are preferred while any is available (optional)
+	BackendZones map[string]string // Per-backend zones keyed by backend URL
+
+	Routes []string // Per-route backends and strategies as [host]/prefix=strategy[@backend,...]
+
+	DiscoverySRV      string        // DNS SRV name to discover backends from (optional)
+	DiscoveryInterval time.Duration // How often to re-resolve the SRV record
+	DiscoveryScheme   string        // Scheme for discovered backend URLs (defaults to http)
+
+	BackendsFile         string        // File listing one backend URL per line, watched for changes (optional)
+	BackendsFileInterval time.Duration // How often to check the backends file for changes
+
+	MetricsProvider string            // Metrics exposition format (defaults to prometheus)
+	MetricsPrefix   string            // Start of every Prometheus metric name (defaults to go_balancer)
+	MetricsLabels   map[string]string // Labels added to every Prometheus metric, e.g. instance=lb-1 (optional)
+
+	StatsDAddress  string        // host:port of a StatsD server to push metrics to (empty disables)
+	StatsDInterval time.Duration // How often metrics are pushed to StatsD
+	StatsDPrefix   string        // Prefix for StatsD metric names
+
+	DrainWindows []string // Scheduled maintenance windows as url=start/end (RFC 3339)
+
+	AdminAddress string // host:port the admin API (/status and /admin/*) listens on, apart from proxied traffic (empty disables it)
+
+	ReusePort       bool          // Bind with SO_REUSEPORT so a new instance can take over the port
+	ShutdownTimeout time.Duration // How long to wait for in-flight requests on shutdown
+
+	ProxyProtocol bool // Expect a PROXY protocol v1/v2 header on every accepted connection
+
+	MaxConnections int // Maximum client connections open at once; further clients wait to be accepted (0 means unlimited)
+
+	MaxHeaderBytes int // Maximum size of inbound request headers in bytes (defaults to 1 MiB)
+
+	MaxResponseHeaderBytes int64 // Maximum size of backend response headers in bytes; larger ones get 502 (defaults to 1 MiB)
+
+	TLSCertFile  string // PEM certificate chain served to clients; with TLSKeyFile enables TLS
+	TLSKeyFile   string // PEM private key for TLSCertFile
+	DisableHTTP2 bool   // Only offer HTTP/1.1 over TLS instead of negotiating HTTP/2 with ALPN
+
+	TLSClientCAFile   string // PEM CA bundle client certificates are verified against; clients may then present one
+	ForwardClientCert bool   // Pass the client certificate's subject, issuer and serial to backends in X-Client-Cert-* headers
+
+	AccessLog              string        // File to write JSON access logs to, or "-" for stdout (empty disables)
+	AccessLogSampleRate    int           // Log 1 in N requests (defaults to 1, every request)
+	AccessLogSlowThreshold time.Duration // Requests at least this slow are always logged (0 disables)
+
+	LogFile       string // File to write the server log to instead of stderr (empty keeps stderr)
+	LogMaxSize    int    // Size in megabytes at which the log file is rotated (defaults to 100)
+	LogMaxBackups int    // Rotated log files to keep (0 keeps none)
+
+	ShutdownDrainPeriod time.Duration // How long to fail readiness and reject new requests before closing the listener
+
+	RateLimit      float64 // Requests per second allowed per client IP (0 disables rate limiting)
+	RateLimitBurst int     // Requests a client may make in a burst (defaults to the rate, at least 1)
+
+	RateLimitResponseBody        string // Body of 429 responses (defaults to a plain message)
+	RateLimitResponseContentType string // Content-Type of 429 responses (defaults to text/plain)
+
+	ErrorFormat         string // Proxy error responses: "text" (default), "json" or "auto" (JSON for clients accepting application/json)
+	ErrorIncludeContext bool   // Include the error's context (e.g. the backend ID) in JSON error responses
+
+	MaxConcurrentRequests   int           // Requests handled at once before new ones get 503 (0 disables the limit)
+	ConcurrencyQueueTimeout time.Duration // How long a request may wait for a free slot before being rejected (0 rejects at once)
+
+	MaxBackendConnections int           // Requests each backend handles at once; once every backend is at it, new ones get 503 (0 disables the limit)
+	BackendQueueTimeout   time.Duration // How long a request may wait for a backend below its connection limit before being rejected (0 rejects at once)
+
+	ServeStaleOnError  bool     // Serve the last good cached GET response when no backend is healthy
+	FailureStatusCodes []string // Backend status codes counted as failures, e.g. "5xx", "429", "500-504" (default 5xx)
+
+	ResponseHeaders       map[string]string // Headers added to (or overriding) every response
+	RemoveResponseHeaders []string          // Upstream response headers to strip (e.g. Server)
+	ServedByHeader        bool              // Name the backend that served each response in X-Served-By (off by default so topology isn't exposed)
+	ServerTimingHeader    bool              // Report upstream latency to clients in a Server-Timing header
+	ForceBackendHeader    bool              // Route requests naming a backend ID in X-Force-Backend to that backend, for debugging (off by default so clients can't pick backends)
+
+	RequestHeaders         map[string]string `redact:"true"` // Headers injected into every backend request (may carry API keys)
+	OverrideRequestHeaders bool              // Replace caller-provided values for injected request headers
+	RequestIDHeader        string            // Header carrying the per-request ID to backends, clients and access logs (defaults to X-Request-ID)
+
+	UpstreamUserAgent string // User-Agent sent to backends in place of the client's (empty forwards the client's unchanged)
+	AppendUserAgent   bool   // Append UpstreamUserAgent to the client's User-Agent instead of replacing it
+
+	DefaultQueryParams map[string]string `redact:"true"` // Query parameters added to backend requests when absent (may carry API keys)
+	RemoveQueryParams  []string          // Query parameters stripped before forwarding
 }
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// ConnectTargets matches the host:port a CONNECT request asks to reach
+// against an allowlist
+type ConnectTargets struct {
+	rules []connectRule
+}
+
+// connectRule is one allowlist entry
+type connectRule struct {
+	host     string // Lowercased host, or the domain after "*." for wildcards
+	wildcard bool   // Matches subdomains of host rather than host itself
+	port     string // Port to match, or "*" for any
+}
+
+// ParseConnectTargets parses allowlist entries such as "db.internal:5432".
+// A host of "*.example.com" matches any subdomain of example.com, and a port
+// of "*" matches any port.
+func ParseConnectTargets(targets []string) (*ConnectTargets, error) {
+	t := &ConnectTargets{}
+	for _, target := range targets {
+		host, port, err := net.SplitHostPort(strings.TrimSpace(target))
+		if err != nil {
+			return nil, fmt.Errorf("CONNECT target must be host:port: %q", target)
+		}
+		if port != "*" {
+			if n, err := strconv.Atoi(port); err != nil || n <= 0 || n > 65535 {
+				return nil, fmt.Errorf("invalid port in CONNECT target %q", target)
+			}
+		}
+
+		rule := connectRule{host: strings.ToLower(host), port: port}
+		if domain, ok := strings.CutPrefix(rule.host, "*."); ok {
+			rule.host, rule.wildcard = domain, true
+		}
+		if rule.host == "" || strings.Contains(rule.host, "*") {
+			return nil, fmt.Errorf("invalid host in CONNECT target %q", target)
+		}
+		t.rules = append(t.rules, rule)
+	}
+	return t, nil
+}
+
+// Allows reports whether a CONNECT request may reach target, given as
+// host:port. Nothing is allowed by an empty allowlist.
+func (t *ConnectTargets) Allows(target string) bool {
+	if t == nil {
+		return false
+	}
+
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		return false
+	}
+	host = strings.ToLower(host)
+
+	for _, rule := range t.rules {
+		if rule.port != "*" && rule.port != port {
+			continue
+		}
+		if rule.wildcard && strings.HasSuffix(host, "."+rule.host) {
+			return true
+		}
+		if !rule.wildcard && host == rule.host {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package config
+
+import "testing"
+
+func TestConnectTargets(t *testing.T) {
+	targets, err := ParseConnectTargets([]string{"db.internal:5432", "*.example.com:443", "cache.internal:*"})
+	if err != nil {
+		t.Fatalf("Failed to parse targets: %v", err)
+	}
+
+	tests := []struct {
+		target   string
+		expected bool
+	}{
+		{"db.internal:5432", true},
+		{"DB.Internal:5432", true},
+		{"db.internal:5433", false},
+		{"api.example.com:443", true},
+		{"a.b.example.com:443", true},
+		{"example.com:443", false}, // wildcards only match subdomains
+		{"api.example.com:80", false},
+		{"evilexample.com:443", false},
+		{"cache.internal:6379", true},
+		{"other.internal:5432", false},
+		{"db.internal", false}, // no port
+	}
+
+	for _, tt := range tests {
+		if got := targets.Allows(tt.target); got != tt.expected {
+			t.Errorf("Allows(%q): expected %v, got %v", tt.target, tt.expected, got)
+		}
+	}
+
+	var empty *ConnectTargets
+	if empty.Allows("db.internal:5432") {
+		t.Errorf("Expected a nil allowlist to allow nothing")
+	}
+}
+
+func TestParseConnectTargetsInvalid(t *testing.T) {
+	for _, target := range []string{"db.internal", "db.internal:0", "db.internal:http", ":443", "*:443", "a.*.com:443"} {
+		if _, err := ParseConnectTargets([]string{target}); err == nil {
+			t.Errorf("Expected error for target %q", target)
+		}
+	}
+}
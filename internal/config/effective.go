@@ -0,0 +1,231 @@
+package config
+
+import (
+	"math"
+	"net/http"
+	"reflect"
+	"time"
+
+	"go-balancer/internal/metrics"
+	"go-balancer/internal/strategy"
+)
+
+// DefaultHealthCheckConcurrency is the probe concurrency used when none is configured
+const DefaultHealthCheckConcurrency = 10
+
+// DefaultHealthCheckStartupBurstInterval spaces startup burst probes when unset
+const DefaultHealthCheckStartupBurstInterval = 500 * time.Millisecond
+
+// DefaultDiscoveryInterval is how often SRV records are re-resolved when unset
+const DefaultDiscoveryInterval = 30 * time.Second
+
+// DefaultBackendsFileInterval is how often the backends file is checked when unset
+const DefaultBackendsFileInterval = 5 * time.Second
+
+// DefaultStatsDInterval is how often metrics are pushed to StatsD when unset
+const DefaultStatsDInterval = 10 * time.Second
+
+// DefaultShutdownTimeout bounds graceful shutdown when unset
+const DefaultShutdownTimeout = 30 * time.Second
+
+// DefaultMaxRetryAfterDelay caps backend-requested backoff when unset
+const DefaultMaxRetryAfterDelay = time.Minute
+
+// DefaultErrorBudgetWindow is the window backend error rates are measured over when unset
+const DefaultErrorBudgetWindow = time.Minute
+
+// Flap damping settings used when unset
+const (
+	DefaultHealthCheckFlapWindow  = 5 * time.Minute
+	DefaultHealthCheckFlapPenalty = 10 * time.Minute
+)
+
+// DefaultHealthCheckContentType is the Content-Type of health probe bodies
+// when unset
+const DefaultHealthCheckContentType = "application/json"
+
+// DefaultMaxResponseHeaderBytes bounds backend response headers when unset
+const DefaultMaxResponseHeaderBytes = 1 << 20
+
+// DefaultRateLimitContentType is the Content-Type of 429 responses when unset
+const DefaultRateLimitContentType = "text/plain; charset=utf-8"
+
+// DefaultLogMaxSize is the log file rotation size in megabytes when unset
+const DefaultLogMaxSize = 100
+
+// DefaultRequestIDHeader carries the per-request ID when no header is configured
+const DefaultRequestIDHeader = "X-Request-ID"
+
+// redactedValue replaces sensitive values in config dumps
+const redactedValue = "[REDACTED]"
+
+// WithDefaults returns a copy of the config with unset optional fields
+// resolved to the values the load balancer actually uses
+func (c *Config) WithDefaults() *Config {
+	effective := *c
+
+	if effective.HealthCheckMethod == "" {
+		effective.HealthCheckMethod = http.MethodGet
+	}
+	if effective.HealthCheckType == "" {
+		effective.HealthCheckType = HealthCheckHTTP
+	}
+	if effective.HealthCheckBody != "" && effective.HealthCheckContentType == "" {
+		effective.HealthCheckContentType = DefaultHealthCheckContentType
+	}
+	if effective.HealthCheckIdleTimeout == 0 {
+		effective.HealthCheckIdleTimeout = 2 * effective.HealthCheckInterval
+	}
+	if effective.HealthCheckConcurrency <= 0 {
+		effective.HealthCheckConcurrency = DefaultHealthCheckConcurrency
+	}
+	if effective.HealthCheckStartupBurst > 1 && effective.HealthCheckStartupBurstInterval == 0 {
+		effective.HealthCheckStartupBurstInterval = DefaultHealthCheckStartupBurstInterval
+	}
+	if effective.Strategy == "" {
+		effective.Strategy = strategy.RoundRobin
+	}
+	if effective.DuplicateBackends == "" {
+		effective.DuplicateBackends = DuplicateBackendsReject
+	}
+	if effective.MaxHeaderBytes == 0 {
+		effective.MaxHeaderBytes = http.DefaultMaxHeaderBytes
+	}
+	if effective.MaxResponseHeaderBytes == 0 {
+		effective.MaxResponseHeaderBytes = DefaultMaxResponseHeaderBytes
+	}
+	if effective.ShutdownTimeout == 0 {
+		effective.ShutdownTimeout = DefaultShutdownTimeout
+	}
+	if effective.MetricsProvider == "" {
+		effective.MetricsProvider = metrics.PrometheusProvider
+	}
+	if effective.MetricsPrefix == "" {
+		effective.MetricsPrefix = metrics.DefaultPrometheusPrefix
+	}
+	if effective.StatsDAddress != "" {
+		if effective.StatsDInterval == 0 {
+			effective.StatsDInterval = DefaultStatsDInterval
+		}
+		if effective.StatsDPrefix == "" {
+			effective.StatsDPrefix = metrics.DefaultStatsDPrefix
+		}
+	}
+	if effective.HashKey == "" {
+		effective.HashKey = HashKeyClientIP
+	}
+	if effective.RequestIDHeader == "" {
+		effective.RequestIDHeader = DefaultRequestIDHeader
+	}
+
+	if effective.DiscoverySRV != "" {
+		if effective.DiscoveryInterval == 0 {
+			effective.DiscoveryInterval = DefaultDiscoveryInterval
+		}
+		if effective.DiscoveryScheme == "" {
+			effective.DiscoveryScheme = "http"
+		}
+	}
+
+	if effective.ErrorFormat == "" {
+		effective.ErrorFormat = ErrorFormatText
+	}
+
+	if effective.RateLimit > 0 {
+		if effective.RateLimitBurst == 0 {
+			effective.RateLimitBurst = max(1, int(math.Ceil(effective.RateLimit)))
+		}
+		if effective.RateLimitResponseContentType == "" {
+			effective.RateLimitResponseContentType = DefaultRateLimitContentType
+		}
+	}
+
+	if len(effective.StaticResponses) > 0 {
+		responses := make(map[string]StaticResponse, len(effective.StaticResponses))
+		for path, response := range effective.StaticResponses {
+			if response.ContentType == "" {
+				response.ContentType = DefaultStaticContentType
+			}
+			responses[path] = response
+		}
+		effective.StaticResponses = responses
+	}
+
+	if effective.AccessLog != "" && effective.AccessLogSampleRate == 0 {
+		effective.AccessLogSampleRate = 1
+	}
+
+	if effective.LogFile != "" && effective.LogMaxSize == 0 {
+		effective.LogMaxSize = DefaultLogMaxSize
+	}
+
+	if effective.HonorRetryAfter && effective.MaxRetryAfterDelay == 0 {
+		effective.MaxRetryAfterDelay = DefaultMaxRetryAfterDelay
+	}
+
+	if effective.ErrorBudgetThreshold > 0 && effective.ErrorBudgetWindow == 0 {
+		effective.ErrorBudgetWindow = DefaultErrorBudgetWindow
+	}
+
+	if effective.HealthCheckFlapThreshold > 0 {
+		if effective.HealthCheckFlapWindow == 0 {
+			effective.HealthCheckFlapWindow = DefaultHealthCheckFlapWindow
+		}
+		if effective.HealthCheckFlapPenalty == 0 {
+			effective.HealthCheckFlapPenalty = DefaultHealthCheckFlapPenalty
+		}
+	}
+
+	if effective.BackendsFile != "" && effective.BackendsFileInterval == 0 {
+		effective.BackendsFileInterval = DefaultBackendsFileInterval
+	}
+
+	return &effective
+}
+
+// Redacted returns the config as a JSON-friendly map keyed by field name.
+// Durations are rendered as strings and fields tagged `redact:"true"` have
+// their values masked; for maps only the values are masked so the keys
+// (e.g. header names) stay visible.
+func (c *Config) Redacted() map[string]interface{} {
+	out := make(map[string]interface{})
+
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		value := v.Field(i)
+
+		if field.Tag.Get("redact") == "true" {
+			out[field.Name] = redact(value)
+			continue
+		}
+
+		if d, ok := value.Interface().(time.Duration); ok {
+			out[field.Name] = d.String()
+			continue
+		}
+
+		out[field.Name] = value.Interface()
+	}
+
+	return out
+}
+
+// redact masks a sensitive value, keeping map keys and leaving empty values empty
+func redact(value reflect.Value) interface{} {
+	if value.IsZero() {
+		return value.Interface()
+	}
+
+	if value.Kind() == reflect.Map {
+		masked := make(map[string]string, value.Len())
+		iter := value.MapRange()
+		for iter.Next() {
+			masked[iter.Key().String()] = redactedValue
+		}
+		return masked
+	}
+
+	return redactedValue
+}
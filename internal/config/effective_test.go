@@ -0,0 +1,90 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"go-balancer/internal/metrics"
+)
+
+func TestWithDefaults(t *testing.T) {
+	cfg := &Config{
+		Port:                8000,
+		Backends:            []string{"http://localhost:8080"},
+		HealthCheckPath:     "/",
+		HealthCheckInterval: 10 * time.Second,
+		HealthCheckTimeout:  2 * time.Second,
+		BackendTimeout:      30 * time.Second,
+	}
+
+	effective := cfg.WithDefaults()
+
+	if effective.HealthCheckMethod != "GET" {
+		t.Errorf("Expected default health check method GET, got %q", effective.HealthCheckMethod)
+	}
+	if effective.HealthCheckIdleTimeout != 20*time.Second {
+		t.Errorf("Expected derived idle timeout 20s, got %s", effective.HealthCheckIdleTimeout)
+	}
+	if effective.HealthCheckConcurrency != DefaultHealthCheckConcurrency {
+		t.Errorf("Expected default concurrency %d, got %d", DefaultHealthCheckConcurrency, effective.HealthCheckConcurrency)
+	}
+	if effective.Strategy != "round-robin" {
+		t.Errorf("Expected default strategy round-robin, got %q", effective.Strategy)
+	}
+	if effective.HashKey != HashKeyClientIP {
+		t.Errorf("Expected default hash key %q, got %q", HashKeyClientIP, effective.HashKey)
+	}
+	if effective.LogMaxSize != 0 {
+		t.Errorf("Expected no log file size without a log file, got %d", effective.LogMaxSize)
+	}
+	if effective.HealthCheckFlapWindow != 0 || effective.HealthCheckFlapPenalty != 0 {
+		t.Errorf("Expected no flap window or penalty without damping, got %s and %s", effective.HealthCheckFlapWindow, effective.HealthCheckFlapPenalty)
+	}
+	if effective.MetricsPrefix != metrics.DefaultPrometheusPrefix {
+		t.Errorf("Expected default metrics prefix %q, got %q", metrics.DefaultPrometheusPrefix, effective.MetricsPrefix)
+	}
+	if effective.RequestIDHeader != "X-Request-ID" {
+		t.Errorf("Expected default request ID header X-Request-ID, got %q", effective.RequestIDHeader)
+	}
+	if effective.MaxResponseHeaderBytes != DefaultMaxResponseHeaderBytes {
+		t.Errorf("Expected default max response header bytes %d, got %d", DefaultMaxResponseHeaderBytes, effective.MaxResponseHeaderBytes)
+	}
+	if effective.DuplicateBackends != DuplicateBackendsReject {
+		t.Errorf("Expected duplicate backends to be rejected by default, got %q", effective.DuplicateBackends)
+	}
+
+	// The original config must be left untouched
+	if cfg.HealthCheckMethod != "" || cfg.Strategy != "" {
+		t.Errorf("Expected WithDefaults not to modify the original config")
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := &Config{
+		Port:                8000,
+		HealthCheckInterval: 10 * time.Second,
+		RequestHeaders:      map[string]string{"X-Api-Key": "super-secret"},
+	}
+
+	redacted := cfg.Redacted()
+
+	if redacted["Port"] != 8000 {
+		t.Errorf("Expected Port 8000, got %v", redacted["Port"])
+	}
+	if redacted["HealthCheckInterval"] != "10s" {
+		t.Errorf("Expected duration rendered as 10s, got %v", redacted["HealthCheckInterval"])
+	}
+
+	headers, ok := redacted["RequestHeaders"].(map[string]string)
+	if !ok {
+		t.Fatalf("Expected RequestHeaders to be a map, got %T", redacted["RequestHeaders"])
+	}
+	if headers["X-Api-Key"] != redactedValue {
+		t.Errorf("Expected X-Api-Key to be redacted, got %q", headers["X-Api-Key"])
+	}
+
+	// Redaction works on a copy
+	if cfg.RequestHeaders["X-Api-Key"] != "super-secret" {
+		t.Errorf("Expected original request header value to be preserved")
+	}
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// PathRuleRegexPrefix marks a path rule as a regular expression rather than
+// a path prefix, e.g. "regex:^/api/v[0-9]+/internal"
+const PathRuleRegexPrefix = "regex:"
+
+// PathMatcher matches request paths against prefix and regex rules
+type PathMatcher struct {
+	prefixes []string
+	patterns []*regexp.Regexp
+}
+
+// ParsePathRules parses rules such as "/admin" (prefix) or "regex:\.php$"
+func ParsePathRules(rules []string) (*PathMatcher, error) {
+	m := &PathMatcher{}
+	for _, rule := range rules {
+		rule = strings.TrimSpace(rule)
+		if pattern, ok := strings.CutPrefix(rule, PathRuleRegexPrefix); ok {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid path pattern %q: %w", pattern, err)
+			}
+			m.patterns = append(m.patterns, re)
+			continue
+		}
+		if !strings.HasPrefix(rule, "/") {
+			return nil, fmt.Errorf("path prefix must start with /: %q", rule)
+		}
+		m.prefixes = append(m.prefixes, rule)
+	}
+	return m, nil
+}
+
+// Matches reports whether the path matches any rule. The path is cleaned
+// first so dot segments and repeated slashes can't be used to slip past a
+// rule the backend would resolve to the same resource.
+func (m *PathMatcher) Matches(requestPath string) bool {
+	if m == nil {
+		return false
+	}
+
+	cleaned := path.Clean("/" + requestPath)
+	for _, prefix := range m.prefixes {
+		if strings.HasPrefix(cleaned, prefix) || strings.HasPrefix(requestPath, prefix) {
+			return true
+		}
+	}
+	for _, re := range m.patterns {
+		if re.MatchString(cleaned) || re.MatchString(requestPath) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,39 @@
+package config
+
+import "testing"
+
+func TestPathMatcher(t *testing.T) {
+	m, err := ParsePathRules([]string{"/admin", `regex:\.(php|env)$`})
+	if err != nil {
+		t.Fatalf("Failed to parse rules: %v", err)
+	}
+
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{"/admin", true},
+		{"/admin/users", true},
+		{"/api/users", false},
+		{"/index.php", true},
+		{"/config/.env", true},
+		{"/environment", false},
+		{"/api/../admin", true}, // dot segments are resolved
+		{"//admin", true},       // repeated slashes are collapsed
+		{"/", false},
+	}
+
+	for _, tt := range tests {
+		if got := m.Matches(tt.path); got != tt.expected {
+			t.Errorf("Matches(%q): expected %v, got %v", tt.path, tt.expected, got)
+		}
+	}
+}
+
+func TestParsePathRulesInvalid(t *testing.T) {
+	for _, rule := range []string{"admin", "regex:(unclosed"} {
+		if _, err := ParsePathRules([]string{rule}); err == nil {
+			t.Errorf("Expected error for rule %q", rule)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"go-balancer/internal/strategy"
+)
+
+// Route sends requests matching a host and path prefix to its own set of
+// backends, selected with its own strategy
+type Route struct {
+	Host       string   // Host to match, without port (empty matches any host)
+	PathPrefix string   // Path prefix to match, e.g. "/api"
+	Strategy   string   // Load balancing strategy for this route
+	Backends   []string // Backend URLs for this route (empty uses every primary backend)
+}
+
+// ParseRoute parses "[host]/prefix=strategy[@backend,backend...]", e.g.
+// "/api=weighted-least-connections@http://api1:8080,http://api2:8080" or
+// "static.example.com/=round-robin"
+func ParseRoute(spec string) (Route, error) {
+	pattern, target, found := strings.Cut(strings.TrimSpace(spec), "=")
+	if !found {
+		return Route{}, fmt.Errorf("route must be in the form [host]/prefix=strategy[@backends]: %q", spec)
+	}
+
+	var route Route
+	slash := strings.Index(pattern, "/")
+	if slash < 0 {
+		return Route{}, fmt.Errorf("route path prefix must start with /: %q", spec)
+	}
+	route.Host = strings.ToLower(pattern[:slash])
+	route.PathPrefix = pattern[slash:]
+
+	strategyName, backends, _ := strings.Cut(target, "@")
+	route.Strategy = strings.TrimSpace(strategyName)
+	if !strategy.IsValidName(route.Strategy) || route.Strategy == "" {
+		return Route{}, fmt.Errorf("unknown strategy %q in route %q", route.Strategy, spec)
+	}
+
+	for _, backend := range strings.Split(backends, ",") {
+		if backend = strings.TrimSpace(backend); backend != "" {
+			route.Backends = append(route.Backends, backend)
+		}
+	}
+	return route, nil
+}
+
+// Matches reports whether the request's host and path fall under the route
+func (rt Route) Matches(r *http.Request) bool {
+	if rt.Host != "" {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if !strings.EqualFold(host, rt.Host) {
+			return false
+		}
+	}
+	return strings.HasPrefix(r.URL.Path, rt.PathPrefix)
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParseRoute(t *testing.T) {
+	tests := []struct {
+		spec      string
+		expected  Route
+		expectErr bool
+	}{
+		{
+			spec:     "/api=weighted-least-connections@http://api1:8080,http://api2:8080",
+			expected: Route{PathPrefix: "/api", Strategy: "weighted-least-connections", Backends: []string{"http://api1:8080", "http://api2:8080"}},
+		},
+		{
+			spec:     "/static=round-robin",
+			expected: Route{PathPrefix: "/static", Strategy: "round-robin"},
+		},
+		{
+			spec:     "Static.Example.com/=consistent-hash",
+			expected: Route{Host: "static.example.com", PathPrefix: "/", Strategy: "consistent-hash"},
+		},
+		{spec: "/api", expectErr: true},
+		{spec: "api=round-robin", expectErr: true},
+		{spec: "/api=fastest", expectErr: true},
+		{spec: "/api=", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		route, err := ParseRoute(tt.spec)
+		if tt.expectErr {
+			if err == nil {
+				t.Errorf("Expected error for %q", tt.spec)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %v", tt.spec, err)
+			continue
+		}
+		if !reflect.DeepEqual(route, tt.expected) {
+			t.Errorf("ParseRoute(%q): expected %+v, got %+v", tt.spec, tt.expected, route)
+		}
+	}
+}
+
+func TestRouteMatches(t *testing.T) {
+	tests := []struct {
+		spec     string
+		url      string
+		expected bool
+	}{
+		{"/api=round-robin", "http://lb.example.com/api/users", true},
+		{"/api=round-robin", "http://lb.example.com/static/app.js", false},
+		{"static.example.com/=round-robin", "http://static.example.com:8000/app.js", true},
+		{"static.example.com/=round-robin", "http://STATIC.example.com/app.js", true},
+		{"static.example.com/=round-robin", "http://api.example.com/app.js", false},
+	}
+
+	for _, tt := range tests {
+		route, err := ParseRoute(tt.spec)
+		if err != nil {
+			t.Fatalf("Failed to parse %q: %v", tt.spec, err)
+		}
+		if got := route.Matches(httptest.NewRequest("GET", tt.url, nil)); got != tt.expected {
+			t.Errorf("Route %q matching %s: expected %v, got %v", tt.spec, tt.url, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// DefaultStaticContentType is the Content-Type of static responses when unset
+const DefaultStaticContentType = "text/plain; charset=utf-8"
+
+// StaticResponse is a response the load balancer serves itself for a path,
+// without contacting a backend
+type StaticResponse struct {
+	Status      int    // HTTP status code
+	ContentType string // Content-Type header (defaults to text/plain)
+	Body        string // Response body
+}
+
+// ParseStaticResponse parses "path=status[,content-type[,body]]", e.g.
+// "/ping=200,text/plain,pong" or "/favicon.ico=204". The body is everything
+// after the second comma, so it may itself contain commas.
+func ParseStaticResponse(spec string) (string, StaticResponse, error) {
+	path, rest, found := strings.Cut(strings.TrimSpace(spec), "=")
+	if !found {
+		return "", StaticResponse{}, fmt.Errorf("static response must be in the form path=status[,content-type[,body]]: %q", spec)
+	}
+	if !strings.HasPrefix(path, "/") {
+		return "", StaticResponse{}, fmt.Errorf("static response path must start with /: %q", spec)
+	}
+
+	parts := strings.SplitN(rest, ",", 3)
+	status, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return "", StaticResponse{}, fmt.Errorf("invalid status in static response %q: %w", spec, err)
+	}
+
+	response := StaticResponse{Status: status}
+	if len(parts) > 1 {
+		response.ContentType = strings.TrimSpace(parts[1])
+	}
+	if len(parts) > 2 {
+		response.Body = parts[2]
+	}
+	return path, response, nil
+}
+
+// isValidStaticStatus reports whether a static response status can be written
+func isValidStaticStatus(status int) bool {
+	return status >= 200 && status <= 599 && http.StatusText(status) != ""
+}
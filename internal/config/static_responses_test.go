@@ -0,0 +1,37 @@
+package config
+
+import "testing"
+
+func TestParseStaticResponse(t *testing.T) {
+	tests := []struct {
+		spec         string
+		expectedPath string
+		expected     StaticResponse
+		expectErr    bool
+	}{
+		{"/ping=200,text/plain,pong", "/ping", StaticResponse{Status: 200, ContentType: "text/plain", Body: "pong"}, false},
+		{"/favicon.ico=204", "/favicon.ico", StaticResponse{Status: 204}, false},
+		{`/info=200,application/json,{"a":1,"b":2}`, "/info", StaticResponse{Status: 200, ContentType: "application/json", Body: `{"a":1,"b":2}`}, false},
+		{"/empty=200,,", "/empty", StaticResponse{Status: 200}, false},
+		{"/ping", "", StaticResponse{}, true},
+		{"ping=200", "", StaticResponse{}, true},
+		{"/ping=ok,text/plain,pong", "", StaticResponse{}, true},
+	}
+
+	for _, tt := range tests {
+		path, response, err := ParseStaticResponse(tt.spec)
+		if tt.expectErr {
+			if err == nil {
+				t.Errorf("Expected error for %q", tt.spec)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %v", tt.spec, err)
+			continue
+		}
+		if path != tt.expectedPath || response != tt.expected {
+			t.Errorf("ParseStaticResponse(%q): expected %s %+v, got %s %+v", tt.spec, tt.expectedPath, tt.expected, path, response)
+		}
+	}
+}
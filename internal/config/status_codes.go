@@ -0,0 +1,80 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// StatusRange is an inclusive range of HTTP status codes
+type StatusRange struct {
+	Min int
+	Max int
+}
+
+// StatusCodeSet matches HTTP status codes against a list of ranges
+type StatusCodeSet []StatusRange
+
+// DefaultFailureStatusCodes treats every 5xx response as a failure
+var DefaultFailureStatusCodes = StatusCodeSet{{Min: 500, Max: 599}}
+
+// Contains reports whether code falls within any range in the set
+func (s StatusCodeSet) Contains(code int) bool {
+	for _, r := range s {
+		if code >= r.Min && code <= r.Max {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseStatusCodes parses specs such as "429", "500-599" or "5xx" into a set
+func ParseStatusCodes(specs []string) (StatusCodeSet, error) {
+	set := make(StatusCodeSet, 0, len(specs))
+	for _, spec := range specs {
+		r, err := parseStatusRange(strings.TrimSpace(spec))
+		if err != nil {
+			return nil, err
+		}
+		set = append(set, r)
+	}
+	return set, nil
+}
+
+// parseStatusRange parses a single status code, "a-b" range, or "Nxx" class
+func parseStatusRange(spec string) (StatusRange, error) {
+	if len(spec) == 3 && strings.HasSuffix(strings.ToLower(spec), "xx") {
+		class, err := strconv.Atoi(spec[:1])
+		if err != nil || class < 1 || class > 5 {
+			return StatusRange{}, fmt.Errorf("invalid status class: %q", spec)
+		}
+		return StatusRange{Min: class * 100, Max: class*100 + 99}, nil
+	}
+
+	lo, hi, isRange := strings.Cut(spec, "-")
+	min, err := parseStatusCode(lo)
+	if err != nil {
+		return StatusRange{}, err
+	}
+	if !isRange {
+		return StatusRange{Min: min, Max: min}, nil
+	}
+
+	max, err := parseStatusCode(hi)
+	if err != nil {
+		return StatusRange{}, err
+	}
+	if min > max {
+		return StatusRange{}, fmt.Errorf("invalid status range %q: start exceeds end", spec)
+	}
+	return StatusRange{Min: min, Max: max}, nil
+}
+
+// parseStatusCode parses a single code in the 100-599 range
+func parseStatusCode(value string) (int, error) {
+	code, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || code < 100 || code > 599 {
+		return 0, fmt.Errorf("invalid status code: %q", value)
+	}
+	return code, nil
+}
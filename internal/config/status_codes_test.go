@@ -0,0 +1,52 @@
+package config
+
+import "testing"
+
+func TestParseStatusCodes(t *testing.T) {
+	set, err := ParseStatusCodes([]string{"429", "500-504", "4xx"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		code     int
+		expected bool
+	}{
+		{200, false},
+		{404, true},
+		{429, true},
+		{502, true},
+		{504, true},
+		{505, false},
+	}
+	for _, tt := range tests {
+		if got := set.Contains(tt.code); got != tt.expected {
+			t.Errorf("Contains(%d) = %v, expected %v", tt.code, got, tt.expected)
+		}
+	}
+}
+
+func TestParseStatusCodesInvalid(t *testing.T) {
+	invalid := [][]string{
+		{"abc"},
+		{"99"},
+		{"600"},
+		{"504-500"},
+		{"6xx"},
+		{"500-"},
+	}
+	for _, specs := range invalid {
+		if _, err := ParseStatusCodes(specs); err == nil {
+			t.Errorf("Expected error for %v", specs)
+		}
+	}
+}
+
+func TestDefaultFailureStatusCodes(t *testing.T) {
+	if !DefaultFailureStatusCodes.Contains(500) || !DefaultFailureStatusCodes.Contains(599) {
+		t.Errorf("Expected default failure codes to cover 5xx")
+	}
+	if DefaultFailureStatusCodes.Contains(429) {
+		t.Errorf("Expected default failure codes to exclude 429")
+	}
+}
@@ -2,9 +2,18 @@ package config
 
 import (
 	"fmt"
+	"net"
+	"net/http"
 	"net/url"
+	"strings"
 
+	"go-balancer/internal/clientip"
 	"go-balancer/internal/errors"
+	"go-balancer/internal/listener"
+	"go-balancer/internal/metrics"
+	"go-balancer/internal/pool"
+	"go-balancer/internal/schedule"
+	"go-balancer/internal/strategy"
 )
 
 // ValidationError aggregates multiple validation errors
@@ -42,8 +51,8 @@ func ValidateConfig(c *Config) error {
 		validationErr.Add(errors.NewInvalidPortError(c.Port))
 	}
 
-	// Validate backends
-	if len(c.Backends) == 0 {
+	// Validate backends (discovery or a backends file can supply them instead)
+	if len(c.Backends) == 0 && c.DiscoverySRV == "" && c.BackendsFile == "" {
 		validationErr.Add(errors.NewInvalidConfigError("at least one backend is required", nil))
 	}
 
@@ -57,44 +66,312 @@ func ValidateConfig(c *Config) error {
 			continue
 		}
 
-		parsedURL, err := url.Parse(backend)
-		if err != nil {
-			validationErr.Add(errors.NewInvalidBackendError(backend, err).WithContext("index", i))
+		for _, err := range backendURLErrors(backend) {
+			validationErr.Add(err.WithContext("index", i))
+		}
+	}
+
+	// Validate backup backend URLs; a backend can't be both primary and backup
+	for i, backend := range c.BackupBackends {
+		if backend == "" {
+			validationErr.Add(errors.NewInvalidBackendError(
+				fmt.Sprintf("backup[%d]", i),
+				fmt.Errorf("backend cannot be empty"),
+			).WithContext("backup_index", i))
 			continue
 		}
 
-		if parsedURL.Scheme == "" {
+		for _, err := range backendURLErrors(backend) {
+			validationErr.Add(err.WithContext("backup_index", i))
+		}
+		if containsString(c.Backends, backend) {
 			validationErr.Add(errors.NewInvalidBackendError(
 				backend,
-				fmt.Errorf("must include a scheme (http:// or https://)"),
-			).WithContext("index", i))
+				fmt.Errorf("backend is configured as both primary and backup"),
+			).WithContext("backup_index", i))
 		}
+	}
 
-		if parsedURL.Host == "" {
+	// Validate the canary backend and how traffic reaches it
+	if c.CanaryBackend != "" {
+		for _, err := range backendURLErrors(c.CanaryBackend) {
+			validationErr.Add(err.WithContext("canary", true))
+		}
+		if containsString(c.Backends, c.CanaryBackend) || containsString(c.BackupBackends, c.CanaryBackend) {
+			validationErr.Add(errors.NewInvalidBackendError(
+				c.CanaryBackend,
+				fmt.Errorf("canary backend is also configured as a primary or backup"),
+			))
+		}
+	} else if c.CanaryPercent != 0 || c.CanaryMatch != "" {
+		validationErr.Add(errors.NewInvalidConfigError("canary percent and match require a canary backend", nil))
+	}
+	if c.CanaryPercent < 0 || c.CanaryPercent > 100 {
+		validationErr.Add(errors.NewInvalidConfigError(
+			fmt.Sprintf("canary percent must be between 0 and 100: %g", c.CanaryPercent), nil,
+		).WithContext("canary_percent", c.CanaryPercent))
+	}
+	if c.CanaryMatch != "" {
+		if _, err := ParseCanaryMatch(c.CanaryMatch); err != nil {
+			validationErr.Add(errors.NewInvalidConfigError("invalid canary match", err).
+				WithContext("canary_match", c.CanaryMatch))
+		}
+	}
+
+	// Validate the green backend group and its share of traffic
+	for i, backend := range c.GreenBackends {
+		for _, err := range backendURLErrors(backend) {
+			validationErr.Add(err.WithContext("green_index", i))
+		}
+		if containsString(c.Backends, backend) || containsString(c.BackupBackends, backend) || backend == c.CanaryBackend {
 			validationErr.Add(errors.NewInvalidBackendError(
 				backend,
-				fmt.Errorf("must include a host"),
-			).WithContext("index", i))
+				fmt.Errorf("green backend is also configured as a primary, backup or canary"),
+			).WithContext("green_index", i))
+		}
+	}
+	if len(c.GreenBackends) > 0 && len(c.Backends) == 0 {
+		validationErr.Add(errors.NewInvalidConfigError("green backends require blue backends to split traffic with", nil))
+	} else if len(c.GreenBackends) == 0 && c.GreenPercent != 0 {
+		validationErr.Add(errors.NewInvalidConfigError("green percent requires green backends", nil))
+	}
+	if c.GreenPercent < 0 || c.GreenPercent > 100 {
+		validationErr.Add(errors.NewInvalidConfigError(
+			fmt.Sprintf("green percent must be between 0 and 100: %g", c.GreenPercent), nil,
+		).WithContext("green_percent", c.GreenPercent))
+	}
+
+	// Validate the backend cap; the statically configured backends must fit
+	if c.MaxBackends < 0 {
+		validationErr.Add(errors.NewInvalidConfigError(
+			fmt.Sprintf("max backends cannot be negative: %d", c.MaxBackends), nil,
+		).WithContext("max_backends", c.MaxBackends))
+	} else if c.MaxBackends > 0 {
+		static := len(c.Backends) + len(c.BackupBackends) + len(c.GreenBackends)
+		if c.CanaryBackend != "" {
+			static++
+		}
+		if static > c.MaxBackends {
+			validationErr.Add(errors.NewInvalidConfigError(
+				fmt.Sprintf("%d backends configured but max backends is %d", static, c.MaxBackends), nil,
+			).WithContext("max_backends", c.MaxBackends))
+		}
+	}
+
+	// Validate how duplicate backend URLs are handled
+	if c.DuplicateBackends != "" && c.DuplicateBackends != DuplicateBackendsReject && c.DuplicateBackends != DuplicateBackendsIgnore {
+		validationErr.Add(errors.NewInvalidConfigError(
+			fmt.Sprintf("duplicate backends must be %s or %s: %q", DuplicateBackendsReject, DuplicateBackendsIgnore, c.DuplicateBackends), nil,
+		).WithContext("duplicate_backends", c.DuplicateBackends))
+	}
+
+	// Validate the error response format
+	if c.ErrorFormat != "" && c.ErrorFormat != ErrorFormatText && c.ErrorFormat != ErrorFormatJSON && c.ErrorFormat != ErrorFormatAuto {
+		validationErr.Add(errors.NewInvalidConfigError(
+			fmt.Sprintf("error format must be %s, %s or %s: %q", ErrorFormatText, ErrorFormatJSON, ErrorFormatAuto, c.ErrorFormat), nil,
+		).WithContext("error_format", c.ErrorFormat))
+	}
+
+	// Validate the shadow backend URL
+	if c.ShadowBackend != "" {
+		for _, err := range backendURLErrors(c.ShadowBackend) {
+			validationErr.Add(err.WithContext("shadow", true))
 		}
 	}
 
-	// Validate health check path
-	if c.HealthCheckPath == "" {
+	// Validate access rules
+	for _, method := range c.AllowedMethods {
+		if !isValidHTTPMethod(method) {
+			validationErr.Add(errors.NewInvalidConfigError(
+				fmt.Sprintf("unrecognized allowed method: %s", method), nil,
+			).WithContext("method", method))
+		}
+	}
+	if _, err := ParsePathRules(c.DeniedPaths); err != nil {
+		validationErr.Add(errors.NewInvalidConfigError("invalid denied paths", err).
+			WithContext("denied_paths", c.DeniedPaths))
+	}
+
+	// Validate CONNECT tunneling; an open forward proxy is never intended, so
+	// tunnels need an explicit allowlist
+	if _, err := ParseConnectTargets(c.ConnectAllowedTargets); err != nil {
+		validationErr.Add(errors.NewInvalidConfigError("invalid CONNECT targets", err).
+			WithContext("connect_allowed_targets", c.ConnectAllowedTargets))
+	}
+	if c.ConnectTunnels && len(c.ConnectAllowedTargets) == 0 {
+		validationErr.Add(errors.NewInvalidConfigError("CONNECT tunnels need at least one allowed target", nil))
+	} else if !c.ConnectTunnels && len(c.ConnectAllowedTargets) > 0 {
+		validationErr.Add(errors.NewInvalidConfigError("CONNECT targets need CONNECT tunnels to be enabled", nil))
+	}
+
+	// Validate discovery settings
+	if c.DiscoverySRV != "" {
+		if c.DiscoveryInterval < 0 {
+			validationErr.Add(errors.NewInvalidTimeoutError(c.DiscoveryInterval, "discovery interval"))
+		}
+		if c.DiscoveryScheme != "" && c.DiscoveryScheme != "http" && c.DiscoveryScheme != "https" {
+			validationErr.Add(errors.NewInvalidConfigError(
+				fmt.Sprintf("discovery scheme must be http or https, got %q", c.DiscoveryScheme), nil,
+			).WithContext("scheme", c.DiscoveryScheme))
+		}
+	}
+
+	// Validate backends file watch interval
+	if c.BackendsFile != "" && c.BackendsFileInterval < 0 {
+		validationErr.Add(errors.NewInvalidTimeoutError(c.BackendsFileInterval, "backends file interval"))
+	}
+
+	// Validate health check path. With health checks disabled, the probe
+	// settings are never used, so they may be left unset.
+	if !c.DisableHealthChecks && c.HealthCheckPath == "" {
 		validationErr.Add(errors.NewInvalidHealthCheckError("health check path cannot be empty"))
 	}
 
+	// Validate health check method (empty means GET)
+	if c.HealthCheckMethod != "" && !isValidHTTPMethod(c.HealthCheckMethod) {
+		validationErr.Add(errors.NewInvalidHealthCheckError(
+			fmt.Sprintf("unrecognized health check method: %s", c.HealthCheckMethod),
+		).WithContext("method", c.HealthCheckMethod))
+	}
+
+	// Validate the health check body, which only methods that carry one may send
+	if method := c.WithDefaults().HealthCheckMethod; c.HealthCheckBody != "" && !allowsBody(method) {
+		validationErr.Add(errors.NewInvalidHealthCheckError(
+			fmt.Sprintf("health check body requires a POST, PUT or PATCH method, not %s", method),
+		).WithContext("method", method))
+	}
+	if c.HealthCheckContentType != "" && c.HealthCheckBody == "" {
+		validationErr.Add(errors.NewInvalidHealthCheckError("health check content type requires a health check body"))
+	}
+
+	// Validate health check type (empty means HTTP)
+	if c.HealthCheckType != "" && c.HealthCheckType != HealthCheckHTTP && c.HealthCheckType != HealthCheckTCP && c.HealthCheckType != HealthCheckGRPC {
+		validationErr.Add(errors.NewInvalidHealthCheckError(
+			fmt.Sprintf("unknown health check type: %s (must be %s, %s or %s)", c.HealthCheckType, HealthCheckHTTP, HealthCheckTCP, HealthCheckGRPC),
+		).WithContext("type", c.HealthCheckType))
+	}
+
+	// The gRPC service name is only sent by grpc health checks
+	if c.HealthCheckGRPCService != "" && c.HealthCheckType != HealthCheckGRPC {
+		validationErr.Add(errors.NewInvalidHealthCheckError(
+			"a gRPC health check service needs grpc health checks",
+		).WithContext("service", c.HealthCheckGRPCService))
+	}
+
 	// Validate health check interval
-	if c.HealthCheckInterval <= 0 {
+	if !c.DisableHealthChecks && c.HealthCheckInterval <= 0 {
 		validationErr.Add(errors.NewInvalidTimeoutError(c.HealthCheckInterval, "health check interval"))
 	}
 
 	// Validate health check timeout
-	if c.HealthCheckTimeout <= 0 {
+	if !c.DisableHealthChecks && c.HealthCheckTimeout <= 0 {
 		validationErr.Add(errors.NewInvalidTimeoutError(c.HealthCheckTimeout, "health check timeout"))
 	}
 
+	// Validate health check idle timeout (zero derives it from the interval)
+	if c.HealthCheckIdleTimeout < 0 {
+		validationErr.Add(errors.NewInvalidTimeoutError(c.HealthCheckIdleTimeout, "health check idle"))
+	}
+
+	// Validate health check concurrency (zero selects the default)
+	if c.HealthCheckConcurrency < 0 {
+		validationErr.Add(errors.NewInvalidHealthCheckError(
+			fmt.Sprintf("health check concurrency cannot be negative: %d", c.HealthCheckConcurrency),
+		).WithContext("concurrency", c.HealthCheckConcurrency))
+	}
+
+	// Validate the required health check header, which only HTTP probes can see
+	if c.HealthCheckRequireHeader != "" {
+		if !isValidHeaderName(c.HealthCheckRequireHeader) {
+			validationErr.Add(errors.NewInvalidHealthCheckError(
+				fmt.Sprintf("invalid required health check header name: %q", c.HealthCheckRequireHeader),
+			).WithContext("header", c.HealthCheckRequireHeader))
+		}
+		if c.HealthCheckType == HealthCheckTCP || c.HealthCheckType == HealthCheckGRPC {
+			validationErr.Add(errors.NewInvalidHealthCheckError(
+				"a required health check header needs http health checks",
+			).WithContext("header", c.HealthCheckRequireHeader))
+		}
+	} else if c.HealthCheckRequireHeaderValue != "" {
+		validationErr.Add(errors.NewInvalidHealthCheckError(
+			"a required health check header value needs a header name",
+		))
+	}
+
+	if c.HealthCheckFollowRedirects && (c.HealthCheckType == HealthCheckTCP || c.HealthCheckType == HealthCheckGRPC) {
+		validationErr.Add(errors.NewInvalidHealthCheckError(
+			"following health check redirects needs http health checks",
+		))
+	}
+
+	// TCP probes never speak TLS, so TLS settings need http or grpc checks
+	if c.HealthCheckSNI != "" {
+		if strings.ContainsAny(c.HealthCheckSNI, ":/ \t") {
+			validationErr.Add(errors.NewInvalidHealthCheckError(
+				fmt.Sprintf("health check SNI must be a bare host name: %q", c.HealthCheckSNI),
+			).WithContext("sni", c.HealthCheckSNI))
+		}
+		if c.HealthCheckType == HealthCheckTCP {
+			validationErr.Add(errors.NewInvalidHealthCheckError(
+				"a health check SNI needs http or grpc health checks",
+			).WithContext("sni", c.HealthCheckSNI))
+		}
+	}
+	if c.HealthCheckCAFile != "" && c.HealthCheckType == HealthCheckTCP {
+		validationErr.Add(errors.NewInvalidHealthCheckError(
+			"a health check CA file needs http or grpc health checks",
+		).WithContext("ca_file", c.HealthCheckCAFile))
+	}
+
+	// Validate health check jitter; a full interval or more would let rounds overlap
+	if c.HealthCheckJitter < 0 || c.HealthCheckJitter >= 1 {
+		validationErr.Add(errors.NewInvalidHealthCheckError(
+			fmt.Sprintf("health check jitter must be at least 0 and less than 1: %g", c.HealthCheckJitter),
+		).WithContext("jitter", c.HealthCheckJitter))
+	}
+
+	if c.HealthCheckStartupGrace < 0 {
+		validationErr.Add(errors.NewInvalidHealthCheckError(
+			fmt.Sprintf("health check startup grace period cannot be negative: %s", c.HealthCheckStartupGrace),
+		).WithContext("startup_grace", c.HealthCheckStartupGrace))
+	}
+
+	// Burst probes are meant to come quicker than the regular ones
+	if c.HealthCheckStartupBurst < 0 {
+		validationErr.Add(errors.NewInvalidHealthCheckError(
+			fmt.Sprintf("health check startup burst cannot be negative: %d", c.HealthCheckStartupBurst),
+		).WithContext("startup_burst", c.HealthCheckStartupBurst))
+	}
+	if c.HealthCheckStartupBurstInterval < 0 || (c.HealthCheckStartupBurstInterval > 0 && c.HealthCheckStartupBurstInterval >= c.HealthCheckInterval) {
+		validationErr.Add(errors.NewInvalidHealthCheckError(
+			fmt.Sprintf("health check startup burst interval (%s) must be positive and less than the interval (%s)",
+				c.HealthCheckStartupBurstInterval, c.HealthCheckInterval),
+		).WithContext("startup_burst_interval", c.HealthCheckStartupBurstInterval))
+	}
+
+	// An adaptive interval only ever grows from the base interval
+	if c.HealthCheckHealthyMaxInterval != 0 && c.HealthCheckHealthyMaxInterval < c.HealthCheckInterval {
+		validationErr.Add(errors.NewInvalidHealthCheckError(
+			fmt.Sprintf("healthy max interval (%s) must be at least the interval (%s)",
+				c.HealthCheckHealthyMaxInterval, c.HealthCheckInterval),
+		).WithContext("healthy_max_interval", c.HealthCheckHealthyMaxInterval))
+	}
+
+	// Validate flap damping
+	if c.HealthCheckFlapThreshold < 0 {
+		validationErr.Add(errors.NewInvalidHealthCheckError(
+			fmt.Sprintf("flap threshold must not be negative: %d", c.HealthCheckFlapThreshold),
+		).WithContext("flap_threshold", c.HealthCheckFlapThreshold))
+	}
+	if c.HealthCheckFlapWindow < 0 {
+		validationErr.Add(errors.NewInvalidTimeoutError(c.HealthCheckFlapWindow, "health check flap window"))
+	}
+	if c.HealthCheckFlapPenalty < 0 {
+		validationErr.Add(errors.NewInvalidTimeoutError(c.HealthCheckFlapPenalty, "health check flap penalty"))
+	}
+
 	// Validate timeout relationship
-	if c.HealthCheckTimeout >= c.HealthCheckInterval {
+	if !c.DisableHealthChecks && c.HealthCheckTimeout >= c.HealthCheckInterval {
 		validationErr.Add(errors.NewInvalidConfigError(
 			fmt.Sprintf("health check timeout (%s) must be less than interval (%s)",
 				c.HealthCheckTimeout, c.HealthCheckInterval),
@@ -102,11 +379,481 @@ func ValidateConfig(c *Config) error {
 		).WithContext("timeout", c.HealthCheckTimeout).WithContext("interval", c.HealthCheckInterval))
 	}
 
+	// Validate per-backend intervals refer to configured backends and leave
+	// room for the probe timeout, as the global interval must. A backend with
+	// its own timeout is checked against it below.
+	for backend, interval := range c.HealthCheckIntervals {
+		if !containsString(c.Backends, backend) && !containsString(c.BackupBackends, backend) && !containsString(c.GreenBackends, backend) {
+			validationErr.Add(errors.NewInvalidBackendError(
+				backend,
+				fmt.Errorf("health check interval given for unknown backend"),
+			))
+		}
+		if _, ok := c.HealthCheckTimeouts[backend]; !ok && interval <= c.HealthCheckTimeout {
+			validationErr.Add(errors.NewInvalidBackendError(
+				backend,
+				fmt.Errorf("health check interval (%s) must be greater than the timeout (%s)", interval, c.HealthCheckTimeout),
+			).WithContext("interval", interval))
+		}
+	}
+
+	// Validate per-backend probe timeouts refer to configured backends and are
+	// shorter than the backend's effective interval
+	for backend, timeout := range c.HealthCheckTimeouts {
+		if !containsString(c.Backends, backend) && !containsString(c.BackupBackends, backend) && !containsString(c.GreenBackends, backend) {
+			validationErr.Add(errors.NewInvalidBackendError(
+				backend,
+				fmt.Errorf("health check timeout given for unknown backend"),
+			))
+		}
+		interval := c.HealthCheckInterval
+		if override, ok := c.HealthCheckIntervals[backend]; ok {
+			interval = override
+		}
+		if timeout <= 0 {
+			validationErr.Add(errors.NewInvalidBackendError(
+				backend,
+				fmt.Errorf("health check timeout must be positive, got %s", timeout),
+			).WithContext("timeout", timeout))
+		} else if timeout >= interval {
+			validationErr.Add(errors.NewInvalidBackendError(
+				backend,
+				fmt.Errorf("health check timeout (%s) must be less than the interval (%s)", timeout, interval),
+			).WithContext("timeout", timeout).WithContext("interval", interval))
+		}
+	}
+
 	// Validate backend timeout
 	if c.BackendTimeout <= 0 {
 		validationErr.Add(errors.NewInvalidTimeoutError(c.BackendTimeout, "backend timeout"))
 	}
 
+	if c.ResponseBufferMaxBytes < 0 {
+		validationErr.Add(errors.NewInvalidConfigError(
+			fmt.Sprintf("response buffer size cannot be negative: %d", c.ResponseBufferMaxBytes), nil,
+		).WithContext("response_buffer_max_bytes", c.ResponseBufferMaxBytes))
+	}
+	if c.StreamIdleReadTimeout < 0 {
+		validationErr.Add(errors.NewInvalidTimeoutError(c.StreamIdleReadTimeout, "stream idle read timeout"))
+	}
+	if c.StreamIdleWriteTimeout < 0 {
+		validationErr.Add(errors.NewInvalidTimeoutError(c.StreamIdleWriteTimeout, "stream idle write timeout"))
+	}
+	if c.DialRetries < 0 {
+		validationErr.Add(errors.NewInvalidConfigError(
+			fmt.Sprintf("dial retries must not be negative: %d", c.DialRetries), nil,
+		).WithContext("dial_retries", c.DialRetries))
+	}
+
+	// Validate per-method backend timeouts
+	for method, timeout := range c.MethodTimeouts {
+		if !isValidHTTPMethod(method) {
+			validationErr.Add(errors.NewInvalidConfigError(
+				fmt.Sprintf("unrecognized method for backend timeout: %s", method), nil,
+			).WithContext("method", method))
+		}
+		if timeout <= 0 {
+			validationErr.Add(errors.NewInvalidTimeoutError(timeout, method+" backend timeout"))
+		}
+	}
+
+	// Validate request timeout; a shorter one would make the backend timeout unreachable
+	if c.RequestTimeout < 0 {
+		validationErr.Add(errors.NewInvalidTimeoutError(c.RequestTimeout, "request timeout"))
+	} else if c.RequestTimeout > 0 && c.RequestTimeout < c.BackendTimeout {
+		validationErr.Add(errors.NewInvalidConfigError(
+			"request timeout must be greater than or equal to backend timeout",
+			nil,
+		).WithContext("request_timeout", c.RequestTimeout).WithContext("backend_timeout", c.BackendTimeout))
+	} else if c.RequestTimeout > 0 {
+		for method, timeout := range c.MethodTimeouts {
+			if c.RequestTimeout < timeout {
+				validationErr.Add(errors.NewInvalidConfigError(
+					fmt.Sprintf("request timeout must be greater than or equal to the %s backend timeout", method),
+					nil,
+				).WithContext("request_timeout", c.RequestTimeout).WithContext("backend_timeout", timeout))
+			}
+		}
+	}
+
+	if c.SlowRequestThreshold < 0 {
+		validationErr.Add(errors.NewInvalidTimeoutError(c.SlowRequestThreshold, "slow request threshold"))
+	}
+
+	// Validate load balancing strategy
+	if !strategy.IsValidName(c.Strategy) {
+		validationErr.Add(errors.NewInvalidConfigError(
+			fmt.Sprintf("unknown load balancing strategy: %s", c.Strategy), nil,
+		).WithContext("strategy", c.Strategy))
+	}
+
+	// Validate metrics provider
+	if _, err := metrics.LookupProvider(c.MetricsProvider); err != nil {
+		validationErr.Add(errors.NewInvalidConfigError(err.Error(), nil).
+			WithContext("metrics_provider", c.MetricsProvider))
+	}
+
+	if c.MetricsPrefix != "" && !metrics.IsValidPrometheusPrefix(c.MetricsPrefix) {
+		validationErr.Add(errors.NewInvalidConfigError(
+			fmt.Sprintf("metrics prefix must be a valid Prometheus metric name: %q", c.MetricsPrefix), nil,
+		).WithContext("metrics_prefix", c.MetricsPrefix))
+	}
+
+	if err := metrics.ValidateStaticLabels(c.MetricsLabels); err != nil {
+		validationErr.Add(errors.NewInvalidConfigError(err.Error(), nil))
+	}
+
+	// Validate StatsD target
+	if c.StatsDAddress != "" {
+		if _, _, err := net.SplitHostPort(c.StatsDAddress); err != nil {
+			validationErr.Add(errors.NewInvalidConfigError("statsd address must be host:port", err).
+				WithContext("statsd_address", c.StatsDAddress))
+		}
+		if c.StatsDInterval < 0 {
+			validationErr.Add(errors.NewInvalidTimeoutError(c.StatsDInterval, "statsd interval"))
+		}
+	}
+
+	// Validate listener settings
+	if c.AdminAddress != "" {
+		if _, _, err := net.SplitHostPort(c.AdminAddress); err != nil {
+			validationErr.Add(errors.NewInvalidConfigError("admin address must be host:port", err).
+				WithContext("admin_address", c.AdminAddress))
+		}
+	}
+	if c.ReusePort && !listener.ReusePortSupported {
+		validationErr.Add(errors.NewInvalidConfigError("SO_REUSEPORT is not supported on this platform", nil))
+	}
+	if c.MaxConnections < 0 {
+		validationErr.Add(errors.NewInvalidConfigError(
+			fmt.Sprintf("max connections must not be negative: %d", c.MaxConnections), nil,
+		))
+	}
+	if c.MaxHeaderBytes < 0 {
+		validationErr.Add(errors.NewInvalidConfigError(
+			fmt.Sprintf("max header bytes must be positive: %d", c.MaxHeaderBytes), nil,
+		).WithContext("max_header_bytes", c.MaxHeaderBytes))
+	}
+	if c.MaxResponseHeaderBytes < 0 {
+		validationErr.Add(errors.NewInvalidConfigError(
+			fmt.Sprintf("max response header bytes must be positive: %d", c.MaxResponseHeaderBytes), nil,
+		).WithContext("max_response_header_bytes", c.MaxResponseHeaderBytes))
+	}
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		validationErr.Add(errors.NewInvalidConfigError("TLS needs both a certificate and a key file", nil).
+			WithContext("tls_cert_file", c.TLSCertFile).
+			WithContext("tls_key_file", c.TLSKeyFile))
+	}
+	if c.DisableHTTP2 && c.TLSCertFile == "" {
+		validationErr.Add(errors.NewInvalidConfigError("disabling HTTP/2 needs TLS, which is the only way HTTP/2 is offered", nil))
+	}
+	if c.TLSClientCAFile != "" && c.TLSCertFile == "" {
+		validationErr.Add(errors.NewInvalidConfigError("client certificates need TLS", nil).
+			WithContext("tls_client_ca_file", c.TLSClientCAFile))
+	}
+	if c.ForwardClientCert && c.TLSClientCAFile == "" {
+		validationErr.Add(errors.NewInvalidConfigError("forwarding client certificates needs a client CA file to verify them against", nil))
+	}
+	if c.ShutdownTimeout < 0 {
+		validationErr.Add(errors.NewInvalidTimeoutError(c.ShutdownTimeout, "shutdown timeout"))
+	}
+	if c.ShutdownDrainPeriod < 0 {
+		validationErr.Add(errors.NewInvalidTimeoutError(c.ShutdownDrainPeriod, "shutdown drain period"))
+	}
+
+	// Validate access log sampling
+	if c.AccessLogSampleRate < 0 {
+		validationErr.Add(errors.NewInvalidConfigError(
+			fmt.Sprintf("access log sample rate must not be negative: %d", c.AccessLogSampleRate), nil,
+		).WithContext("access_log_sample_rate", c.AccessLogSampleRate))
+	}
+	if c.AccessLogSlowThreshold < 0 {
+		validationErr.Add(errors.NewInvalidTimeoutError(c.AccessLogSlowThreshold, "access log slow threshold"))
+	}
+
+	// Validate log file rotation
+	if c.LogMaxSize < 0 {
+		validationErr.Add(errors.NewInvalidConfigError(
+			fmt.Sprintf("log file max size must not be negative: %d", c.LogMaxSize), nil,
+		).WithContext("log_max_size", c.LogMaxSize))
+	}
+	if c.LogMaxBackups < 0 {
+		validationErr.Add(errors.NewInvalidConfigError(
+			fmt.Sprintf("log file max backups must not be negative: %d", c.LogMaxBackups), nil,
+		).WithContext("log_max_backups", c.LogMaxBackups))
+	}
+
+	// Validate rate limiting
+	if c.RateLimit < 0 {
+		validationErr.Add(errors.NewInvalidConfigError("rate limit must not be negative", nil).
+			WithContext("rate_limit", c.RateLimit))
+	}
+	if c.RateLimitBurst < 0 {
+		validationErr.Add(errors.NewInvalidConfigError("rate limit burst must not be negative", nil).
+			WithContext("rate_limit_burst", c.RateLimitBurst))
+	}
+
+	// Validate the global concurrency limit
+	if c.MaxConcurrentRequests < 0 {
+		validationErr.Add(errors.NewInvalidConfigError("max concurrent requests must not be negative", nil).
+			WithContext("max_concurrent_requests", c.MaxConcurrentRequests))
+	}
+	if c.ConcurrencyQueueTimeout < 0 {
+		validationErr.Add(errors.NewInvalidTimeoutError(c.ConcurrencyQueueTimeout, "concurrency queue timeout"))
+	}
+
+	// Validate the per-backend concurrency limit
+	if c.MaxBackendConnections < 0 {
+		validationErr.Add(errors.NewInvalidConfigError("max backend connections must not be negative", nil).
+			WithContext("max_backend_connections", c.MaxBackendConnections))
+	}
+	if c.BackendQueueTimeout < 0 {
+		validationErr.Add(errors.NewInvalidTimeoutError(c.BackendQueueTimeout, "backend queue timeout"))
+	}
+
+	// Validate scheduled drain windows
+	for _, spec := range c.DrainWindows {
+		if _, err := schedule.ParseDrainWindow(spec); err != nil {
+			validationErr.Add(errors.NewInvalidConfigError(err.Error(), nil).
+				WithContext("drain_window", spec))
+		}
+	}
+
+	// Validate hash key source
+	if c.HashKey != "" && c.HashKey != HashKeyClientIP {
+		name, isHeader := strings.CutPrefix(c.HashKey, HashKeyHeaderPrefix)
+		if !isHeader || !isValidHeaderName(name) {
+			validationErr.Add(errors.NewInvalidConfigError(
+				fmt.Sprintf("invalid hash key %q: must be %q or %q<Header-Name>",
+					c.HashKey, HashKeyClientIP, HashKeyHeaderPrefix), nil,
+			).WithContext("hash_key", c.HashKey))
+		}
+	}
+
+	// Validate the affinity header
+	if c.AffinityHeader != "" && !isValidHeaderName(c.AffinityHeader) {
+		validationErr.Add(errors.NewInvalidConfigError(
+			fmt.Sprintf("invalid affinity header name %q", c.AffinityHeader), nil,
+		).WithContext("affinity_header", c.AffinityHeader))
+	}
+
+	// Validate static responses
+	for path, response := range c.StaticResponses {
+		if !strings.HasPrefix(path, "/") {
+			validationErr.Add(errors.NewInvalidConfigError(
+				fmt.Sprintf("static response path must start with /: %q", path), nil,
+			).WithContext("static_path", path))
+		}
+		if !isValidStaticStatus(response.Status) {
+			validationErr.Add(errors.NewInvalidConfigError(
+				fmt.Sprintf("invalid static response status %d for %s", response.Status, path), nil,
+			).WithContext("static_path", path))
+		}
+	}
+
+	// Validate routes; their backends must be configured primaries
+	seenRoutes := make(map[string]bool)
+	for _, spec := range c.Routes {
+		route, err := ParseRoute(spec)
+		if err != nil {
+			validationErr.Add(errors.NewInvalidConfigError(err.Error(), nil).
+				WithContext("route", spec))
+			continue
+		}
+		if pattern := route.Host + route.PathPrefix; seenRoutes[pattern] {
+			validationErr.Add(errors.NewInvalidConfigError(
+				fmt.Sprintf("duplicate route for %s", pattern), nil,
+			).WithContext("route", spec))
+		} else {
+			seenRoutes[pattern] = true
+		}
+		for _, backend := range route.Backends {
+			if !containsString(c.Backends, backend) {
+				validationErr.Add(errors.NewInvalidBackendError(
+					backend,
+					fmt.Errorf("route backend is not a configured backend"),
+				).WithContext("route", spec))
+			}
+		}
+	}
+
+	// Validate backend weights refer to configured backends
+	for backend, weight := range c.BackendWeights {
+		if !containsString(c.Backends, backend) && !containsString(c.BackupBackends, backend) && !containsString(c.GreenBackends, backend) {
+			validationErr.Add(errors.NewInvalidBackendError(
+				backend,
+				fmt.Errorf("weight given for unknown backend"),
+			))
+		}
+		if weight <= 0 || weight > pool.MaxWeight {
+			validationErr.Add(errors.NewInvalidBackendError(
+				backend,
+				fmt.Errorf("weight must be between 1 and %d, got %d", pool.MaxWeight, weight),
+			).WithContext("weight", weight))
+		}
+	}
+
+	// Validate backend zones refer to configured backends
+	for backend, zone := range c.BackendZones {
+		if !containsString(c.Backends, backend) && !containsString(c.BackupBackends, backend) && !containsString(c.GreenBackends, backend) {
+			validationErr.Add(errors.NewInvalidBackendError(
+				backend,
+				fmt.Errorf("zone given for unknown backend"),
+			))
+		}
+		if zone == "" {
+			validationErr.Add(errors.NewInvalidBackendError(
+				backend,
+				fmt.Errorf("zone must not be empty"),
+			))
+		}
+	}
+	if c.LocalZone != "" && len(c.BackendZones) == 0 {
+		validationErr.Add(errors.NewInvalidConfigError(
+			"local zone requires backend zones", nil,
+		).WithContext("local_zone", c.LocalZone))
+	}
+
+	// Validate upstream scheme overrides
+	if c.UpstreamScheme != "" && !isValidUpstreamScheme(c.UpstreamScheme) {
+		validationErr.Add(errors.NewInvalidConfigError(
+			fmt.Sprintf("upstream scheme must be http or https: %q", c.UpstreamScheme), nil,
+		).WithContext("upstream_scheme", c.UpstreamScheme))
+	}
+	for backend, scheme := range c.UpstreamSchemes {
+		if !containsString(c.Backends, backend) && !containsString(c.BackupBackends, backend) && !containsString(c.GreenBackends, backend) {
+			validationErr.Add(errors.NewInvalidBackendError(
+				backend,
+				fmt.Errorf("upstream scheme given for unknown backend"),
+			))
+		}
+		if !isValidUpstreamScheme(scheme) {
+			validationErr.Add(errors.NewInvalidBackendError(
+				backend,
+				fmt.Errorf("upstream scheme must be http or https: %q", scheme),
+			).WithContext("upstream_scheme", scheme))
+		}
+	}
+
+	// Validate upstream client certificates come with their keys; the key
+	// pairs themselves are loaded and checked at startup
+	if (c.UpstreamClientCert.CertFile == "") != (c.UpstreamClientCert.KeyFile == "") {
+		validationErr.Add(errors.NewInvalidConfigError("upstream client certificate and key must be set together", nil).
+			WithContext("cert_file", c.UpstreamClientCert.CertFile).
+			WithContext("key_file", c.UpstreamClientCert.KeyFile))
+	}
+	for backend, cert := range c.UpstreamClientCerts {
+		if !containsString(c.Backends, backend) && !containsString(c.BackupBackends, backend) && !containsString(c.GreenBackends, backend) {
+			validationErr.Add(errors.NewInvalidBackendError(
+				backend,
+				fmt.Errorf("client certificate given for unknown backend"),
+			))
+		}
+		if cert.CertFile == "" || cert.KeyFile == "" {
+			validationErr.Add(errors.NewInvalidBackendError(
+				backend,
+				fmt.Errorf("client certificate and key must be set together"),
+			).WithContext("cert_file", cert.CertFile).WithContext("key_file", cert.KeyFile))
+		}
+	}
+
+	// Validate keep-alive overrides refer to configured backends
+	for _, backend := range c.DisableKeepAliveBackends {
+		if !containsString(c.Backends, backend) && !containsString(c.BackupBackends, backend) && !containsString(c.GreenBackends, backend) {
+			validationErr.Add(errors.NewInvalidBackendError(
+				backend,
+				fmt.Errorf("keep-alive disabled for unknown backend"),
+			))
+		}
+	}
+
+	// Validate the Retry-After backoff cap
+	if c.MaxRetryAfterDelay < 0 {
+		validationErr.Add(errors.NewInvalidTimeoutError(c.MaxRetryAfterDelay, "max retry-after delay"))
+	}
+
+	// Validate the error budget; a budget of 1 or more could never be exceeded
+	if c.ErrorBudgetThreshold < 0 || c.ErrorBudgetThreshold >= 1 {
+		validationErr.Add(errors.NewInvalidConfigError(
+			fmt.Sprintf("error budget threshold must be at least 0 and less than 1: %g", c.ErrorBudgetThreshold), nil,
+		).WithContext("error_budget_threshold", c.ErrorBudgetThreshold))
+	}
+	if c.ErrorBudgetWindow < 0 {
+		validationErr.Add(errors.NewInvalidTimeoutError(c.ErrorBudgetWindow, "error budget window"))
+	}
+
+	// Validate failure status codes
+	if _, err := ParseStatusCodes(c.FailureStatusCodes); err != nil {
+		validationErr.Add(errors.NewInvalidConfigError("invalid failure status codes", err).
+			WithContext("failure_status_codes", c.FailureStatusCodes))
+	}
+
+	// Validate trusted proxy CIDRs
+	for i, proxy := range c.TrustedProxies {
+		if _, err := clientip.ParseCIDR(proxy); err != nil {
+			validationErr.Add(errors.NewInvalidConfigError("invalid trusted proxy", err).
+				WithContext("trusted_proxy", proxy).
+				WithContext("index", i))
+		}
+	}
+
+	// Validate the client allowlist
+	for i, cidr := range c.AllowedClientCIDRs {
+		if _, err := clientip.ParseCIDR(cidr); err != nil {
+			validationErr.Add(errors.NewInvalidConfigError("invalid allowed client CIDR", err).
+				WithContext("allowed_client_cidr", cidr).
+				WithContext("index", i))
+		}
+	}
+
+	// Validate injected header names
+	for name := range c.ResponseHeaders {
+		if !isValidHeaderName(name) {
+			validationErr.Add(errors.NewInvalidConfigError(
+				fmt.Sprintf("invalid response header name: %q", name), nil,
+			).WithContext("header", name))
+		}
+	}
+	for name := range c.RequestHeaders {
+		if !isValidHeaderName(name) {
+			validationErr.Add(errors.NewInvalidConfigError(
+				fmt.Sprintf("invalid request header name: %q", name), nil,
+			).WithContext("header", name))
+		}
+	}
+	if c.RequestIDHeader != "" && !isValidHeaderName(c.RequestIDHeader) {
+		validationErr.Add(errors.NewInvalidConfigError(
+			fmt.Sprintf("invalid request ID header name: %q", c.RequestIDHeader), nil,
+		).WithContext("header", c.RequestIDHeader))
+	}
+	if strings.ContainsAny(c.UpstreamUserAgent, "\r\n") {
+		validationErr.Add(errors.NewInvalidConfigError(
+			fmt.Sprintf("upstream User-Agent cannot contain line breaks: %q", c.UpstreamUserAgent), nil,
+		))
+	}
+	if c.AppendUserAgent && c.UpstreamUserAgent == "" {
+		validationErr.Add(errors.NewInvalidConfigError("appending to the User-Agent needs an upstream User-Agent", nil))
+	}
+	for _, name := range c.RemoveResponseHeaders {
+		if !isValidHeaderName(name) {
+			validationErr.Add(errors.NewInvalidConfigError(
+				fmt.Sprintf("invalid response header name to remove: %q", name), nil,
+			).WithContext("header", name))
+		}
+	}
+
+	// Validate query parameter rewriting rules
+	for name := range c.DefaultQueryParams {
+		if name == "" {
+			validationErr.Add(errors.NewInvalidConfigError("default query parameter name cannot be empty", nil))
+		}
+	}
+	for _, name := range c.RemoveQueryParams {
+		if name == "" {
+			validationErr.Add(errors.NewInvalidConfigError("query parameter name to remove cannot be empty", nil))
+		}
+	}
+
 	if validationErr.HasErrors() {
 		return validationErr
 	}
@@ -114,7 +861,89 @@ func ValidateConfig(c *Config) error {
 	return nil
 }
 
+// ValidateBackendURL applies the configuration checks for a backend URL,
+// returning the first problem found
+func ValidateBackendURL(backend string) error {
+	if backend == "" {
+		return errors.NewInvalidBackendError(backend, fmt.Errorf("backend cannot be empty"))
+	}
+	if errs := backendURLErrors(backend); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// backendURLErrors returns every problem with a non-empty backend URL
+func backendURLErrors(backend string) []*errors.LoadBalancerError {
+	parsedURL, err := url.Parse(backend)
+	if err != nil {
+		return []*errors.LoadBalancerError{errors.NewInvalidBackendError(backend, err)}
+	}
+
+	var errs []*errors.LoadBalancerError
+	if parsedURL.Scheme == "" {
+		errs = append(errs, errors.NewInvalidBackendError(
+			backend,
+			fmt.Errorf("must include a scheme (http:// or https://)"),
+		))
+	}
+	if parsedURL.Host == "" {
+		errs = append(errs, errors.NewInvalidBackendError(
+			backend,
+			fmt.Errorf("must include a host"),
+		))
+	}
+	return errs
+}
+
+// allowsBody reports whether requests with method are meant to carry a body
+func allowsBody(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		return true
+	}
+	return false
+}
+
+// isValidHTTPMethod reports whether method is one of the standard HTTP methods
+func isValidHTTPMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// containsString reports whether values contains s
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// isValidHeaderName reports whether name is a non-empty HTTP header token
+func isValidHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if r <= ' ' || r >= 0x7f || strings.ContainsRune(`"(),/:;<=>?@[\]{}`, r) {
+			return false
+		}
+	}
+	return true
+}
+
 // Validate method on Config struct
 func (c *Config) Validate() error {
 	return ValidateConfig(c)
 }
+
+// isValidUpstreamScheme reports whether scheme can be used to reach a backend
+func isValidUpstreamScheme(scheme string) bool {
+	return scheme == "http" || scheme == "https"
+}
@@ -191,3 +191,2075 @@ func TestValidationErrorAggregation(t *testing.T) {
 		t.Errorf("Expected non-empty error message")
 	}
 }
+
+func TestHealthCheckMethodValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		expectValid bool
+	}{
+		{"Empty defaults to GET", "", true},
+		{"GET", "GET", true},
+		{"HEAD", "HEAD", true},
+		{"OPTIONS", "OPTIONS", true},
+		{"Unknown method", "FETCH", false},
+		{"Lowercase method", "head", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                8000,
+				Backends:            []string{"http://localhost:8080"},
+				HealthCheckPath:     "/",
+				HealthCheckMethod:   tt.method,
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      30 * time.Second,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected method %q to be valid, got error: %v", tt.method, err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected method %q to be invalid, but validation passed", tt.method)
+			}
+		})
+	}
+}
+
+func TestHealthCheckBodyValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		body        string
+		contentType string
+		expectValid bool
+	}{
+		{"No body", "GET", "", "", true},
+		{"POST with body", "POST", `{"check":"deep"}`, "", true},
+		{"PUT with body and content type", "PUT", "ping", "text/plain", true},
+		{"PATCH with body", "PATCH", "{}", "", true},
+		{"GET with body", "GET", "{}", "", false},
+		{"Default method with body", "", "{}", "", false},
+		{"HEAD with body", "HEAD", "{}", "", false},
+		{"Content type without body", "POST", "", "application/json", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                   8000,
+				Backends:               []string{"http://localhost:8080"},
+				HealthCheckPath:        "/",
+				HealthCheckMethod:      tt.method,
+				HealthCheckInterval:    10 * time.Second,
+				HealthCheckTimeout:     2 * time.Second,
+				BackendTimeout:         30 * time.Second,
+				HealthCheckBody:        tt.body,
+				HealthCheckContentType: tt.contentType,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected configuration to be valid, got error: %v", err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected configuration to be invalid, but validation passed")
+			}
+		})
+	}
+}
+
+func TestDisabledHealthChecksValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		disabled    bool
+		expectValid bool
+	}{
+		{"Enabled without probe settings", false, false},
+		{"Disabled without probe settings", true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                8000,
+				Backends:            []string{"http://localhost:8080"},
+				BackendTimeout:      30 * time.Second,
+				DisableHealthChecks: tt.disabled,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected configuration to be valid, got error: %v", err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected configuration to be invalid, but validation passed")
+			}
+		})
+	}
+}
+
+func TestTrustedProxiesValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		proxies     []string
+		expectValid bool
+	}{
+		{"No trusted proxies", nil, true},
+		{"Valid CIDRs", []string{"10.0.0.0/8", "fd00::/8"}, true},
+		{"Bare IP", []string{"192.168.1.1"}, true},
+		{"Invalid prefix length", []string{"10.0.0.0/33"}, false},
+		{"Garbage entry", []string{"10.0.0.0/8", "proxy.local"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                8000,
+				Backends:            []string{"http://localhost:8080"},
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      30 * time.Second,
+				TrustedProxies:      tt.proxies,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected trusted proxies %v to be valid, got error: %v", tt.proxies, err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected trusted proxies %v to be invalid, but validation passed", tt.proxies)
+			}
+		})
+	}
+}
+
+func TestAllowedClientCIDRsValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		cidrs       []string
+		expectValid bool
+	}{
+		{"No allowlist", nil, true},
+		{"Valid CIDRs", []string{"10.0.0.0/8", "fd00::/8"}, true},
+		{"Bare IP", []string{"192.168.1.1"}, true},
+		{"Invalid prefix length", []string{"10.0.0.0/33"}, false},
+		{"Garbage entry", []string{"10.0.0.0/8", "intranet.local"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                8000,
+				Backends:            []string{"http://localhost:8080"},
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      30 * time.Second,
+				AllowedClientCIDRs:  tt.cidrs,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected allowed client CIDRs %v to be valid, got error: %v", tt.cidrs, err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected allowed client CIDRs %v to be invalid, but validation passed", tt.cidrs)
+			}
+		})
+	}
+}
+
+func TestResponseHeaderValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		headers     map[string]string
+		remove      []string
+		expectValid bool
+	}{
+		{"No headers", nil, nil, true},
+		{"Valid headers", map[string]string{"Strict-Transport-Security": "max-age=63072000"}, []string{"Server"}, true},
+		{"Empty header name", map[string]string{"": "value"}, nil, false},
+		{"Header name with space", map[string]string{"X Bad": "value"}, nil, false},
+		{"Invalid removal", nil, []string{"Bad:Header"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                  8000,
+				Backends:              []string{"http://localhost:8080"},
+				HealthCheckPath:       "/",
+				HealthCheckInterval:   10 * time.Second,
+				HealthCheckTimeout:    2 * time.Second,
+				BackendTimeout:        30 * time.Second,
+				ResponseHeaders:       tt.headers,
+				RemoveResponseHeaders: tt.remove,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected configuration to be valid, got error: %v", err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected configuration to be invalid, but validation passed")
+			}
+		})
+	}
+}
+
+func TestRequestIDHeaderValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		header      string
+		expectValid bool
+	}{
+		{"Default header", "", true},
+		{"Custom header", "X-Correlation-ID", true},
+		{"Header name with space", "X Correlation", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                8000,
+				Backends:            []string{"http://localhost:8080"},
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      30 * time.Second,
+				RequestIDHeader:     tt.header,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected configuration to be valid, got error: %v", err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected configuration to be invalid, but validation passed")
+			}
+		})
+	}
+}
+
+func TestUpstreamUserAgentValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		userAgent   string
+		appendUA    bool
+		expectValid bool
+	}{
+		{"Not set", "", false, true},
+		{"Replacement", "go-balancer/1.0", false, true},
+		{"Appended", "go-balancer/1.0", true, true},
+		{"Append without a User-Agent", "", true, false},
+		{"Line break", "go-balancer/1.0\r\nX-Evil: 1", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                8000,
+				Backends:            []string{"http://localhost:8080"},
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      30 * time.Second,
+				UpstreamUserAgent:   tt.userAgent,
+				AppendUserAgent:     tt.appendUA,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected configuration to be valid, got error: %v", err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected configuration to be invalid, but validation passed")
+			}
+		})
+	}
+}
+
+func TestStrategyAndWeightValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		strategy    string
+		weights     map[string]int
+		expectValid bool
+	}{
+		{"Default strategy", "", nil, true},
+		{"Weighted least connections", "weighted-least-connections", map[string]int{"http://localhost:8080": 3}, true},
+		{"Unknown strategy", "fastest", nil, false},
+		{"Weight for unknown backend", "", map[string]int{"http://localhost:9999": 2}, false},
+		{"Zero weight", "", map[string]int{"http://localhost:8080": 0}, false},
+		{"Maximum weight", "", map[string]int{"http://localhost:8080": 1000}, true},
+		{"Weight above maximum", "", map[string]int{"http://localhost:8080": 1001}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                8000,
+				Backends:            []string{"http://localhost:8080"},
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      30 * time.Second,
+				Strategy:            tt.strategy,
+				BackendWeights:      tt.weights,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected configuration to be valid, got error: %v", err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected configuration to be invalid, but validation passed")
+			}
+		})
+	}
+}
+
+func TestHashKeyValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		hashKey     string
+		expectValid bool
+	}{
+		{"Default", "", true},
+		{"Client IP", "client-ip", true},
+		{"Header", "header:X-Session-ID", true},
+		{"Empty header name", "header:", false},
+		{"Unknown source", "cookie:session", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                8000,
+				Backends:            []string{"http://localhost:8080"},
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      30 * time.Second,
+				Strategy:            "consistent-hash",
+				HashKey:             tt.hashKey,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected hash key %q to be valid, got error: %v", tt.hashKey, err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected hash key %q to be invalid, but validation passed", tt.hashKey)
+			}
+		})
+	}
+}
+
+func TestHealthCheckTypeValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		checkType   string
+		expectValid bool
+	}{
+		{"Default", "", true},
+		{"HTTP", "http", true},
+		{"TCP", "tcp", true},
+		{"Unknown", "udp", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                8000,
+				Backends:            []string{"http://localhost:8080"},
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      30 * time.Second,
+				HealthCheckType:     tt.checkType,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected health check type %q to be valid, got error: %v", tt.checkType, err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected health check type %q to be invalid, but validation passed", tt.checkType)
+			}
+		})
+	}
+}
+
+func TestHealthCheckJitterValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		jitter      float64
+		expectValid bool
+	}{
+		{"Disabled", 0, true},
+		{"Fraction of interval", 0.2, true},
+		{"Negative", -0.1, false},
+		{"Full interval", 1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                8000,
+				Backends:            []string{"http://localhost:8080"},
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      30 * time.Second,
+				HealthCheckJitter:   tt.jitter,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected health check jitter %g to be valid, got error: %v", tt.jitter, err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected health check jitter %g to be invalid, but validation passed", tt.jitter)
+			}
+		})
+	}
+}
+
+func TestDiscoveryValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		backends    []string
+		srv         string
+		scheme      string
+		expectValid bool
+	}{
+		{"Discovery without static backends", nil, "_http._tcp.backends.example.com", "", true},
+		{"No backends and no discovery", nil, "", "", false},
+		{"HTTPS discovery", nil, "_https._tcp.backends.example.com", "https", true},
+		{"Invalid discovery scheme", nil, "_http._tcp.backends.example.com", "ftp", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                8000,
+				Backends:            tt.backends,
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      30 * time.Second,
+				DiscoverySRV:        tt.srv,
+				DiscoveryScheme:     tt.scheme,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected configuration to be valid, got error: %v", err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected configuration to be invalid, but validation passed")
+			}
+		})
+	}
+}
+
+func TestStatsDValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		address     string
+		interval    time.Duration
+		expectValid bool
+	}{
+		{"Disabled", "", 0, true},
+		{"Host and port", "localhost:8125", 0, true},
+		{"Explicit interval", "127.0.0.1:8125", 5 * time.Second, true},
+		{"Missing port", "localhost", 0, false},
+		{"Negative interval", "localhost:8125", -time.Second, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                8000,
+				Backends:            []string{"http://localhost:8080"},
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      30 * time.Second,
+				StatsDAddress:       tt.address,
+				StatsDInterval:      tt.interval,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected configuration to be valid, got error: %v", err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected configuration to be invalid, but validation passed")
+			}
+		})
+	}
+}
+
+func TestAdminAddressValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		address     string
+		expectValid bool
+	}{
+		{"Disabled", "", true},
+		{"Loopback", "127.0.0.1:8001", true},
+		{"All interfaces", ":8001", true},
+		{"Missing port", "localhost", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                8000,
+				Backends:            []string{"http://localhost:8080"},
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      30 * time.Second,
+				AdminAddress:        tt.address,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected configuration to be valid, got error: %v", err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected configuration to be invalid, but validation passed")
+			}
+		})
+	}
+}
+
+func TestShutdownTimeoutValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		timeout     time.Duration
+		expectValid bool
+	}{
+		{"Default", 0, true},
+		{"Explicit", 10 * time.Second, true},
+		{"Negative", -time.Second, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                8000,
+				Backends:            []string{"http://localhost:8080"},
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      30 * time.Second,
+				ShutdownTimeout:     tt.timeout,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected configuration to be valid, got error: %v", err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected configuration to be invalid, but validation passed")
+			}
+		})
+	}
+}
+
+func TestRequestTimeoutValidation(t *testing.T) {
+	tests := []struct {
+		name           string
+		requestTimeout time.Duration
+		expectValid    bool
+	}{
+		{"Unbounded", 0, true},
+		{"Equal to backend timeout", 30 * time.Second, true},
+		{"Longer than backend timeout", time.Minute, true},
+		{"Shorter than backend timeout", 10 * time.Second, false},
+		{"Negative", -time.Second, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                8000,
+				Backends:            []string{"http://localhost:8080"},
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      30 * time.Second,
+				RequestTimeout:      tt.requestTimeout,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected configuration to be valid, got error: %v", err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected configuration to be invalid, but validation passed")
+			}
+		})
+	}
+}
+
+func TestMethodTimeoutsValidation(t *testing.T) {
+	tests := []struct {
+		name           string
+		timeouts       map[string]time.Duration
+		requestTimeout time.Duration
+		expectValid    bool
+	}{
+		{"None", nil, 0, true},
+		{"Longer writes", map[string]time.Duration{"POST": time.Minute, "PUT": time.Minute}, 0, true},
+		{"Within request timeout", map[string]time.Duration{"POST": time.Minute}, time.Minute, true},
+		{"Beyond request timeout", map[string]time.Duration{"POST": 2 * time.Minute}, time.Minute, false},
+		{"Unknown method", map[string]time.Duration{"FETCH": time.Minute}, 0, false},
+		{"Lower-case method", map[string]time.Duration{"post": time.Minute}, 0, false},
+		{"Zero timeout", map[string]time.Duration{"POST": 0}, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                8000,
+				Backends:            []string{"http://localhost:8080"},
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      30 * time.Second,
+				MethodTimeouts:      tt.timeouts,
+				RequestTimeout:      tt.requestTimeout,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected configuration to be valid, got error: %v", err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected configuration to be invalid, but validation passed")
+			}
+		})
+	}
+}
+
+func TestDialRetriesValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		retries     int
+		expectValid bool
+	}{
+		{"Disabled", 0, true},
+		{"Retries", 3, true},
+		{"Negative", -1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                8000,
+				Backends:            []string{"http://localhost:8080"},
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      30 * time.Second,
+				DialRetries:         tt.retries,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected configuration to be valid, got error: %v", err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected configuration to be invalid, but validation passed")
+			}
+		})
+	}
+}
+
+func TestLocalityValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		localZone   string
+		zones       map[string]string
+		expectValid bool
+	}{
+		{"Disabled", "", nil, true},
+		{"Zones without local zone", "", map[string]string{"http://localhost:8080": "us-east-1a"}, true},
+		{"Local zone", "us-east-1a", map[string]string{"http://localhost:8080": "us-east-1a"}, true},
+		{"Local zone without zones", "us-east-1a", nil, false},
+		{"Unknown backend", "us-east-1a", map[string]string{"http://localhost:9090": "us-east-1a"}, false},
+		{"Empty zone", "us-east-1a", map[string]string{"http://localhost:8080": ""}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                8000,
+				Backends:            []string{"http://localhost:8080"},
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      30 * time.Second,
+				LocalZone:           tt.localZone,
+				BackendZones:        tt.zones,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected configuration to be valid, got error: %v", err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected configuration to be invalid, but validation passed")
+			}
+		})
+	}
+}
+
+func TestRateLimitValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		rateLimit   float64
+		burst       int
+		expectValid bool
+	}{
+		{"Disabled", 0, 0, true},
+		{"Rate only", 10, 0, true},
+		{"Rate and burst", 0.5, 5, true},
+		{"Negative rate", -1, 0, false},
+		{"Negative burst", 10, -1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                8000,
+				Backends:            []string{"http://localhost:8080"},
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      30 * time.Second,
+				RateLimit:           tt.rateLimit,
+				RateLimitBurst:      tt.burst,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected configuration to be valid, got error: %v", err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected configuration to be invalid, but validation passed")
+			}
+		})
+	}
+}
+
+func TestConcurrencyLimitValidation(t *testing.T) {
+	tests := []struct {
+		name         string
+		maxRequests  int
+		queueTimeout time.Duration
+		expectValid  bool
+	}{
+		{"Disabled", 0, 0, true},
+		{"Limit without queue", 100, 0, true},
+		{"Limit with queue", 100, 50 * time.Millisecond, true},
+		{"Negative limit", -1, 0, false},
+		{"Negative queue timeout", 100, -time.Millisecond, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                    8000,
+				Backends:                []string{"http://localhost:8080"},
+				HealthCheckPath:         "/",
+				HealthCheckInterval:     10 * time.Second,
+				HealthCheckTimeout:      2 * time.Second,
+				BackendTimeout:          30 * time.Second,
+				MaxConcurrentRequests:   tt.maxRequests,
+				ConcurrencyQueueTimeout: tt.queueTimeout,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected configuration to be valid, got error: %v", err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected configuration to be invalid, but validation passed")
+			}
+		})
+	}
+}
+
+func TestBackendConnectionLimitValidation(t *testing.T) {
+	tests := []struct {
+		name           string
+		maxConnections int
+		queueTimeout   time.Duration
+		expectValid    bool
+	}{
+		{"Disabled", 0, 0, true},
+		{"Limit without queue", 50, 0, true},
+		{"Limit with queue", 50, 100 * time.Millisecond, true},
+		{"Negative limit", -1, 0, false},
+		{"Negative queue timeout", 50, -time.Millisecond, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                  8000,
+				Backends:              []string{"http://localhost:8080"},
+				HealthCheckPath:       "/",
+				HealthCheckInterval:   10 * time.Second,
+				HealthCheckTimeout:    2 * time.Second,
+				BackendTimeout:        30 * time.Second,
+				MaxBackendConnections: tt.maxConnections,
+				BackendQueueTimeout:   tt.queueTimeout,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected configuration to be valid, got error: %v", err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected configuration to be invalid, but validation passed")
+			}
+		})
+	}
+}
+
+func TestHealthCheckIntervalsValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		intervals   map[string]time.Duration
+		expectValid bool
+	}{
+		{"No overrides", nil, true},
+		{"Shorter interval", map[string]time.Duration{"http://localhost:8080": 3 * time.Second}, true},
+		{"Longer interval", map[string]time.Duration{"http://localhost:8080": 30 * time.Second}, true},
+		{"Unknown backend", map[string]time.Duration{"http://localhost:9999": 5 * time.Second}, false},
+		{"Interval not above timeout", map[string]time.Duration{"http://localhost:8080": 2 * time.Second}, false},
+		{"Zero interval", map[string]time.Duration{"http://localhost:8080": 0}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                 8000,
+				Backends:             []string{"http://localhost:8080"},
+				HealthCheckPath:      "/",
+				HealthCheckInterval:  10 * time.Second,
+				HealthCheckTimeout:   2 * time.Second,
+				BackendTimeout:       30 * time.Second,
+				HealthCheckIntervals: tt.intervals,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected configuration to be valid, got error: %v", err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected configuration to be invalid, but validation passed")
+			}
+		})
+	}
+}
+
+func TestHealthCheckTimeoutsValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		timeouts    map[string]time.Duration
+		intervals   map[string]time.Duration
+		expectValid bool
+	}{
+		{"No overrides", nil, nil, true},
+		{"Longer timeout", map[string]time.Duration{"http://localhost:8080": 8 * time.Second}, nil, true},
+		{"Shorter timeout", map[string]time.Duration{"http://localhost:8080": 500 * time.Millisecond}, nil, true},
+		{"Unknown backend", map[string]time.Duration{"http://localhost:9999": 5 * time.Second}, nil, false},
+		{"Zero timeout", map[string]time.Duration{"http://localhost:8080": 0}, nil, false},
+		{"Timeout not below interval", map[string]time.Duration{"http://localhost:8080": 10 * time.Second}, nil, false},
+		{
+			"Timeout below own interval",
+			map[string]time.Duration{"http://localhost:8080": 15 * time.Second},
+			map[string]time.Duration{"http://localhost:8080": 30 * time.Second},
+			true,
+		},
+		{
+			"Timeout not below own interval",
+			map[string]time.Duration{"http://localhost:8080": 5 * time.Second},
+			map[string]time.Duration{"http://localhost:8080": 3 * time.Second},
+			false,
+		},
+		{
+			"Short interval with shorter timeout",
+			map[string]time.Duration{"http://localhost:8080": time.Second},
+			map[string]time.Duration{"http://localhost:8080": 2 * time.Second},
+			true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                 8000,
+				Backends:             []string{"http://localhost:8080"},
+				HealthCheckPath:      "/",
+				HealthCheckInterval:  10 * time.Second,
+				HealthCheckTimeout:   2 * time.Second,
+				BackendTimeout:       30 * time.Second,
+				HealthCheckIntervals: tt.intervals,
+				HealthCheckTimeouts:  tt.timeouts,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected configuration to be valid, got error: %v", err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected configuration to be invalid, but validation passed")
+			}
+		})
+	}
+}
+
+func TestHealthCheckHealthyMaxIntervalValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxInterval time.Duration
+		expectValid bool
+	}{
+		{"Disabled", 0, true},
+		{"Above interval", time.Minute, true},
+		{"Equal to interval", 10 * time.Second, true},
+		{"Below interval", 5 * time.Second, false},
+		{"Negative", -time.Second, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                          8000,
+				Backends:                      []string{"http://localhost:8080"},
+				HealthCheckPath:               "/",
+				HealthCheckInterval:           10 * time.Second,
+				HealthCheckTimeout:            2 * time.Second,
+				BackendTimeout:                30 * time.Second,
+				HealthCheckHealthyMaxInterval: tt.maxInterval,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected configuration to be valid, got error: %v", err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected configuration to be invalid, but validation passed")
+			}
+		})
+	}
+}
+
+func TestTLSValidation(t *testing.T) {
+	tests := []struct {
+		name              string
+		certFile          string
+		keyFile           string
+		disableHTTP2      bool
+		clientCAFile      string
+		forwardClientCert bool
+		expectValid       bool
+	}{
+		{"No TLS", "", "", false, "", false, true},
+		{"Certificate and key", "cert.pem", "key.pem", false, "", false, true},
+		{"HTTP/2 disabled", "cert.pem", "key.pem", true, "", false, true},
+		{"Certificate without key", "cert.pem", "", false, "", false, false},
+		{"Key without certificate", "", "key.pem", false, "", false, false},
+		{"HTTP/2 disabled without TLS", "", "", true, "", false, false},
+		{"Client certificates forwarded", "cert.pem", "key.pem", false, "ca.pem", true, true},
+		{"Client CA without TLS", "", "", false, "ca.pem", false, false},
+		{"Forwarding without client CA", "cert.pem", "key.pem", false, "", true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                8000,
+				Backends:            []string{"http://localhost:8080"},
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      30 * time.Second,
+				TLSCertFile:         tt.certFile,
+				TLSKeyFile:          tt.keyFile,
+				DisableHTTP2:        tt.disableHTTP2,
+				TLSClientCAFile:     tt.clientCAFile,
+				ForwardClientCert:   tt.forwardClientCert,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected configuration to be valid, got error: %v", err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected configuration to be invalid, but validation passed")
+			}
+		})
+	}
+}
+
+func TestUpstreamSchemeValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		global      string
+		perBackend  map[string]string
+		expectValid bool
+	}{
+		{"No override", "", nil, true},
+		{"Global https", "https", nil, true},
+		{"Global http", "http", nil, true},
+		{"Per-backend https", "", map[string]string{"http://localhost:8080": "https"}, true},
+		{"Unsupported global scheme", "ftp", nil, false},
+		{"Unsupported per-backend scheme", "", map[string]string{"http://localhost:8080": "ws"}, false},
+		{"Unknown backend", "", map[string]string{"http://localhost:9999": "https"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                8000,
+				Backends:            []string{"http://localhost:8080"},
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      30 * time.Second,
+				UpstreamScheme:      tt.global,
+				UpstreamSchemes:     tt.perBackend,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected configuration to be valid, got error: %v", err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected configuration to be invalid, but validation passed")
+			}
+		})
+	}
+}
+
+func TestHealthCheckFollowRedirectsValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		follow      bool
+		checkType   string
+		expectValid bool
+	}{
+		{"Not following", false, "", true},
+		{"Following with http checks", true, HealthCheckHTTP, true},
+		{"Not following with tcp checks", false, HealthCheckTCP, true},
+		{"Following with tcp checks", true, HealthCheckTCP, false},
+		{"Following with grpc checks", true, HealthCheckGRPC, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                       8000,
+				Backends:                   []string{"http://localhost:8080"},
+				HealthCheckPath:            "/",
+				HealthCheckInterval:        10 * time.Second,
+				HealthCheckTimeout:         2 * time.Second,
+				BackendTimeout:             30 * time.Second,
+				HealthCheckType:            tt.checkType,
+				HealthCheckFollowRedirects: tt.follow,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected configuration to be valid, got error: %v", err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected configuration to be invalid, but validation passed")
+			}
+		})
+	}
+}
+
+func TestGRPCHealthCheckValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		checkType   string
+		service     string
+		expectValid bool
+	}{
+		{"grpc checks", HealthCheckGRPC, "", true},
+		{"grpc checks with service", HealthCheckGRPC, "payments", true},
+		{"Service with http checks", HealthCheckHTTP, "payments", false},
+		{"Service with tcp checks", HealthCheckTCP, "payments", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                   8000,
+				Backends:               []string{"http://localhost:8080"},
+				HealthCheckPath:        "/",
+				HealthCheckInterval:    10 * time.Second,
+				HealthCheckTimeout:     2 * time.Second,
+				BackendTimeout:         30 * time.Second,
+				HealthCheckType:        tt.checkType,
+				HealthCheckGRPCService: tt.service,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected configuration to be valid, got error: %v", err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected configuration to be invalid, but validation passed")
+			}
+		})
+	}
+}
+
+func TestHealthCheckTLSValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		checkType   string
+		sni         string
+		caFile      string
+		expectValid bool
+	}{
+		{"No TLS settings", "", "", "", true},
+		{"SNI with http checks", HealthCheckHTTP, "backend.internal", "", true},
+		{"SNI and CA with grpc checks", HealthCheckGRPC, "backend.internal", "ca.pem", true},
+		{"SNI with a port", HealthCheckHTTP, "backend.internal:443", "", false},
+		{"SNI as a URL", HealthCheckHTTP, "https://backend.internal", "", false},
+		{"SNI with tcp checks", HealthCheckTCP, "backend.internal", "", false},
+		{"CA file with tcp checks", HealthCheckTCP, "", "ca.pem", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                8000,
+				Backends:            []string{"http://localhost:8080"},
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      30 * time.Second,
+				HealthCheckType:     tt.checkType,
+				HealthCheckSNI:      tt.sni,
+				HealthCheckCAFile:   tt.caFile,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected configuration to be valid, got error: %v", err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected configuration to be invalid, but validation passed")
+			}
+		})
+	}
+}
+
+func TestHealthCheckStartupGraceValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		grace       time.Duration
+		expectValid bool
+	}{
+		{"Disabled", 0, true},
+		{"Grace period", 30 * time.Second, true},
+		{"Negative grace period", -time.Second, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                    8000,
+				Backends:                []string{"http://localhost:8080"},
+				HealthCheckPath:         "/",
+				HealthCheckInterval:     10 * time.Second,
+				HealthCheckTimeout:      2 * time.Second,
+				BackendTimeout:          30 * time.Second,
+				HealthCheckStartupGrace: tt.grace,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected configuration to be valid, got error: %v", err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected configuration to be invalid, but validation passed")
+			}
+		})
+	}
+}
+
+func TestLogFileValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxSize     int
+		maxBackups  int
+		expectValid bool
+	}{
+		{"Defaults", 0, 0, true},
+		{"Custom rotation", 10, 5, true},
+		{"Negative max size", -1, 3, false},
+		{"Negative max backups", 10, -1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                8000,
+				Backends:            []string{"http://localhost:8080"},
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      30 * time.Second,
+				LogFile:             "balancer.log",
+				LogMaxSize:          tt.maxSize,
+				LogMaxBackups:       tt.maxBackups,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected configuration to be valid, got error: %v", err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected configuration to be invalid, but validation passed")
+			}
+		})
+	}
+}
+
+func TestBackupBackendsValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		backups     []string
+		weights     map[string]int
+		expectValid bool
+	}{
+		{"No backups", nil, nil, true},
+		{"Valid backup", []string{"http://localhost:9090"}, nil, true},
+		{"Weighted backup", []string{"http://localhost:9090"}, map[string]int{"http://localhost:9090": 2}, true},
+		{"Empty backup", []string{""}, nil, false},
+		{"Malformed backup URL", []string{"localhost:9090"}, nil, false},
+		{"Backup duplicates primary", []string{"http://localhost:8080"}, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                8000,
+				Backends:            []string{"http://localhost:8080"},
+				BackupBackends:      tt.backups,
+				BackendWeights:      tt.weights,
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      30 * time.Second,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected configuration to be valid, got error: %v", err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected configuration to be invalid, but validation passed")
+			}
+		})
+	}
+}
+
+func TestAccessRulesValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		methods     []string
+		paths       []string
+		expectValid bool
+	}{
+		{"Allow everything", nil, nil, true},
+		{"Allowed methods", []string{"GET", "POST"}, nil, true},
+		{"Unknown method", []string{"FETCH"}, nil, false},
+		{"Prefix and regex paths", nil, []string{"/admin", `regex:\.php$`}, true},
+		{"Relative path prefix", nil, []string{"admin"}, false},
+		{"Invalid regex", nil, []string{"regex:(unclosed"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                8000,
+				Backends:            []string{"http://localhost:8080"},
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      30 * time.Second,
+				AllowedMethods:      tt.methods,
+				DeniedPaths:         tt.paths,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected configuration to be valid, got error: %v", err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected configuration to be invalid, but validation passed")
+			}
+		})
+	}
+}
+
+func TestHealthCheckRequireHeaderValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		header      string
+		value       string
+		checkType   string
+		expectValid bool
+	}{
+		{"Not required", "", "", "", true},
+		{"Header and value", "X-Health", "ok", "", true},
+		{"Header only", "X-Health", "", "", true},
+		{"Value without header", "", "ok", "", false},
+		{"Invalid header name", "X Health", "ok", "", false},
+		{"TCP health checks", "X-Health", "ok", HealthCheckTCP, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                          8000,
+				Backends:                      []string{"http://localhost:8080"},
+				HealthCheckPath:               "/",
+				HealthCheckInterval:           10 * time.Second,
+				HealthCheckTimeout:            2 * time.Second,
+				BackendTimeout:                30 * time.Second,
+				HealthCheckType:               tt.checkType,
+				HealthCheckRequireHeader:      tt.header,
+				HealthCheckRequireHeaderValue: tt.value,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected configuration to be valid, got error: %v", err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected configuration to be invalid, but validation passed")
+			}
+		})
+	}
+}
+
+func TestMaxHeaderBytesValidation(t *testing.T) {
+	tests := []struct {
+		name           string
+		maxHeaderBytes int
+		expectValid    bool
+	}{
+		{"Default", 0, true},
+		{"Explicit", 8192, true},
+		{"Negative", -1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                8000,
+				Backends:            []string{"http://localhost:8080"},
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      30 * time.Second,
+				MaxHeaderBytes:      tt.maxHeaderBytes,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected configuration to be valid, got error: %v", err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected configuration to be invalid, but validation passed")
+			}
+		})
+	}
+}
+
+func TestMetricsPrefixValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		prefix      string
+		expectValid bool
+	}{
+		{"Default", "", true},
+		{"Custom", "tenant_a", true},
+		{"Dash", "tenant-a", false},
+		{"Leading digit", "1tenant", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                8000,
+				Backends:            []string{"http://localhost:8080"},
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      30 * time.Second,
+				MetricsPrefix:       tt.prefix,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected configuration to be valid, got error: %v", err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected configuration to be invalid, but validation passed")
+			}
+		})
+	}
+}
+
+func TestMetricsLabelsValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		labels      map[string]string
+		expectValid bool
+	}{
+		{"None", nil, true},
+		{"Valid", map[string]string{"instance": "lb-1"}, true},
+		{"Invalid name", map[string]string{"instance-id": "lb-1"}, false},
+		{"Reserved name", map[string]string{"backend": "x"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                8000,
+				Backends:            []string{"http://localhost:8080"},
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      30 * time.Second,
+				MetricsLabels:       tt.labels,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected configuration to be valid, got error: %v", err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected configuration to be invalid, but validation passed")
+			}
+		})
+	}
+}
+
+func TestMaxResponseHeaderBytesValidation(t *testing.T) {
+	tests := []struct {
+		name           string
+		maxHeaderBytes int64
+		expectValid    bool
+	}{
+		{"Default", 0, true},
+		{"Explicit", 64 << 10, true},
+		{"Negative", -1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                   8000,
+				Backends:               []string{"http://localhost:8080"},
+				HealthCheckPath:        "/",
+				HealthCheckInterval:    10 * time.Second,
+				HealthCheckTimeout:     2 * time.Second,
+				BackendTimeout:         30 * time.Second,
+				MaxResponseHeaderBytes: tt.maxHeaderBytes,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected configuration to be valid, got error: %v", err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected configuration to be invalid, but validation passed")
+			}
+		})
+	}
+}
+
+func TestStartupBurstValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		burst       int
+		interval    time.Duration
+		expectValid bool
+	}{
+		{"Disabled", 0, 0, true},
+		{"Default interval", 5, 0, true},
+		{"Explicit interval", 5, 200 * time.Millisecond, true},
+		{"Negative burst", -1, 0, false},
+		{"Negative interval", 5, -time.Second, false},
+		{"Interval not below the health check interval", 5, 10 * time.Second, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                            8000,
+				Backends:                        []string{"http://localhost:8080"},
+				HealthCheckPath:                 "/",
+				HealthCheckInterval:             10 * time.Second,
+				HealthCheckTimeout:              2 * time.Second,
+				BackendTimeout:                  30 * time.Second,
+				HealthCheckStartupBurst:         tt.burst,
+				HealthCheckStartupBurstInterval: tt.interval,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected configuration to be valid, got error: %v", err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected configuration to be invalid, but validation passed")
+			}
+		})
+	}
+}
+
+func TestFlapDampingValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		threshold   int
+		window      time.Duration
+		penalty     time.Duration
+		expectValid bool
+	}{
+		{"Disabled", 0, 0, 0, true},
+		{"Defaults", 3, 0, 0, true},
+		{"Explicit", 3, time.Minute, 5 * time.Minute, true},
+		{"Negative threshold", -1, 0, 0, false},
+		{"Negative window", 3, -time.Minute, 0, false},
+		{"Negative penalty", 3, 0, -time.Minute, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                     8000,
+				Backends:                 []string{"http://localhost:8080"},
+				HealthCheckPath:          "/",
+				HealthCheckInterval:      10 * time.Second,
+				HealthCheckTimeout:       2 * time.Second,
+				BackendTimeout:           30 * time.Second,
+				HealthCheckFlapThreshold: tt.threshold,
+				HealthCheckFlapWindow:    tt.window,
+				HealthCheckFlapPenalty:   tt.penalty,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected configuration to be valid, got error: %v", err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected configuration to be invalid, but validation passed")
+			}
+		})
+	}
+}
+
+func TestUpstreamClientCertValidation(t *testing.T) {
+	pair := ClientCert{CertFile: "client.pem", KeyFile: "client-key.pem"}
+	tests := []struct {
+		name        string
+		global      ClientCert
+		perBackend  map[string]ClientCert
+		expectValid bool
+	}{
+		{"None", ClientCert{}, nil, true},
+		{"Global", pair, nil, true},
+		{"Per-backend", ClientCert{}, map[string]ClientCert{"http://localhost:8080": pair}, true},
+		{"Certificate without key", ClientCert{CertFile: "client.pem"}, nil, false},
+		{"Key without certificate", ClientCert{KeyFile: "client-key.pem"}, nil, false},
+		{"Per-backend without key", ClientCert{}, map[string]ClientCert{"http://localhost:8080": {CertFile: "client.pem"}}, false},
+		{"Unknown backend", ClientCert{}, map[string]ClientCert{"http://localhost:9999": pair}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                8000,
+				Backends:            []string{"http://localhost:8080"},
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      30 * time.Second,
+				UpstreamClientCert:  tt.global,
+				UpstreamClientCerts: tt.perBackend,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected configuration to be valid, got error: %v", err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected configuration to be invalid, but validation passed")
+			}
+		})
+	}
+}
+
+func TestDisableKeepAliveBackendsValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		backends    []string
+		expectValid bool
+	}{
+		{"No overrides", nil, true},
+		{"Primary backend", []string{"http://localhost:8080"}, true},
+		{"Backup backend", []string{"http://localhost:9090"}, true},
+		{"Unknown backend", []string{"http://localhost:7070"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                     8000,
+				Backends:                 []string{"http://localhost:8080"},
+				BackupBackends:           []string{"http://localhost:9090"},
+				DisableKeepAliveBackends: tt.backends,
+				HealthCheckPath:          "/",
+				HealthCheckInterval:      10 * time.Second,
+				HealthCheckTimeout:       2 * time.Second,
+				BackendTimeout:           30 * time.Second,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected configuration to be valid, got error: %v", err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected configuration to be invalid, but validation passed")
+			}
+		})
+	}
+}
+
+func TestMaxRetryAfterDelayValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxDelay    time.Duration
+		expectValid bool
+	}{
+		{"Unset", 0, true},
+		{"Positive", 30 * time.Second, true},
+		{"Negative", -time.Second, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                8000,
+				Backends:            []string{"http://localhost:8080"},
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      30 * time.Second,
+				HonorRetryAfter:     true,
+				MaxRetryAfterDelay:  tt.maxDelay,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected configuration to be valid, got error: %v", err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected configuration to be invalid, but validation passed")
+			}
+		})
+	}
+}
+
+func TestErrorBudgetValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		threshold   float64
+		window      time.Duration
+		expectValid bool
+	}{
+		{"Unset", 0, 0, true},
+		{"Threshold with default window", 0.5, 0, true},
+		{"Threshold and window", 0.2, 30 * time.Second, true},
+		{"Negative threshold", -0.1, 0, false},
+		{"Threshold of one", 1, 0, false},
+		{"Negative window", 0.5, -time.Second, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                 8000,
+				Backends:             []string{"http://localhost:8080"},
+				HealthCheckPath:      "/",
+				HealthCheckInterval:  10 * time.Second,
+				HealthCheckTimeout:   2 * time.Second,
+				BackendTimeout:       30 * time.Second,
+				ErrorBudgetThreshold: tt.threshold,
+				ErrorBudgetWindow:    tt.window,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected configuration to be valid, got error: %v", err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected configuration to be invalid, but validation passed")
+			}
+		})
+	}
+}
+
+func TestConnectTunnelValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		enabled     bool
+		targets     []string
+		expectValid bool
+	}{
+		{"Disabled", false, nil, true},
+		{"Enabled with targets", true, []string{"db.internal:5432", "*.example.com:443"}, true},
+		{"Enabled without targets", true, nil, false},
+		{"Targets without tunnels", false, []string{"db.internal:5432"}, false},
+		{"Target without port", true, []string{"db.internal"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                  8000,
+				Backends:              []string{"http://localhost:8080"},
+				HealthCheckPath:       "/",
+				HealthCheckInterval:   10 * time.Second,
+				HealthCheckTimeout:    2 * time.Second,
+				BackendTimeout:        30 * time.Second,
+				ConnectTunnels:        tt.enabled,
+				ConnectAllowedTargets: tt.targets,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected configuration to be valid, got error: %v", err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected configuration to be invalid, but validation passed")
+			}
+		})
+	}
+}
+
+func TestCanaryValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		canary      string
+		percent     float64
+		match       string
+		expectValid bool
+	}{
+		{"No canary", "", 0, "", true},
+		{"Canary with percent", "http://localhost:9191", 10, "", true},
+		{"Canary with header match", "http://localhost:9191", 0, "header:X-Canary", true},
+		{"Canary with cookie match", "http://localhost:9191", 5, "cookie:canary=1", true},
+		{"Percent without canary", "", 10, "", false},
+		{"Match without canary", "", 0, "header:X-Canary", false},
+		{"Percent over 100", "http://localhost:9191", 101, "", false},
+		{"Negative percent", "http://localhost:9191", -1, "", false},
+		{"Invalid match", "http://localhost:9191", 0, "query:canary", false},
+		{"Malformed canary URL", "localhost:9191", 10, "", false},
+		{"Canary duplicates primary", "http://localhost:8080", 10, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                8000,
+				Backends:            []string{"http://localhost:8080"},
+				CanaryBackend:       tt.canary,
+				CanaryPercent:       tt.percent,
+				CanaryMatch:         tt.match,
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      30 * time.Second,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected configuration to be valid, got error: %v", err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected configuration to be invalid, but validation passed")
+			}
+		})
+	}
+}
+
+func TestGreenBackendsValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		green       []string
+		percent     float64
+		weights     map[string]int
+		expectValid bool
+	}{
+		{"No green backends", nil, 0, nil, true},
+		{"Green with percent", []string{"http://localhost:9191"}, 10, nil, true},
+		{"Green with weight", []string{"http://localhost:9191"}, 10, map[string]int{"http://localhost:9191": 3}, true},
+		{"Percent without green", nil, 10, nil, false},
+		{"Percent over 100", []string{"http://localhost:9191"}, 101, nil, false},
+		{"Negative percent", []string{"http://localhost:9191"}, -1, nil, false},
+		{"Malformed green URL", []string{"localhost:9191"}, 10, nil, false},
+		{"Green duplicates blue", []string{"http://localhost:8080"}, 10, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                8000,
+				Backends:            []string{"http://localhost:8080"},
+				GreenBackends:       tt.green,
+				GreenPercent:        tt.percent,
+				BackendWeights:      tt.weights,
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      30 * time.Second,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected configuration to be valid, got error: %v", err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected configuration to be invalid, but validation passed")
+			}
+		})
+	}
+}
+
+func TestMaxBackendsValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxBackends int
+		backups     []string
+		canary      string
+		expectValid bool
+	}{
+		{"Unlimited", 0, nil, "", true},
+		{"Room to spare", 5, nil, "", true},
+		{"Exactly full", 2, nil, "", true},
+		{"Primaries over the cap", 1, nil, "", false},
+		{"Backups count toward the cap", 3, []string{"http://localhost:9090", "http://localhost:9091"}, "", false},
+		{"Canary counts toward the cap", 2, nil, "http://localhost:9191", false},
+		{"Negative", -1, nil, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                8000,
+				Backends:            []string{"http://localhost:8080", "http://localhost:8081"},
+				BackupBackends:      tt.backups,
+				CanaryBackend:       tt.canary,
+				MaxBackends:         tt.maxBackends,
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      30 * time.Second,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected configuration to be valid, got error: %v", err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected configuration to be invalid, but validation passed")
+			}
+		})
+	}
+}
+
+func TestDuplicateBackendsValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      string
+		expectValid bool
+	}{
+		{"Default", "", true},
+		{"Reject", DuplicateBackendsReject, true},
+		{"Ignore", DuplicateBackendsIgnore, true},
+		{"Unknown policy", "merge", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                8000,
+				Backends:            []string{"http://localhost:8080"},
+				DuplicateBackends:   tt.policy,
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      30 * time.Second,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected configuration to be valid, got error: %v", err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected configuration to be invalid, but validation passed")
+			}
+		})
+	}
+}
+
+func TestErrorFormatValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		format      string
+		expectValid bool
+	}{
+		{"Default", "", true},
+		{"Text", ErrorFormatText, true},
+		{"JSON", ErrorFormatJSON, true},
+		{"Auto", ErrorFormatAuto, true},
+		{"Unknown format", "xml", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                8000,
+				Backends:            []string{"http://localhost:8080"},
+				ErrorFormat:         tt.format,
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      30 * time.Second,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected configuration to be valid, got error: %v", err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected configuration to be invalid, but validation passed")
+			}
+		})
+	}
+}
+
+func TestAccessLogValidation(t *testing.T) {
+	tests := []struct {
+		name          string
+		sampleRate    int
+		slowThreshold time.Duration
+		expectValid   bool
+	}{
+		{"Defaults", 0, 0, true},
+		{"Sampled with slow threshold", 10, 500 * time.Millisecond, true},
+		{"Negative sample rate", -1, 0, false},
+		{"Negative slow threshold", 1, -time.Second, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                   8000,
+				Backends:               []string{"http://localhost:8080"},
+				HealthCheckPath:        "/",
+				HealthCheckInterval:    10 * time.Second,
+				HealthCheckTimeout:     2 * time.Second,
+				BackendTimeout:         30 * time.Second,
+				AccessLog:              "-",
+				AccessLogSampleRate:    tt.sampleRate,
+				AccessLogSlowThreshold: tt.slowThreshold,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected configuration to be valid, got error: %v", err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected configuration to be invalid, but validation passed")
+			}
+		})
+	}
+}
+
+func TestAffinityHeaderValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		header      string
+		expectValid bool
+	}{
+		{"Unset", "", true},
+		{"Valid header", "X-Tenant-ID", true},
+		{"Invalid header", "X Tenant", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                8000,
+				Backends:            []string{"http://localhost:8080"},
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      30 * time.Second,
+				AffinityHeader:      tt.header,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected configuration to be valid, got error: %v", err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected configuration to be invalid, but validation passed")
+			}
+		})
+	}
+}
+
+func TestRoutesValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		routes      []string
+		expectValid bool
+	}{
+		{"No routes", nil, true},
+		{"Route over all backends", []string{"/static=round-robin"}, true},
+		{"Route with backends", []string{"/api=weighted-least-connections@http://localhost:8080"}, true},
+		{"Unknown strategy", []string{"/api=fastest"}, false},
+		{"Unknown backend", []string{"/api=round-robin@http://localhost:7070"}, false},
+		{"Duplicate route", []string{"/api=round-robin", "/api=consistent-hash"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                8000,
+				Backends:            []string{"http://localhost:8080", "http://localhost:8081"},
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      30 * time.Second,
+				Routes:              tt.routes,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected configuration to be valid, got error: %v", err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected configuration to be invalid, but validation passed")
+			}
+		})
+	}
+}
+
+func TestStaticResponsesValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		responses   map[string]StaticResponse
+		expectValid bool
+	}{
+		{"None", nil, true},
+		{"Valid", map[string]StaticResponse{"/ping": {Status: 200, Body: "pong"}}, true},
+		{"No content", map[string]StaticResponse{"/favicon.ico": {Status: 204}}, true},
+		{"Relative path", map[string]StaticResponse{"ping": {Status: 200}}, false},
+		{"Informational status", map[string]StaticResponse{"/ping": {Status: 100}}, false},
+		{"Unknown status", map[string]StaticResponse{"/ping": {Status: 299}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Port:                8000,
+				Backends:            []string{"http://localhost:8080"},
+				HealthCheckPath:     "/",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  2 * time.Second,
+				BackendTimeout:      30 * time.Second,
+				StaticResponses:     tt.responses,
+			}
+
+			err := cfg.Validate()
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected configuration to be valid, got error: %v", err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected configuration to be invalid, but validation passed")
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package discovery
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go-balancer/internal/config"
+	"go-balancer/internal/errors"
+	"go-balancer/internal/pool"
+)
+
+// FileDiscoverer keeps a server pool in sync with a file listing one backend
+// URL per line. The file is polled, so it works with editors and config
+// management tools that replace the file rather than writing it in place.
+type FileDiscoverer struct {
+	path     string
+	interval time.Duration
+	managed  *managedBackends
+
+	mu       sync.Mutex
+	contents []byte // File contents at the last successful refresh
+	stopCh   chan struct{}
+}
+
+// NewFileDiscoverer creates a discoverer for the backends file at path
+func NewFileDiscoverer(serverPool *pool.ServerPool, path string, interval time.Duration) *FileDiscoverer {
+	return &FileDiscoverer{
+		path:     path,
+		interval: interval,
+		managed:  newManagedBackends(serverPool, "file "+path),
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start begins polling the file. Callers load it once with Refresh first so
+// a broken file is reported at startup.
+func (d *FileDiscoverer) Start() {
+	go d.watchLoop()
+	log.Printf("Watching backends file %s every %s", d.path, d.interval)
+}
+
+// Stop terminates polling
+func (d *FileDiscoverer) Stop() {
+	close(d.stopCh)
+}
+
+// watchLoop re-reads the file at regular intervals
+func (d *FileDiscoverer) watchLoop() {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := d.Refresh(); err != nil {
+				log.Printf("Backends file reload failed: %v", err)
+			}
+		case <-d.stopCh:
+			log.Println("Backends file watch stopped")
+			return
+		}
+	}
+}
+
+// Refresh reads the file and reconciles the pool if it changed. If the file
+// can't be read or has an invalid entry the pool is left untouched.
+func (d *FileDiscoverer) Refresh() error {
+	contents, err := os.ReadFile(d.path)
+	if err != nil {
+		return errors.NewDiscoveryFailedError(d.path, err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.contents != nil && bytes.Equal(contents, d.contents) {
+		return nil
+	}
+
+	backends, err := ParseBackendsFile(contents)
+	if err != nil {
+		return errors.NewDiscoveryFailedError(d.path, err)
+	}
+
+	desired := make(map[string]int, len(backends))
+	for _, backend := range backends {
+		desired[backend] = 0 // Keep whatever weight the backend has
+	}
+	d.managed.reconcile(desired)
+	d.contents = contents
+	return nil
+}
+
+// ParseBackendsFile returns the backend URLs in a backends file, one per line.
+// Blank lines and lines starting with # are ignored, and every entry must pass
+// the same checks as backends given in configuration.
+func ParseBackendsFile(contents []byte) ([]string, error) {
+	var backends []string
+
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := config.ValidateBackendURL(line); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		backends = append(backends, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return backends, nil
+}
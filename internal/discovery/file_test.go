@@ -0,0 +1,125 @@
+package discovery
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go-balancer/internal/pool"
+)
+
+func writeBackendsFile(t *testing.T, path, contents string) {
+	t.Helper()
+
+	// Replace the file atomically, as config management tools do
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(contents), 0o644); err != nil {
+		t.Fatalf("Failed to write backends file: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatalf("Failed to replace backends file: %v", err)
+	}
+}
+
+func TestParseBackendsFile(t *testing.T) {
+	contents := `
+# primary backends
+http://backend1:8080
+  http://backend2:8080  
+
+# https://disabled:8443
+https://backend3:8443
+`
+	backends, err := ParseBackendsFile([]byte(contents))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []string{"http://backend1:8080", "http://backend2:8080", "https://backend3:8443"}
+	if len(backends) != len(expected) {
+		t.Fatalf("Expected backends %v, got %v", expected, backends)
+	}
+	for i := range expected {
+		if backends[i] != expected[i] {
+			t.Errorf("Expected backend %d to be %q, got %q", i, expected[i], backends[i])
+		}
+	}
+}
+
+func TestParseBackendsFileInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+	}{
+		{"Missing scheme", "backend1:8080\n"},
+		{"Missing host", "http://\n"},
+		{"Unparseable", "http://backend1:8080\n://broken\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseBackendsFile([]byte(tt.contents)); err == nil {
+				t.Errorf("Expected error for %q", tt.contents)
+			}
+		})
+	}
+}
+
+func TestFileDiscoveryReconciles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backends.txt")
+	writeBackendsFile(t, path, "http://a:80\nhttp://b:80\n")
+
+	serverPool := pool.NewServerPool()
+	if err := serverPool.AddBackend("http://static:80"); err != nil {
+		t.Fatalf("Failed to add backend: %v", err)
+	}
+
+	d := NewFileDiscoverer(serverPool, path, time.Minute)
+	if err := d.Refresh(); err != nil {
+		t.Fatalf("Unexpected refresh error: %v", err)
+	}
+	assertURLs(t, serverPool, "http://static", "http://a", "http://b")
+
+	// Entries removed from the file leave the pool; static backends stay
+	writeBackendsFile(t, path, "# b is being retired\nhttp://a:80\nhttp://c:80\n")
+	if err := d.Refresh(); err != nil {
+		t.Fatalf("Unexpected refresh error: %v", err)
+	}
+	assertURLs(t, serverPool, "http://static", "http://a", "http://c")
+
+	// An invalid file leaves the pool untouched
+	writeBackendsFile(t, path, "http://a:80\nnot a url\n")
+	if err := d.Refresh(); err == nil {
+		t.Errorf("Expected refresh of an invalid file to fail")
+	}
+	assertURLs(t, serverPool, "http://static", "http://a", "http://c")
+
+	// So does a missing one
+	os.Remove(path)
+	if err := d.Refresh(); err == nil {
+		t.Errorf("Expected refresh of a missing file to fail")
+	}
+	assertURLs(t, serverPool, "http://static", "http://a", "http://c")
+}
+
+func TestFileDiscoveryWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backends.txt")
+	writeBackendsFile(t, path, "http://a:80\n")
+
+	serverPool := pool.NewServerPool()
+	d := NewFileDiscoverer(serverPool, path, 10*time.Millisecond)
+	if err := d.Refresh(); err != nil {
+		t.Fatalf("Unexpected refresh error: %v", err)
+	}
+	d.Start()
+	defer d.Stop()
+
+	writeBackendsFile(t, path, "http://a:80\nhttp://b:80\n")
+
+	deadline := time.Now().Add(2 * time.Second)
+	for serverPool.GetBackendCount() != 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assertURLs(t, serverPool, "http://a", "http://b")
+}
@@ -0,0 +1,82 @@
+package discovery
+
+import (
+	"log"
+	"sync"
+
+	"go-balancer/internal/pool"
+)
+
+// managedBackends tracks the backends a discovery source added to the pool so
+// it can remove them again without touching statically configured ones
+type managedBackends struct {
+	serverPool *pool.ServerPool
+	source     string // Describes the source in logs, e.g. "SRV _http._tcp.example.com"
+
+	mu      sync.Mutex
+	managed map[string]bool // Backend URLs added by this source
+}
+
+func newManagedBackends(serverPool *pool.ServerPool, source string) *managedBackends {
+	return &managedBackends{
+		serverPool: serverPool,
+		source:     source,
+		managed:    make(map[string]bool),
+	}
+}
+
+// reconcile adds missing backends and removes managed ones that disappeared.
+// Desired backends map to their weight; a weight of 0 leaves the backend's
+// weight alone. Backends configured statically are never removed.
+func (m *managedBackends) reconcile(desired map[string]int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing := make(map[string]*pool.Backend)
+	for _, backend := range m.serverPool.GetBackends() {
+		existing[backend.URL.String()] = backend
+	}
+
+	// Compare in the canonical form the pool stores URLs in
+	normalized := make(map[string]int, len(desired))
+	for backendURL, weight := range desired {
+		normalized[pool.NormalizeURL(backendURL)] = weight
+	}
+	desired = normalized
+
+	for backendURL, weight := range desired {
+		if backend, ok := existing[backendURL]; ok {
+			if m.managed[backendURL] && weight > 0 && backend.Weight() != weight {
+				m.serverPool.SetBackendWeight(backend.ID, weight)
+			}
+			continue
+		}
+
+		backend, err := m.serverPool.Add(backendURL)
+		if err != nil {
+			log.Printf("Discovery could not add backend %s: %v", backendURL, err)
+			continue
+		}
+		if backend.URL.String() != backendURL {
+			// An ignored duplicate of a backend already in the pool, which
+			// this source doesn't own
+			continue
+		}
+		m.managed[backendURL] = true
+		if weight > 0 {
+			m.serverPool.SetBackendWeight(backend.ID, weight)
+		}
+		log.Printf("Discovered backend %s (%s) from %s", backend.ID, backendURL, m.source)
+	}
+
+	for backendURL := range m.managed {
+		if _, ok := desired[backendURL]; ok {
+			continue
+		}
+		if backend, ok := existing[backendURL]; ok {
+			m.serverPool.RemoveBackend(backend.ID)
+			log.Printf("Removed backend %s (%s) no longer in %s", backend.ID, backendURL, m.source)
+		}
+		delete(m.managed, backendURL)
+	}
+}
@@ -0,0 +1,129 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"go-balancer/internal/errors"
+	"go-balancer/internal/pool"
+)
+
+// SRVResolver looks up DNS SRV records. *net.Resolver satisfies it.
+type SRVResolver interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// SRVDiscoverer keeps a server pool in sync with the targets of a DNS SRV record
+type SRVDiscoverer struct {
+	serverPool *pool.ServerPool
+	resolver   SRVResolver
+	name       string // Fully qualified SRV name, e.g. _http._tcp.backends.example.com
+	scheme     string // Scheme used to build backend URLs from SRV targets
+	interval   time.Duration
+	timeout    time.Duration
+	managed    *managedBackends
+	stopCh     chan struct{}
+}
+
+// NewSRVDiscoverer creates a discoverer for the given SRV name
+func NewSRVDiscoverer(
+	serverPool *pool.ServerPool,
+	resolver SRVResolver,
+	name string,
+	scheme string,
+	interval time.Duration,
+) *SRVDiscoverer {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	if scheme == "" {
+		scheme = "http"
+	}
+
+	return &SRVDiscoverer{
+		serverPool: serverPool,
+		resolver:   resolver,
+		name:       name,
+		scheme:     scheme,
+		interval:   interval,
+		timeout:    5 * time.Second,
+		managed:    newManagedBackends(serverPool, "SRV "+name),
+		stopCh:     make(chan struct{}),
+	}
+}
+
+// Start performs an initial resolution and then re-resolves periodically
+func (d *SRVDiscoverer) Start() {
+	if err := d.Refresh(); err != nil {
+		log.Printf("Initial backend discovery failed: %v", err)
+	}
+	go d.discoveryLoop()
+	log.Printf("Backend discovery started for SRV %s every %s", d.name, d.interval)
+}
+
+// Stop terminates periodic discovery
+func (d *SRVDiscoverer) Stop() {
+	close(d.stopCh)
+}
+
+// discoveryLoop re-resolves the SRV record at regular intervals
+func (d *SRVDiscoverer) discoveryLoop() {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := d.Refresh(); err != nil {
+				log.Printf("Backend discovery failed: %v", err)
+			}
+		case <-d.stopCh:
+			log.Println("Backend discovery stopped")
+			return
+		}
+	}
+}
+
+// Refresh resolves the SRV record once and reconciles the pool. On lookup
+// failure the pool is left untouched so a DNS blip doesn't drain all backends.
+func (d *SRVDiscoverer) Refresh() error {
+	ctx, cancel := context.WithTimeout(context.Background(), d.timeout)
+	defer cancel()
+
+	_, records, err := d.resolver.LookupSRV(ctx, "", "", d.name)
+	if err != nil {
+		return errors.NewDiscoveryFailedError(d.name, err)
+	}
+	if len(records) == 0 {
+		return errors.NewDiscoveryFailedError(d.name, fmt.Errorf("no SRV records returned"))
+	}
+
+	d.managed.reconcile(d.desiredBackends(records))
+	return nil
+}
+
+// desiredBackends converts SRV records into de-duplicated backend URLs and weights
+func (d *SRVDiscoverer) desiredBackends(records []*net.SRV) map[string]int {
+	desired := make(map[string]int, len(records))
+	for _, record := range records {
+		host := strings.TrimSuffix(record.Target, ".")
+		backendURL := fmt.Sprintf("%s://%s", d.scheme, net.JoinHostPort(host, fmt.Sprint(record.Port)))
+
+		weight := int(record.Weight)
+		if weight <= 0 {
+			weight = 1
+		}
+		if weight > pool.MaxWeight {
+			weight = pool.MaxWeight
+		}
+		// Duplicate targets keep the larger weight
+		if weight > desired[backendURL] {
+			desired[backendURL] = weight
+		}
+	}
+	return desired
+}
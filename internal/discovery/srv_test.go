@@ -0,0 +1,155 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"go-balancer/internal/pool"
+)
+
+// stubResolver returns whatever record set the test last configured
+type stubResolver struct {
+	mu      sync.Mutex
+	records []*net.SRV
+	err     error
+}
+
+func (s *stubResolver) set(records []*net.SRV, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records, s.err = records, err
+}
+
+func (s *stubResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return name, s.records, s.err
+}
+
+func srv(target string, port uint16, weight uint16) *net.SRV {
+	return &net.SRV{Target: target, Port: port, Weight: weight}
+}
+
+func poolURLs(serverPool *pool.ServerPool) []string {
+	var urls []string
+	for _, backend := range serverPool.GetBackends() {
+		urls = append(urls, backend.URL.String())
+	}
+	sort.Strings(urls)
+	return urls
+}
+
+func assertURLs(t *testing.T, serverPool *pool.ServerPool, expected ...string) {
+	t.Helper()
+	sort.Strings(expected)
+	got := poolURLs(serverPool)
+	if fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf("Expected pool %v, got %v", expected, got)
+	}
+}
+
+func TestSRVDiscoveryReconciles(t *testing.T) {
+	resolver := &stubResolver{}
+	serverPool := pool.NewServerPool()
+	d := NewSRVDiscoverer(serverPool, resolver, "_http._tcp.backends.test", "http", time.Minute)
+
+	// Initial set, including a duplicate target
+	resolver.set([]*net.SRV{
+		srv("a.backends.test.", 8080, 1),
+		srv("b.backends.test.", 8080, 1),
+		srv("a.backends.test.", 8080, 1),
+	}, nil)
+	if err := d.Refresh(); err != nil {
+		t.Fatalf("Unexpected refresh error: %v", err)
+	}
+	assertURLs(t, serverPool, "http://a.backends.test:8080", "http://b.backends.test:8080")
+
+	// b goes away, c appears
+	resolver.set([]*net.SRV{
+		srv("a.backends.test.", 8080, 1),
+		srv("c.backends.test.", 9090, 1),
+	}, nil)
+	if err := d.Refresh(); err != nil {
+		t.Fatalf("Unexpected refresh error: %v", err)
+	}
+	assertURLs(t, serverPool, "http://a.backends.test:8080", "http://c.backends.test:9090")
+
+	// A lookup failure leaves the pool as it was
+	resolver.set(nil, fmt.Errorf("SERVFAIL"))
+	if err := d.Refresh(); err == nil {
+		t.Errorf("Expected refresh error on lookup failure")
+	}
+	assertURLs(t, serverPool, "http://a.backends.test:8080", "http://c.backends.test:9090")
+}
+
+func TestSRVDiscoveryKeepsStaticBackends(t *testing.T) {
+	resolver := &stubResolver{}
+	serverPool := pool.NewServerPool()
+	if err := serverPool.AddBackend("http://static.local:8080"); err != nil {
+		t.Fatalf("Failed to add static backend: %v", err)
+	}
+
+	d := NewSRVDiscoverer(serverPool, resolver, "_http._tcp.backends.test", "http", time.Minute)
+
+	resolver.set([]*net.SRV{srv("a.backends.test.", 8080, 1)}, nil)
+	d.Refresh()
+	resolver.set([]*net.SRV{srv("b.backends.test.", 8080, 1)}, nil)
+	d.Refresh()
+
+	assertURLs(t, serverPool, "http://static.local:8080", "http://b.backends.test:8080")
+}
+
+func TestSRVDiscoveryWeights(t *testing.T) {
+	resolver := &stubResolver{}
+	serverPool := pool.NewServerPool()
+	d := NewSRVDiscoverer(serverPool, resolver, "_http._tcp.backends.test", "https", time.Minute)
+
+	resolver.set([]*net.SRV{srv("a.backends.test.", 443, 5)}, nil)
+	d.Refresh()
+
+	backend := serverPool.GetBackendByIndex(0)
+	if backend.URL.String() != "https://a.backends.test" {
+		t.Errorf("Expected https backend URL, got %s", backend.URL.String())
+	}
+	if backend.Weight() != 5 {
+		t.Errorf("Expected weight 5 from SRV record, got %d", backend.Weight())
+	}
+
+	// Weight changes are applied on the next refresh
+	resolver.set([]*net.SRV{srv("a.backends.test.", 443, 2)}, nil)
+	d.Refresh()
+	if backend.Weight() != 2 {
+		t.Errorf("Expected weight to be updated to 2, got %d", backend.Weight())
+	}
+
+	// Weights above the maximum are capped
+	resolver.set([]*net.SRV{srv("a.backends.test.", 443, 65535)}, nil)
+	d.Refresh()
+	if backend.Weight() != pool.MaxWeight {
+		t.Errorf("Expected weight to be capped at %d, got %d", pool.MaxWeight, backend.Weight())
+	}
+}
+
+func TestSRVDiscoveryBackendIDsNotReused(t *testing.T) {
+	resolver := &stubResolver{}
+	serverPool := pool.NewServerPool()
+	d := NewSRVDiscoverer(serverPool, resolver, "_http._tcp.backends.test", "http", time.Minute)
+
+	resolver.set([]*net.SRV{srv("a.backends.test.", 80, 1), srv("b.backends.test.", 80, 1)}, nil)
+	d.Refresh()
+	resolver.set([]*net.SRV{srv("b.backends.test.", 80, 1), srv("c.backends.test.", 80, 1)}, nil)
+	d.Refresh()
+
+	ids := make(map[string]bool)
+	for _, backend := range serverPool.GetBackends() {
+		if ids[backend.ID] {
+			t.Errorf("Duplicate backend ID %s", backend.ID)
+		}
+		ids[backend.ID] = true
+	}
+}
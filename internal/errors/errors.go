@@ -37,8 +37,65 @@ const (
 	ErrRequestTimeout
 	ErrRequestFailed
 	ErrResponseCopy
+
+	// Balancer state errors
+	ErrMaintenanceMode
+
+	// Client errors
+	ErrClientCanceled
+
+	// Discovery errors
+	ErrDiscoveryFailed
+
+	// Shutdown errors
+	ErrShuttingDown
+
+	// Rate limiting errors
+	ErrRateLimited
+
+	// Access control errors
+	ErrMethodNotAllowed
+	ErrPathDenied
+
+	// Startup errors
+	ErrWarmingUp
+
+	// Concurrency errors
+	ErrOverloaded
+
+	// Client body errors
+	ErrClientBodyRead
+
+	// Pool capacity errors
+	ErrPoolFull
+
+	// Duplicate backend errors
+	ErrDuplicateBackend
+
+	// Forced backend errors
+	ErrInvalidForcedBackend
+
+	// Backend response errors for connections closed mid-response
+	ErrBackendEOF
+
+	// Client allowlist errors
+	ErrClientNotAllowed
+
+	// CONNECT tunnel errors
+	ErrTunnelDenied
+	ErrTunnelFailed
+
+	// Backend response errors for oversized headers
+	ErrBackendHeadersTooLarge
+
+	// Per-backend concurrency errors
+	ErrBackendsSaturated
 )
 
+// StatusClientClosedRequest is the non-standard status used when the client
+// goes away before a response is produced (as popularized by nginx)
+const StatusClientClosedRequest = 499
+
 // LoadBalancerError represents a structured error with context
 type LoadBalancerError struct {
 	Code      ErrorCode
@@ -83,7 +140,7 @@ func (e *LoadBalancerError) HTTPStatusCode() int {
 		return http.StatusServiceUnavailable
 	case ErrBackendTimeout, ErrRequestTimeout:
 		return http.StatusGatewayTimeout
-	case ErrBackendConnection, ErrBackendResponse:
+	case ErrBackendConnection, ErrBackendResponse, ErrBackendEOF, ErrBackendHeadersTooLarge:
 		return http.StatusBadGateway
 	case ErrStrategyFailure, ErrPoolEmpty, ErrMetricsFailure:
 		return http.StatusInternalServerError
@@ -91,6 +148,40 @@ func (e *LoadBalancerError) HTTPStatusCode() int {
 		return http.StatusServiceUnavailable
 	case ErrRequestFailed, ErrResponseCopy:
 		return http.StatusInternalServerError
+	case ErrMaintenanceMode:
+		return http.StatusServiceUnavailable
+	case ErrClientCanceled:
+		return StatusClientClosedRequest
+	case ErrDiscoveryFailed:
+		return http.StatusServiceUnavailable
+	case ErrShuttingDown:
+		return http.StatusServiceUnavailable
+	case ErrRateLimited:
+		return http.StatusTooManyRequests
+	case ErrMethodNotAllowed:
+		return http.StatusMethodNotAllowed
+	case ErrPathDenied:
+		return http.StatusForbidden
+	case ErrWarmingUp:
+		return http.StatusServiceUnavailable
+	case ErrOverloaded:
+		return http.StatusServiceUnavailable
+	case ErrBackendsSaturated:
+		return http.StatusServiceUnavailable
+	case ErrClientBodyRead:
+		return http.StatusBadRequest
+	case ErrPoolFull:
+		return http.StatusInternalServerError
+	case ErrDuplicateBackend:
+		return http.StatusConflict
+	case ErrInvalidForcedBackend:
+		return http.StatusBadRequest
+	case ErrClientNotAllowed:
+		return http.StatusForbidden
+	case ErrTunnelDenied:
+		return http.StatusForbidden
+	case ErrTunnelFailed:
+		return http.StatusBadGateway
 	default:
 		return http.StatusInternalServerError
 	}
@@ -166,6 +257,21 @@ func NewBackendResponseError(backend string, statusCode int) *LoadBalancerError
 		WithContext("status_code", statusCode)
 }
 
+// NewBackendEOFError reports a backend that closed the connection before
+// completing its response
+func NewBackendEOFError(backend string, cause error) *LoadBalancerError {
+	return NewError(ErrBackendEOF, fmt.Sprintf("backend closed connection early: %s", backend), cause).
+		WithContext("backend", backend)
+}
+
+// NewBackendHeadersTooLargeError reports a backend whose response headers
+// exceeded the configured limit
+func NewBackendHeadersTooLargeError(backend string, limit int64, cause error) *LoadBalancerError {
+	return NewError(ErrBackendHeadersTooLarge, fmt.Sprintf("backend response headers too large: %s", backend), cause).
+		WithContext("backend", backend).
+		WithContext("max_response_header_bytes", limit)
+}
+
 func NewNoHealthyBackendsError() *LoadBalancerError {
 	return NewError(ErrNoHealthyBackends, "no healthy backends available", nil)
 }
@@ -208,6 +314,101 @@ func NewResponseCopyError(cause error) *LoadBalancerError {
 	return NewError(ErrResponseCopy, "failed to copy response", cause)
 }
 
+// Balancer State Error Constructors
+func NewMaintenanceModeError() *LoadBalancerError {
+	return NewError(ErrMaintenanceMode, "load balancer is in maintenance mode", nil)
+}
+
+// Client Error Constructors
+func NewClientCanceledError(cause error) *LoadBalancerError {
+	return NewError(ErrClientCanceled, "client canceled request", cause)
+}
+
+// Discovery Error Constructors
+func NewDiscoveryFailedError(name string, cause error) *LoadBalancerError {
+	return NewError(ErrDiscoveryFailed, fmt.Sprintf("backend discovery failed: %s", name), cause).
+		WithContext("source", name)
+}
+
+// Shutdown Error Constructors
+func NewShuttingDownError() *LoadBalancerError {
+	return NewError(ErrShuttingDown, "load balancer is shutting down", nil)
+}
+
+// Rate Limiting Error Constructors
+func NewRateLimitedError(client string) *LoadBalancerError {
+	return NewError(ErrRateLimited, "rate limit exceeded", nil).
+		WithContext("client", client)
+}
+
+// Access Control Error Constructors
+func NewMethodNotAllowedError(method string) *LoadBalancerError {
+	return NewError(ErrMethodNotAllowed, "method not allowed", nil).
+		WithContext("method", method)
+}
+
+func NewPathDeniedError(path string) *LoadBalancerError {
+	return NewError(ErrPathDenied, "forbidden", nil).
+		WithContext("path", path)
+}
+
+func NewClientNotAllowedError(client string) *LoadBalancerError {
+	return NewError(ErrClientNotAllowed, "forbidden", nil).
+		WithContext("client", client)
+}
+
+// Tunnel Error Constructors
+func NewTunnelDeniedError(target string) *LoadBalancerError {
+	return NewError(ErrTunnelDenied, "forbidden", nil).
+		WithContext("target", target)
+}
+
+func NewTunnelFailedError(target string, cause error) *LoadBalancerError {
+	return NewError(ErrTunnelFailed, "cannot reach tunnel target", cause).
+		WithContext("target", target)
+}
+
+// Startup Error Constructors
+func NewWarmingUpError() *LoadBalancerError {
+	return NewError(ErrWarmingUp, "backends are still being health checked", nil)
+}
+
+// Concurrency Error Constructors
+func NewOverloadedError(limit int) *LoadBalancerError {
+	return NewError(ErrOverloaded, "too many concurrent requests", nil).
+		WithContext("max_concurrent_requests", limit)
+}
+
+func NewBackendsSaturatedError(limit int) *LoadBalancerError {
+	return NewError(ErrBackendsSaturated, "every backend is at its connection limit", nil).
+		WithContext("max_backend_connections", limit)
+}
+
+// Client Body Error Constructors
+func NewClientBodyReadError(cause error) *LoadBalancerError {
+	return NewError(ErrClientBodyRead, "failed to read request body", cause)
+}
+
+// Pool Capacity Error Constructors
+func NewPoolFullError(backendURL string, maxBackends int) *LoadBalancerError {
+	return NewError(ErrPoolFull, fmt.Sprintf("server pool is full, cannot add backend: %s", backendURL), nil).
+		WithContext("backend", backendURL).
+		WithContext("max_backends", maxBackends)
+}
+
+// Duplicate Backend Error Constructors
+func NewDuplicateBackendError(backendURL, existingID string) *LoadBalancerError {
+	return NewError(ErrDuplicateBackend, fmt.Sprintf("backend already in pool as %s: %s", existingID, backendURL), nil).
+		WithContext("backend", backendURL).
+		WithContext("existing_backend", existingID)
+}
+
+// Forced Backend Error Constructors
+func NewInvalidForcedBackendError(id, reason string) *LoadBalancerError {
+	return NewError(ErrInvalidForcedBackend, fmt.Sprintf("cannot force backend %s: %s", id, reason), nil).
+		WithContext("backend", id)
+}
+
 // IsConfigurationError checks if the error is a configuration-related error
 func IsConfigurationError(err error) bool {
 	if lbErr, ok := err.(*LoadBalancerError); ok {
@@ -219,7 +420,8 @@ func IsConfigurationError(err error) bool {
 // IsBackendError checks if the error is a backend-related error
 func IsBackendError(err error) bool {
 	if lbErr, ok := err.(*LoadBalancerError); ok {
-		return lbErr.Code >= ErrBackendUnavailable && lbErr.Code <= ErrNoHealthyBackends
+		return (lbErr.Code >= ErrBackendUnavailable && lbErr.Code <= ErrNoHealthyBackends) ||
+			lbErr.Code == ErrBackendEOF || lbErr.Code == ErrBackendHeadersTooLarge
 	}
 	return false
 }
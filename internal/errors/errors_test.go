@@ -1,6 +1,7 @@
 package errors
 
 import (
+	"fmt"
 	"net/http"
 	"strings"
 	"testing"
@@ -42,6 +43,68 @@ func TestErrorCodes(t *testing.T) {
 			expectedHTTP:     http.StatusServiceUnavailable,
 			expectedCategory: "health_check",
 		},
+		{
+			name:         "Client Body Read Error",
+			err:          NewClientBodyReadError(nil),
+			expectedCode: ErrClientBodyRead,
+			expectedHTTP: http.StatusBadRequest,
+		},
+		{
+			name:         "Pool Full Error",
+			err:          NewPoolFullError("http://localhost:8080", 2),
+			expectedCode: ErrPoolFull,
+			expectedHTTP: http.StatusInternalServerError,
+		},
+		{
+			name:         "Duplicate Backend Error",
+			err:          NewDuplicateBackendError("http://localhost:8080", "backend-1"),
+			expectedCode: ErrDuplicateBackend,
+			expectedHTTP: http.StatusConflict,
+		},
+		{
+			name:             "Backend EOF Error",
+			err:              NewBackendEOFError("backend-1", nil),
+			expectedCode:     ErrBackendEOF,
+			expectedHTTP:     http.StatusBadGateway,
+			expectedCategory: "backend",
+		},
+		{
+			name:             "Backend Headers Too Large Error",
+			err:              NewBackendHeadersTooLargeError("backend-1", 1024, nil),
+			expectedCode:     ErrBackendHeadersTooLarge,
+			expectedHTTP:     http.StatusBadGateway,
+			expectedCategory: "backend",
+		},
+		{
+			name:         "Backends Saturated Error",
+			err:          NewBackendsSaturatedError(10),
+			expectedCode: ErrBackendsSaturated,
+			expectedHTTP: http.StatusServiceUnavailable,
+		},
+		{
+			name:         "Invalid Forced Backend Error",
+			err:          NewInvalidForcedBackendError("backend-9", "unknown backend"),
+			expectedCode: ErrInvalidForcedBackend,
+			expectedHTTP: http.StatusBadRequest,
+		},
+		{
+			name:         "Client Not Allowed Error",
+			err:          NewClientNotAllowedError("203.0.113.5"),
+			expectedCode: ErrClientNotAllowed,
+			expectedHTTP: http.StatusForbidden,
+		},
+		{
+			name:         "Tunnel Denied Error",
+			err:          NewTunnelDeniedError("db.internal:5432"),
+			expectedCode: ErrTunnelDenied,
+			expectedHTTP: http.StatusForbidden,
+		},
+		{
+			name:         "Tunnel Failed Error",
+			err:          NewTunnelFailedError("db.internal:5432", fmt.Errorf("connection refused")),
+			expectedCode: ErrTunnelFailed,
+			expectedHTTP: http.StatusBadGateway,
+		},
 	}
 
 	for _, tt := range tests {
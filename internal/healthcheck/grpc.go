@@ -0,0 +1,163 @@
+package healthcheck
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"go-balancer/internal/errors"
+	"go-balancer/internal/pool"
+)
+
+// grpcHealthCheckPath is the method path of the standard gRPC health checking
+// protocol (grpc.health.v1.Health/Check)
+const grpcHealthCheckPath = "/grpc.health.v1.Health/Check"
+
+// grpcServing is the HealthCheckResponse status reported by a serving backend
+const grpcServing = 1
+
+// probeGRPC checks a backend with the gRPC health RPC. Plain http backends
+// are spoken to over h2c, https ones over h2; the backend counts as healthy
+// only if the call succeeds and reports SERVING.
+func (hc *HealthChecker) probeGRPC(backend *pool.Backend) *errors.LoadBalancerError {
+	healthURL := backend.URL.String() + grpcHealthCheckPath
+
+	ctx, cancel := context.WithTimeout(context.Background(), hc.timeoutFor(backend))
+	defer cancel()
+
+	body := grpcFrame(encodeHealthCheckRequest(hc.grpcService))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, healthURL, bytes.NewReader(body))
+	if err != nil {
+		return errors.NewHealthCheckFailedError(backend.ID, err).WithContext("url", healthURL)
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+	req.Header.Add("User-Agent", "GoLoadBalancer-HealthCheck/1.0")
+
+	// The grpc-status trailer only arrives after the body, so the response is
+	// read in full before it is judged
+	start := time.Now()
+	resp, err := hc.client.Do(req)
+	var message []byte
+	if err == nil {
+		message, err = io.ReadAll(io.LimitReader(resp.Body, maxDrainBytes))
+		resp.Body.Close()
+	}
+	hc.recordDuration(backend, start)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return errors.NewHealthCheckTimeoutError(backend.ID).WithContext("url", healthURL)
+		}
+		return errors.NewHealthCheckFailedError(backend.ID, err).WithContext("url", healthURL)
+	}
+
+	status, err := grpcHealthStatus(resp, message)
+	if err != nil || status != grpcServing {
+		return errors.NewHealthCheckFailedError(backend.ID, err).
+			WithContext("serving_status", status).
+			WithContext("url", healthURL)
+	}
+	return nil
+}
+
+// grpcFrame wraps a protobuf message in the gRPC length-prefixed framing:
+// an uncompressed flag byte followed by the big-endian message length
+func grpcFrame(message []byte) []byte {
+	frame := make([]byte, 5, 5+len(message))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(message)))
+	return append(frame, message...)
+}
+
+// encodeHealthCheckRequest encodes a HealthCheckRequest, whose only field is
+// the service name (field 1); an empty name is encoded as an empty message
+func encodeHealthCheckRequest(service string) []byte {
+	if service == "" {
+		return nil
+	}
+	message := []byte{0x0a} // field 1, length-delimited
+	message = binary.AppendUvarint(message, uint64(len(service)))
+	return append(message, service...)
+}
+
+// grpcHealthStatus returns the serving status from a health RPC response. A
+// non-zero grpc-status (e.g. NOT_FOUND for an unknown service) is an error.
+func grpcHealthStatus(resp *http.Response, body []byte) (int, error) {
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unexpected HTTP status %d", resp.StatusCode)
+	}
+
+	// Errors may come as a trailers-only response, with the status in the headers
+	code := resp.Trailer.Get("Grpc-Status")
+	if code == "" {
+		code = resp.Header.Get("Grpc-Status")
+	}
+	if code != "0" {
+		message := resp.Trailer.Get("Grpc-Message")
+		if message == "" {
+			message = resp.Header.Get("Grpc-Message")
+		}
+		return 0, fmt.Errorf("grpc-status %q: %s", code, message)
+	}
+
+	if len(body) < 5 {
+		return 0, fmt.Errorf("short gRPC response frame (%d bytes)", len(body))
+	}
+	if body[0] != 0 {
+		return 0, fmt.Errorf("compressed gRPC responses are not supported")
+	}
+	length := binary.BigEndian.Uint32(body[1:5])
+	if uint64(len(body)-5) < uint64(length) {
+		return 0, fmt.Errorf("truncated gRPC response frame")
+	}
+	return decodeHealthCheckResponse(body[5 : 5+length])
+}
+
+// decodeHealthCheckResponse reads the status (field 1, varint) from a
+// HealthCheckResponse, skipping any fields it doesn't know. A missing status
+// is UNKNOWN (0), the proto3 default.
+func decodeHealthCheckResponse(message []byte) (int, error) {
+	status := 0
+	for len(message) > 0 {
+		key, n := binary.Uvarint(message)
+		if n <= 0 {
+			return 0, fmt.Errorf("malformed health check response")
+		}
+		message = message[n:]
+
+		field, wireType := key>>3, key&7
+		switch wireType {
+		case 0: // varint
+			value, n := binary.Uvarint(message)
+			if n <= 0 {
+				return 0, fmt.Errorf("malformed health check response")
+			}
+			message = message[n:]
+			if field == 1 {
+				status = int(value)
+			}
+		case 1: // fixed64
+			if len(message) < 8 {
+				return 0, fmt.Errorf("malformed health check response")
+			}
+			message = message[8:]
+		case 2: // length-delimited
+			length, n := binary.Uvarint(message)
+			if n <= 0 || uint64(len(message)-n) < length {
+				return 0, fmt.Errorf("malformed health check response")
+			}
+			message = message[n+int(length):]
+		case 5: // fixed32
+			if len(message) < 4 {
+				return 0, fmt.Errorf("malformed health check response")
+			}
+			message = message[4:]
+		default:
+			return 0, fmt.Errorf("malformed health check response")
+		}
+	}
+	return status, nil
+}
@@ -2,67 +2,425 @@ package healthcheck
 
 import (
 	"context"
+	"crypto/tls"
+	"io"
 	"log"
+	"math/rand"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"go-balancer/internal/clientcert"
+	"go-balancer/internal/config"
 	"go-balancer/internal/errors"
+	"go-balancer/internal/metrics"
 	"go-balancer/internal/pool"
 )
 
+// maxDrainBytes bounds how much of a probe response body is read so the
+// connection can be reused
+const maxDrainBytes = 64 << 10
+
+// pendingRetryInterval is how often backends still pending during the startup
+// grace period are re-probed
+const pendingRetryInterval = 250 * time.Millisecond
+
 // HealthChecker performs periodic health checks on backend servers
 type HealthChecker struct {
 	serverPool    *pool.ServerPool
+	checkType     string
 	checkPath     string
+	checkMethod   string
+	checkBody     string // Request body sent with HTTP probes (optional)
+	contentType   string // Content-Type of checkBody
 	checkInterval time.Duration
 	checkTimeout  time.Duration
+	checkJitter   time.Duration            // Upper bound on the random delay before each probe
+	jitter        float64                  // checkJitter as a fraction of the interval
+	intervals     map[string]time.Duration // Per-backend intervals keyed by backend URL
+	timeouts      map[string]time.Duration // Per-backend probe timeouts keyed by backend URL
+	requireHeader string                   // Header a healthy probe response must carry (optional)
+	requireValue  string                   // Value requireHeader must have (empty accepts any)
+	grpcService   string                   // Service name sent in grpc health checks
 	client        *http.Client
 	stopCh        chan struct{}
+	stopOnce      sync.Once
+	wg            sync.WaitGroup   // Tracks the check loop and probe rounds
+	semaphore     chan struct{}    // Bounds the number of concurrent probes
+	rng           *rand.Rand       // Draws probe delays; only used from the check loop
+	metrics       *metrics.Metrics // Receives probe durations; nil disables recording
+	startupGrace  time.Duration    // How long after Start unverified backends stay pending
+	graceUntil    time.Time        // End of the startup grace period; set by Start
+	healthyMax    time.Duration    // Longest adaptive interval; 0 disables adaptive intervals
+	burst         int              // Probes of every backend at startup, counting the first round
+	burstInterval time.Duration    // Time between startup burst rounds
+
+	// healthyStreaks counts, per backend ID, the consecutive probes that found
+	// the backend healthy; it drives adaptive intervals
+	streakMu       sync.Mutex
+	healthyStreaks map[string]int
+	streakBroken   chan struct{} // Wakes the check loop to pull in probes of backends whose streak ended
+
+	// initialized is set once the first round of probes has finished, so
+	// every backend present at startup has a known health state
+	initialized atomic.Bool
 }
 
-// NewHealthChecker creates a new health checker
-func NewHealthChecker(
-	serverPool *pool.ServerPool,
-	checkPath string,
-	checkInterval time.Duration,
-	checkTimeout time.Duration,
-) *HealthChecker {
+// NewHealthChecker creates a new health checker from the health check settings in cfg
+func NewHealthChecker(serverPool *pool.ServerPool, cfg *config.Config) *HealthChecker {
+	checkMethod := cfg.HealthCheckMethod
+	if checkMethod == "" {
+		checkMethod = http.MethodGet
+	}
+
+	contentType := cfg.HealthCheckContentType
+	if contentType == "" {
+		contentType = config.DefaultHealthCheckContentType
+	}
+
+	concurrency := cfg.HealthCheckConcurrency
+	if concurrency <= 0 {
+		concurrency = config.DefaultHealthCheckConcurrency
+	}
+
+	burstInterval := cfg.HealthCheckStartupBurstInterval
+	if burstInterval <= 0 {
+		burstInterval = config.DefaultHealthCheckStartupBurstInterval
+	}
+
 	return &HealthChecker{
 		serverPool:    serverPool,
-		checkPath:     checkPath,
-		checkInterval: checkInterval,
-		checkTimeout:  checkTimeout,
-		client: &http.Client{
-			Timeout: checkTimeout,
-		},
-		stopCh: make(chan struct{}),
+		checkType:     cfg.HealthCheckType,
+		checkPath:     cfg.HealthCheckPath,
+		checkMethod:   checkMethod,
+		checkBody:     cfg.HealthCheckBody,
+		contentType:   contentType,
+		checkInterval: cfg.HealthCheckInterval,
+		checkTimeout:  cfg.HealthCheckTimeout,
+		checkJitter:   time.Duration(cfg.HealthCheckJitter * float64(cfg.HealthCheckInterval)),
+		jitter:        cfg.HealthCheckJitter,
+		intervals:     normalizeIntervals(cfg.HealthCheckIntervals),
+		timeouts:      normalizeIntervals(cfg.HealthCheckTimeouts),
+		requireHeader: cfg.HealthCheckRequireHeader,
+		requireValue:  cfg.HealthCheckRequireHeaderValue,
+		grpcService:   cfg.HealthCheckGRPCService,
+		startupGrace:  cfg.HealthCheckStartupGrace,
+		healthyMax:    cfg.HealthCheckHealthyMaxInterval,
+		burst:         cfg.HealthCheckStartupBurst,
+		burstInterval: burstInterval,
+		client:        newClient(cfg),
+		stopCh:        make(chan struct{}),
+		semaphore:     make(chan struct{}, concurrency),
+		rng:           rand.New(rand.NewSource(time.Now().UnixNano())),
+
+		healthyStreaks: make(map[string]int),
+		streakBroken:   make(chan struct{}, 1),
+	}
+}
+
+// CheckURL probes backendURL once using the health check settings in cfg,
+// without a running checker or a shared pool. It returns nil if the backend is
+// healthy and the reason otherwise.
+func CheckURL(cfg *config.Config, backendURL string) error {
+	serverPool := pool.NewServerPool()
+	backend, err := serverPool.Add(backendURL)
+	if err != nil {
+		return errors.NewInvalidBackendError(backendURL, err)
+	}
+
+	hc := NewHealthChecker(serverPool, cfg)
+	if cfg.HealthCheckCAFile != "" {
+		roots, err := LoadRootCAs(cfg.HealthCheckCAFile)
+		if err != nil {
+			return errors.NewInvalidConfigError("invalid health check CA file", err).
+				WithContext("health_ca_file", cfg.HealthCheckCAFile)
+		}
+		hc.SetRootCAs(roots)
+	}
+	clientCerts, err := clientcert.Load(cfg)
+	if err != nil {
+		return errors.NewInvalidConfigError("invalid upstream client certificate", err)
+	}
+	hc.SetClientCertificates(clientCerts)
+
+	if healthErr := hc.probe(backend); healthErr != nil {
+		return healthErr
+	}
+	return nil
+}
+
+// normalizeIntervals rekeys per-backend intervals (or timeouts) by canonical
+// backend URL, the form pooled backends carry
+func normalizeIntervals(intervals map[string]time.Duration) map[string]time.Duration {
+	if intervals == nil {
+		return nil
+	}
+	normalized := make(map[string]time.Duration, len(intervals))
+	for backendURL, interval := range intervals {
+		normalized[pool.NormalizeURL(backendURL)] = interval
+	}
+	return normalized
+}
+
+// newClient builds the probe client. Unless configured to follow them,
+// redirects are not followed: the 3xx response itself is judged, so a backend
+// redirecting to a login page isn't mistaken for healthy.
+func newClient(cfg *config.Config) *http.Client {
+	// Each probe's context enforces its backend's timeout; the client timeout
+	// is only a backstop and must not cut short the longest override
+	timeout := cfg.HealthCheckTimeout
+	for _, override := range cfg.HealthCheckTimeouts {
+		timeout = max(timeout, override)
+	}
+
+	client := &http.Client{
+		Timeout:   timeout,
+		Transport: newTransport(cfg),
+	}
+	if !cfg.HealthCheckFollowRedirects {
+		client.CheckRedirect = func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+	return client
+}
+
+// newTransport builds a transport dedicated to health probes, separate from the
+// proxy transport. Each backend is probed by one request per interval, so a
+// single idle connection per host is enough; keeping the idle timeout just past
+// the interval lets probes reuse it without piling up descriptors.
+func newTransport(cfg *config.Config) *http.Transport {
+	idleTimeout := cfg.HealthCheckIdleTimeout
+	if idleTimeout == 0 {
+		idleTimeout = 2 * cfg.HealthCheckInterval
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = 1
+	transport.IdleConnTimeout = idleTimeout
+	if cfg.HealthCheckSNI != "" {
+		// Send and verify the configured name rather than the URL host, for
+		// backends addressed by IP or an alias their certificate doesn't cover
+		transport.TLSClientConfig = &tls.Config{ServerName: cfg.HealthCheckSNI}
+	}
+	if cfg.HealthCheckType == config.HealthCheckGRPC {
+		// gRPC needs HTTP/2: h2c for plain http backends, h2 over TLS otherwise
+		protocols := new(http.Protocols)
+		protocols.SetHTTP2(true)
+		protocols.SetUnencryptedHTTP2(true)
+		transport.Protocols = protocols
+	}
+	return transport
+}
+
+// SetMetrics registers where probe durations are recorded. Call it before Start.
+func (hc *HealthChecker) SetMetrics(m *metrics.Metrics) {
+	hc.metrics = m
+}
+
+// recordDuration records how long a probe took, if metrics are registered
+func (hc *HealthChecker) recordDuration(backend *pool.Backend, start time.Time) {
+	if hc.metrics != nil {
+		hc.metrics.RecordHealthCheckDuration(backend.ID, time.Since(start))
 	}
 }
 
 // Start begins periodic health checking
 func (hc *HealthChecker) Start() {
-	go hc.healthCheckLoop()
-	log.Printf("Health checker started with interval %s and path %s",
-		hc.checkInterval, hc.checkPath)
+	hc.graceUntil = time.Now().Add(hc.startupGrace)
+	hc.wg.Add(1)
+	go func() {
+		defer hc.wg.Done()
+		hc.healthCheckLoop()
+	}()
+	if hc.checkType == config.HealthCheckTCP {
+		log.Printf("Health checker started with interval %s (jitter %s) using TCP connect", hc.checkInterval, hc.checkJitter)
+		return
+	}
+	if hc.checkType == config.HealthCheckGRPC {
+		log.Printf("Health checker started with interval %s (jitter %s) using gRPC health checks (service %q)",
+			hc.checkInterval, hc.checkJitter, hc.grpcService)
+		return
+	}
+	log.Printf("Health checker started with interval %s (jitter %s) and %s %s",
+		hc.checkInterval, hc.checkJitter, hc.checkMethod, hc.checkPath)
+}
+
+// Initialized reports whether the first round of probes has finished and no
+// backend is still pending in the startup grace period. Until then backends
+// have not been verified and are treated as unhealthy.
+func (hc *HealthChecker) Initialized() bool {
+	if !hc.initialized.Load() {
+		return false
+	}
+	if hc.inStartupGrace() {
+		for _, backend := range hc.serverPool.GetBackends() {
+			if !backend.IsHealthy() {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// inStartupGrace reports whether the startup grace period is still running
+func (hc *HealthChecker) inStartupGrace() bool {
+	return time.Now().Before(hc.graceUntil)
+}
+
+// pending reports whether a backend has not passed a probe yet and the
+// startup grace period is still running. Failed probes of pending backends
+// are expected while they start up and are not reported as failures.
+func (hc *HealthChecker) pending(backend *pool.Backend) bool {
+	return !backend.IsHealthy() && hc.inStartupGrace()
 }
 
-// Stop terminates health checking
+// nextProbe returns how long until a backend is probed again: its interval,
+// stretched while it stays healthy, or a short retry while it is pending so
+// it is put in rotation soon after it comes up
+func (hc *HealthChecker) nextProbe(backend *pool.Backend) time.Duration {
+	interval := hc.intervalFor(backend)
+	if hc.pending(backend) {
+		return min(interval, pendingRetryInterval)
+	}
+	return hc.adaptiveInterval(backend, interval)
+}
+
+// adaptiveInterval stretches a backend's base interval while it keeps passing
+// probes: each healthy probe after the first doubles it, up to healthyMax. A
+// backend that is down, or failed its last probe, is probed on the base
+// interval, so one that flaps is watched closely.
+func (hc *HealthChecker) adaptiveInterval(backend *pool.Backend, base time.Duration) time.Duration {
+	if hc.healthyMax <= base || !backend.IsHealthy() {
+		return base
+	}
+
+	hc.streakMu.Lock()
+	streak := hc.healthyStreaks[backend.ID]
+	hc.streakMu.Unlock()
+
+	interval := base
+	for i := 1; i < streak && interval < hc.healthyMax; i++ {
+		interval *= 2
+	}
+	return min(interval, hc.healthyMax)
+}
+
+// recordProbe updates a backend's healthy streak after a probe
+func (hc *HealthChecker) recordProbe(backend *pool.Backend) {
+	if hc.healthyMax <= 0 {
+		return
+	}
+
+	hc.streakMu.Lock()
+	defer hc.streakMu.Unlock()
+	if backend.IsHealthy() {
+		hc.healthyStreaks[backend.ID]++
+		return
+	}
+
+	// The backend's next probe was scheduled on its stretched interval;
+	// have the loop bring it back to the base interval
+	if hc.healthyStreaks[backend.ID] > 0 {
+		select {
+		case hc.streakBroken <- struct{}{}:
+		default:
+		}
+	}
+	delete(hc.healthyStreaks, backend.ID)
+}
+
+// Stop terminates health checking and returns once every health check
+// goroutine has exited. Probes already in flight are allowed to finish, so it
+// may block for up to the probe timeout. It is safe to call more than once.
 func (hc *HealthChecker) Stop() {
-	close(hc.stopCh)
+	hc.stopOnce.Do(func() {
+		close(hc.stopCh)
+	})
+	hc.wg.Wait()
+}
+
+// intervalFor returns how often a backend is probed
+func (hc *HealthChecker) intervalFor(backend *pool.Backend) time.Duration {
+	if interval, ok := hc.intervals[backend.URL.String()]; ok && interval > 0 {
+		return interval
+	}
+	return hc.checkInterval
+}
+
+// timeoutFor returns how long a probe of a backend may take
+func (hc *HealthChecker) timeoutFor(backend *pool.Backend) time.Duration {
+	if timeout, ok := hc.timeouts[backend.URL.String()]; ok && timeout > 0 {
+		return timeout
+	}
+	return hc.checkTimeout
 }
 
-// healthCheckLoop runs the health check at regular intervals
+// healthCheckLoop probes each backend on its own interval. Rather than one
+// ticker per backend it tracks when each is next due and sleeps until the
+// earliest, so backends added or removed at runtime are picked up on the
+// next wake-up.
 func (hc *HealthChecker) healthCheckLoop() {
-	ticker := time.NewTicker(hc.checkInterval)
-	defer ticker.Stop()
+	// Run an immediate check when starting; jitter only applies afterwards so
+	// backends are known healthy as soon as possible
+	hc.checkAllBackends(0)
+	hc.initialized.Store(true)
+	if !hc.warmUp() {
+		log.Println("Health checker stopped")
+		return
+	}
+
+	now := time.Now()
+	due := make(map[*pool.Backend]time.Time)
+	for _, backend := range hc.serverPool.GetBackends() {
+		due[backend] = now.Add(hc.nextProbe(backend))
+	}
 
-	// Run an immediate check when starting
-	hc.checkAllBackends()
+	timer := time.NewTimer(hc.nextWake(due, now))
+	defer timer.Stop()
 
 	for {
 		select {
-		case <-ticker.C:
-			hc.checkAllBackends()
+		case <-timer.C:
+			now := time.Now()
+			var ready []*pool.Backend
+			var delays []time.Duration
+			current := make(map[*pool.Backend]time.Time, len(due))
+			for _, backend := range hc.serverPool.GetBackends() {
+				// Backends added since the last wake-up are probed now
+				at, ok := due[backend]
+				if !ok || !at.After(now) {
+					interval := hc.intervalFor(backend)
+					ready = append(ready, backend)
+					delays = append(delays, hc.probeDelay(time.Duration(hc.jitter*float64(interval))))
+					at = now.Add(hc.nextProbe(backend))
+				}
+				current[backend] = at
+			}
+			due = current
+
+			// Probe without blocking the schedule, so a slow probe doesn't
+			// delay backends on shorter intervals
+			if len(ready) > 0 {
+				hc.wg.Add(1)
+				go func() {
+					defer hc.wg.Done()
+					hc.probeBackends(ready, delays)
+				}()
+			}
+			timer.Reset(hc.nextWake(due, now))
+		case <-hc.streakBroken:
+			now := time.Now()
+			for backend, at := range due {
+				if sooner := now.Add(hc.nextProbe(backend)); sooner.Before(at) {
+					due[backend] = sooner
+				}
+			}
+			timer.Reset(hc.nextWake(due, now))
 		case <-hc.stopCh:
 			log.Println("Health checker stopped")
 			return
@@ -70,67 +428,223 @@ func (hc *HealthChecker) healthCheckLoop() {
 	}
 }
 
-// checkAllBackends performs health checks on all backends
-func (hc *HealthChecker) checkAllBackends() {
+// warmUp follows the startup round with the rest of the startup burst: more
+// rounds of probes, burstInterval apart, so a backend that wasn't ready for
+// the first probe is found healthy without waiting a full interval. It
+// returns false if the checker was stopped meanwhile.
+func (hc *HealthChecker) warmUp() bool {
+	for round := 1; round < hc.burst; round++ {
+		timer := time.NewTimer(hc.burstInterval)
+		select {
+		case <-timer.C:
+		case <-hc.stopCh:
+			timer.Stop()
+			return false
+		}
+		hc.checkAllBackends(0)
+	}
+	return true
+}
+
+// nextWake returns how long to sleep until the earliest due probe, or a
+// global interval when there are no backends yet
+func (hc *HealthChecker) nextWake(due map[*pool.Backend]time.Time, now time.Time) time.Duration {
+	wake := hc.checkInterval
+	for _, at := range due {
+		if wait := at.Sub(now); wait < wake {
+			wake = wait
+		}
+	}
+	return max(wake, 0)
+}
+
+// checkAllBackends performs health checks on all backends and returns when
+// every probe has finished. Each probe is delayed by a random amount below
+// maxDelay so backends aren't all probed at the same instant.
+func (hc *HealthChecker) checkAllBackends(maxDelay time.Duration) {
 	backends := hc.serverPool.GetBackends()
-	for _, backend := range backends {
-		go hc.checkBackend(backend)
+	delays := make([]time.Duration, len(backends))
+	for i := range delays {
+		delays[i] = hc.probeDelay(maxDelay)
+	}
+	hc.probeBackends(backends, delays)
+}
+
+// probeBackends probes each backend after its delay, running at most
+// cap(semaphore) probes at once, and returns when every probe has finished.
+// Delays are drawn by the caller since rng is only used from the check loop.
+func (hc *HealthChecker) probeBackends(backends []*pool.Backend, delays []time.Duration) {
+	var wg sync.WaitGroup
+	for i, backend := range backends {
+		delay := delays[i]
+		wg.Add(1)
+		go func(b *pool.Backend) {
+			defer wg.Done()
+
+			if delay > 0 {
+				timer := time.NewTimer(delay)
+				select {
+				case <-timer.C:
+				case <-hc.stopCh:
+					timer.Stop()
+					return
+				}
+			}
+
+			hc.semaphore <- struct{}{}
+			defer func() { <-hc.semaphore }()
+			hc.checkBackend(b)
+			hc.recordProbe(b)
+		}(backend)
+	}
+	wg.Wait()
+}
+
+// probeDelay returns a random delay in [0, maxDelay)
+func (hc *HealthChecker) probeDelay(maxDelay time.Duration) time.Duration {
+	if maxDelay <= 0 {
+		return 0
 	}
+	return time.Duration(hc.rng.Int63n(int64(maxDelay)))
 }
 
-// checkBackend checks the health of a single backend
+// checkBackend probes a single backend and updates its health. Failures of a
+// pending backend are expected while it starts up and leave it pending.
 func (hc *HealthChecker) checkBackend(backend *pool.Backend) {
+	healthErr := hc.probe(backend)
+	healthy := healthErr == nil
+	if !healthy && hc.pending(backend) {
+		log.Printf("Backend %s not ready during startup grace period: %v", backend.ID, healthErr)
+		return
+	}
+
+	// A backend that flapped too often sits out its penalty whatever probes say
+	if healthy && !backend.IsHealthy() && backend.Damped() {
+		log.Printf("Backend %s passed its health check but is held unhealthy for flapping", backend.ID)
+		return
+	}
+
+	// Update backend health status if changed
+	if backend.IsHealthy() != healthy {
+		if healthy {
+			log.Printf("Backend %s is now healthy", backend.ID)
+		} else {
+			log.Printf("Backend %s is now unhealthy: %v", backend.ID, healthErr)
+		}
+		hc.serverPool.SetBackendHealth(backend.ID, healthy)
+		if !healthy && backend.Damped() {
+			log.Printf("Backend %s flapped too often and is held unhealthy", backend.ID)
+		}
+	} else if !healthy {
+		log.Printf("Health check failed for backend %s: %v", backend.ID, healthErr)
+	}
+}
+
+// probe checks a backend once with the configured check type, returning nil
+// if it is healthy and the reason otherwise. It records the probe duration
+// but leaves the backend's health alone.
+func (hc *HealthChecker) probe(backend *pool.Backend) *errors.LoadBalancerError {
+	switch hc.checkType {
+	case config.HealthCheckTCP:
+		return hc.probeTCP(backend)
+	case config.HealthCheckGRPC:
+		return hc.probeGRPC(backend)
+	}
+	return hc.probeHTTP(backend)
+}
+
+// probeHTTP requests the backend's health endpoint, which must answer 200
+// (and carry the required header, if one is set)
+func (hc *HealthChecker) probeHTTP(backend *pool.Backend) *errors.LoadBalancerError {
 	// Construct health check URL
 	healthURL := backend.URL.String() + hc.checkPath
 
 	// Create context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), hc.checkTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), hc.timeoutFor(backend))
 	defer cancel()
 
-	// Create request with context
-	req, err := http.NewRequestWithContext(ctx, "GET", healthURL, nil)
+	// Create request with context, carrying the configured body if any
+	var body io.Reader
+	if hc.checkBody != "" {
+		body = strings.NewReader(hc.checkBody)
+	}
+	req, err := http.NewRequestWithContext(ctx, hc.checkMethod, healthURL, body)
 	if err != nil {
-		healthErr := errors.NewHealthCheckFailedError(backend.ID, err)
-		log.Printf("Health check error: %v", healthErr)
-		hc.serverPool.SetBackendHealth(backend.ID, false)
-		return
+		return errors.NewHealthCheckFailedError(backend.ID, err).WithContext("url", healthURL)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", hc.contentType)
 	}
 
 	// Add headers to identify health check requests
 	req.Header.Add("User-Agent", "GoLoadBalancer-HealthCheck/1.0")
 
-	// Perform the health check request
+	// Perform the health check request, timing it to spot slow health endpoints
+	start := time.Now()
 	resp, err := hc.client.Do(req)
+	hc.recordDuration(backend, start)
 	if err != nil {
-		var healthErr *errors.LoadBalancerError
-
 		// Check if it's a timeout error
 		if ctx.Err() == context.DeadlineExceeded {
-			healthErr = errors.NewHealthCheckTimeoutError(backend.ID)
-		} else {
-			healthErr = errors.NewHealthCheckFailedError(backend.ID, err)
+			return errors.NewHealthCheckTimeoutError(backend.ID).WithContext("url", healthURL)
 		}
-
-		log.Printf("Health check failed for backend %s (%s): %v",
-			backend.ID, healthURL, healthErr)
-		hc.serverPool.SetBackendHealth(backend.ID, false)
-		return
+		return errors.NewHealthCheckFailedError(backend.ID, err).WithContext("url", healthURL)
 	}
 	defer resp.Body.Close()
 
-	// Check if status code indicates health
-	healthy := resp.StatusCode == http.StatusOK
+	// Drain the body so the connection goes back to the idle pool
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrainBytes))
 
-	// Update backend health status if changed
-	if backend.Healthy != healthy {
-		if healthy {
-			log.Printf("Backend %s is now healthy", backend.ID)
-		} else {
-			healthErr := errors.NewHealthCheckFailedError(backend.ID, nil).
-				WithContext("status_code", resp.StatusCode).
-				WithContext("url", healthURL)
-			log.Printf("Backend %s is now unhealthy: %v", backend.ID, healthErr)
+	// Check if status code, and the required header if any, indicate health
+	statusOK := resp.StatusCode == http.StatusOK
+	headerOK := hc.hasRequiredHeader(resp.Header)
+	if statusOK && headerOK {
+		return nil
+	}
+
+	healthErr := errors.NewHealthCheckFailedError(backend.ID, nil).
+		WithContext("status_code", resp.StatusCode).
+		WithContext("url", healthURL)
+	if !headerOK {
+		healthErr = healthErr.WithContext("header", hc.requireHeader).
+			WithContext("header_value", resp.Header.Get(hc.requireHeader))
+	}
+	return healthErr
+}
+
+// hasRequiredHeader reports whether a probe response carries the required
+// header (with the required value, if one is set)
+func (hc *HealthChecker) hasRequiredHeader(header http.Header) bool {
+	if hc.requireHeader == "" {
+		return true
+	}
+
+	values := header.Values(hc.requireHeader)
+	if hc.requireValue == "" {
+		return len(values) > 0
+	}
+	for _, value := range values {
+		if strings.TrimSpace(value) == hc.requireValue {
+			return true
 		}
-		hc.serverPool.SetBackendHealth(backend.ID, healthy)
 	}
+	return false
+}
+
+// probeTCP checks a backend by opening (and immediately closing) a TCP
+// connection to its host and port
+func (hc *HealthChecker) probeTCP(backend *pool.Backend) *errors.LoadBalancerError {
+	address := net.JoinHostPort(backend.URL.Hostname(), strconv.Itoa(backend.Port))
+
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", address, hc.timeoutFor(backend))
+	hc.recordDuration(backend, start)
+	if err != nil {
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			return errors.NewHealthCheckTimeoutError(backend.ID).WithContext("address", address)
+		}
+		return errors.NewHealthCheckFailedError(backend.ID, err).WithContext("address", address)
+	}
+	conn.Close()
+	return nil
 }
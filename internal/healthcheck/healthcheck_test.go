@@ -0,0 +1,1033 @@
+package healthcheck
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	cryptorand "crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-balancer/internal/config"
+	"go-balancer/internal/metrics"
+	"go-balancer/internal/pool"
+)
+
+func newTestConfig(method string) *config.Config {
+	return &config.Config{
+		HealthCheckPath:     "/",
+		HealthCheckMethod:   method,
+		HealthCheckInterval: 10 * time.Second,
+		HealthCheckTimeout:  2 * time.Second,
+	}
+}
+
+func TestHealthCheckMethod(t *testing.T) {
+	// Mock backend that only reports healthy for HEAD requests
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	tests := []struct {
+		name          string
+		method        string
+		expectHealthy bool
+	}{
+		{"HEAD probe", http.MethodHead, true},
+		{"GET probe", http.MethodGet, false},
+		{"Default probe", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serverPool := pool.NewServerPool()
+			if err := serverPool.AddBackend(mockServer.URL); err != nil {
+				t.Fatalf("Failed to add backend: %v", err)
+			}
+
+			hc := NewHealthChecker(serverPool, newTestConfig(tt.method))
+
+			backend := serverPool.GetBackendByIndex(0)
+			hc.checkBackend(backend)
+
+			if backend.IsHealthy() != tt.expectHealthy {
+				t.Errorf("Expected healthy=%v for method %q, got %v", tt.expectHealthy, tt.method, backend.IsHealthy())
+			}
+		})
+	}
+}
+
+func TestHealthCheckBody(t *testing.T) {
+	// Mock backend that only reports healthy for a POST carrying the expected JSON body
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Method != http.MethodPost || string(body) != `{"check":"deep"}` || r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	tests := []struct {
+		name          string
+		method        string
+		body          string
+		contentType   string
+		expectHealthy bool
+	}{
+		{"POST with the expected body", http.MethodPost, `{"check":"deep"}`, "", true},
+		{"POST with another body", http.MethodPost, `{"check":"shallow"}`, "", false},
+		{"POST without a body", http.MethodPost, "", "", false},
+		{"POST with another content type", http.MethodPost, `{"check":"deep"}`, "text/plain", false},
+		{"GET probe", http.MethodGet, "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serverPool := pool.NewServerPool()
+			if err := serverPool.AddBackend(mockServer.URL); err != nil {
+				t.Fatalf("Failed to add backend: %v", err)
+			}
+
+			cfg := newTestConfig(tt.method)
+			cfg.HealthCheckBody = tt.body
+			cfg.HealthCheckContentType = tt.contentType
+			hc := NewHealthChecker(serverPool, cfg)
+
+			backend := serverPool.GetBackendByIndex(0)
+			hc.checkBackend(backend)
+
+			if backend.IsHealthy() != tt.expectHealthy {
+				t.Errorf("Expected healthy=%v, got %v", tt.expectHealthy, backend.IsHealthy())
+			}
+		})
+	}
+}
+
+func TestHealthCheckRequireHeader(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		headerValue   string // X-Health value sent by the backend ("" omits it)
+		requireHeader string
+		requireValue  string
+		expectHealthy bool
+	}{
+		{"Matching value", http.StatusOK, "ok", "X-Health", "ok", true},
+		{"Degraded value", http.StatusOK, "degraded", "X-Health", "ok", false},
+		{"Missing header", http.StatusOK, "", "X-Health", "ok", false},
+		{"Any value accepted", http.StatusOK, "degraded", "X-Health", "", true},
+		{"Presence required", http.StatusOK, "", "X-Health", "", false},
+		{"Header matches but status fails", http.StatusServiceUnavailable, "ok", "X-Health", "ok", false},
+		{"No requirement", http.StatusOK, "degraded", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.headerValue != "" {
+					w.Header().Set("X-Health", tt.headerValue)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer mockServer.Close()
+
+			serverPool := pool.NewServerPool()
+			if err := serverPool.AddBackend(mockServer.URL); err != nil {
+				t.Fatalf("Failed to add backend: %v", err)
+			}
+
+			cfg := newTestConfig("")
+			cfg.HealthCheckRequireHeader = tt.requireHeader
+			cfg.HealthCheckRequireHeaderValue = tt.requireValue
+			hc := NewHealthChecker(serverPool, cfg)
+
+			backend := serverPool.GetBackendByIndex(0)
+			hc.checkBackend(backend)
+
+			if backend.IsHealthy() != tt.expectHealthy {
+				t.Errorf("Expected healthy=%v, got %v", tt.expectHealthy, backend.IsHealthy())
+			}
+		})
+	}
+}
+
+func TestHealthCheckRedirects(t *testing.T) {
+	// Mock backend that redirects its health endpoint to a login page
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		http.Redirect(w, r, "/login", http.StatusFound)
+	}))
+	defer mockServer.Close()
+
+	tests := []struct {
+		name            string
+		followRedirects bool
+		expectHealthy   bool
+	}{
+		{"Redirect not followed", false, false},
+		{"Redirect followed", true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serverPool := pool.NewServerPool()
+			if err := serverPool.AddBackend(mockServer.URL); err != nil {
+				t.Fatalf("Failed to add backend: %v", err)
+			}
+
+			cfg := newTestConfig("")
+			cfg.HealthCheckFollowRedirects = tt.followRedirects
+			hc := NewHealthChecker(serverPool, cfg)
+
+			backend := serverPool.GetBackendByIndex(0)
+			serverPool.SetBackendHealth(backend.ID, !tt.expectHealthy)
+			hc.checkBackend(backend)
+
+			if backend.IsHealthy() != tt.expectHealthy {
+				t.Errorf("Expected healthy=%v, got %v", tt.expectHealthy, backend.IsHealthy())
+			}
+		})
+	}
+}
+
+func TestHealthCheckTransport(t *testing.T) {
+	tests := []struct {
+		name                string
+		idleTimeout         time.Duration
+		expectedIdleTimeout time.Duration
+	}{
+		{"Derived from interval", 0, 20 * time.Second},
+		{"Explicit idle timeout", 45 * time.Second, 45 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig("")
+			cfg.HealthCheckIdleTimeout = tt.idleTimeout
+
+			hc := NewHealthChecker(pool.NewServerPool(), cfg)
+
+			transport, ok := hc.client.Transport.(*http.Transport)
+			if !ok {
+				t.Fatalf("Expected *http.Transport, got %T", hc.client.Transport)
+			}
+			if transport == http.DefaultTransport {
+				t.Errorf("Expected a dedicated transport, got http.DefaultTransport")
+			}
+			if transport.MaxIdleConnsPerHost != 1 {
+				t.Errorf("Expected MaxIdleConnsPerHost 1, got %d", transport.MaxIdleConnsPerHost)
+			}
+			if transport.IdleConnTimeout != tt.expectedIdleTimeout {
+				t.Errorf("Expected IdleConnTimeout %s, got %s", tt.expectedIdleTimeout, transport.IdleConnTimeout)
+			}
+		})
+	}
+}
+
+func TestHealthCheckReusesConnections(t *testing.T) {
+	var newConns int64
+	mockServer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	}))
+	mockServer.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt64(&newConns, 1)
+		}
+	}
+	mockServer.Start()
+	defer mockServer.Close()
+
+	serverPool := pool.NewServerPool()
+	if err := serverPool.AddBackend(mockServer.URL); err != nil {
+		t.Fatalf("Failed to add backend: %v", err)
+	}
+
+	hc := NewHealthChecker(serverPool, newTestConfig(""))
+	backend := serverPool.GetBackendByIndex(0)
+
+	for i := 0; i < 5; i++ {
+		hc.checkBackend(backend)
+	}
+
+	if conns := atomic.LoadInt64(&newConns); conns != 1 {
+		t.Errorf("Expected probes to reuse a single connection, got %d connections", conns)
+	}
+}
+
+func TestHealthCheckConcurrencyLimit(t *testing.T) {
+	const limit = 3
+	const backendCount = 20
+
+	var inFlight, maxInFlight int64
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := atomic.AddInt64(&inFlight, 1)
+		defer atomic.AddInt64(&inFlight, -1)
+
+		for {
+			observed := atomic.LoadInt64(&maxInFlight)
+			if current <= observed || atomic.CompareAndSwapInt64(&maxInFlight, observed, current) {
+				break
+			}
+		}
+
+		time.Sleep(20 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	serverPool := pool.NewServerPool()
+	for i := 0; i < backendCount; i++ {
+		mockServer := httptest.NewServer(handler)
+		defer mockServer.Close()
+		if err := serverPool.AddBackend(mockServer.URL); err != nil {
+			t.Fatalf("Failed to add backend: %v", err)
+		}
+	}
+
+	cfg := newTestConfig("")
+	cfg.HealthCheckConcurrency = limit
+	hc := NewHealthChecker(serverPool, cfg)
+
+	hc.checkAllBackends(0)
+
+	if observed := atomic.LoadInt64(&maxInFlight); observed > limit {
+		t.Errorf("Expected at most %d concurrent probes, observed %d", limit, observed)
+	}
+	if healthy := serverPool.GetHealthyBackendCount(); healthy != backendCount {
+		t.Errorf("Expected all %d backends probed healthy, got %d", backendCount, healthy)
+	}
+}
+
+func TestHealthCheckDefaultConcurrency(t *testing.T) {
+	hc := NewHealthChecker(pool.NewServerPool(), newTestConfig(""))
+	if cap(hc.semaphore) != config.DefaultHealthCheckConcurrency {
+		t.Errorf("Expected default concurrency %d, got %d", config.DefaultHealthCheckConcurrency, cap(hc.semaphore))
+	}
+}
+
+func TestHealthCheckJitterSpreadsProbes(t *testing.T) {
+	const backendCount = 8
+	const rounds = 3
+	const maxDelay = 80 * time.Millisecond
+	const slack = 20 * time.Millisecond
+
+	var mu sync.Mutex
+	var probes []time.Time
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		probes = append(probes, time.Now())
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	})
+
+	serverPool := pool.NewServerPool()
+	for i := 0; i < backendCount; i++ {
+		mockServer := httptest.NewServer(handler)
+		defer mockServer.Close()
+		if err := serverPool.AddBackend(mockServer.URL); err != nil {
+			t.Fatalf("Failed to add backend: %v", err)
+		}
+	}
+
+	hc := NewHealthChecker(serverPool, newTestConfig(""))
+	hc.rng = rand.New(rand.NewSource(1))
+
+	for round := 0; round < rounds; round++ {
+		mu.Lock()
+		probes = nil
+		mu.Unlock()
+
+		start := time.Now()
+		hc.checkAllBackends(maxDelay)
+
+		mu.Lock()
+		if len(probes) != backendCount {
+			t.Errorf("Round %d: expected %d probes, got %d", round, backendCount, len(probes))
+		}
+		earliest, latest := maxDelay, time.Duration(0)
+		for _, probe := range probes {
+			offset := probe.Sub(start)
+			if offset > maxDelay+slack {
+				t.Errorf("Round %d: expected probe within %s of round start, got %s", round, maxDelay+slack, offset)
+			}
+			earliest = min(earliest, offset)
+			latest = max(latest, offset)
+		}
+		mu.Unlock()
+
+		// Without jitter every probe lands within a few milliseconds
+		if spread := latest - earliest; spread < maxDelay/4 {
+			t.Errorf("Round %d: expected probes spread across the jitter window, got spread %s", round, spread)
+		}
+	}
+}
+
+func TestHealthCheckJitterFromConfig(t *testing.T) {
+	cfg := newTestConfig("")
+	cfg.HealthCheckJitter = 0.25
+	hc := NewHealthChecker(pool.NewServerPool(), cfg)
+
+	if hc.checkJitter != 2500*time.Millisecond {
+		t.Errorf("Expected jitter of 2.5s for a 10s interval, got %s", hc.checkJitter)
+	}
+	for i := 0; i < 1000; i++ {
+		if delay := hc.probeDelay(hc.checkJitter); delay < 0 || delay >= hc.checkJitter {
+			t.Fatalf("Expected delay in [0, %s), got %s", hc.checkJitter, delay)
+		}
+	}
+	if delay := hc.probeDelay(0); delay != 0 {
+		t.Errorf("Expected no delay without jitter, got %s", delay)
+	}
+}
+
+func TestTCPHealthCheck(t *testing.T) {
+	// A plain TCP listener with no HTTP server behind it
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	// Reserve a port and release it so nothing is listening there
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	unreachable := closed.Addr().String()
+	closed.Close()
+
+	tests := []struct {
+		name          string
+		address       string
+		expectHealthy bool
+	}{
+		{"Reachable target", listener.Addr().String(), true},
+		{"Unreachable target", unreachable, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serverPool := pool.NewServerPool()
+			if err := serverPool.AddBackend("http://" + tt.address); err != nil {
+				t.Fatalf("Failed to add backend: %v", err)
+			}
+
+			cfg := newTestConfig("")
+			cfg.HealthCheckType = config.HealthCheckTCP
+			hc := NewHealthChecker(serverPool, cfg)
+
+			backend := serverPool.GetBackendByIndex(0)
+			serverPool.SetBackendHealth(backend.ID, !tt.expectHealthy) // start from the opposite state
+			hc.checkBackend(backend)
+
+			if backend.IsHealthy() != tt.expectHealthy {
+				t.Errorf("Expected healthy=%v for %s, got %v", tt.expectHealthy, tt.address, backend.IsHealthy())
+			}
+		})
+	}
+}
+
+func TestGRPCHealthCheck(t *testing.T) {
+	// Minimal gRPC health server over h2c: the whole server is SERVING,
+	// "payments" is NOT_SERVING and any other service is unknown
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 || r.URL.Path != grpcHealthCheckPath || r.Header.Get("Content-Type") != "application/grpc" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		service := ""
+		if len(body) > 7 && body[5] == 0x0a {
+			service = string(body[7 : 7+int(body[6])])
+		}
+
+		var status byte
+		switch service {
+		case "":
+			status = 1 // SERVING
+		case "payments":
+			status = 2 // NOT_SERVING
+		default:
+			// Trailers-only response: NOT_FOUND
+			w.Header().Set("Content-Type", "application/grpc")
+			w.Header().Set("Grpc-Status", "5")
+			w.Header().Set("Grpc-Message", "unknown service")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.WriteHeader(http.StatusOK)
+		w.Write(grpcFrame([]byte{0x08, status}))
+		w.Header().Set("Grpc-Status", "0")
+	}))
+	server.Config.Protocols = new(http.Protocols)
+	server.Config.Protocols.SetHTTP1(true)
+	server.Config.Protocols.SetUnencryptedHTTP2(true)
+	server.Start()
+	defer server.Close()
+
+	tests := []struct {
+		name          string
+		service       string
+		expectHealthy bool
+	}{
+		{"Server serving", "", true},
+		{"Service not serving", "payments", false},
+		{"Unknown service", "billing", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serverPool := pool.NewServerPool()
+			if err := serverPool.AddBackend(server.URL); err != nil {
+				t.Fatalf("Failed to add backend: %v", err)
+			}
+
+			cfg := newTestConfig("")
+			cfg.HealthCheckType = config.HealthCheckGRPC
+			cfg.HealthCheckGRPCService = tt.service
+			hc := NewHealthChecker(serverPool, cfg)
+
+			backend := serverPool.GetBackendByIndex(0)
+			serverPool.SetBackendHealth(backend.ID, !tt.expectHealthy) // start from the opposite state
+			hc.checkBackend(backend)
+
+			if backend.IsHealthy() != tt.expectHealthy {
+				t.Errorf("Expected healthy=%v for service %q, got %v", tt.expectHealthy, tt.service, backend.IsHealthy())
+			}
+		})
+	}
+}
+
+func TestHealthCheckSNI(t *testing.T) {
+	// The backend's certificate only covers backend.internal, not the
+	// 127.0.0.1 it is addressed by
+	certPEM, keyPEM := newTestCertificate(t, "backend.internal")
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("Failed to load certificate: %v", err)
+	}
+
+	var serverName atomic.Value
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serverName.Store(r.TLS.ServerName)
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	server.StartTLS()
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, certPEM, 0o600); err != nil {
+		t.Fatalf("Failed to write CA file: %v", err)
+	}
+	roots, err := LoadRootCAs(caFile)
+	if err != nil {
+		t.Fatalf("Failed to load CA file: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		sni           string
+		trustCA       bool
+		expectHealthy bool
+	}{
+		{"SNI matching the certificate", "backend.internal", true, true},
+		{"No SNI", "", true, false},
+		{"SNI not on the certificate", "other.internal", true, false},
+		{"Untrusted certificate", "backend.internal", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serverPool := pool.NewServerPool()
+			if err := serverPool.AddBackend(server.URL); err != nil {
+				t.Fatalf("Failed to add backend: %v", err)
+			}
+
+			cfg := newTestConfig("")
+			cfg.HealthCheckSNI = tt.sni
+			hc := NewHealthChecker(serverPool, cfg)
+			if tt.trustCA {
+				hc.SetRootCAs(roots)
+			}
+
+			backend := serverPool.GetBackendByIndex(0)
+			serverPool.SetBackendHealth(backend.ID, !tt.expectHealthy) // start from the opposite state
+			hc.checkBackend(backend)
+
+			if backend.IsHealthy() != tt.expectHealthy {
+				t.Errorf("Expected healthy=%v with SNI %q, got %v", tt.expectHealthy, tt.sni, backend.IsHealthy())
+			}
+			if tt.expectHealthy && serverName.Load() != tt.sni {
+				t.Errorf("Expected the backend to see SNI %q, got %v", tt.sni, serverName.Load())
+			}
+		})
+	}
+}
+
+// newTestCertificate returns a self-signed certificate for host and its key, PEM encoded
+func newTestCertificate(t *testing.T, host string) (certPEM, keyPEM []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), cryptorand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: host},
+		DNSNames:              []string{host},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(cryptorand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM
+}
+
+func TestHealthCheckRecordsDuration(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	serverPool := pool.NewServerPool()
+	if err := serverPool.AddBackend(mockServer.URL); err != nil {
+		t.Fatalf("Failed to add backend: %v", err)
+	}
+
+	m := metrics.NewMetrics()
+	hc := NewHealthChecker(serverPool, newTestConfig(http.MethodGet))
+	hc.SetMetrics(m)
+
+	backend := serverPool.GetBackendByIndex(0)
+	hc.checkBackend(backend)
+	hc.checkBackend(backend)
+
+	snapshot, ok := m.HealthCheckDurations()[backend.ID]
+	if !ok {
+		t.Fatalf("Expected a duration histogram for %s", backend.ID)
+	}
+	if snapshot.Count != 2 {
+		t.Errorf("Expected 2 observations, got %d", snapshot.Count)
+	}
+	if snapshot.Sum < 0.04 {
+		t.Errorf("Expected durations to sum to at least 40ms, got %fs", snapshot.Sum)
+	}
+}
+
+func TestHealthCheckPerBackendIntervals(t *testing.T) {
+	var fastProbes, slowProbes int64
+	fastServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&fastProbes, 1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer fastServer.Close()
+	slowServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&slowProbes, 1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer slowServer.Close()
+
+	serverPool := pool.NewServerPool()
+	for _, url := range []string{fastServer.URL, slowServer.URL} {
+		if err := serverPool.AddBackend(url); err != nil {
+			t.Fatalf("Failed to add backend: %v", err)
+		}
+	}
+
+	// The global interval is far longer than the test, so every probe after
+	// the first round comes from a per-backend interval
+	cfg := newTestConfig("")
+	cfg.HealthCheckTimeout = 50 * time.Millisecond
+	cfg.HealthCheckIntervals = map[string]time.Duration{
+		fastServer.URL: 100 * time.Millisecond,
+		slowServer.URL: 400 * time.Millisecond,
+	}
+	hc := NewHealthChecker(serverPool, cfg)
+	hc.Start()
+	time.Sleep(1050 * time.Millisecond)
+	hc.Stop()
+
+	// One startup probe each, then one per elapsed interval
+	if fast := atomic.LoadInt64(&fastProbes); fast < 8 || fast > 12 {
+		t.Errorf("Expected about 11 probes of the 100ms backend, got %d", fast)
+	}
+	if slow := atomic.LoadInt64(&slowProbes); slow < 2 || slow > 4 {
+		t.Errorf("Expected about 3 probes of the 400ms backend, got %d", slow)
+	}
+}
+
+func TestHealthCheckIntervalDefaultsToGlobal(t *testing.T) {
+	serverPool := pool.NewServerPool()
+	for _, url := range []string{"http://backend1:8080", "http://backend2:8080"} {
+		if err := serverPool.AddBackend(url); err != nil {
+			t.Fatalf("Failed to add backend: %v", err)
+		}
+	}
+
+	cfg := newTestConfig("")
+	cfg.HealthCheckIntervals = map[string]time.Duration{"http://backend1:8080": 2 * time.Second}
+	hc := NewHealthChecker(serverPool, cfg)
+
+	if interval := hc.intervalFor(serverPool.GetBackendByIndex(0)); interval != 2*time.Second {
+		t.Errorf("Expected overridden interval 2s, got %s", interval)
+	}
+	if interval := hc.intervalFor(serverPool.GetBackendByIndex(1)); interval != cfg.HealthCheckInterval {
+		t.Errorf("Expected global interval %s, got %s", cfg.HealthCheckInterval, interval)
+	}
+}
+
+func TestHealthCheckPerBackendTimeouts(t *testing.T) {
+	// Both backends take 100ms to answer their health endpoint
+	slowHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	})
+	patientServer := httptest.NewServer(slowHandler)
+	defer patientServer.Close()
+	strictServer := httptest.NewServer(slowHandler)
+	defer strictServer.Close()
+
+	serverPool := pool.NewServerPool()
+	for _, url := range []string{patientServer.URL, strictServer.URL} {
+		if err := serverPool.AddBackend(url); err != nil {
+			t.Fatalf("Failed to add backend: %v", err)
+		}
+	}
+	patient := serverPool.GetBackendByIndex(0)
+	strict := serverPool.GetBackendByIndex(1)
+	serverPool.SetBackendHealth(strict.ID, true)
+
+	// The global timeout would fail both; only the patient backend gets longer
+	cfg := newTestConfig("")
+	cfg.HealthCheckTimeout = 50 * time.Millisecond
+	cfg.HealthCheckTimeouts = map[string]time.Duration{patientServer.URL: time.Second}
+	hc := NewHealthChecker(serverPool, cfg)
+
+	if timeout := hc.timeoutFor(strict); timeout != cfg.HealthCheckTimeout {
+		t.Errorf("Expected global timeout %s, got %s", cfg.HealthCheckTimeout, timeout)
+	}
+
+	hc.checkBackend(patient)
+	hc.checkBackend(strict)
+
+	if !patient.IsHealthy() {
+		t.Errorf("Expected backend with a 1s probe timeout to be healthy")
+	}
+	if strict.IsHealthy() {
+		t.Errorf("Expected backend with a 50ms probe timeout to be unhealthy")
+	}
+}
+
+func TestHealthCheckAdaptiveInterval(t *testing.T) {
+	serverPool := pool.NewServerPool()
+	if err := serverPool.AddBackend("http://backend1:8080"); err != nil {
+		t.Fatalf("Failed to add backend: %v", err)
+	}
+	backend := serverPool.GetBackendByIndex(0)
+	serverPool.SetBackendHealth(backend.ID, true)
+
+	cfg := newTestConfig("")
+	cfg.HealthCheckHealthyMaxInterval = 60 * time.Second
+	hc := NewHealthChecker(serverPool, cfg)
+
+	// Each healthy probe after the first doubles the interval, up to the cap
+	expected := []time.Duration{10 * time.Second, 10 * time.Second, 20 * time.Second, 40 * time.Second, 60 * time.Second, 60 * time.Second}
+	for probes, want := range expected {
+		if got := hc.nextProbe(backend); got != want {
+			t.Errorf("After %d healthy probes: expected interval %s, got %s", probes, want, got)
+		}
+		hc.recordProbe(backend)
+	}
+
+	// A failed probe drops straight back to the base interval
+	serverPool.SetBackendHealth(backend.ID, false)
+	hc.recordProbe(backend)
+	serverPool.SetBackendHealth(backend.ID, true)
+	if got := hc.nextProbe(backend); got != cfg.HealthCheckInterval {
+		t.Errorf("Expected base interval %s after a failed probe, got %s", cfg.HealthCheckInterval, got)
+	}
+}
+
+func TestHealthCheckFlapDamping(t *testing.T) {
+	var up atomic.Bool
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !up.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	penalty := 200 * time.Millisecond
+	serverPool := pool.NewServerPool()
+	serverPool.SetFlapDamping(2, time.Minute, penalty)
+	if err := serverPool.AddBackend(mockServer.URL); err != nil {
+		t.Fatalf("Failed to add backend: %v", err)
+	}
+	backend := serverPool.GetBackendByIndex(0)
+	hc := NewHealthChecker(serverPool, newTestConfig(""))
+
+	// Going down twice is tolerated; each passing probe restores the backend
+	for flap := 1; flap <= 2; flap++ {
+		up.Store(true)
+		hc.checkBackend(backend)
+		if !backend.IsHealthy() {
+			t.Fatalf("Expected backend healthy before flap %d", flap)
+		}
+		up.Store(false)
+		hc.checkBackend(backend)
+		if got := backend.FlapCount(); got != flap {
+			t.Errorf("Expected flap count %d, got %d", flap, got)
+		}
+	}
+
+	// The third time it goes down it is damped, and passing probes can't
+	// bring it back until the penalty ends
+	up.Store(true)
+	hc.checkBackend(backend)
+	up.Store(false)
+	hc.checkBackend(backend)
+	if !backend.Damped() {
+		t.Fatalf("Expected backend to be damped after flapping 3 times")
+	}
+
+	up.Store(true)
+	hc.checkBackend(backend)
+	if backend.IsHealthy() {
+		t.Errorf("Expected damped backend to stay unhealthy while its probes pass")
+	}
+
+	time.Sleep(penalty + 50*time.Millisecond)
+	hc.checkBackend(backend)
+	if !backend.IsHealthy() {
+		t.Errorf("Expected backend healthy once its penalty ended")
+	}
+	if got := backend.FlapCount(); got != 0 {
+		t.Errorf("Expected flap count to reset after damping, got %d", got)
+	}
+}
+
+func TestHealthCheckAdaptiveCadence(t *testing.T) {
+	var stableProbes, failingProbes int64
+	stableServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&stableProbes, 1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer stableServer.Close()
+	failingServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&failingProbes, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failingServer.Close()
+
+	serverPool := pool.NewServerPool()
+	for _, url := range []string{stableServer.URL, failingServer.URL} {
+		if err := serverPool.AddBackend(url); err != nil {
+			t.Fatalf("Failed to add backend: %v", err)
+		}
+	}
+
+	cfg := newTestConfig("")
+	cfg.HealthCheckInterval = 50 * time.Millisecond
+	cfg.HealthCheckTimeout = 20 * time.Millisecond
+	cfg.HealthCheckHealthyMaxInterval = 400 * time.Millisecond
+	hc := NewHealthChecker(serverPool, cfg)
+	hc.Start()
+	time.Sleep(1050 * time.Millisecond)
+	hc.Stop()
+
+	// The healthy backend backs off (probes at 0, 50, 100, 200, 400 and
+	// 800ms) while the failing one stays on the 50ms base interval
+	if stable := atomic.LoadInt64(&stableProbes); stable < 4 || stable > 8 {
+		t.Errorf("Expected about 6 probes of the stable backend, got %d", stable)
+	}
+	if failing := atomic.LoadInt64(&failingProbes); failing < 15 {
+		t.Errorf("Expected about 21 probes of the failing backend, got %d", failing)
+	}
+}
+
+func TestHealthCheckStartupGrace(t *testing.T) {
+	serverPool := pool.NewServerPool()
+	for _, url := range []string{"http://backend1:8080", "http://backend2:8080"} {
+		if err := serverPool.AddBackend(url); err != nil {
+			t.Fatalf("Failed to add backend: %v", err)
+		}
+	}
+	pending := serverPool.GetBackendByIndex(0)
+	verified := serverPool.GetBackendByIndex(1)
+	serverPool.SetBackendHealth(verified.ID, true)
+
+	cfg := newTestConfig("")
+	cfg.HealthCheckStartupGrace = time.Minute
+	hc := NewHealthChecker(serverPool, cfg)
+	hc.graceUntil = time.Now().Add(cfg.HealthCheckStartupGrace)
+	hc.initialized.Store(true)
+
+	// A pending backend is retried quickly and holds back initialization
+	if next := hc.nextProbe(pending); next != pendingRetryInterval {
+		t.Errorf("Expected pending backend to be retried after %s, got %s", pendingRetryInterval, next)
+	}
+	if next := hc.nextProbe(verified); next != cfg.HealthCheckInterval {
+		t.Errorf("Expected verified backend to keep its interval %s, got %s", cfg.HealthCheckInterval, next)
+	}
+	if hc.Initialized() {
+		t.Errorf("Expected health checker not to be initialized while a backend is pending")
+	}
+
+	// Once the grace period is over the regular schedule applies
+	hc.graceUntil = time.Now()
+	if next := hc.nextProbe(pending); next != cfg.HealthCheckInterval {
+		t.Errorf("Expected interval %s after the grace period, got %s", cfg.HealthCheckInterval, next)
+	}
+	if !hc.Initialized() {
+		t.Errorf("Expected health checker to be initialized after the grace period")
+	}
+}
+
+func TestHealthCheckStartupBurst(t *testing.T) {
+	// The backend is still starting for its first two probes
+	var probes atomic.Int64
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if probes.Add(1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	serverPool := pool.NewServerPool()
+	if err := serverPool.AddBackend(mockServer.URL); err != nil {
+		t.Fatalf("Failed to add backend: %v", err)
+	}
+
+	cfg := newTestConfig("")
+	cfg.HealthCheckStartupBurst = 5
+	cfg.HealthCheckStartupBurstInterval = 20 * time.Millisecond
+	hc := NewHealthChecker(serverPool, cfg)
+	hc.Start()
+	defer hc.Stop()
+
+	// Without the burst the backend would wait out the 10s interval
+	deadline := time.Now().Add(time.Second)
+	for serverPool.GetHealthyBackendCount() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected backend healthy within the startup burst, got %d probes", probes.Load())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// The burst ends after its probes and the regular interval takes over
+	time.Sleep(200 * time.Millisecond)
+	if got := probes.Load(); got != int64(cfg.HealthCheckStartupBurst) {
+		t.Errorf("Expected %d probes during the startup burst, got %d", cfg.HealthCheckStartupBurst, got)
+	}
+}
+
+func TestHealthCheckStopIsIdempotent(t *testing.T) {
+	var probes int64
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&probes, 1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	serverPool := pool.NewServerPool()
+	if err := serverPool.AddBackend(mockServer.URL); err != nil {
+		t.Fatalf("Failed to add backend: %v", err)
+	}
+
+	cfg := newTestConfig("")
+	cfg.HealthCheckInterval = 20 * time.Millisecond
+	cfg.HealthCheckTimeout = 10 * time.Millisecond
+	hc := NewHealthChecker(serverPool, cfg)
+	hc.Start()
+	time.Sleep(100 * time.Millisecond)
+
+	hc.Stop()
+	hc.Stop()
+
+	// Stop waits for the check loop and probe rounds, so nothing probes afterwards
+	stopped := atomic.LoadInt64(&probes)
+	if stopped == 0 {
+		t.Fatalf("Expected probes before Stop")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if after := atomic.LoadInt64(&probes); after != stopped {
+		t.Errorf("Expected no probes after Stop, got %d more", after-stopped)
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer mockServer.Close()
+
+	// The HTTP check judges the response, while a TCP check only needs the
+	// connection to succeed
+	cfg := newTestConfig("")
+	if err := CheckURL(cfg, mockServer.URL); err == nil {
+		t.Errorf("Expected an HTTP check of a 503 backend to fail")
+	}
+	cfg.HealthCheckType = config.HealthCheckTCP
+	if err := CheckURL(cfg, mockServer.URL); err != nil {
+		t.Errorf("Expected a TCP check of a listening backend to pass, got %v", err)
+	}
+
+	if err := CheckURL(cfg, "://bad"); err == nil {
+		t.Errorf("Expected an invalid URL to fail")
+	}
+}
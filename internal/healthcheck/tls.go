@@ -0,0 +1,41 @@
+package healthcheck
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+
+	"go-balancer/internal/clientcert"
+)
+
+// LoadRootCAs reads a PEM bundle of CA certificates for verifying backends
+func LoadRootCAs(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no PEM certificates found in %s", path)
+	}
+	return roots, nil
+}
+
+// SetRootCAs makes probes verify https backends against roots instead of the
+// system roots. Call it before Start.
+func (hc *HealthChecker) SetRootCAs(roots *x509.CertPool) {
+	transport := hc.client.Transport.(*http.Transport)
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	transport.TLSClientConfig.RootCAs = roots
+}
+
+// SetClientCertificates makes probes present client certificates to backends
+// that require mutual TLS. Call it after SetRootCAs and before Start.
+func (hc *HealthChecker) SetClientCertificates(certs *clientcert.Certificates) {
+	hc.client.Transport = certs.Transport(hc.client.Transport.(*http.Transport))
+}
@@ -0,0 +1,64 @@
+package listener
+
+import (
+	"net"
+	"sync"
+)
+
+// LimitListener caps the number of connections open at once, bounding file
+// descriptor use regardless of request-level limits. Once the cap is reached
+// Accept waits for an accepted connection to close, so further clients queue
+// in the kernel's accept backlog rather than being served.
+type LimitListener struct {
+	net.Listener
+	slots     chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewLimitListener wraps inner so at most maxConns accepted connections are
+// open at once
+func NewLimitListener(inner net.Listener, maxConns int) *LimitListener {
+	return &LimitListener{
+		Listener: inner,
+		slots:    make(chan struct{}, maxConns),
+		done:     make(chan struct{}),
+	}
+}
+
+// Accept waits for a free slot, then for the next connection
+func (l *LimitListener) Accept() (net.Conn, error) {
+	select {
+	case l.slots <- struct{}{}:
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		<-l.slots
+		return nil, err
+	}
+	return &limitConn{Conn: conn, release: func() { <-l.slots }}, nil
+}
+
+// Close closes the listener, unblocking any Accept waiting for a slot
+func (l *LimitListener) Close() error {
+	err := l.Listener.Close()
+	l.closeOnce.Do(func() { close(l.done) })
+	return err
+}
+
+// limitConn frees its listener slot when closed
+type limitConn struct {
+	net.Conn
+	releaseOnce sync.Once
+	release     func()
+}
+
+// Close closes the connection and frees its slot; closing twice frees it once
+func (c *limitConn) Close() error {
+	err := c.Conn.Close()
+	c.releaseOnce.Do(c.release)
+	return err
+}
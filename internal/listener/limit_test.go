@@ -0,0 +1,101 @@
+package listener
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestLimitListenerCapsAcceptedConnections(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	ln := NewLimitListener(inner, 2)
+	defer ln.Close()
+
+	// Dial more clients than the cap; the kernel completes the handshakes
+	// and the extra connection waits in the backlog
+	for i := 0; i < 3; i++ {
+		client, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatalf("Failed to dial: %v", err)
+		}
+		defer client.Close()
+	}
+
+	accepted := make(chan net.Conn, 3)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn
+		}
+	}()
+
+	var conns []net.Conn
+	for i := 0; i < 2; i++ {
+		select {
+		case conn := <-accepted:
+			conns = append(conns, conn)
+		case <-time.After(time.Second):
+			t.Fatalf("Expected connection %d to be accepted", i+1)
+		}
+	}
+
+	select {
+	case <-accepted:
+		t.Fatalf("Expected the third connection to wait while two are open")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Closing a connection, even twice, frees exactly one slot
+	conns[0].Close()
+	conns[0].Close()
+	select {
+	case conn := <-accepted:
+		defer conn.Close()
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the third connection to be accepted once a slot freed")
+	}
+	conns[1].Close()
+}
+
+func TestLimitListenerCloseUnblocksAccept(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	ln := NewLimitListener(inner, 1)
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer client.Close()
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatalf("Failed to accept: %v", err)
+	}
+	defer conn.Close()
+
+	// The next Accept waits for a slot until the listener closes
+	errs := make(chan error, 1)
+	go func() {
+		_, err := ln.Accept()
+		errs <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	ln.Close()
+
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Errorf("Expected Accept to fail after Close")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected Close to unblock Accept")
+	}
+}
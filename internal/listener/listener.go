@@ -0,0 +1,29 @@
+// Package listener creates the load balancer's listening socket.
+//
+// With SO_REUSEPORT enabled, a zero-downtime handoff looks like this:
+//
+//  1. The running instance (old) is listening on the port with reuseport.
+//  2. The new instance starts and binds the same port with reuseport. The
+//     kernel now spreads new connections across both listeners.
+//  3. The old instance receives SIGTERM and calls http.Server.Shutdown, which
+//     closes its listener so new connections go only to the new instance,
+//     then waits for in-flight requests to finish before exiting.
+//
+// Both instances must enable reuseport (and, on Linux, run as the same user)
+// for the second bind to succeed.
+package listener
+
+import (
+	"context"
+	"net"
+)
+
+// Listen opens a TCP listener on address, setting SO_REUSEPORT on the socket
+// when reusePort is true
+func Listen(ctx context.Context, address string, reusePort bool) (net.Listener, error) {
+	lc := net.ListenConfig{}
+	if reusePort {
+		lc.Control = reusePortControl
+	}
+	return lc.Listen(ctx, "tcp", address)
+}
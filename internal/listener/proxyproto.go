@@ -0,0 +1,230 @@
+package listener
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultProxyHeaderTimeout bounds how long a connection may take to send its
+// PROXY protocol header
+const DefaultProxyHeaderTimeout = 5 * time.Second
+
+// proxyV1MaxLength is the longest valid v1 header, including the CRLF
+const proxyV1MaxLength = 107
+
+// proxyV2Signature starts every v2 header
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// ProxyProtocolListener wraps a listener whose peers (typically an L4 load
+// balancer) prefix each connection with a PROXY protocol v1 or v2 header. The
+// header is parsed lazily on the connection's own goroutine so a slow peer
+// can't stall Accept, and RemoteAddr reports the original client address.
+// Connections without a valid header fail on first use.
+type ProxyProtocolListener struct {
+	net.Listener
+	headerTimeout time.Duration
+}
+
+// NewProxyProtocolListener wraps inner so accepted connections are expected to
+// start with a PROXY protocol header
+func NewProxyProtocolListener(inner net.Listener, headerTimeout time.Duration) *ProxyProtocolListener {
+	if headerTimeout <= 0 {
+		headerTimeout = DefaultProxyHeaderTimeout
+	}
+	return &ProxyProtocolListener{Listener: inner, headerTimeout: headerTimeout}
+}
+
+// Accept waits for the next connection and wraps it for header parsing
+func (l *ProxyProtocolListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &proxyConn{
+		Conn:          conn,
+		reader:        bufio.NewReaderSize(conn, 256),
+		headerTimeout: l.headerTimeout,
+	}, nil
+}
+
+// proxyConn is a connection whose PROXY header is consumed before any data
+type proxyConn struct {
+	net.Conn
+	reader        *bufio.Reader
+	headerTimeout time.Duration
+
+	once       sync.Once
+	headerErr  error
+	remoteAddr net.Addr // Original client address; nil for LOCAL/UNKNOWN headers
+	localAddr  net.Addr // Original destination address
+}
+
+// Read returns data following the PROXY header
+func (c *proxyConn) Read(b []byte) (int, error) {
+	c.once.Do(c.readHeader)
+	if c.headerErr != nil {
+		return 0, c.headerErr
+	}
+	return c.reader.Read(b)
+}
+
+// RemoteAddr returns the client address from the PROXY header, falling back
+// to the peer's address for LOCAL/UNKNOWN headers or when parsing failed
+func (c *proxyConn) RemoteAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.remoteAddr != nil {
+		return c.remoteAddr
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// LocalAddr returns the destination address from the PROXY header when present
+func (c *proxyConn) LocalAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.localAddr != nil {
+		return c.localAddr
+	}
+	return c.Conn.LocalAddr()
+}
+
+// readHeader consumes the PROXY header under a deadline
+func (c *proxyConn) readHeader() {
+	c.Conn.SetReadDeadline(time.Now().Add(c.headerTimeout))
+	defer c.Conn.SetReadDeadline(time.Time{})
+
+	src, dst, err := parseProxyHeader(c.reader)
+	if err != nil {
+		c.headerErr = fmt.Errorf("proxy protocol: %w", err)
+		return
+	}
+	c.remoteAddr, c.localAddr = src, dst
+}
+
+// parseProxyHeader reads a v1 or v2 header, returning the source and
+// destination addresses (nil for LOCAL/UNKNOWN)
+func parseProxyHeader(r *bufio.Reader) (src, dst net.Addr, err error) {
+	// Every valid header is at least as long as the v2 signature
+	prefix, err := r.Peek(len(proxyV2Signature))
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading header: %w", err)
+	}
+
+	switch {
+	case bytes.Equal(prefix, proxyV2Signature):
+		return parseProxyV2(r)
+	case bytes.HasPrefix(prefix, []byte("PROXY ")):
+		return parseProxyV1(r)
+	default:
+		return nil, nil, fmt.Errorf("missing PROXY header")
+	}
+}
+
+// parseProxyV1 parses the text form, e.g. "PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n"
+func parseProxyV1(r *bufio.Reader) (net.Addr, net.Addr, error) {
+	var line []byte
+	for len(line) < proxyV1MaxLength {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading v1 header: %w", err)
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, nil, fmt.Errorf("v1 header too long or not CRLF terminated")
+	}
+
+	fields := strings.Fields(string(line[:len(line)-2]))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil, nil
+	}
+	if len(fields) != 6 {
+		return nil, nil, fmt.Errorf("malformed v1 header %q", line)
+	}
+	if fields[1] != "TCP4" && fields[1] != "TCP6" {
+		return nil, nil, fmt.Errorf("unsupported v1 protocol %q", fields[1])
+	}
+
+	src, err := parseV1Addr(fields[2], fields[4])
+	if err != nil {
+		return nil, nil, err
+	}
+	dst, err := parseV1Addr(fields[3], fields[5])
+	if err != nil {
+		return nil, nil, err
+	}
+	return src, dst, nil
+}
+
+func parseV1Addr(host, port string) (*net.TCPAddr, error) {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid v1 address %q", host)
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid v1 port %q", port)
+	}
+	return &net.TCPAddr{IP: ip, Port: int(p)}, nil
+}
+
+// parseProxyV2 parses the binary form
+func parseProxyV2(r *bufio.Reader) (net.Addr, net.Addr, error) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, nil, fmt.Errorf("reading v2 header: %w", err)
+	}
+
+	verCmd, family := header[12], header[13]
+	length := int(binary.BigEndian.Uint16(header[14:16]))
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, nil, fmt.Errorf("reading v2 addresses: %w", err)
+	}
+
+	if verCmd>>4 != 2 {
+		return nil, nil, fmt.Errorf("unsupported v2 version %d", verCmd>>4)
+	}
+	switch verCmd & 0x0f {
+	case 0x0: // LOCAL: health checks from the proxy itself
+		return nil, nil, nil
+	case 0x1: // PROXY
+	default:
+		return nil, nil, fmt.Errorf("unsupported v2 command %d", verCmd&0x0f)
+	}
+
+	var ipLen int
+	switch family {
+	case 0x11: // TCP over IPv4
+		ipLen = net.IPv4len
+	case 0x21: // TCP over IPv6
+		ipLen = net.IPv6len
+	default:
+		// UDP and UNIX sockets carry no usable TCP client address
+		return nil, nil, nil
+	}
+	if len(payload) < 2*ipLen+4 {
+		return nil, nil, fmt.Errorf("v2 address block too short")
+	}
+
+	src := &net.TCPAddr{
+		IP:   net.IP(append([]byte(nil), payload[:ipLen]...)),
+		Port: int(binary.BigEndian.Uint16(payload[2*ipLen:])),
+	}
+	dst := &net.TCPAddr{
+		IP:   net.IP(append([]byte(nil), payload[ipLen:2*ipLen]...)),
+		Port: int(binary.BigEndian.Uint16(payload[2*ipLen+2:])),
+	}
+	return src, dst, nil
+}
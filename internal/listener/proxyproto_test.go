@@ -0,0 +1,187 @@
+package listener
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// acceptWith dials a proxy protocol listener, writes payload and returns the
+// server side of the connection
+func acceptWith(t *testing.T, payload []byte) net.Conn {
+	t.Helper()
+
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	ln := NewProxyProtocolListener(inner, time.Second)
+	t.Cleanup(func() { ln.Close() })
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	if _, err := client.Write(payload); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatalf("Failed to accept: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func proxyV2Header(cmd byte, src, dst net.IP, srcPort, dstPort uint16) []byte {
+	var family byte = 0x11
+	if src.To4() == nil {
+		family = 0x21
+	} else {
+		src, dst = src.To4(), dst.To4()
+	}
+
+	addrs := append(append([]byte{}, src...), dst...)
+	addrs = binary.BigEndian.AppendUint16(addrs, srcPort)
+	addrs = binary.BigEndian.AppendUint16(addrs, dstPort)
+
+	header := append([]byte{}, proxyV2Signature...)
+	header = append(header, 0x20|cmd, family)
+	header = binary.BigEndian.AppendUint16(header, uint16(len(addrs)))
+	return append(header, addrs...)
+}
+
+func TestProxyProtocolV1(t *testing.T) {
+	tests := []struct {
+		name           string
+		header         string
+		expectedRemote string
+	}{
+		{"TCP4", "PROXY TCP4 203.0.113.7 198.51.100.1 56324 443\r\n", "203.0.113.7:56324"},
+		{"TCP6", "PROXY TCP6 2001:db8::7 2001:db8::1 40000 443\r\n", "[2001:db8::7]:40000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := acceptWith(t, []byte(tt.header+"hello"))
+
+			if got := conn.RemoteAddr().String(); got != tt.expectedRemote {
+				t.Errorf("Expected remote address %s, got %s", tt.expectedRemote, got)
+			}
+
+			// Data after the header is passed through untouched
+			buf := make([]byte, 5)
+			if _, err := io.ReadFull(conn, buf); err != nil {
+				t.Fatalf("Failed to read payload: %v", err)
+			}
+			if string(buf) != "hello" {
+				t.Errorf("Expected payload %q, got %q", "hello", buf)
+			}
+		})
+	}
+}
+
+func TestProxyProtocolV1Unknown(t *testing.T) {
+	conn := acceptWith(t, []byte("PROXY UNKNOWN\r\nhello"))
+
+	// Without a client address the peer's own address is reported
+	if got := conn.RemoteAddr().String(); !strings.HasPrefix(got, "127.0.0.1:") {
+		t.Errorf("Expected peer address for UNKNOWN, got %s", got)
+	}
+}
+
+func TestProxyProtocolV2(t *testing.T) {
+	tests := []struct {
+		name           string
+		header         []byte
+		expectedRemote string
+	}{
+		{"TCP4", proxyV2Header(0x1, net.ParseIP("203.0.113.7"), net.ParseIP("198.51.100.1"), 56324, 443), "203.0.113.7:56324"},
+		{"TCP6", proxyV2Header(0x1, net.ParseIP("2001:db8::7"), net.ParseIP("2001:db8::1"), 40000, 443), "[2001:db8::7]:40000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := acceptWith(t, append(tt.header, []byte("hello")...))
+
+			if got := conn.RemoteAddr().String(); got != tt.expectedRemote {
+				t.Errorf("Expected remote address %s, got %s", tt.expectedRemote, got)
+			}
+
+			buf := make([]byte, 5)
+			if _, err := io.ReadFull(conn, buf); err != nil {
+				t.Fatalf("Failed to read payload: %v", err)
+			}
+			if string(buf) != "hello" {
+				t.Errorf("Expected payload %q, got %q", "hello", buf)
+			}
+		})
+	}
+}
+
+func TestProxyProtocolV2Local(t *testing.T) {
+	header := proxyV2Header(0x0, net.ParseIP("203.0.113.7"), net.ParseIP("198.51.100.1"), 56324, 443)
+	conn := acceptWith(t, header)
+
+	if got := conn.RemoteAddr().String(); !strings.HasPrefix(got, "127.0.0.1:") {
+		t.Errorf("Expected peer address for LOCAL, got %s", got)
+	}
+}
+
+func TestProxyProtocolRejectsMissingHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload []byte
+	}{
+		{"Plain HTTP", []byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n")},
+		{"Bad v1 address", []byte("PROXY TCP4 not-an-ip 198.51.100.1 1 2\r\n")},
+		{"Unterminated v1", append([]byte("PROXY TCP4 "), bytes.Repeat([]byte("1"), 200)...)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := acceptWith(t, tt.payload)
+			if _, err := conn.Read(make([]byte, 1)); err == nil {
+				t.Errorf("Expected read to fail without a valid PROXY header")
+			}
+		})
+	}
+}
+
+func TestProxyProtocolHTTPRemoteAddr(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	ln := NewProxyProtocolListener(inner, time.Second)
+
+	remoteAddrs := make(chan string, 1)
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remoteAddrs <- r.RemoteAddr
+	})}
+	go server.Serve(ln)
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("PROXY TCP4 203.0.113.7 198.51.100.1 56324 80\r\nGET / HTTP/1.1\r\nHost: example.com\r\n\r\n"))
+
+	select {
+	case got := <-remoteAddrs:
+		if got != "203.0.113.7:56324" {
+			t.Errorf("Expected r.RemoteAddr 203.0.113.7:56324, got %s", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Timed out waiting for request")
+	}
+}
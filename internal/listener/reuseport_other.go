@@ -0,0 +1,17 @@
+//go:build !(linux || darwin || freebsd)
+
+package listener
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+)
+
+// ReusePortSupported reports whether SO_REUSEPORT is available on this platform
+const ReusePortSupported = false
+
+// reusePortControl fails the bind since SO_REUSEPORT isn't available
+func reusePortControl(network, address string, c syscall.RawConn) error {
+	return fmt.Errorf("SO_REUSEPORT is not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,51 @@
+//go:build linux || darwin || freebsd
+
+package listener
+
+import (
+	"context"
+	"net"
+	"testing"
+)
+
+func TestListenReusePort(t *testing.T) {
+	first, err := Listen(context.Background(), "127.0.0.1:0", true)
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer first.Close()
+
+	// A second instance can bind the same port during handoff
+	second, err := Listen(context.Background(), first.Addr().String(), true)
+	if err != nil {
+		t.Fatalf("Expected second reuseport listener to bind %s, got: %v", first.Addr(), err)
+	}
+	defer second.Close()
+
+	// Closing the old listener leaves the new one accepting connections
+	first.Close()
+	go func() {
+		if conn, err := second.Accept(); err == nil {
+			conn.Close()
+		}
+	}()
+	conn, err := net.Dial("tcp", second.Addr().String())
+	if err != nil {
+		t.Fatalf("Expected to connect to the remaining listener, got: %v", err)
+	}
+	conn.Close()
+}
+
+func TestListenWithoutReusePort(t *testing.T) {
+	first, err := Listen(context.Background(), "127.0.0.1:0", false)
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer first.Close()
+
+	second, err := Listen(context.Background(), first.Addr().String(), false)
+	if err == nil {
+		second.Close()
+		t.Errorf("Expected second plain listener on %s to fail", first.Addr())
+	}
+}
@@ -0,0 +1,20 @@
+//go:build linux || darwin || freebsd
+
+package listener
+
+import "syscall"
+
+// ReusePortSupported reports whether SO_REUSEPORT is available on this platform
+const ReusePortSupported = true
+
+// reusePortControl sets SO_REUSEPORT on the socket before it is bound
+func reusePortControl(network, address string, c syscall.RawConn) error {
+	var sockErr error
+	err := c.Control(func(fd uintptr) {
+		sockErr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, soReusePort, 1)
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
@@ -0,0 +1,8 @@
+//go:build darwin || freebsd
+
+package listener
+
+import "syscall"
+
+// soReusePort is SO_REUSEPORT as defined by the syscall package
+const soReusePort = syscall.SO_REUSEPORT
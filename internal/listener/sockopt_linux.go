@@ -0,0 +1,7 @@
+//go:build linux && !(mips || mipsle || mips64 || mips64le)
+
+package listener
+
+// soReusePort is SO_REUSEPORT, which the syscall package omits on some Linux
+// architectures (including amd64)
+const soReusePort = 0xf
@@ -0,0 +1,6 @@
+//go:build linux && (mips || mipsle || mips64 || mips64le)
+
+package listener
+
+// soReusePort is SO_REUSEPORT, which differs on MIPS
+const soReusePort = 0x200
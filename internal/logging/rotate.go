@@ -0,0 +1,101 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// RotatingFile is an io.WriteCloser appending to a file that is rotated once
+// a write would take it past maxBytes. The file being rotated out becomes
+// path.1, older ones shift to path.2 and so on, and only maxBackups of them
+// are kept. It is safe for concurrent use.
+type RotatingFile struct {
+	path       string
+	maxBytes   int64 // Rotate before exceeding this size (0 disables rotation)
+	maxBackups int   // Rotated files to keep (0 keeps none)
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// NewRotatingFile opens path for appending, creating it if needed
+func NewRotatingFile(path string, maxBytes int64, maxBackups int) (*RotatingFile, error) {
+	rf := &RotatingFile{path: path, maxBytes: maxBytes, maxBackups: maxBackups}
+	file, size, err := rf.open()
+	if err != nil {
+		return nil, err
+	}
+	rf.file, rf.size = file, size
+	return rf, nil
+}
+
+// open opens the log file for appending and returns its current size
+func (rf *RotatingFile) open() (*os.File, int64, error) {
+	file, err := os.OpenFile(rf.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, 0, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, 0, err
+	}
+	return file, info.Size(), nil
+}
+
+// Write appends p, rotating first if it would take the file past maxBytes.
+// A single write larger than maxBytes still goes to one file.
+func (rf *RotatingFile) Write(p []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if rf.maxBytes > 0 && rf.size > 0 && rf.size+int64(len(p)) > rf.maxBytes {
+		if err := rf.rotate(); err != nil {
+			// Keep logging to the current file rather than losing lines
+			fmt.Fprintf(os.Stderr, "log rotation of %s failed: %v\n", rf.path, err)
+		}
+	}
+
+	n, err := rf.file.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+// rotate shifts the backups along, moves the current file to path.1 and
+// starts a new one. The old handle is only closed once the new file is
+// open, so a failure leaves writes going to the old file.
+func (rf *RotatingFile) rotate() error {
+	if rf.maxBackups > 0 {
+		os.Remove(backupName(rf.path, rf.maxBackups))
+		for n := rf.maxBackups - 1; n >= 1; n-- {
+			os.Rename(backupName(rf.path, n), backupName(rf.path, n+1))
+		}
+		if err := os.Rename(rf.path, backupName(rf.path, 1)); err != nil {
+			return err
+		}
+	} else if err := os.Remove(rf.path); err != nil {
+		return err
+	}
+
+	file, size, err := rf.open()
+	if err != nil {
+		return err
+	}
+	rf.file.Close()
+	rf.file, rf.size = file, size
+	return nil
+}
+
+// Close closes the current file
+func (rf *RotatingFile) Close() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	return rf.file.Close()
+}
+
+// backupName returns the name of the nth rotated file
+func backupName(path string, n int) string {
+	return fmt.Sprintf("%s.%d", path, n)
+}
@@ -0,0 +1,99 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	return string(data)
+}
+
+func TestRotatingFileRotatesPastMaxBytes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "balancer.log")
+	rf, err := NewRotatingFile(path, 20, 2)
+	if err != nil {
+		t.Fatalf("Failed to open log file: %v", err)
+	}
+	defer rf.Close()
+
+	// Each line is 10 bytes, so every file holds two
+	for _, line := range []string{"line-0001\n", "line-0002\n", "line-0003\n"} {
+		if _, err := rf.Write([]byte(line)); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+
+	if got := readFile(t, path+".1"); got != "line-0001\nline-0002\n" {
+		t.Errorf("Expected the first two lines in the rotated file, got %q", got)
+	}
+	if got := readFile(t, path); got != "line-0003\n" {
+		t.Errorf("Expected the third line in a new file, got %q", got)
+	}
+}
+
+func TestRotatingFileKeepsMaxBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "balancer.log")
+	rf, err := NewRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatalf("Failed to open log file: %v", err)
+	}
+	defer rf.Close()
+
+	// One 10-byte line per file: 5 lines leave the current file and 2 backups
+	for i := 1; i <= 5; i++ {
+		rf.Write([]byte(strings.Repeat(string(rune('0'+i)), 9) + "\n"))
+	}
+
+	expected := map[string]string{
+		path:        "555555555\n",
+		path + ".1": "444444444\n",
+		path + ".2": "333333333\n",
+	}
+	for file, content := range expected {
+		if got := readFile(t, file); got != content {
+			t.Errorf("Expected %s to contain %q, got %q", filepath.Base(file), content, got)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("Expected no more than 2 backups, found %s.3", filepath.Base(path))
+	}
+}
+
+func TestRotatingFileAppendsToExistingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "balancer.log")
+	if err := os.WriteFile(path, []byte("earlier\n"), 0644); err != nil {
+		t.Fatalf("Failed to seed log file: %v", err)
+	}
+
+	rf, err := NewRotatingFile(path, 12, 1)
+	if err != nil {
+		t.Fatalf("Failed to open log file: %v", err)
+	}
+	defer rf.Close()
+
+	// The existing 8 bytes count towards the limit
+	rf.Write([]byte("later\n"))
+
+	if got := readFile(t, path+".1"); got != "earlier\n" {
+		t.Errorf("Expected the existing content to be rotated out, got %q", got)
+	}
+	if got := readFile(t, path); got != "later\n" {
+		t.Errorf("Expected the new line in a new file, got %q", got)
+	}
+}
+
+func TestRotatingFileOpenError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "balancer.log")
+	if _, err := NewRotatingFile(path, 10, 1); err == nil {
+		t.Errorf("Expected an error opening a log file in a missing directory")
+	}
+}
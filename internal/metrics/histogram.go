@@ -0,0 +1,118 @@
+package metrics
+
+import "time"
+
+// HealthCheckDurationBuckets are the upper bounds, in seconds, of the health
+// check duration histogram buckets
+var HealthCheckDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// RequestDurationBuckets are the upper bounds, in seconds, of the proxied
+// request duration histogram buckets
+var RequestDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// histogram counts observations into cumulative buckets, Prometheus style.
+// It is not safe for concurrent use; Metrics guards it with its lock.
+type histogram struct {
+	bounds    []float64
+	counts    []int64 // counts[i] is the number of observations <= bounds[i]
+	count     int64
+	sum       float64
+	exemplars []*Exemplar // Latest traced observation per bucket, the last being +Inf
+}
+
+// Exemplar links an observation to the trace it was made in
+type Exemplar struct {
+	TraceID   string
+	Value     float64
+	Timestamp time.Time
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{
+		bounds:    bounds,
+		counts:    make([]int64, len(bounds)),
+		exemplars: make([]*Exemplar, len(bounds)+1),
+	}
+}
+
+// observe records a value
+func (h *histogram) observe(value float64) {
+	h.count++
+	h.sum += value
+	for i, bound := range h.bounds {
+		if value <= bound {
+			h.counts[i]++
+		}
+	}
+}
+
+// observeTraced records a value and keeps it as the exemplar of the bucket
+// it falls in
+func (h *histogram) observeTraced(value float64, traceID string, now time.Time) {
+	h.observe(value)
+
+	bucket := len(h.bounds)
+	for i, bound := range h.bounds {
+		if value <= bound {
+			bucket = i
+			break
+		}
+	}
+	h.exemplars[bucket] = &Exemplar{TraceID: traceID, Value: value, Timestamp: now}
+}
+
+// HistogramSnapshot is a point-in-time copy of a histogram
+type HistogramSnapshot struct {
+	Bounds    []float64   // Bucket upper bounds
+	Counts    []int64     // Cumulative observations per bucket
+	Count     int64       // Total observations
+	Sum       float64     // Sum of observed values
+	Exemplars []*Exemplar // Latest traced observation per bucket (nil if none), the last being +Inf
+}
+
+func (h *histogram) snapshot() HistogramSnapshot {
+	return HistogramSnapshot{
+		Bounds:    h.bounds,
+		Counts:    append([]int64(nil), h.counts...),
+		Count:     h.count,
+		Sum:       h.sum,
+		Exemplars: append([]*Exemplar(nil), h.exemplars...),
+	}
+}
+
+// RecordHealthCheckDuration records how long a health probe of a backend took
+func (m *Metrics) RecordHealthCheckDuration(backend string, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	h, ok := m.healthCheckDurations[backend]
+	if !ok {
+		h = newHistogram(HealthCheckDurationBuckets)
+		m.healthCheckDurations[backend] = h
+	}
+	h.observe(duration.Seconds())
+}
+
+// HealthCheckDurations returns the health probe duration histogram per backend
+func (m *Metrics) HealthCheckDurations() map[string]HistogramSnapshot {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	snapshots := make(map[string]HistogramSnapshot, len(m.healthCheckDurations))
+	for backend, h := range m.healthCheckDurations {
+		snapshots[backend] = h.snapshot()
+	}
+	return snapshots
+}
+
+// RequestDurations returns the proxied request duration histogram per backend
+func (m *Metrics) RequestDurations() map[string]HistogramSnapshot {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	snapshots := make(map[string]HistogramSnapshot, len(m.requestDurations))
+	for backend, h := range m.requestDurations {
+		snapshots[backend] = h.snapshot()
+	}
+	return snapshots
+}
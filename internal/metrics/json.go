@@ -0,0 +1,159 @@
+package metrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// JSONMetricsProvider serves metrics as a JSON document, for tools and
+// dashboards that don't speak the Prometheus format
+type JSONMetricsProvider struct {
+	metrics *Metrics
+}
+
+// NewJSONMetricsProvider creates a JSON provider reporting on metrics
+func NewJSONMetricsProvider(metrics *Metrics) *JSONMetricsProvider {
+	return &JSONMetricsProvider{metrics: metrics}
+}
+
+// Name returns the provider name
+func (p *JSONMetricsProvider) Name() string {
+	return JSONProvider
+}
+
+// jsonMetrics is the document served by JSONMetricsProvider
+type jsonMetrics struct {
+	Timestamp time.Time               `json:"timestamp"`
+	Requests  jsonRequests            `json:"requests"`
+	Backends  jsonBackendCounts       `json:"backends"`
+	ByBackend map[string]*jsonBackend `json:"by_backend"`
+}
+
+// jsonRequests holds the request counters across all backends
+type jsonRequests struct {
+	Total                int64 `json:"total"`
+	Successful           int64 `json:"successful"`
+	Failed               int64 `json:"failed"`
+	MaintenanceRejected  int64 `json:"maintenance_rejected"`
+	ConcurrencyRejected  int64 `json:"concurrency_rejected"`
+	BackendQueueServed   int64 `json:"backend_queue_served"`
+	BackendQueueTimeouts int64 `json:"backend_queue_timeouts"`
+	ClientCanceled       int64 `json:"client_canceled"`
+	TimedOut             int64 `json:"timed_out"`
+	ClientReadErrors     int64 `json:"client_read_errors"`
+	ResponseCopyErrors   int64 `json:"response_copy_errors"`
+}
+
+// jsonBackendCounts holds the current number of healthy and total backends
+type jsonBackendCounts struct {
+	Healthy int `json:"healthy"`
+	Total   int `json:"total"`
+}
+
+// jsonBackend holds one backend's metrics
+type jsonBackend struct {
+	Requests            int64          `json:"requests"`
+	Failures            int64          `json:"failures"`
+	Retries             int64          `json:"retries"`
+	SlowRequests        int64          `json:"slow_requests"`
+	ResponseBytes       int64          `json:"response_bytes"`
+	UpstreamEOFs        int64          `json:"upstream_eofs"`
+	ActiveConnections   int64          `json:"active_connections"`
+	RequestDuration     *jsonHistogram `json:"request_duration_seconds,omitempty"`
+	HealthCheckDuration *jsonHistogram `json:"healthcheck_duration_seconds,omitempty"`
+}
+
+// jsonHistogram is a histogram with cumulative bucket counts keyed by upper bound
+type jsonHistogram struct {
+	Count   int64            `json:"count"`
+	Sum     float64          `json:"sum"`
+	Buckets map[string]int64 `json:"buckets"`
+}
+
+// newJSONHistogram copies h for the JSON document; the caller must hold the
+// metrics lock
+func newJSONHistogram(h *histogram) *jsonHistogram {
+	buckets := make(map[string]int64, len(h.bounds)+1)
+	for i, bound := range h.bounds {
+		buckets[strconv.FormatFloat(bound, 'g', -1, 64)] = h.counts[i]
+	}
+	buckets["+Inf"] = h.count
+	return &jsonHistogram{Count: h.count, Sum: h.sum, Buckets: buckets}
+}
+
+// ServeHTTP writes the metrics as JSON. Like the Prometheus provider, it reads
+// every counter under one lock, so totals agree with the per-backend values.
+func (p *JSONMetricsProvider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Read before taking the metrics lock since the source locks the server pool
+	activeConnections := p.metrics.ActiveConnections()
+
+	p.metrics.mu.RLock()
+	snapshot := p.metrics.snapshotLocked()
+	report := jsonMetrics{
+		Timestamp: snapshot.Timestamp,
+		Requests: jsonRequests{
+			Total:                snapshot.TotalRequests,
+			Successful:           snapshot.SuccessfulRequests,
+			Failed:               snapshot.FailedRequests,
+			MaintenanceRejected:  snapshot.MaintenanceRejections,
+			ConcurrencyRejected:  snapshot.ConcurrencyRejections,
+			BackendQueueServed:   snapshot.BackendQueueServed,
+			BackendQueueTimeouts: snapshot.BackendQueueTimeouts,
+			ClientCanceled:       snapshot.ClientCanceled,
+			TimedOut:             snapshot.RequestTimeouts,
+			ClientReadErrors:     snapshot.ClientReadErrors,
+			ResponseCopyErrors:   snapshot.ResponseCopyErrors,
+		},
+		Backends: jsonBackendCounts{
+			Healthy: snapshot.HealthyBackends,
+			Total:   snapshot.TotalBackends,
+		},
+		ByBackend: make(map[string]*jsonBackend),
+	}
+
+	// entry returns the backend's entry, creating it on first use
+	entry := func(id string) *jsonBackend {
+		b, ok := report.ByBackend[id]
+		if !ok {
+			b = &jsonBackend{}
+			report.ByBackend[id] = b
+		}
+		return b
+	}
+	for id, count := range p.metrics.backendRequests {
+		entry(id).Requests = count
+	}
+	for id, count := range p.metrics.backendFailures {
+		entry(id).Failures = count
+	}
+	for id, count := range p.metrics.backendRetries {
+		entry(id).Retries = count
+	}
+	for id, count := range p.metrics.slowRequests {
+		entry(id).SlowRequests = count
+	}
+	for id, n := range p.metrics.responseBytes {
+		entry(id).ResponseBytes = n
+	}
+	for id, count := range p.metrics.upstreamEOFs {
+		entry(id).UpstreamEOFs = count
+	}
+	for id, h := range p.metrics.requestDurations {
+		entry(id).RequestDuration = newJSONHistogram(h)
+	}
+	for id, h := range p.metrics.healthCheckDurations {
+		entry(id).HealthCheckDuration = newJSONHistogram(h)
+	}
+	p.metrics.mu.RUnlock()
+
+	for id, count := range activeConnections {
+		entry(id).ActiveConnections = count
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(report)
+}
@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestJSONMetrics(t *testing.T) {
+	m := NewMetrics()
+	m.RecordRequest("backend-1", 20*time.Millisecond)
+	m.RecordRequest("backend-1", 2*time.Second)
+	m.RecordFailure("backend-2")
+	m.RecordRetry("backend-2")
+	m.UpdateBackendCount(1, 2)
+	m.SetActiveConnectionsSource(func() map[string]int64 {
+		return map[string]int64{"backend-1": 3}
+	})
+
+	req := httptest.NewRequest("GET", "http://localhost:8000/metrics.json", nil)
+	recorder := httptest.NewRecorder()
+	NewJSONMetricsProvider(m).ServeHTTP(recorder, req)
+
+	if contentType := recorder.Header().Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("Expected Content-Type application/json, got %q", contentType)
+	}
+
+	var report jsonMetrics
+	if err := json.Unmarshal(recorder.Body.Bytes(), &report); err != nil {
+		t.Fatalf("Expected JSON body, got error: %v\n%s", err, recorder.Body.String())
+	}
+
+	if report.Requests.Total != 3 || report.Requests.Successful != 2 || report.Requests.Failed != 1 {
+		t.Errorf("Expected 3 requests (2 successful, 1 failed), got %+v", report.Requests)
+	}
+	if report.Backends.Healthy != 1 || report.Backends.Total != 2 {
+		t.Errorf("Expected 1 of 2 backends healthy, got %+v", report.Backends)
+	}
+
+	backend1 := report.ByBackend["backend-1"]
+	if backend1 == nil {
+		t.Fatalf("Expected an entry for backend-1, got %+v", report.ByBackend)
+	}
+	if backend1.Requests != 2 || backend1.ActiveConnections != 3 {
+		t.Errorf("Expected backend-1 with 2 requests and 3 active connections, got %+v", backend1)
+	}
+	duration := backend1.RequestDuration
+	if duration == nil || duration.Count != 2 || duration.Buckets["0.025"] != 1 || duration.Buckets["+Inf"] != 2 {
+		t.Errorf("Expected 2 request durations, 1 within 25ms, got %+v", duration)
+	}
+
+	backend2 := report.ByBackend["backend-2"]
+	if backend2 == nil || backend2.Failures != 1 || backend2.Retries != 1 {
+		t.Errorf("Expected backend-2 with 1 failure and 1 retry, got %+v", backend2)
+	}
+}
@@ -1,6 +1,7 @@
 package metrics
 
 import (
+	"fmt"
 	"net/http"
 	"sync"
 	"time"
@@ -15,17 +16,61 @@ type Metrics struct {
 	successfulRequests int64
 	failedRequests     int64
 
+	// Requests rejected while the balancer is in maintenance mode
+	maintenanceRejections int64
+
+	// Requests rejected because the concurrency limit was reached
+	concurrencyRejections int64
+
+	// Requests that waited for a backend below its connection limit, by how
+	// the wait ended
+	backendQueueServed   int64
+	backendQueueTimeouts int64
+
+	// Requests abandoned by the client before the backend responded
+	clientCanceled int64
+
+	// Requests cut off by the overall request timeout
+	requestTimeouts int64
+
+	// Requests whose body could not be read from the client while forwarding
+	clientReadErrors int64
+
+	// Responses whose body failed to copy to the client after the status was sent
+	responseCopyErrors int64
+
 	// Backend metrics
 	backendRequests map[string]int64
 	backendFailures map[string]int64
 
+	// Resilience metrics
+	backendRetries map[string]int64
+
+	// Backend responses slower than the slow request threshold
+	slowRequests map[string]int64
+
+	// Response body bytes written to clients, partial writes included
+	responseBytes map[string]int64
+
+	// Backend connections closed before the response was complete
+	upstreamEOFs map[string]int64
+
 	// Health check metrics
 	healthCheckPasses map[string]int64
 	healthCheckFails  map[string]int64
 
+	// Health probe durations per backend
+	healthCheckDurations map[string]*histogram
+
+	// Proxied request durations per backend
+	requestDurations map[string]*histogram
+
 	// Current state
 	healthyBackends int
 	totalBackends   int
+
+	// activeConnections reports in-flight requests per backend at read time
+	activeConnections func() map[string]int64
 }
 
 // NewMetrics creates a new metrics instance
@@ -33,20 +78,47 @@ func NewMetrics() *Metrics {
 	return &Metrics{
 		backendRequests:   make(map[string]int64),
 		backendFailures:   make(map[string]int64),
+		backendRetries:    make(map[string]int64),
+		slowRequests:      make(map[string]int64),
+		responseBytes:     make(map[string]int64),
+		upstreamEOFs:      make(map[string]int64),
 		healthCheckPasses: make(map[string]int64),
 		healthCheckFails:  make(map[string]int64),
+
+		healthCheckDurations: make(map[string]*histogram),
+		requestDurations:     make(map[string]*histogram),
 	}
 }
 
 // RecordRequest records a successful request
 func (m *Metrics) RecordRequest(backend string, duration time.Duration) {
+	m.RecordTracedRequest(backend, duration, "")
+}
+
+// RecordTracedRequest records a successful request made as part of a trace.
+// The trace ID becomes the exemplar of the duration bucket the request falls
+// in; an empty trace ID records the request without one.
+func (m *Metrics) RecordTracedRequest(backend string, duration time.Duration, traceID string) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	m.totalRequests++
 	m.successfulRequests++
 	m.backendRequests[backend]++
-} // RecordFailure records a failed request
+
+	h, ok := m.requestDurations[backend]
+	if !ok {
+		h = newHistogram(RequestDurationBuckets)
+		m.requestDurations[backend] = h
+	}
+	if traceID != "" {
+		h.observeTraced(duration.Seconds(), traceID, time.Now())
+	} else {
+		h.observe(duration.Seconds())
+	}
+}
+
+// RecordFailure records a failed request
 func (m *Metrics) RecordFailure(backend string) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -56,6 +128,153 @@ func (m *Metrics) RecordFailure(backend string) {
 	m.backendFailures[backend]++
 }
 
+// RecordMaintenanceRejection records a request rejected due to maintenance mode
+func (m *Metrics) RecordMaintenanceRejection() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.maintenanceRejections++
+}
+
+// RecordConcurrencyRejection records a request rejected because the balancer
+// was already handling its maximum number of concurrent requests
+func (m *Metrics) RecordConcurrencyRejection() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.concurrencyRejections++
+}
+
+// RecordBackendQueuedRequest records a request that waited for a backend
+// below its connection limit, and whether it got one before the backend queue
+// timeout expired
+func (m *Metrics) RecordBackendQueuedRequest(served bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if served {
+		m.backendQueueServed++
+	} else {
+		m.backendQueueTimeouts++
+	}
+}
+
+// RecordRetry records a retried request against a backend
+func (m *Metrics) RecordRetry(backend string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.backendRetries[backend]++
+}
+
+// RecordSlowRequest records a backend response that took longer than the
+// configured slow request threshold
+func (m *Metrics) RecordSlowRequest(backend string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.slowRequests[backend]++
+}
+
+// RecordUpstreamEOF records a backend closing the connection before its
+// response was complete
+func (m *Metrics) RecordUpstreamEOF(backend string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.upstreamEOFs[backend]++
+}
+
+// RecordClientCanceled records a request the client abandoned mid-flight.
+// These are not backend failures and are kept out of the failure counters.
+func (m *Metrics) RecordClientCanceled() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.clientCanceled++
+}
+
+// RecordRequestTimeout records a request cut off by the overall request
+// timeout. The backend's own timeout hadn't passed, so it is kept out of the
+// backend's failure counters.
+func (m *Metrics) RecordRequestTimeout() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requestTimeouts++
+}
+
+// RecordClientReadError records a request whose body failed to read from the
+// client mid-forward. Like cancellations these are kept out of the failure
+// counters, as the backend was not at fault.
+func (m *Metrics) RecordClientReadError() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.clientReadErrors++
+}
+
+// RecordResponseCopyError records a response whose body failed to copy to the
+// client. The status was already sent, so the request is not counted again as
+// a failure.
+func (m *Metrics) RecordResponseCopyError() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.responseCopyErrors++
+}
+
+// RecordResponseBytes records response body bytes written to a client for a
+// backend's response, including the part written before a failed copy
+func (m *Metrics) RecordResponseBytes(backend string, n int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.responseBytes[backend] += n
+}
+
+// ResponseBytes returns the response body bytes written to clients per backend
+func (m *Metrics) ResponseBytes() map[string]int64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	bytes := make(map[string]int64, len(m.responseBytes))
+	for backend, n := range m.responseBytes {
+		bytes[backend] = n
+	}
+	return bytes
+}
+
+// BackendTraffic is a backend's proxied request counts and latency
+type BackendTraffic struct {
+	Requests    int64         // Successful requests
+	Failures    int64         // Failed requests
+	MeanLatency time.Duration // Mean latency of successful requests; 0 before any
+}
+
+// BackendTraffic returns the requests each backend has served so far, keyed
+// by backend ID
+func (m *Metrics) BackendTraffic() map[string]BackendTraffic {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	traffic := make(map[string]BackendTraffic, len(m.backendRequests))
+	for backend, count := range m.backendRequests {
+		t := traffic[backend]
+		t.Requests = count
+		if h, ok := m.requestDurations[backend]; ok && h.count > 0 {
+			t.MeanLatency = time.Duration(h.sum / float64(h.count) * float64(time.Second))
+		}
+		traffic[backend] = t
+	}
+	for backend, count := range m.backendFailures {
+		t := traffic[backend]
+		t.Failures = count
+		traffic[backend] = t
+	}
+	return traffic
+}
+
 // RecordHealthCheck records a health check result
 func (m *Metrics) RecordHealthCheck(backend string, success bool) {
 	m.mu.Lock()
@@ -77,29 +296,75 @@ func (m *Metrics) UpdateBackendCount(healthy, total int) {
 	m.totalBackends = total
 }
 
+// SetActiveConnectionsSource registers the function that reports in-flight
+// requests per backend. The counts live on the backends themselves, so they are
+// read when metrics are exported rather than recorded here.
+func (m *Metrics) SetActiveConnectionsSource(source func() map[string]int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.activeConnections = source
+}
+
+// ActiveConnections returns the current in-flight requests per backend, or nil
+// if no source is registered
+func (m *Metrics) ActiveConnections() map[string]int64 {
+	m.mu.RLock()
+	source := m.activeConnections
+	m.mu.RUnlock()
+
+	if source == nil {
+		return nil
+	}
+	return source()
+}
+
 // GetSnapshot returns a snapshot of current metrics
 func (m *Metrics) GetSnapshot() MetricsSnapshot {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
+	return m.snapshotLocked()
+}
+
+// snapshotLocked builds a snapshot; the caller must hold m.mu. Providers that
+// also read the per-backend maps take the snapshot under the same lock so the
+// totals agree with them.
+func (m *Metrics) snapshotLocked() MetricsSnapshot {
 	return MetricsSnapshot{
-		TotalRequests:      m.totalRequests,
-		SuccessfulRequests: m.successfulRequests,
-		FailedRequests:     m.failedRequests,
-		HealthyBackends:    m.healthyBackends,
-		TotalBackends:      m.totalBackends,
-		Timestamp:          time.Now(),
+		TotalRequests:         m.totalRequests,
+		SuccessfulRequests:    m.successfulRequests,
+		FailedRequests:        m.failedRequests,
+		MaintenanceRejections: m.maintenanceRejections,
+		ConcurrencyRejections: m.concurrencyRejections,
+		BackendQueueServed:    m.backendQueueServed,
+		BackendQueueTimeouts:  m.backendQueueTimeouts,
+		ClientCanceled:        m.clientCanceled,
+		RequestTimeouts:       m.requestTimeouts,
+		ClientReadErrors:      m.clientReadErrors,
+		ResponseCopyErrors:    m.responseCopyErrors,
+		HealthyBackends:       m.healthyBackends,
+		TotalBackends:         m.totalBackends,
+		Timestamp:             time.Now(),
 	}
 }
 
 // MetricsSnapshot represents a point-in-time view of metrics
 type MetricsSnapshot struct {
-	TotalRequests      int64
-	SuccessfulRequests int64
-	FailedRequests     int64
-	HealthyBackends    int
-	TotalBackends      int
-	Timestamp          time.Time
+	TotalRequests         int64
+	SuccessfulRequests    int64
+	FailedRequests        int64
+	MaintenanceRejections int64
+	ConcurrencyRejections int64
+	BackendQueueServed    int64
+	BackendQueueTimeouts  int64
+	ClientCanceled        int64
+	RequestTimeouts       int64
+	ClientReadErrors      int64
+	ResponseCopyErrors    int64
+	HealthyBackends       int
+	TotalBackends         int
+	Timestamp             time.Time
 }
 
 // SuccessRate returns the success rate as a percentage
@@ -118,8 +383,46 @@ func (ms *MetricsSnapshot) HealthyPercentage() float64 {
 	return (float64(ms.HealthyBackends) / float64(ms.TotalBackends)) * 100.0
 }
 
-// MetricsProvider is an interface for exposing metrics
+// MetricsProvider exposes collected metrics over HTTP in some format
 // (e.g., Prometheus, JSON, etc.)
 type MetricsProvider interface {
-	ServeHTTP(w http.ResponseWriter, r *http.Request)
+	http.Handler
+
+	// Name identifies the provider, e.g. "prometheus"
+	Name() string
+}
+
+// ProviderOptions customize how a provider exposes metrics. Providers ignore
+// options that don't apply to their format.
+type ProviderOptions struct {
+	Prefix string            // Start of every metric name, joined to it with an underscore (defaults to DefaultPrometheusPrefix)
+	Labels map[string]string // Labels added to every sample, alongside its own (e.g. instance="lb-1")
+}
+
+// ProviderFactory builds a MetricsProvider reporting on the given metrics
+type ProviderFactory func(m *Metrics, opts ProviderOptions) MetricsProvider
+
+// Provider names accepted in configuration
+const (
+	PrometheusProvider = "prometheus"
+	JSONProvider       = "json"
+)
+
+// providers maps provider names to their factories
+var providers = map[string]ProviderFactory{
+	PrometheusProvider: func(m *Metrics, opts ProviderOptions) MetricsProvider { return NewPrometheusMetricsProvider(m, opts) },
+	JSONProvider:       func(m *Metrics, _ ProviderOptions) MetricsProvider { return NewJSONMetricsProvider(m) },
+}
+
+// LookupProvider returns the factory for a provider name. An empty name
+// selects Prometheus.
+func LookupProvider(name string) (ProviderFactory, error) {
+	if name == "" {
+		name = PrometheusProvider
+	}
+	factory, ok := providers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown metrics provider: %s", name)
+	}
+	return factory, nil
 }
@@ -2,52 +2,249 @@ package metrics
 
 import (
 	"fmt"
+	"io"
+	"maps"
 	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// DefaultPrometheusPrefix starts every Prometheus metric name unless another
+// prefix is configured
+const DefaultPrometheusPrefix = "go_balancer"
+
+// prometheusPrefixPattern matches prefixes that keep metric names valid
+var prometheusPrefixPattern = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// IsValidPrometheusPrefix reports whether prefix can start Prometheus metric names
+func IsValidPrometheusPrefix(prefix string) bool {
+	return prometheusPrefixPattern.MatchString(prefix)
+}
+
+// labelNamePattern matches valid Prometheus label names
+var labelNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// reservedLabels are set on samples by the provider itself
+var reservedLabels = []string{"backend", "le", "state"}
+
+// ValidateStaticLabels returns an error for the first label that can't be
+// added to every sample: an invalid name, or one the provider sets itself
+func ValidateStaticLabels(labels map[string]string) error {
+	for _, name := range slices.Sorted(maps.Keys(labels)) {
+		switch {
+		case !labelNamePattern.MatchString(name) || strings.HasPrefix(name, "__"):
+			return fmt.Errorf("invalid metrics label name: %q", name)
+		case slices.Contains(reservedLabels, name):
+			return fmt.Errorf("metrics label %q is already set on samples", name)
+		}
+	}
+	return nil
+}
+
 type PrometheusMetricsProvider struct {
-	metrics *Metrics
+	metrics      *Metrics
+	prefix       string // Metric names are <prefix>_<name>
+	staticLabels string // Labels added to every sample, already rendered
+}
+
+func NewPrometheusMetricsProvider(metrics *Metrics, opts ProviderOptions) *PrometheusMetricsProvider {
+	prefix := opts.Prefix
+	if prefix == "" {
+		prefix = DefaultPrometheusPrefix
+	}
+	return &PrometheusMetricsProvider{
+		metrics:      metrics,
+		prefix:       prefix,
+		staticLabels: renderStaticLabels(opts.Labels),
+	}
 }
 
-func NewPrometheusMetricsProvider(metrics *Metrics) *PrometheusMetricsProvider {
-	return &PrometheusMetricsProvider{metrics: metrics}
+// Name returns the provider name
+func (p *PrometheusMetricsProvider) Name() string {
+	return PrometheusProvider
 }
 
+// Content types of the two exposition formats
+const (
+	prometheusContentType  = "text/plain; version=0.0.4"
+	openMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+)
+
+// ServeHTTP writes the metrics in the Prometheus text format, or in the
+// OpenMetrics format when the scraper accepts it. Only OpenMetrics can carry
+// exemplars, so histogram buckets link to trace IDs only in that format.
 func (p *PrometheusMetricsProvider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	snapshot := p.metrics.GetSnapshot()
+	// Read before taking the metrics lock since the source locks the server pool
+	activeConnections := p.metrics.ActiveConnections()
 
-	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	// Hold the lock for the whole scrape so totals and per-backend series agree
+	p.metrics.mu.RLock()
+	defer p.metrics.mu.RUnlock()
+	snapshot := p.metrics.snapshotLocked()
+	prefix := p.prefix
 
-	fmt.Fprintf(w, "# HELP go_balancer_requests_total Total number of requests processed\n")
-	fmt.Fprintf(w, "# TYPE go_balancer_requests_total counter\n")
-	fmt.Fprintf(w, "go_balancer_requests_total %d\n", snapshot.TotalRequests)
+	// labels renders a sample's label set: its own labels, then the static ones
+	labels := func(own string) string {
+		if list := joinLabels(own, p.staticLabels); list != "" {
+			return "{" + list + "}"
+		}
+		return ""
+	}
 
-	fmt.Fprintf(w, "# HELP go_balancer_requests_success_total Total number of successful requests\n")
-	fmt.Fprintf(w, "# TYPE go_balancer_requests_success_total counter\n")
-	fmt.Fprintf(w, "go_balancer_requests_success_total %d\n", snapshot.SuccessfulRequests)
+	openMetrics := strings.Contains(r.Header.Get("Accept"), "application/openmetrics-text")
+	if openMetrics {
+		w.Header().Set("Content-Type", openMetricsContentType)
+		defer fmt.Fprintf(w, "# EOF\n")
+	} else {
+		w.Header().Set("Content-Type", prometheusContentType)
+	}
 
-	fmt.Fprintf(w, "# HELP go_balancer_requests_failed_total Total number of failed requests\n")
-	fmt.Fprintf(w, "# TYPE go_balancer_requests_failed_total counter\n")
-	fmt.Fprintf(w, "go_balancer_requests_failed_total %d\n", snapshot.FailedRequests)
+	writeFamily(w, prefix+"_requests_total", "counter", "Total number of requests processed", openMetrics)
+	fmt.Fprintf(w, "%s_requests_total%s %d\n", prefix, labels(""), snapshot.TotalRequests)
 
-	fmt.Fprintf(w, "# HELP go_balancer_backend_healthy Current health status (1=healthy, 0=unhealthy)\n")
-	fmt.Fprintf(w, "# TYPE go_balancer_backend_healthy gauge\n")
-	fmt.Fprintf(w, "go_balancer_backend_healthy{state=\"healthy\"} %d\n", snapshot.HealthyBackends)
-	fmt.Fprintf(w, "go_balancer_backend_healthy{state=\"total\"} %d\n", snapshot.TotalBackends)
+	writeFamily(w, prefix+"_requests_success_total", "counter", "Total number of successful requests", openMetrics)
+	fmt.Fprintf(w, "%s_requests_success_total%s %d\n", prefix, labels(""), snapshot.SuccessfulRequests)
 
-	// For backend-specific metrics, we need to access the maps directly (with lock)
-	p.metrics.mu.RLock()
-	defer p.metrics.mu.RUnlock()
+	writeFamily(w, prefix+"_requests_failed_total", "counter", "Total number of failed requests", openMetrics)
+	fmt.Fprintf(w, "%s_requests_failed_total%s %d\n", prefix, labels(""), snapshot.FailedRequests)
+
+	writeFamily(w, prefix+"_requests_maintenance_total", "counter", "Total number of requests rejected during maintenance mode", openMetrics)
+	fmt.Fprintf(w, "%s_requests_maintenance_total%s %d\n", prefix, labels(""), snapshot.MaintenanceRejections)
+
+	writeFamily(w, prefix+"_concurrency_rejected_total", "counter", "Total number of requests rejected because the concurrency limit was reached", openMetrics)
+	fmt.Fprintf(w, "%s_concurrency_rejected_total%s %d\n", prefix, labels(""), snapshot.ConcurrencyRejections)
+
+	writeFamily(w, prefix+"_backend_queue_served_total", "counter", "Total number of requests served after waiting for a backend below its connection limit", openMetrics)
+	fmt.Fprintf(w, "%s_backend_queue_served_total%s %d\n", prefix, labels(""), snapshot.BackendQueueServed)
+
+	writeFamily(w, prefix+"_backend_queue_timeouts_total", "counter", "Total number of requests rejected after waiting for a backend below its connection limit", openMetrics)
+	fmt.Fprintf(w, "%s_backend_queue_timeouts_total%s %d\n", prefix, labels(""), snapshot.BackendQueueTimeouts)
+
+	writeFamily(w, prefix+"_requests_client_canceled_total", "counter", "Total number of requests canceled by the client", openMetrics)
+	fmt.Fprintf(w, "%s_requests_client_canceled_total%s %d\n", prefix, labels(""), snapshot.ClientCanceled)
+
+	writeFamily(w, prefix+"_requests_timed_out_total", "counter", "Total number of requests cut off by the request timeout", openMetrics)
+	fmt.Fprintf(w, "%s_requests_timed_out_total%s %d\n", prefix, labels(""), snapshot.RequestTimeouts)
+
+	writeFamily(w, prefix+"_client_read_errors_total", "counter", "Total number of requests whose body could not be read from the client", openMetrics)
+	fmt.Fprintf(w, "%s_client_read_errors_total%s %d\n", prefix, labels(""), snapshot.ClientReadErrors)
+
+	writeFamily(w, prefix+"_response_copy_errors_total", "counter", "Total number of responses whose body failed to copy to the client", openMetrics)
+	fmt.Fprintf(w, "%s_response_copy_errors_total%s %d\n", prefix, labels(""), snapshot.ResponseCopyErrors)
+
+	writeFamily(w, prefix+"_backend_healthy", "gauge", "Current health status (1=healthy, 0=unhealthy)", openMetrics)
+	fmt.Fprintf(w, "%s_backend_healthy%s %d\n", prefix, labels(`state="healthy"`), snapshot.HealthyBackends)
+	fmt.Fprintf(w, "%s_backend_healthy%s %d\n", prefix, labels(`state="total"`), snapshot.TotalBackends)
+
+	writeFamily(w, prefix+"_backend_active_connections", "gauge", "Requests currently in flight to backend", openMetrics)
+	for backend, count := range activeConnections {
+		fmt.Fprintf(w, "%s_backend_active_connections%s %d\n", prefix, labels(backendLabel(backend)), count)
+	}
 
-	fmt.Fprintf(w, "# HELP go_balancer_backend_requests_total Total requests sent to backend\n")
-	fmt.Fprintf(w, "# TYPE go_balancer_backend_requests_total counter\n")
+	writeFamily(w, prefix+"_backend_requests_total", "counter", "Total requests sent to backend", openMetrics)
 	for backend, count := range p.metrics.backendRequests {
-		fmt.Fprintf(w, "go_balancer_backend_requests_total{backend=\"%s\"} %d\n", backend, count)
+		fmt.Fprintf(w, "%s_backend_requests_total%s %d\n", prefix, labels(backendLabel(backend)), count)
 	}
 
-	fmt.Fprintf(w, "# HELP go_balancer_backend_failures_total Total failures from backend\n")
-	fmt.Fprintf(w, "# TYPE go_balancer_backend_failures_total counter\n")
+	writeFamily(w, prefix+"_backend_failures_total", "counter", "Total failures from backend", openMetrics)
 	for backend, count := range p.metrics.backendFailures {
-		fmt.Fprintf(w, "go_balancer_backend_failures_total{backend=\"%s\"} %d\n", backend, count)
+		fmt.Fprintf(w, "%s_backend_failures_total%s %d\n", prefix, labels(backendLabel(backend)), count)
+	}
+
+	writeFamily(w, prefix+"_retries_total", "counter", "Total retries sent to backend", openMetrics)
+	for backend, count := range p.metrics.backendRetries {
+		fmt.Fprintf(w, "%s_retries_total%s %d\n", prefix, labels(backendLabel(backend)), count)
+	}
+
+	writeFamily(w, prefix+"_slow_requests_total", "counter", "Total backend responses slower than the slow request threshold", openMetrics)
+	for backend, count := range p.metrics.slowRequests {
+		fmt.Fprintf(w, "%s_slow_requests_total%s %d\n", prefix, labels(backendLabel(backend)), count)
+	}
+
+	writeFamily(w, prefix+"_backend_upstream_eof_total", "counter", "Total times the backend closed the connection before completing a response", openMetrics)
+	for backend, count := range p.metrics.upstreamEOFs {
+		fmt.Fprintf(w, "%s_backend_upstream_eof_total%s %d\n", prefix, labels(backendLabel(backend)), count)
+	}
+
+	writeFamily(w, prefix+"_backend_response_bytes_total", "counter", "Total response body bytes written to clients from backend", openMetrics)
+	for backend, n := range p.metrics.responseBytes {
+		fmt.Fprintf(w, "%s_backend_response_bytes_total%s %d\n", prefix, labels(backendLabel(backend)), n)
+	}
+
+	writeFamily(w, prefix+"_healthcheck_duration_seconds", "histogram", "Duration of backend health probes", openMetrics)
+	for backend, h := range p.metrics.healthCheckDurations {
+		writeHistogram(w, prefix+"_healthcheck_duration_seconds", joinLabels(backendLabel(backend), p.staticLabels), h, openMetrics)
+	}
+
+	writeFamily(w, prefix+"_request_duration_seconds", "histogram", "Duration of successful requests proxied to backend", openMetrics)
+	for backend, h := range p.metrics.requestDurations {
+		writeHistogram(w, prefix+"_request_duration_seconds", joinLabels(backendLabel(backend), p.staticLabels), h, openMetrics)
+	}
+}
+
+// writeFamily writes the HELP and TYPE lines of a metric family. OpenMetrics
+// names counter families without the _total suffix their samples carry.
+func writeFamily(w io.Writer, name, metricType, help string, openMetrics bool) {
+	if openMetrics && metricType == "counter" {
+		name = strings.TrimSuffix(name, "_total")
+	}
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, metricType)
+}
+
+// writeHistogram writes the bucket, sum and count samples of one backend's
+// histogram, with exemplars on the buckets in OpenMetrics. labels is the
+// rendered label list shared by every sample; buckets add le to it.
+func writeHistogram(w io.Writer, name, labels string, h *histogram, openMetrics bool) {
+	for i, bound := range h.bounds {
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"%s\"} %d%s\n",
+			name, labels, strconv.FormatFloat(bound, 'g', -1, 64), h.counts[i], exemplarSuffix(h.exemplars[i], openMetrics))
+	}
+	fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d%s\n",
+		name, labels, h.count, exemplarSuffix(h.exemplars[len(h.bounds)], openMetrics))
+	fmt.Fprintf(w, "%s_sum{%s} %g\n", name, labels, h.sum)
+	fmt.Fprintf(w, "%s_count{%s} %d\n", name, labels, h.count)
+}
+
+// backendLabel renders the backend label of a per-backend sample
+func backendLabel(backend string) string {
+	return fmt.Sprintf("backend=\"%s\"", backend)
+}
+
+// joinLabels joins rendered label lists, skipping empty ones
+func joinLabels(lists ...string) string {
+	var nonEmpty []string
+	for _, list := range lists {
+		if list != "" {
+			nonEmpty = append(nonEmpty, list)
+		}
+	}
+	return strings.Join(nonEmpty, ",")
+}
+
+// renderStaticLabels renders labels sorted by name, escaping their values as
+// the exposition format requires
+func renderStaticLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for _, name := range slices.Sorted(maps.Keys(labels)) {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", name, labelValueEscaper.Replace(labels[name])))
+	}
+	return strings.Join(pairs, ",")
+}
+
+// labelValueEscaper escapes label values for the exposition format
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// exemplarSuffix formats an exemplar as OpenMetrics appends it to a sample:
+// ` # {trace_id="..."} value timestamp`. It is empty outside OpenMetrics.
+func exemplarSuffix(exemplar *Exemplar, openMetrics bool) string {
+	if !openMetrics || exemplar == nil {
+		return ""
 	}
+	timestamp := float64(exemplar.Timestamp.UnixNano()) / float64(time.Second)
+	return fmt.Sprintf(" # {trace_id=\"%s\"} %g %s",
+		exemplar.TraceID, exemplar.Value, strconv.FormatFloat(timestamp, 'f', 3, 64))
 }
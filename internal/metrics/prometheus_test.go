@@ -0,0 +1,400 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+
+	req := httptest.NewRequest("GET", "http://localhost:8000/metrics", nil)
+	recorder := httptest.NewRecorder()
+	NewPrometheusMetricsProvider(m, ProviderOptions{}).ServeHTTP(recorder, req)
+	return recorder.Body.String()
+}
+
+// scrapeOpenMetrics scrapes m as a scraper that accepts OpenMetrics would
+func scrapeOpenMetrics(t *testing.T, m *Metrics) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest("GET", "http://localhost:8000/metrics", nil)
+	req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0,text/plain;version=0.0.4;q=0.5")
+	recorder := httptest.NewRecorder()
+	NewPrometheusMetricsProvider(m, ProviderOptions{}).ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestPrometheusRetryMetrics(t *testing.T) {
+	m := NewMetrics()
+	m.RecordRetry("backend-1")
+	m.RecordRetry("backend-1")
+	m.RecordRetry("backend-2")
+
+	body := scrape(t, m)
+
+	expected := []string{
+		`go_balancer_retries_total{backend="backend-1"} 2`,
+		`go_balancer_retries_total{backend="backend-2"} 1`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(body, line) {
+			t.Errorf("Expected metrics output to contain %q, got:\n%s", line, body)
+		}
+	}
+}
+
+func TestPrometheusSlowRequestMetrics(t *testing.T) {
+	m := NewMetrics()
+	m.RecordSlowRequest("backend-1")
+	m.RecordSlowRequest("backend-1")
+
+	body := scrape(t, m)
+
+	line := `go_balancer_slow_requests_total{backend="backend-1"} 2`
+	if !strings.Contains(body, line) {
+		t.Errorf("Expected metrics output to contain %q, got:\n%s", line, body)
+	}
+}
+
+func TestLookupProvider(t *testing.T) {
+	tests := []struct {
+		name         string
+		provider     string
+		expectedName string
+		expectError  bool
+	}{
+		{"Default", "", PrometheusProvider, false},
+		{"Prometheus", "prometheus", PrometheusProvider, false},
+		{"JSON", "json", JSONProvider, false},
+		{"Unknown", "graphite", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			factory, err := LookupProvider(tt.provider)
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("Expected error for provider %q", tt.provider)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if name := factory(NewMetrics(), ProviderOptions{}).Name(); name != tt.expectedName {
+				t.Errorf("Expected provider %q, got %q", tt.expectedName, name)
+			}
+		})
+	}
+}
+
+func TestPrometheusActiveConnections(t *testing.T) {
+	m := NewMetrics()
+
+	// Without a source the gauge has no series
+	if body := scrape(t, m); strings.Contains(body, "go_balancer_backend_active_connections{") {
+		t.Errorf("Expected no active connection series without a source, got:\n%s", body)
+	}
+
+	m.SetActiveConnectionsSource(func() map[string]int64 {
+		return map[string]int64{"backend-1": 3, "backend-2": 0}
+	})
+	body := scrape(t, m)
+
+	expected := []string{
+		"# TYPE go_balancer_backend_active_connections gauge",
+		`go_balancer_backend_active_connections{backend="backend-1"} 3`,
+		`go_balancer_backend_active_connections{backend="backend-2"} 0`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(body, line) {
+			t.Errorf("Expected metrics output to contain %q, got:\n%s", line, body)
+		}
+	}
+}
+
+func TestPrometheusHealthCheckDurationMetrics(t *testing.T) {
+	m := NewMetrics()
+	m.RecordHealthCheckDuration("backend-1", 30*time.Millisecond)
+	m.RecordHealthCheckDuration("backend-1", 3*time.Second)
+
+	body := scrape(t, m)
+
+	expected := []string{
+		`go_balancer_healthcheck_duration_seconds_bucket{backend="backend-1",le="0.025"} 0`,
+		`go_balancer_healthcheck_duration_seconds_bucket{backend="backend-1",le="0.05"} 1`,
+		`go_balancer_healthcheck_duration_seconds_bucket{backend="backend-1",le="5"} 2`,
+		`go_balancer_healthcheck_duration_seconds_bucket{backend="backend-1",le="+Inf"} 2`,
+		`go_balancer_healthcheck_duration_seconds_sum{backend="backend-1"} 3.03`,
+		`go_balancer_healthcheck_duration_seconds_count{backend="backend-1"} 2`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(body, line) {
+			t.Errorf("Expected metrics output to contain %q, got:\n%s", line, body)
+		}
+	}
+}
+
+func TestPrometheusConcurrencyRejections(t *testing.T) {
+	m := NewMetrics()
+	m.RecordConcurrencyRejection()
+	m.RecordConcurrencyRejection()
+
+	body := scrape(t, m)
+
+	line := "go_balancer_concurrency_rejected_total 2"
+	if !strings.Contains(body, line) {
+		t.Errorf("Expected metrics output to contain %q, got:\n%s", line, body)
+	}
+}
+
+func TestPrometheusClientReadErrors(t *testing.T) {
+	m := NewMetrics()
+	m.RecordClientReadError()
+
+	body := scrape(t, m)
+
+	line := "go_balancer_client_read_errors_total 1"
+	if !strings.Contains(body, line) {
+		t.Errorf("Expected metrics output to contain %q, got:\n%s", line, body)
+	}
+}
+
+func TestPrometheusResponseCopyMetrics(t *testing.T) {
+	m := NewMetrics()
+	m.RecordResponseCopyError()
+	m.RecordResponseBytes("backend-1", 1000)
+	m.RecordResponseBytes("backend-1", 24)
+
+	body := scrape(t, m)
+
+	expected := []string{
+		"go_balancer_response_copy_errors_total 1",
+		`go_balancer_backend_response_bytes_total{backend="backend-1"} 1024`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(body, line) {
+			t.Errorf("Expected metrics output to contain %q, got:\n%s", line, body)
+		}
+	}
+}
+
+func TestPrometheusRequestDurationMetrics(t *testing.T) {
+	m := NewMetrics()
+	m.RecordRequest("backend-1", 30*time.Millisecond)
+	m.RecordTracedRequest("backend-1", 200*time.Millisecond, "4bf92f3577b34da6a3ce929d0e0e4736")
+
+	body := scrape(t, m)
+
+	expected := []string{
+		`go_balancer_request_duration_seconds_bucket{backend="backend-1",le="0.05"} 1`,
+		`go_balancer_request_duration_seconds_bucket{backend="backend-1",le="0.25"} 2`,
+		`go_balancer_request_duration_seconds_bucket{backend="backend-1",le="+Inf"} 2`,
+		`go_balancer_request_duration_seconds_count{backend="backend-1"} 2`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(body, line) {
+			t.Errorf("Expected metrics output to contain %q, got:\n%s", line, body)
+		}
+	}
+
+	// The Prometheus text format has no exemplar syntax
+	if strings.Contains(body, "trace_id") || strings.Contains(body, "# EOF") {
+		t.Errorf("Expected no OpenMetrics syntax in the Prometheus format, got:\n%s", body)
+	}
+}
+
+func TestOpenMetricsExemplars(t *testing.T) {
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+
+	m := NewMetrics()
+	m.RecordRequest("backend-1", 30*time.Millisecond)
+	before := time.Now()
+	m.RecordTracedRequest("backend-1", 200*time.Millisecond, traceID)
+
+	recorder := scrapeOpenMetrics(t, m)
+	body := recorder.Body.String()
+
+	if got := recorder.Header().Get("Content-Type"); got != "application/openmetrics-text; version=1.0.0; charset=utf-8" {
+		t.Errorf("Expected OpenMetrics content type, got %q", got)
+	}
+	if !strings.HasSuffix(body, "# EOF\n") {
+		t.Errorf("Expected output to end with # EOF, got:\n%s", body)
+	}
+	if !strings.Contains(body, "# TYPE go_balancer_requests counter\n") {
+		t.Errorf("Expected counter family named without _total, got:\n%s", body)
+	}
+
+	// The traced request lands in the le="0.25" bucket, which carries its exemplar
+	exemplarLine := regexp.MustCompile(`(?m)^go_balancer_request_duration_seconds_bucket\{backend="backend-1",le="([^"]+)"\} (\d+) # \{trace_id="([0-9a-f]{32})"\} (\S+) (\d+\.\d{3})$`)
+	matches := exemplarLine.FindAllStringSubmatch(body, -1)
+	if len(matches) != 1 {
+		t.Fatalf("Expected exactly one bucket with an exemplar, got %d in:\n%s", len(matches), body)
+	}
+	match := matches[0]
+	if match[1] != "0.25" || match[2] != "2" {
+		t.Errorf("Expected exemplar on bucket le=0.25 with count 2, got le=%s count %s", match[1], match[2])
+	}
+	if match[3] != traceID {
+		t.Errorf("Expected trace ID %s, got %s", traceID, match[3])
+	}
+	if value, err := strconv.ParseFloat(match[4], 64); err != nil || value != 0.2 {
+		t.Errorf("Expected exemplar value 0.2, got %s", match[4])
+	}
+	timestamp, err := strconv.ParseFloat(match[5], 64)
+	if err != nil || timestamp < float64(before.Unix()) || timestamp > float64(time.Now().Unix()+1) {
+		t.Errorf("Expected exemplar timestamp around now, got %s", match[5])
+	}
+
+	// Buckets without a traced observation carry no exemplar
+	if !strings.Contains(body, `go_balancer_request_duration_seconds_bucket{backend="backend-1",le="0.05"} 1`+"\n") {
+		t.Errorf("Expected untraced bucket without exemplar, got:\n%s", body)
+	}
+}
+
+// recordEverything records a sample in every metric family
+func recordEverything(m *Metrics) {
+	m.SetActiveConnectionsSource(func() map[string]int64 { return map[string]int64{"backend-1": 1} })
+	m.RecordRequest("backend-1", 30*time.Millisecond)
+	m.RecordFailure("backend-1")
+	m.RecordRetry("backend-1")
+	m.RecordSlowRequest("backend-1")
+	m.RecordUpstreamEOF("backend-1")
+	m.RecordResponseBytes("backend-1", 128)
+	m.RecordHealthCheckDuration("backend-1", 10*time.Millisecond)
+	m.RecordMaintenanceRejection()
+	m.RecordConcurrencyRejection()
+	m.RecordClientCanceled()
+	m.RecordRequestTimeout()
+	m.RecordClientReadError()
+	m.RecordResponseCopyError()
+}
+
+func TestPrometheusMetricsPrefix(t *testing.T) {
+	m := NewMetrics()
+	recordEverything(m)
+
+	for _, openMetrics := range []bool{false, true} {
+		req := httptest.NewRequest("GET", "http://localhost:8000/metrics", nil)
+		if openMetrics {
+			req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+		}
+		recorder := httptest.NewRecorder()
+		NewPrometheusMetricsProvider(m, ProviderOptions{Prefix: "tenant_a"}).ServeHTTP(recorder, req)
+
+		for _, line := range strings.Split(strings.TrimSpace(recorder.Body.String()), "\n") {
+			name := line
+			if strings.HasPrefix(line, "# HELP ") || strings.HasPrefix(line, "# TYPE ") {
+				name = line[len("# HELP "):]
+			} else if line == "# EOF" {
+				continue
+			}
+			if !strings.HasPrefix(name, "tenant_a_") {
+				t.Errorf("Expected every metric to carry the prefix tenant_a_ (OpenMetrics: %v), got %q", openMetrics, line)
+			}
+		}
+	}
+
+	if body := scrape(t, m); !strings.Contains(body, "go_balancer_retries_total{backend=\"backend-1\"} 1") {
+		t.Errorf("Expected the default go_balancer prefix, got:\n%s", body)
+	}
+}
+
+func TestIsValidPrometheusPrefix(t *testing.T) {
+	for prefix, expected := range map[string]bool{
+		"go_balancer": true,
+		"tenant:a":    true,
+		"_private":    true,
+		"":            false,
+		"1tenant":     false,
+		"tenant-a":    false,
+		"tenant a":    false,
+	} {
+		if got := IsValidPrometheusPrefix(prefix); got != expected {
+			t.Errorf("IsValidPrometheusPrefix(%q): expected %v, got %v", prefix, expected, got)
+		}
+	}
+}
+
+func TestPrometheusStaticLabels(t *testing.T) {
+	m := NewMetrics()
+	recordEverything(m)
+
+	provider := NewPrometheusMetricsProvider(m, ProviderOptions{Labels: map[string]string{"region": "us-east", "instance": "lb-1"}})
+	for _, openMetrics := range []bool{false, true} {
+		req := httptest.NewRequest("GET", "http://localhost:8000/metrics", nil)
+		if openMetrics {
+			req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+		}
+		recorder := httptest.NewRecorder()
+		provider.ServeHTTP(recorder, req)
+		body := recorder.Body.String()
+
+		// Every sample carries the static labels, sorted by name
+		for _, line := range strings.Split(strings.TrimSpace(body), "\n") {
+			if strings.HasPrefix(line, "#") {
+				continue
+			}
+			if !strings.Contains(line, `instance="lb-1",region="us-east"`) {
+				t.Errorf("Expected every sample to carry the static labels (OpenMetrics: %v), got %q", openMetrics, line)
+			}
+		}
+
+		expected := []string{
+			`go_balancer_requests_total{instance="lb-1",region="us-east"} 2`,
+			`go_balancer_backend_healthy{state="healthy",instance="lb-1",region="us-east"} 0`,
+			`go_balancer_retries_total{backend="backend-1",instance="lb-1",region="us-east"} 1`,
+			`go_balancer_request_duration_seconds_bucket{backend="backend-1",instance="lb-1",region="us-east",le="+Inf"} 1`,
+			`go_balancer_request_duration_seconds_count{backend="backend-1",instance="lb-1",region="us-east"} 1`,
+		}
+		for _, line := range expected {
+			if !strings.Contains(body, line) {
+				t.Errorf("Expected metrics output to contain %q (OpenMetrics: %v), got:\n%s", line, openMetrics, body)
+			}
+		}
+	}
+}
+
+func TestPrometheusStaticLabelEscaping(t *testing.T) {
+	m := NewMetrics()
+	provider := NewPrometheusMetricsProvider(m, ProviderOptions{Labels: map[string]string{"note": "a \"b\"\\c\nd"}})
+
+	recorder := httptest.NewRecorder()
+	provider.ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8000/metrics", nil))
+
+	line := `go_balancer_requests_total{note="a \"b\"\\c\nd"} 0`
+	if !strings.Contains(recorder.Body.String(), line) {
+		t.Errorf("Expected metrics output to contain %q, got:\n%s", line, recorder.Body.String())
+	}
+}
+
+func TestValidateStaticLabels(t *testing.T) {
+	tests := []struct {
+		name        string
+		labels      map[string]string
+		expectValid bool
+	}{
+		{"None", nil, true},
+		{"Valid", map[string]string{"instance": "lb-1", "region": "us-east"}, true},
+		{"Invalid name", map[string]string{"instance-id": "lb-1"}, false},
+		{"Reserved prefix", map[string]string{"__name__": "x"}, false},
+		{"Backend label", map[string]string{"backend": "x"}, false},
+		{"Bucket label", map[string]string{"le": "x"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateStaticLabels(tt.labels)
+			if tt.expectValid && err != nil {
+				t.Errorf("Expected labels to be valid, got error: %v", err)
+			} else if !tt.expectValid && err == nil {
+				t.Errorf("Expected labels to be invalid")
+			}
+		})
+	}
+}
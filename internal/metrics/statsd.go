@@ -0,0 +1,180 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// DefaultStatsDPrefix is prepended to every StatsD metric name when no prefix is configured
+const DefaultStatsDPrefix = "go_balancer"
+
+// maxStatsDPacketSize keeps each datagram under a typical Ethernet MTU so
+// packets are not fragmented
+const maxStatsDPacketSize = 1432
+
+// StatsDEmitter periodically pushes metrics to a StatsD (or DogStatsD) server
+// over UDP. Counters are sent as deltas since the previous flush and backend
+// counts as gauges. Send failures are logged and the emitter keeps running;
+// metrics for a failed flush are not resent.
+type StatsDEmitter struct {
+	metrics  *Metrics
+	address  string
+	prefix   string
+	interval time.Duration
+
+	mu       sync.Mutex
+	conn     net.Conn
+	last     MetricsSnapshot
+	failing  bool // Whether the previous flush failed, to avoid log spam
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// NewStatsDEmitter creates an emitter that sends metrics to address (host:port)
+// every interval
+func NewStatsDEmitter(metrics *Metrics, address, prefix string, interval time.Duration) *StatsDEmitter {
+	if prefix == "" {
+		prefix = DefaultStatsDPrefix
+	}
+
+	return &StatsDEmitter{
+		metrics:  metrics,
+		address:  address,
+		prefix:   prefix,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start begins periodic flushing
+func (e *StatsDEmitter) Start() {
+	go e.flushLoop()
+	log.Printf("StatsD emitter started for %s with interval %s", e.address, e.interval)
+}
+
+// Stop terminates periodic flushing; pending counts are sent in a final flush
+func (e *StatsDEmitter) Stop() {
+	e.stopOnce.Do(func() {
+		close(e.stopCh)
+	})
+}
+
+// flushLoop flushes metrics at regular intervals
+func (e *StatsDEmitter) flushLoop() {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			e.flushAndLog()
+		case <-e.stopCh:
+			e.flushAndLog()
+			e.mu.Lock()
+			if e.conn != nil {
+				e.conn.Close()
+				e.conn = nil
+			}
+			e.mu.Unlock()
+			log.Println("StatsD emitter stopped")
+			return
+		}
+	}
+}
+
+// flushAndLog flushes and logs only when the target starts or stops failing
+func (e *StatsDEmitter) flushAndLog() {
+	err := e.Flush()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err != nil && !e.failing {
+		log.Printf("StatsD flush to %s failed: %v", e.address, err)
+	} else if err == nil && e.failing {
+		log.Printf("StatsD flush to %s recovered", e.address)
+	}
+	e.failing = err != nil
+}
+
+// Flush sends the current metrics to the StatsD server
+func (e *StatsDEmitter) Flush() error {
+	snapshot := e.metrics.GetSnapshot()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	lines := e.format(snapshot)
+	// Counters are deltas, so advance the baseline even if the send fails;
+	// otherwise a recovered target would receive one large burst
+	e.last = snapshot
+
+	if e.conn == nil {
+		conn, err := net.Dial("udp", e.address)
+		if err != nil {
+			return err
+		}
+		e.conn = conn
+	}
+
+	for _, packet := range packLines(lines, maxStatsDPacketSize) {
+		if _, err := e.conn.Write(packet); err != nil {
+			// Redial on the next flush in case the target address changed
+			e.conn.Close()
+			e.conn = nil
+			return err
+		}
+	}
+	return nil
+}
+
+// format renders a snapshot as StatsD lines relative to the previous flush
+func (e *StatsDEmitter) format(s MetricsSnapshot) []string {
+	counter := func(name string, current, previous int64) string {
+		return fmt.Sprintf("%s.%s:%d|c", e.prefix, name, current-previous)
+	}
+	gauge := func(name string, value int) string {
+		return fmt.Sprintf("%s.%s:%d|g", e.prefix, name, value)
+	}
+
+	return []string{
+		counter("requests", s.TotalRequests, e.last.TotalRequests),
+		counter("requests.success", s.SuccessfulRequests, e.last.SuccessfulRequests),
+		counter("requests.failed", s.FailedRequests, e.last.FailedRequests),
+		counter("requests.maintenance_rejected", s.MaintenanceRejections, e.last.MaintenanceRejections),
+		counter("requests.concurrency_rejected", s.ConcurrencyRejections, e.last.ConcurrencyRejections),
+		counter("requests.backend_queue_served", s.BackendQueueServed, e.last.BackendQueueServed),
+		counter("requests.backend_queue_timeouts", s.BackendQueueTimeouts, e.last.BackendQueueTimeouts),
+		counter("requests.client_canceled", s.ClientCanceled, e.last.ClientCanceled),
+		counter("requests.timed_out", s.RequestTimeouts, e.last.RequestTimeouts),
+		counter("requests.client_read_errors", s.ClientReadErrors, e.last.ClientReadErrors),
+		counter("requests.response_copy_errors", s.ResponseCopyErrors, e.last.ResponseCopyErrors),
+		gauge("backends.healthy", s.HealthyBackends),
+		gauge("backends.total", s.TotalBackends),
+	}
+}
+
+// packLines joins lines with newlines into packets no larger than maxSize
+func packLines(lines []string, maxSize int) [][]byte {
+	var packets [][]byte
+	var buf bytes.Buffer
+
+	for _, line := range lines {
+		if buf.Len() > 0 && buf.Len()+1+len(line) > maxSize {
+			packets = append(packets, append([]byte(nil), buf.Bytes()...))
+			buf.Reset()
+		}
+		if buf.Len() > 0 {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(line)
+	}
+	if buf.Len() > 0 {
+		packets = append(packets, buf.Bytes())
+	}
+	return packets
+}
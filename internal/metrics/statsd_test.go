@@ -0,0 +1,152 @@
+package metrics
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func listenUDP(t *testing.T) *net.UDPConn {
+	t.Helper()
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func readPacket(t *testing.T, conn *net.UDPConn) string {
+	t.Helper()
+
+	buf := make([]byte, 2048)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("Failed to read StatsD packet: %v", err)
+	}
+	return string(buf[:n])
+}
+
+func TestStatsDFlush(t *testing.T) {
+	listener := listenUDP(t)
+
+	m := NewMetrics()
+	m.RecordRequest("backend-1", time.Millisecond)
+	m.RecordRequest("backend-1", time.Millisecond)
+	m.RecordFailure("backend-2")
+	m.UpdateBackendCount(2, 3)
+
+	emitter := NewStatsDEmitter(m, listener.LocalAddr().String(), "", time.Minute)
+	defer emitter.Stop()
+
+	if err := emitter.Flush(); err != nil {
+		t.Fatalf("Unexpected flush error: %v", err)
+	}
+	packet := readPacket(t, listener)
+
+	expected := []string{
+		"go_balancer.requests:3|c",
+		"go_balancer.requests.success:2|c",
+		"go_balancer.requests.failed:1|c",
+		"go_balancer.backends.healthy:2|g",
+		"go_balancer.backends.total:3|g",
+	}
+	lines := strings.Split(packet, "\n")
+	for _, line := range expected {
+		if !containsLine(lines, line) {
+			t.Errorf("Expected packet to contain %q, got:\n%s", line, packet)
+		}
+	}
+
+	// Counters are deltas since the previous flush
+	m.RecordRequest("backend-1", time.Millisecond)
+	if err := emitter.Flush(); err != nil {
+		t.Fatalf("Unexpected flush error: %v", err)
+	}
+	packet = readPacket(t, listener)
+	lines = strings.Split(packet, "\n")
+	for _, line := range []string{"go_balancer.requests:1|c", "go_balancer.requests.failed:0|c"} {
+		if !containsLine(lines, line) {
+			t.Errorf("Expected packet to contain %q, got:\n%s", line, packet)
+		}
+	}
+}
+
+func TestStatsDPrefix(t *testing.T) {
+	listener := listenUDP(t)
+
+	m := NewMetrics()
+	m.RecordRequest("backend-1", time.Millisecond)
+
+	emitter := NewStatsDEmitter(m, listener.LocalAddr().String(), "edge.lb", time.Minute)
+	defer emitter.Stop()
+
+	if err := emitter.Flush(); err != nil {
+		t.Fatalf("Unexpected flush error: %v", err)
+	}
+	if packet := readPacket(t, listener); !strings.HasPrefix(packet, "edge.lb.requests:1|c") {
+		t.Errorf("Expected packet to use prefix edge.lb, got:\n%s", packet)
+	}
+}
+
+func TestStatsDPeriodicFlush(t *testing.T) {
+	listener := listenUDP(t)
+
+	m := NewMetrics()
+	m.RecordRequest("backend-1", time.Millisecond)
+
+	emitter := NewStatsDEmitter(m, listener.LocalAddr().String(), "", 20*time.Millisecond)
+	emitter.Start()
+	defer emitter.Stop()
+
+	if packet := readPacket(t, listener); !strings.Contains(packet, "go_balancer.requests:1|c") {
+		t.Errorf("Expected periodic flush to report 1 request, got:\n%s", packet)
+	}
+}
+
+func TestStatsDUnreachableTarget(t *testing.T) {
+	// Reserve a port and release it so nothing is listening there
+	closed := listenUDP(t)
+	address := closed.LocalAddr().String()
+	closed.Close()
+
+	m := NewMetrics()
+	m.RecordRequest("backend-1", time.Millisecond)
+
+	emitter := NewStatsDEmitter(m, address, "", 10*time.Millisecond)
+	emitter.Start()
+
+	// Several flushes against a dead target must not panic or block
+	time.Sleep(50 * time.Millisecond)
+	emitter.Stop()
+
+	// Unresolvable hosts fail the flush instead of crashing
+	bad := NewStatsDEmitter(m, "statsd.invalid:8125", "", time.Minute)
+	if err := bad.Flush(); err == nil {
+		t.Errorf("Expected flush to an unresolvable host to fail")
+	}
+}
+
+func TestPackLines(t *testing.T) {
+	lines := []string{"aaaa", "bbbb", "cccc"}
+
+	packets := packLines(lines, 9)
+	if len(packets) != 2 {
+		t.Fatalf("Expected 2 packets, got %d", len(packets))
+	}
+	if string(packets[0]) != "aaaa\nbbbb" || string(packets[1]) != "cccc" {
+		t.Errorf("Unexpected packets: %q", packets)
+	}
+}
+
+func containsLine(lines []string, want string) bool {
+	for _, line := range lines {
+		if line == want {
+			return true
+		}
+	}
+	return false
+}
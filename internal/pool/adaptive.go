@@ -0,0 +1,59 @@
+package pool
+
+import (
+	"time"
+)
+
+// Adaptive weight tuning
+const (
+	// capacityDecay is the weight given to each new capacity sample
+	capacityDecay = 0.1
+
+	// minCapacityLatency bounds the latency used in capacity samples so an
+	// instant response doesn't produce an unbounded rate
+	minCapacityLatency = 100 * time.Microsecond
+)
+
+// capacityEstimate tracks how many requests per second a backend drains
+type capacityEstimate struct {
+	rate    float64 // Moving average of completed requests per second
+	samples int64
+}
+
+// RecordCompletion feeds a successfully completed request into the backend's
+// capacity estimate. By Little's law a backend holding inFlight requests that
+// each take latency completes inFlight/latency requests per second, so a
+// backend that drains its connections quickly under load shows a high rate.
+func (b *Backend) RecordCompletion(latency time.Duration, inFlight int64) {
+	latency = max(latency, minCapacityLatency)
+	inFlight = max(inFlight, 1)
+	sample := float64(inFlight) / latency.Seconds()
+
+	b.scoreMu.Lock()
+	defer b.scoreMu.Unlock()
+
+	c := &b.capacity
+	if c.samples == 0 {
+		c.rate = sample
+	} else {
+		c.rate += capacityDecay * (sample - c.rate)
+	}
+	c.samples++
+}
+
+// AdaptiveWeight returns the backend's weight scaled by its observed capacity
+// in requests per second, or 0 if no requests have completed yet
+func (b *Backend) AdaptiveWeight() float64 {
+	weight := b.Weight()
+	if weight <= 0 {
+		weight = 1
+	}
+
+	b.scoreMu.Lock()
+	defer b.scoreMu.Unlock()
+
+	if b.capacity.samples == 0 {
+		return 0
+	}
+	return float64(weight) * b.capacity.rate
+}
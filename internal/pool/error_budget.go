@@ -0,0 +1,108 @@
+package pool
+
+import (
+	"time"
+)
+
+// Error budget tuning
+const (
+	// errorWindowBuckets is how many slices the rolling window is split into;
+	// outcomes age out one slice at a time
+	errorWindowBuckets = 10
+
+	// ErrorBudgetMinRequests is how many requests a backend must have served
+	// within the window before its error rate can exceed the budget, so a
+	// single early failure doesn't take it out of rotation
+	ErrorBudgetMinRequests = 10
+)
+
+// errorWindow counts requests and failures over a rolling window, bucketed so
+// old outcomes age out without keeping every sample
+type errorWindow struct {
+	width   time.Duration // Span of each bucket
+	buckets [errorWindowBuckets]outcomeBucket
+}
+
+// outcomeBucket holds the counts for one span of the window
+type outcomeBucket struct {
+	epoch    int64 // Which span, counted in widths since the Unix epoch, the counts belong to
+	requests int64
+	failures int64
+}
+
+// newErrorWindow creates a window covering the given duration
+func newErrorWindow(window time.Duration) *errorWindow {
+	return &errorWindow{width: max(window/errorWindowBuckets, time.Millisecond)}
+}
+
+// record counts one request outcome at now
+func (w *errorWindow) record(now time.Time, failed bool) {
+	epoch := now.UnixNano() / int64(w.width)
+	bucket := &w.buckets[epoch%errorWindowBuckets]
+	if bucket.epoch != epoch {
+		*bucket = outcomeBucket{epoch: epoch}
+	}
+	bucket.requests++
+	if failed {
+		bucket.failures++
+	}
+}
+
+// rate returns the share of requests within the window ending at now that
+// failed, and how many requests that covers
+func (w *errorWindow) rate(now time.Time) (float64, int64) {
+	epoch := now.UnixNano() / int64(w.width)
+
+	var requests, failures int64
+	for _, bucket := range w.buckets {
+		if bucket.epoch > epoch-errorWindowBuckets && bucket.epoch <= epoch {
+			requests += bucket.requests
+			failures += bucket.failures
+		}
+	}
+	if requests == 0 {
+		return 0, 0
+	}
+	return float64(failures) / float64(requests), requests
+}
+
+// setErrorBudget starts tracking the backend's error rate over window,
+// keeping it from new requests while the rate exceeds threshold. A zero
+// threshold stops tracking.
+func (b *Backend) setErrorBudget(threshold float64, window time.Duration) {
+	b.scoreMu.Lock()
+	defer b.scoreMu.Unlock()
+
+	if threshold <= 0 {
+		b.errorBudget, b.outcomes = 0, nil
+		return
+	}
+	b.errorBudget = threshold
+	b.outcomes = newErrorWindow(window)
+}
+
+// ErrorRate returns the share of the backend's requests that failed within
+// the error budget window, or 0 if no error budget is configured
+func (b *Backend) ErrorRate() float64 {
+	b.scoreMu.Lock()
+	defer b.scoreMu.Unlock()
+
+	if b.outcomes == nil {
+		return 0
+	}
+	rate, _ := b.outcomes.rate(time.Now())
+	return rate
+}
+
+// OverErrorBudget reports whether the backend's recent error rate exceeds its
+// error budget. It recovers on its own as failures age out of the window.
+func (b *Backend) OverErrorBudget() bool {
+	b.scoreMu.Lock()
+	defer b.scoreMu.Unlock()
+
+	if b.outcomes == nil {
+		return false
+	}
+	rate, requests := b.outcomes.rate(time.Now())
+	return requests >= ErrorBudgetMinRequests && rate > b.errorBudget
+}
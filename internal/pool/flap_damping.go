@@ -0,0 +1,83 @@
+package pool
+
+import (
+	"time"
+)
+
+// flapDamping decides when a backend that keeps going down is held out of
+// rotation. A zero threshold disables damping.
+type flapDamping struct {
+	threshold int           // Flaps within the window tolerated before damping
+	window    time.Duration // How far back flaps are counted
+	penalty   time.Duration // How long a damped backend is held unhealthy
+}
+
+// setFlapDamping sets when the backend is damped
+func (b *Backend) setFlapDamping(damping flapDamping) {
+	b.flapMu.Lock()
+	defer b.flapMu.Unlock()
+
+	b.damping = damping
+	if damping.threshold <= 0 {
+		b.flaps, b.dampedUntil = nil, time.Time{}
+	}
+}
+
+// recordFlap notes that the backend went down at now and starts its penalty
+// once it has gone down more than the threshold allows within the window
+func (b *Backend) recordFlap(now time.Time) {
+	b.flapMu.Lock()
+	defer b.flapMu.Unlock()
+
+	if b.damping.threshold <= 0 {
+		return
+	}
+	b.flaps = append(recentFlaps(b.flaps, now, b.damping.window), now)
+	if len(b.flaps) > b.damping.threshold {
+		b.dampedUntil = now.Add(b.damping.penalty)
+		b.flaps = nil
+	}
+}
+
+// recentFlaps drops flaps older than window before now
+func recentFlaps(flaps []time.Time, now time.Time, window time.Duration) []time.Time {
+	cutoff := now.Add(-window)
+	for len(flaps) > 0 && !flaps[0].After(cutoff) {
+		flaps = flaps[1:]
+	}
+	return flaps
+}
+
+// FlapCount returns how many times the backend went down within the flap
+// window. It resets when the backend is damped.
+func (b *Backend) FlapCount() int {
+	b.flapMu.Lock()
+	defer b.flapMu.Unlock()
+
+	b.flaps = recentFlaps(b.flaps, time.Now(), b.damping.window)
+	return len(b.flaps)
+}
+
+// Damped reports whether the backend flapped too often and is held unhealthy
+// until its penalty ends, whatever its probes say
+func (b *Backend) Damped() bool {
+	b.flapMu.Lock()
+	defer b.flapMu.Unlock()
+
+	return time.Now().Before(b.dampedUntil)
+}
+
+// SetFlapDamping holds backends that go down more than threshold times within
+// window unhealthy for penalty, even while their probes pass, so a backend
+// bouncing between up and down stops taking traffic on every bounce. It
+// applies to pooled backends and ones added later; a zero threshold disables
+// it.
+func (sp *ServerPool) SetFlapDamping(threshold int, window, penalty time.Duration) {
+	sp.mutex.Lock()
+	defer sp.mutex.Unlock()
+
+	sp.flapDamping = flapDamping{threshold: threshold, window: window, penalty: penalty}
+	for _, backend := range sp.backends {
+		backend.setFlapDamping(sp.flapDamping)
+	}
+}
@@ -0,0 +1,48 @@
+package pool
+
+// Protocols a backend can be detected to speak
+const (
+	ProtocolHTTP1 = "HTTP/1.1"
+	ProtocolHTTP2 = "HTTP/2"
+)
+
+// Protocol returns the protocol the backend was detected to speak, or an
+// empty string until detection finishes
+func (b *Backend) Protocol() string {
+	b.protocolMu.Lock()
+	defer b.protocolMu.Unlock()
+
+	return b.protocol
+}
+
+// SetProtocol records the protocol the backend was detected to speak
+func (b *Backend) SetProtocol(protocol string) {
+	b.protocolMu.Lock()
+	defer b.protocolMu.Unlock()
+
+	b.protocol = protocol
+}
+
+// ClaimProtocolDetection reports whether the caller should detect the
+// backend's protocol. It returns true once per backend, so the backend is
+// probed once however many requests reach it first, unless the claim is given
+// back with ReleaseProtocolDetection.
+func (b *Backend) ClaimProtocolDetection() bool {
+	b.protocolMu.Lock()
+	defer b.protocolMu.Unlock()
+
+	if b.detecting {
+		return false
+	}
+	b.detecting = true
+	return true
+}
+
+// ReleaseProtocolDetection gives back a claim on detection whose probe
+// learned nothing, so the next ClaimProtocolDetection succeeds again
+func (b *Backend) ReleaseProtocolDetection() {
+	b.protocolMu.Lock()
+	defer b.protocolMu.Unlock()
+
+	b.detecting = false
+}
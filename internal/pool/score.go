@@ -0,0 +1,92 @@
+package pool
+
+import (
+	"time"
+)
+
+// Health score tuning. Scores are exponentially weighted moving averages of
+// recent outcomes, so a backend's score recovers as it serves requests again.
+const (
+	// scoreDecay is the weight given to each new outcome
+	scoreDecay = 0.1
+
+	// ScoreLatencyReference is the latency at which a backend's score is
+	// halved relative to an instant backend with no errors
+	ScoreLatencyReference = 100 * time.Millisecond
+
+	// MinHealthScore keeps failing backends receiving a trickle of traffic so
+	// their score can recover once they do
+	MinHealthScore = 0.01
+)
+
+// healthScore tracks recent latency and error rate for a backend
+type healthScore struct {
+	errorRate float64 // Moving average of failures, 0..1
+	latency   float64 // Moving average of successful request latency, in seconds
+	samples   int64
+}
+
+// RecordOutcome feeds a proxied request's latency and whether it failed into
+// the backend's health score and, if configured, its error budget. Failed
+// requests count towards the error rate only, since their latency says little
+// about the backend's speed.
+func (b *Backend) RecordOutcome(latency time.Duration, failed bool) {
+	b.scoreMu.Lock()
+	defer b.scoreMu.Unlock()
+
+	s := &b.score
+	errorSample := 0.0
+	if failed {
+		errorSample = 1
+	}
+
+	if s.samples == 0 {
+		s.errorRate = errorSample
+		if !failed {
+			s.latency = latency.Seconds()
+		}
+	} else {
+		s.errorRate += scoreDecay * (errorSample - s.errorRate)
+		if !failed {
+			s.latency += scoreDecay * (latency.Seconds() - s.latency)
+		}
+	}
+	s.samples++
+
+	if b.outcomes != nil {
+		b.outcomes.record(time.Now(), failed)
+	}
+}
+
+// HealthScore returns a score between MinHealthScore and 1 blending the
+// backend's recent error rate and latency. Backends without traffic score 1.
+func (b *Backend) HealthScore() float64 {
+	b.scoreMu.Lock()
+	defer b.scoreMu.Unlock()
+
+	s := b.score
+	latencyFactor := 1 / (1 + s.latency/ScoreLatencyReference.Seconds())
+	score := (1 - s.errorRate) * latencyFactor
+	if score < MinHealthScore {
+		return MinHealthScore
+	}
+	return score
+}
+
+// Latency returns the moving average latency of the backend's recent
+// successful requests that feeds its health score
+func (b *Backend) Latency() time.Duration {
+	b.scoreMu.Lock()
+	defer b.scoreMu.Unlock()
+
+	return time.Duration(b.score.latency * float64(time.Second))
+}
+
+// RecentErrorRate returns the moving average share of the backend's recent
+// requests that failed, as fed into its health score
+func (b *Backend) RecentErrorRate() float64 {
+	b.scoreMu.Lock()
+	defer b.scoreMu.Unlock()
+
+	return b.score.errorRate
+}
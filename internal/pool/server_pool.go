@@ -2,25 +2,175 @@ package pool
 
 import (
 	"fmt"
+	"net"
 	"net/url"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"go-balancer/internal/errors"
 )
 
+// MaxWeight is the largest backend weight accepted. Consistent hashing places
+// virtual nodes in proportion to weight, so an unbounded weight could make a
+// single ring rebuild exhaust memory.
+const MaxWeight = 1000
+
 // Backend represents a single backend server
 type Backend struct {
-	ID      string
-	URL     *url.URL
-	Healthy bool
-	Port    int
+	ID   string
+	URL  *url.URL
+	Port int
+
+	// Backup backends only receive traffic while no primary is available
+	Backup bool
+
+	// Canary backends only receive traffic routed to them explicitly
+	Canary bool
+
+	// Zone is the locality (e.g. availability zone) the backend runs in;
+	// empty when unknown
+	Zone string
+
+	weight            int64       // Relative capacity used by weighted strategies (defaults to 1), updated atomically
+	activeConnections int64       // In-flight proxied requests, updated atomically
+	maxConnections    int64       // In-flight requests the backend takes at once (0 means unlimited), updated atomically
+	backoffUntil      int64       // UnixNano until which the backend asked not to be sent requests, updated atomically
+	healthy           atomic.Bool // False until a health probe succeeds, see IsHealthy
+	draining          atomic.Bool // Whether the backend is draining, see IsDraining
+
+	scoreMu     sync.Mutex
+	score       healthScore      // Recent latency and error rate, see RecordOutcome
+	capacity    capacityEstimate // Recent throughput, see RecordCompletion
+	outcomes    *errorWindow     // Rolling error rate; nil unless an error budget is set
+	errorBudget float64          // Error rate above which the backend takes no new requests
+
+	flapMu      sync.Mutex
+	damping     flapDamping // When the backend is held unhealthy for flapping
+	flaps       []time.Time // When the backend went down within the flap window, oldest first
+	dampedUntil time.Time   // End of the penalty for flapping; probes can't mark it healthy before then
+
+	protocolMu sync.Mutex
+	protocol   string // Detected protocol, see ProtocolHTTP1 and ProtocolHTTP2; empty until detected
+	detecting  bool   // Whether detection has been claimed, see ClaimProtocolDetection
+}
+
+// Weight returns the backend's relative capacity used by weighted strategies
+func (b *Backend) Weight() int {
+	return int(atomic.LoadInt64(&b.weight))
+}
+
+// SetWeight changes the backend's relative capacity; strategies use it from
+// their next pick
+func (b *Backend) SetWeight(weight int) {
+	atomic.StoreInt64(&b.weight, int64(weight))
+}
+
+// IsHealthy reports whether the backend passed its last health check
+func (b *Backend) IsHealthy() bool {
+	return b.healthy.Load()
+}
+
+// IsDraining reports whether the backend is draining: it stays in the pool
+// and keeps its in-flight requests but receives no new ones
+func (b *Backend) IsDraining() bool {
+	return b.draining.Load()
+}
+
+// SetDraining starts or stops draining the backend
+func (b *Backend) SetDraining(draining bool) {
+	b.draining.Store(draining)
+}
+
+// IncrementConnections marks the start of a proxied request
+func (b *Backend) IncrementConnections() {
+	atomic.AddInt64(&b.activeConnections, 1)
+}
+
+// DecrementConnections marks the end of a proxied request
+func (b *Backend) DecrementConnections() {
+	atomic.AddInt64(&b.activeConnections, -1)
+}
+
+// ActiveConnections returns the number of in-flight proxied requests
+func (b *Backend) ActiveConnections() int64 {
+	return atomic.LoadInt64(&b.activeConnections)
+}
+
+// TryIncrementConnections marks the start of a proxied request unless the
+// backend is already at its connection limit, reporting whether it did. Unlike
+// checking Saturated first, it never lets concurrent callers overshoot the
+// limit.
+func (b *Backend) TryIncrementConnections() bool {
+	for {
+		current := atomic.LoadInt64(&b.activeConnections)
+		limit := atomic.LoadInt64(&b.maxConnections)
+		if limit > 0 && current >= limit {
+			return false
+		}
+		if atomic.CompareAndSwapInt64(&b.activeConnections, current, current+1) {
+			return true
+		}
+	}
+}
+
+// Saturated reports whether the backend is handling as many requests as its
+// connection limit allows
+func (b *Backend) Saturated() bool {
+	limit := atomic.LoadInt64(&b.maxConnections)
+	return limit > 0 && b.ActiveConnections() >= limit
+}
+
+// BackOff keeps the backend from receiving new requests until the given
+// time, e.g. when it answered with Retry-After. A later time extends the
+// backoff; an earlier one never shortens it.
+func (b *Backend) BackOff(until time.Time) {
+	next := until.UnixNano()
+	for {
+		current := atomic.LoadInt64(&b.backoffUntil)
+		if next <= current || atomic.CompareAndSwapInt64(&b.backoffUntil, current, next) {
+			return
+		}
+	}
+}
+
+// BackingOff reports whether the backend asked not to be sent requests for now
+func (b *Backend) BackingOff() bool {
+	until := atomic.LoadInt64(&b.backoffUntil)
+	return until != 0 && time.Now().UnixNano() < until
+}
+
+// Available reports whether the backend can take new requests
+func (b *Backend) Available() bool {
+	return b.InRotation() && !b.Saturated()
+}
+
+// InRotation reports whether the backend takes new requests at all, even if
+// it is at its connection limit right now and they have to wait for a slot
+func (b *Backend) InRotation() bool {
+	return b.IsHealthy() && !b.IsDraining() && !b.BackingOff() && !b.OverErrorBudget()
 }
 
 // ServerPool manages a collection of backend servers
 type ServerPool struct {
 	backends []*Backend
 	mutex    sync.RWMutex // RWMutex allows multiple readers OR one writer
+	nextID   int          // Monotonic ID counter so IDs are never reused after removals
+
+	maxBackends int // Upper bound on the number of backends; 0 means unlimited
+
+	ignoreDuplicates bool // Adding a pooled URL again is a no-op rather than an error
+
+	errorBudget       float64       // Error rate above which backends take no new requests; 0 disables
+	errorBudgetWindow time.Duration // Rolling window the error rate is measured over
+
+	flapDamping flapDamping // Holds backends that keep going down unhealthy for a while
+
+	maxConnections int // In-flight requests each backend takes at once; 0 means unlimited
+
+	startHealthy bool // Backends join healthy instead of waiting for their first probe
 }
 
 // NewServerPool creates a new server pool
@@ -30,33 +180,169 @@ func NewServerPool() *ServerPool {
 	}
 }
 
-// AddBackend adds a new backend server to the pool
+// SetMaxBackends caps the number of backends the pool holds; once full,
+// AddBackend fails until a backend is removed. 0 removes the cap.
+func (sp *ServerPool) SetMaxBackends(maxBackends int) {
+	sp.mutex.Lock()
+	defer sp.mutex.Unlock()
+
+	sp.maxBackends = maxBackends
+}
+
+// SetIgnoreDuplicates chooses how adding a URL that is already pooled is
+// handled: ignored, keeping the existing backend, or rejected with
+// ErrDuplicateBackend (the default)
+func (sp *ServerPool) SetIgnoreDuplicates(ignore bool) {
+	sp.mutex.Lock()
+	defer sp.mutex.Unlock()
+
+	sp.ignoreDuplicates = ignore
+}
+
+// SetErrorBudget keeps backends whose share of failed requests over the
+// rolling window exceeds threshold (0-1) from receiving new requests, until
+// enough failures age out of the window. It applies to pooled backends and
+// ones added later; a zero threshold disables it.
+func (sp *ServerPool) SetErrorBudget(threshold float64, window time.Duration) {
+	sp.mutex.Lock()
+	defer sp.mutex.Unlock()
+
+	sp.errorBudget = threshold
+	sp.errorBudgetWindow = window
+	for _, backend := range sp.backends {
+		backend.setErrorBudget(threshold, window)
+	}
+}
+
+// SetMaxConnections caps how many requests each backend handles at once; a
+// backend at its cap is unavailable until one of its requests finishes. It
+// applies to pooled backends and ones added later; 0 removes the cap.
+func (sp *ServerPool) SetMaxConnections(maxConnections int) {
+	sp.mutex.Lock()
+	defer sp.mutex.Unlock()
+
+	sp.maxConnections = maxConnections
+	for _, backend := range sp.backends {
+		atomic.StoreInt64(&backend.maxConnections, int64(maxConnections))
+	}
+}
+
+// SetStartHealthy chooses whether backends join the pool healthy instead of
+// waiting for a health probe to pass, for when nothing probes them. Enabling it
+// also marks pooled backends healthy; from then on their health only changes
+// through SetBackendHealth.
+func (sp *ServerPool) SetStartHealthy(healthy bool) {
+	sp.mutex.Lock()
+	defer sp.mutex.Unlock()
+
+	sp.startHealthy = healthy
+	if healthy {
+		for _, backend := range sp.backends {
+			backend.healthy.Store(true)
+		}
+	}
+}
+
+// AddBackend adds a new backend server to the pool. It fails with ErrPoolFull
+// when the pool already holds the maximum number of backends.
 func (sp *ServerPool) AddBackend(backendURL string) error {
+	_, err := sp.Add(backendURL)
+	return err
+}
+
+// Add adds a new backend server to the pool and returns it. A URL with the
+// same scheme, host and port as a pooled backend is a duplicate: it fails with
+// ErrDuplicateBackend, or returns the existing backend if duplicates are
+// ignored.
+func (sp *ServerPool) Add(backendURL string) (*Backend, error) {
 	sp.mutex.Lock()         // Exclusive lock for writing
 	defer sp.mutex.Unlock() // Always unlock when function exits
 
 	parsedURL, err := url.Parse(backendURL)
 	if err != nil {
-		return errors.NewInvalidBackendError(backendURL, err)
+		return nil, errors.NewInvalidBackendError(backendURL, err)
 	}
 
 	// Validate URL has required components
 	if parsedURL.Scheme == "" {
-		return errors.NewInvalidBackendError(backendURL, fmt.Errorf("missing URL scheme"))
+		return nil, errors.NewInvalidBackendError(backendURL, fmt.Errorf("missing URL scheme"))
 	}
 	if parsedURL.Host == "" {
-		return errors.NewInvalidBackendError(backendURL, fmt.Errorf("missing URL host"))
+		return nil, errors.NewInvalidBackendError(backendURL, fmt.Errorf("missing URL host"))
+	}
+	normalizeURL(parsedURL)
+
+	key := backendKey(parsedURL)
+	for _, existing := range sp.backends {
+		if backendKey(existing.URL) == key {
+			if sp.ignoreDuplicates {
+				return existing, nil
+			}
+			return nil, errors.NewDuplicateBackendError(backendURL, existing.ID)
+		}
 	}
 
+	if sp.maxBackends > 0 && len(sp.backends) >= sp.maxBackends {
+		return nil, errors.NewPoolFullError(backendURL, sp.maxBackends)
+	}
+
+	sp.nextID++
 	backend := &Backend{
-		ID:      fmt.Sprintf("backend-%d", len(sp.backends)+1),
-		URL:     parsedURL,
-		Healthy: true, // Assume healthy initially
-		Port:    getPortFromURL(parsedURL),
+		ID:     fmt.Sprintf("backend-%d", sp.nextID),
+		URL:    parsedURL,
+		Port:   getPortFromURL(parsedURL),
+		weight: 1,
+
+		maxConnections: int64(sp.maxConnections),
+	}
+	backend.healthy.Store(sp.startHealthy) // Unknown until the first successful health probe, unless nothing probes
+	if sp.errorBudget > 0 {
+		backend.setErrorBudget(sp.errorBudget, sp.errorBudgetWindow)
 	}
+	backend.setFlapDamping(sp.flapDamping)
 
 	sp.backends = append(sp.backends, backend)
-	return nil
+	return backend, nil
+}
+
+// NormalizeURL returns the canonical form the pool stores a backend URL in,
+// for matching configured URLs against pooled backends. A URL that doesn't
+// parse is returned unchanged.
+func NormalizeURL(backendURL string) string {
+	parsedURL, err := url.Parse(backendURL)
+	if err != nil {
+		return backendURL
+	}
+	normalizeURL(parsedURL)
+	return parsedURL.String()
+}
+
+// normalizeURL lowercases the scheme and host, drops the scheme's default
+// port and trims trailing slashes, so http://Host:80/ becomes http://host
+func normalizeURL(u *url.URL) {
+	u.Scheme = strings.ToLower(u.Scheme)
+
+	host, port := strings.ToLower(u.Hostname()), u.Port()
+	if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	switch {
+	case port != "":
+		u.Host = net.JoinHostPort(host, port)
+	case strings.Contains(host, ":"):
+		u.Host = "[" + host + "]" // IPv6 literal
+	default:
+		u.Host = host
+	}
+
+	u.Path = strings.TrimRight(u.Path, "/")
+	u.RawPath = strings.TrimRight(u.RawPath, "/")
+}
+
+// backendKey identifies the server a backend URL points at, so URLs differing
+// only in case, an explicit default port or a path count as duplicates
+func backendKey(u *url.URL) string {
+	return u.Scheme + "://" + net.JoinHostPort(strings.ToLower(u.Hostname()), strconv.Itoa(getPortFromURL(u)))
 }
 
 // RemoveBackend removes a backend by ID
@@ -96,6 +382,19 @@ func (sp *ServerPool) GetBackendByIndex(index int) *Backend {
 	return nil
 }
 
+// GetBackendByID returns the backend with the given ID, or nil
+func (sp *ServerPool) GetBackendByID(id string) *Backend {
+	sp.mutex.RLock()
+	defer sp.mutex.RUnlock()
+
+	for _, backend := range sp.backends {
+		if backend.ID == id {
+			return backend
+		}
+	}
+	return nil
+}
+
 // GetHealthyBackendCount returns the number of healthy backends
 func (sp *ServerPool) GetHealthyBackendCount() int {
 	sp.mutex.RLock()
@@ -103,13 +402,42 @@ func (sp *ServerPool) GetHealthyBackendCount() int {
 
 	count := 0
 	for _, backend := range sp.backends {
-		if backend.Healthy {
+		if backend.IsHealthy() {
 			count++
 		}
 	}
 	return count
 }
 
+// GetAvailableBackendCount returns the number of backends that can take new
+// requests (healthy, not draining and not backing off)
+func (sp *ServerPool) GetAvailableBackendCount() int {
+	sp.mutex.RLock()
+	defer sp.mutex.RUnlock()
+
+	count := 0
+	for _, backend := range sp.backends {
+		if backend.Available() {
+			count++
+		}
+	}
+	return count
+}
+
+// HasSaturatedBackend reports whether any backend would take new requests but
+// for being at its connection limit
+func (sp *ServerPool) HasSaturatedBackend() bool {
+	sp.mutex.RLock()
+	defer sp.mutex.RUnlock()
+
+	for _, backend := range sp.backends {
+		if backend.Saturated() && backend.InRotation() {
+			return true
+		}
+	}
+	return false
+}
+
 // GetBackendCount returns total number of backends
 func (sp *ServerPool) GetBackendCount() int {
 	sp.mutex.RLock()
@@ -124,12 +452,157 @@ func (sp *ServerPool) SetBackendHealth(id string, healthy bool) {
 
 	for _, backend := range sp.backends {
 		if backend.ID == id {
-			backend.Healthy = healthy
+			// A damped backend stays down until its penalty ends
+			if healthy && backend.Damped() {
+				break
+			}
+			if backend.healthy.Swap(healthy) && !healthy {
+				backend.recordFlap(time.Now())
+			}
 			break
 		}
 	}
 }
 
+// SetBackendWeight updates the weight of a backend
+func (sp *ServerPool) SetBackendWeight(id string, weight int) bool {
+	sp.mutex.Lock()
+	defer sp.mutex.Unlock()
+
+	for _, backend := range sp.backends {
+		if backend.ID == id {
+			backend.SetWeight(weight)
+			return true
+		}
+	}
+	return false
+}
+
+// SetBackendDraining marks a backend as draining (or not)
+func (sp *ServerPool) SetBackendDraining(id string, draining bool) bool {
+	sp.mutex.Lock()
+	defer sp.mutex.Unlock()
+
+	for _, backend := range sp.backends {
+		if backend.ID == id {
+			backend.SetDraining(draining)
+			return true
+		}
+	}
+	return false
+}
+
+// SetBackendCanary marks a backend as a canary (or not)
+func (sp *ServerPool) SetBackendCanary(id string, canary bool) bool {
+	sp.mutex.Lock()
+	defer sp.mutex.Unlock()
+
+	for _, backend := range sp.backends {
+		if backend.ID == id {
+			backend.Canary = canary
+			return true
+		}
+	}
+	return false
+}
+
+// SetBackendBackup marks a backend as a backup (or a primary)
+func (sp *ServerPool) SetBackendBackup(id string, backup bool) bool {
+	sp.mutex.Lock()
+	defer sp.mutex.Unlock()
+
+	for _, backend := range sp.backends {
+		if backend.ID == id {
+			backend.Backup = backup
+			return true
+		}
+	}
+	return false
+}
+
+// SetBackendZone sets the locality a backend runs in
+func (sp *ServerPool) SetBackendZone(id, zone string) bool {
+	sp.mutex.Lock()
+	defer sp.mutex.Unlock()
+
+	for _, backend := range sp.backends {
+		if backend.ID == id {
+			backend.Zone = zone
+			return true
+		}
+	}
+	return false
+}
+
+// HasAvailablePrimary reports whether any primary backend is in rotation. A
+// primary at its connection limit counts, so requests queue for it rather
+// than fail over to the backups.
+func (sp *ServerPool) HasAvailablePrimary() bool {
+	sp.mutex.RLock()
+	defer sp.mutex.RUnlock()
+
+	for _, backend := range sp.backends {
+		if !backend.Backup && !backend.Canary && backend.InRotation() {
+			return true
+		}
+	}
+	return false
+}
+
+// Tier returns a view of the pool holding only the backup backends, or only
+// the primaries, for strategies to select from; canary backends are in
+// neither. The view shares Backend values with sp, so health and connection
+// changes show up in both; backends must not be added to or removed from the
+// view.
+func (sp *ServerPool) Tier(backup bool) *ServerPool {
+	sp.mutex.RLock()
+	defer sp.mutex.RUnlock()
+
+	view := &ServerPool{backends: make([]*Backend, 0, len(sp.backends))}
+	for _, backend := range sp.backends {
+		if backend.Backup == backup && !backend.Canary {
+			view.backends = append(view.backends, backend)
+		}
+	}
+	return view
+}
+
+// Subset returns a view of the pool holding only the backends with the given
+// URLs, matched in canonical form, in pool order. Like Tier, the view shares
+// Backend values with sp.
+func (sp *ServerPool) Subset(urls []string) *ServerPool {
+	sp.mutex.RLock()
+	defer sp.mutex.RUnlock()
+
+	wanted := make(map[string]bool, len(urls))
+	for _, u := range urls {
+		wanted[NormalizeURL(u)] = true
+	}
+
+	view := &ServerPool{backends: make([]*Backend, 0, len(urls))}
+	for _, backend := range sp.backends {
+		if wanted[backend.URL.String()] {
+			view.backends = append(view.backends, backend)
+		}
+	}
+	return view
+}
+
+// Zone returns a view of the pool holding only the backends in the given
+// zone, in pool order. Like Tier, the view shares Backend values with sp.
+func (sp *ServerPool) Zone(zone string) *ServerPool {
+	sp.mutex.RLock()
+	defer sp.mutex.RUnlock()
+
+	view := &ServerPool{backends: make([]*Backend, 0, len(sp.backends))}
+	for _, backend := range sp.backends {
+		if backend.Zone == zone {
+			view.backends = append(view.backends, backend)
+		}
+	}
+	return view
+}
+
 // Helper function to remove item from slice (cleaner than manual slice manipulation)
 func removeFromSlice(slice []*Backend, index int) []*Backend {
 	if index < 0 || index >= len(slice) {
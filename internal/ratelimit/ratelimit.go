@@ -0,0 +1,104 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// DefaultMaxKeys bounds how many clients are tracked at once
+const DefaultMaxKeys = 10000
+
+// Limiter is a thread-safe set of token buckets keyed by client. Each bucket
+// holds up to burst tokens and refills at rate tokens per second; a request
+// spends one token.
+type Limiter struct {
+	rate    float64
+	burst   float64
+	maxKeys int
+	now     func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter creates a limiter allowing rate requests per second per key with
+// bursts of up to burst requests. A burst below 1 is treated as 1.
+func NewLimiter(rate float64, burst int) *Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &Limiter{
+		rate:    rate,
+		burst:   float64(burst),
+		maxKeys: DefaultMaxKeys,
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Allow spends a token from key's bucket. When the bucket is empty it reports
+// false along with how long until a token is available.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= l.maxKeys {
+			l.evictFull(now)
+		}
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	l.refill(b, now)
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	return false, wait
+}
+
+// refill adds the tokens accrued since the bucket was last touched
+func (l *Limiter) refill(b *bucket, now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(l.burst, b.tokens+elapsed.Seconds()*l.rate)
+		b.last = now
+	}
+}
+
+// evictFull drops buckets that have refilled completely; forgetting them is
+// indistinguishable from keeping them. If every client is still limited the
+// map is cleared rather than growing without bound.
+func (l *Limiter) evictFull(now time.Time) {
+	for key, b := range l.buckets {
+		l.refill(b, now)
+		if b.tokens >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+	if len(l.buckets) >= l.maxKeys {
+		l.buckets = make(map[string]*bucket)
+	}
+}
+
+// RetryAfterSeconds renders a wait as a Retry-After value, rounding up so
+// clients that honor it don't retry before a token is available
+func RetryAfterSeconds(wait time.Duration) int {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	return seconds
+}
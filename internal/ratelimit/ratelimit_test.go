@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiterAllowsBurstThenLimits(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	l := NewLimiter(0.5, 2)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Allow("client"); !ok {
+			t.Errorf("Expected request %d within burst to be allowed", i+1)
+		}
+	}
+
+	ok, wait := l.Allow("client")
+	if ok {
+		t.Errorf("Expected request beyond burst to be limited")
+	}
+	if wait != 2*time.Second {
+		t.Errorf("Expected wait of 2s for a 0.5/s refill, got %s", wait)
+	}
+
+	// Other clients have their own bucket
+	if ok, _ := l.Allow("other"); !ok {
+		t.Errorf("Expected a different key to be allowed")
+	}
+
+	// Half a token later the wait shrinks accordingly
+	now = now.Add(time.Second)
+	if ok, wait := l.Allow("client"); ok || wait != time.Second {
+		t.Errorf("Expected limited with 1s wait, got allowed=%v wait=%s", ok, wait)
+	}
+
+	now = now.Add(time.Second)
+	if ok, _ := l.Allow("client"); !ok {
+		t.Errorf("Expected request to be allowed after refill")
+	}
+}
+
+func TestLimiterEvictsFullBuckets(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	l := NewLimiter(1, 1)
+	l.now = func() time.Time { return now }
+	l.maxKeys = 2
+
+	l.Allow("a")
+	l.Allow("b")
+
+	// Both buckets refill, so adding a third key evicts them
+	now = now.Add(2 * time.Second)
+	l.Allow("c")
+
+	if len(l.buckets) != 1 {
+		t.Errorf("Expected 1 tracked key after eviction, got %d", len(l.buckets))
+	}
+}
+
+func TestRetryAfterSeconds(t *testing.T) {
+	tests := []struct {
+		wait     time.Duration
+		expected int
+	}{
+		{0, 1},
+		{100 * time.Millisecond, 1},
+		{time.Second, 1},
+		{1500 * time.Millisecond, 2},
+		{10 * time.Second, 10},
+	}
+
+	for _, tt := range tests {
+		if got := RetryAfterSeconds(tt.wait); got != tt.expected {
+			t.Errorf("RetryAfterSeconds(%s): expected %d, got %d", tt.wait, tt.expected, got)
+		}
+	}
+}
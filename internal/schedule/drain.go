@@ -0,0 +1,148 @@
+package schedule
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"go-balancer/internal/pool"
+)
+
+// DefaultCheckInterval is how often windows are evaluated when unset
+const DefaultCheckInterval = time.Second
+
+// DrainWindow is a period during which a backend is drained for maintenance
+type DrainWindow struct {
+	Backend string    // Backend URL as configured
+	Start   time.Time // Draining begins at Start (inclusive)
+	End     time.Time // and is lifted at End (exclusive)
+}
+
+// Active reports whether t falls inside the window
+func (w DrainWindow) Active(t time.Time) bool {
+	return !t.Before(w.Start) && t.Before(w.End)
+}
+
+// ParseDrainWindow parses "url=start/end" where start and end are RFC 3339
+// timestamps, e.g. "http://backend1:8080=2024-06-01T02:00:00Z/2024-06-01T03:00:00Z"
+func ParseDrainWindow(spec string) (DrainWindow, error) {
+	idx := strings.LastIndex(spec, "=")
+	if idx < 0 {
+		return DrainWindow{}, fmt.Errorf("drain window must be in the form url=start/end: %q", spec)
+	}
+	backend := strings.TrimSpace(spec[:idx])
+	if backend == "" {
+		return DrainWindow{}, fmt.Errorf("drain window is missing a backend: %q", spec)
+	}
+
+	startStr, endStr, found := strings.Cut(spec[idx+1:], "/")
+	if !found {
+		return DrainWindow{}, fmt.Errorf("drain window must be in the form url=start/end: %q", spec)
+	}
+	start, err := time.Parse(time.RFC3339, strings.TrimSpace(startStr))
+	if err != nil {
+		return DrainWindow{}, fmt.Errorf("invalid drain window start in %q: %w", spec, err)
+	}
+	end, err := time.Parse(time.RFC3339, strings.TrimSpace(endStr))
+	if err != nil {
+		return DrainWindow{}, fmt.Errorf("invalid drain window end in %q: %w", spec, err)
+	}
+	if !end.After(start) {
+		return DrainWindow{}, fmt.Errorf("drain window end must be after start: %q", spec)
+	}
+
+	return DrainWindow{Backend: backend, Start: start, End: end}, nil
+}
+
+// DrainScheduler drains backends while their maintenance windows are open and
+// restores them afterwards. It only restores backends it drained itself.
+type DrainScheduler struct {
+	serverPool *pool.ServerPool
+	windows    []DrainWindow
+	interval   time.Duration
+	now        func() time.Time
+
+	mu       sync.Mutex
+	drained  map[string]bool // Backend URLs currently drained by the scheduler
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// NewDrainScheduler creates a scheduler that evaluates windows every interval
+func NewDrainScheduler(serverPool *pool.ServerPool, windows []DrainWindow, interval time.Duration) *DrainScheduler {
+	if interval <= 0 {
+		interval = DefaultCheckInterval
+	}
+
+	return &DrainScheduler{
+		serverPool: serverPool,
+		windows:    windows,
+		interval:   interval,
+		now:        time.Now,
+		drained:    make(map[string]bool),
+		stopCh:     make(chan struct{}),
+	}
+}
+
+// Start begins evaluating windows, applying the current state immediately
+func (s *DrainScheduler) Start() {
+	go s.scheduleLoop()
+	log.Printf("Drain scheduler started with %d window(s)", len(s.windows))
+}
+
+// Stop terminates scheduling. Backends drained at that point stay drained.
+func (s *DrainScheduler) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stopCh)
+	})
+}
+
+// scheduleLoop applies the windows at regular intervals
+func (s *DrainScheduler) scheduleLoop() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	s.apply()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.apply()
+		case <-s.stopCh:
+			log.Println("Drain scheduler stopped")
+			return
+		}
+	}
+}
+
+// apply drains backends with an open window and restores those whose windows
+// have all closed
+func (s *DrainScheduler) apply() {
+	now := s.now()
+
+	want := make(map[string]bool)
+	for _, window := range s.windows {
+		if window.Active(now) {
+			want[pool.NormalizeURL(window.Backend)] = true
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, backend := range s.serverPool.GetBackends() {
+		url := backend.URL.String()
+		switch {
+		case want[url] && !s.drained[url]:
+			backend.SetDraining(true)
+			s.drained[url] = true
+			log.Printf("Backend %s (%s) draining for scheduled maintenance", backend.ID, url)
+		case !want[url] && s.drained[url]:
+			backend.SetDraining(false)
+			delete(s.drained, url)
+			log.Printf("Backend %s (%s) restored after scheduled maintenance", backend.ID, url)
+		}
+	}
+}
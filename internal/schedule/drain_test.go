@@ -0,0 +1,169 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"go-balancer/internal/pool"
+	"go-balancer/internal/strategy"
+)
+
+func newTestPool(t *testing.T, urls ...string) *pool.ServerPool {
+	t.Helper()
+
+	serverPool := pool.NewServerPool()
+	for _, url := range urls {
+		if err := serverPool.AddBackend(url); err != nil {
+			t.Fatalf("Failed to add backend: %v", err)
+		}
+	}
+	for _, backend := range serverPool.GetBackends() {
+		serverPool.SetBackendHealth(backend.ID, true)
+	}
+	return serverPool
+}
+
+// waitForAvailable polls until the pool has the expected number of available backends
+func waitForAvailable(t *testing.T, serverPool *pool.ServerPool, expected int, timeout time.Duration) {
+	t.Helper()
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if serverPool.GetAvailableBackendCount() == expected {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("Expected %d available backends, got %d", expected, serverPool.GetAvailableBackendCount())
+}
+
+func TestParseDrainWindow(t *testing.T) {
+	tests := []struct {
+		name        string
+		spec        string
+		expectError bool
+	}{
+		{"Valid", "http://backend1:8080=2024-06-01T02:00:00Z/2024-06-01T03:00:00Z", false},
+		{"With offset", "http://backend1:8080=2024-06-01T02:00:00+02:00/2024-06-01T03:00:00+02:00", false},
+		{"Missing separator", "http://backend1:8080", true},
+		{"Missing backend", "=2024-06-01T02:00:00Z/2024-06-01T03:00:00Z", true},
+		{"Missing end", "http://backend1:8080=2024-06-01T02:00:00Z", true},
+		{"Bad timestamp", "http://backend1:8080=tonight/tomorrow", true},
+		{"End before start", "http://backend1:8080=2024-06-01T03:00:00Z/2024-06-01T02:00:00Z", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			window, err := ParseDrainWindow(tt.spec)
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("Expected error for %q", tt.spec)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if window.Backend != "http://backend1:8080" {
+				t.Errorf("Expected backend http://backend1:8080, got %q", window.Backend)
+			}
+			if window.End.Sub(window.Start) != time.Hour {
+				t.Errorf("Expected a one hour window, got %s", window.End.Sub(window.Start))
+			}
+		})
+	}
+}
+
+func TestDrainSchedulerApply(t *testing.T) {
+	serverPool := newTestPool(t, "http://backend1:8080", "http://backend2:8080")
+
+	base := time.Date(2024, 6, 1, 2, 0, 0, 0, time.UTC)
+	windows := []DrainWindow{
+		{Backend: "http://backend1:8080", Start: base, End: base.Add(time.Hour)},
+	}
+	s := NewDrainScheduler(serverPool, windows, time.Minute)
+
+	tests := []struct {
+		name              string
+		now               time.Time
+		expectedDraining  bool
+		expectedAvailable int
+	}{
+		{"Before window", base.Add(-time.Minute), false, 2},
+		{"Window opens", base, true, 1},
+		{"Inside window", base.Add(30 * time.Minute), true, 1},
+		{"Window closes", base.Add(time.Hour), false, 2},
+	}
+
+	for _, tt := range tests {
+		s.now = func() time.Time { return tt.now }
+		s.apply()
+
+		backend := serverPool.GetBackendByIndex(0)
+		if backend.IsDraining() != tt.expectedDraining {
+			t.Errorf("%s: expected draining=%v, got %v", tt.name, tt.expectedDraining, backend.IsDraining())
+		}
+		if available := serverPool.GetAvailableBackendCount(); available != tt.expectedAvailable {
+			t.Errorf("%s: expected %d available backends, got %d", tt.name, tt.expectedAvailable, available)
+		}
+	}
+}
+
+func TestDrainSchedulerLeavesOtherDrainsAlone(t *testing.T) {
+	serverPool := newTestPool(t, "http://backend1:8080")
+	backend := serverPool.GetBackendByIndex(0)
+	serverPool.SetBackendDraining(backend.ID, true)
+
+	// No open window, but the scheduler didn't drain this backend so it must not restore it
+	s := NewDrainScheduler(serverPool, nil, time.Minute)
+	s.apply()
+
+	if !backend.IsDraining() {
+		t.Errorf("Expected backend drained elsewhere to stay draining")
+	}
+}
+
+func TestDrainSchedulerShortWindow(t *testing.T) {
+	serverPool := newTestPool(t, "http://backend1:8080", "http://backend2:8080")
+
+	start := time.Now().Add(50 * time.Millisecond)
+	windows := []DrainWindow{
+		{Backend: "http://backend2:8080", Start: start, End: start.Add(100 * time.Millisecond)},
+	}
+	s := NewDrainScheduler(serverPool, windows, 5*time.Millisecond)
+	s.Start()
+	defer s.Stop()
+
+	waitForAvailable(t, serverPool, 1, time.Second)
+	if backend := serverPool.GetBackendByIndex(1); !backend.IsDraining() {
+		t.Errorf("Expected backend2 to be draining during its window")
+	}
+
+	waitForAvailable(t, serverPool, 2, time.Second)
+}
+
+func TestDrainSchedulerWhileSelecting(t *testing.T) {
+	serverPool := newTestPool(t, "http://backend1:8080", "http://backend2:8080")
+
+	// Short back-to-back windows keep draining and restoring backend2
+	var windows []DrainWindow
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		windows = append(windows, DrainWindow{
+			Backend: "http://backend2:8080",
+			Start:   start.Add(time.Duration(2*i) * 10 * time.Millisecond),
+			End:     start.Add(time.Duration(2*i+1) * 10 * time.Millisecond),
+		})
+	}
+	s := NewDrainScheduler(serverPool, windows, time.Millisecond)
+	s.Start()
+	defer s.Stop()
+
+	// Run with -race: strategies read draining state while the scheduler changes it
+	roundRobin := strategy.NewRoundRobinStrategy()
+	for time.Since(start) < 200*time.Millisecond {
+		if backend := roundRobin.NextBackend(serverPool); backend == nil {
+			t.Fatalf("Expected backend1 to stay available")
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package strategy
+
+import (
+	"math/rand"
+	"sync"
+
+	"go-balancer/internal/pool"
+)
+
+// AdaptiveWeightStrategy picks a healthy backend at random with probability
+// proportional to its adaptive weight: its configured weight scaled by how
+// many requests per second it has been seen to complete. Backends that drain
+// their connections faster get more traffic without weights being tuned by
+// hand. Backends with no completed requests yet are given the average weight
+// of the others so they get enough traffic to be measured.
+type AdaptiveWeightStrategy struct {
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// NewAdaptiveWeightStrategy creates an adaptive-weight strategy whose choices
+// are drawn from a generator seeded with seed
+func NewAdaptiveWeightStrategy(seed int64) *AdaptiveWeightStrategy {
+	return &AdaptiveWeightStrategy{rng: rand.New(rand.NewSource(seed))}
+}
+
+// NextBackend returns a healthy backend chosen with probability
+// adaptiveWeight/total
+func (aw *AdaptiveWeightStrategy) NextBackend(serverPool *pool.ServerPool) *pool.Backend {
+	var healthy []*pool.Backend
+	var weights []float64
+	measured, measuredTotal := 0, 0.0
+
+	for _, backend := range serverPool.GetBackends() {
+		if !backend.Available() {
+			continue
+		}
+		weight := backend.AdaptiveWeight()
+		if weight > 0 {
+			measured++
+			measuredTotal += weight
+		}
+		healthy = append(healthy, backend)
+		weights = append(weights, weight)
+	}
+
+	if len(healthy) == 0 {
+		return nil
+	}
+
+	// Unmeasured backends get the average, or an equal share if none are measured
+	fill := 1.0
+	if measured > 0 {
+		fill = measuredTotal / float64(measured)
+	}
+	total := 0.0
+	cumulative := make([]float64, len(weights))
+	for i, weight := range weights {
+		if weight <= 0 {
+			weight = fill
+		}
+		total += weight
+		cumulative[i] = total
+	}
+
+	aw.mu.Lock()
+	pick := aw.rng.Float64() * total
+	aw.mu.Unlock()
+
+	for i, bound := range cumulative {
+		if pick < bound {
+			return healthy[i]
+		}
+	}
+	return healthy[len(healthy)-1]
+}
+
+// Name returns the strategy name
+func (aw *AdaptiveWeightStrategy) Name() string {
+	return AdaptiveWeight
+}
@@ -0,0 +1,66 @@
+package strategy
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAdaptiveWeightPrefersFasterBackend(t *testing.T) {
+	serverPool := newTestPool(t, []int{1, 1}, []int{0, 0})
+	slow := serverPool.GetBackendByIndex(0)
+	fast := serverPool.GetBackendByIndex(1)
+
+	for i := 0; i < 50; i++ {
+		slow.RecordCompletion(200*time.Millisecond, 4)
+		fast.RecordCompletion(20*time.Millisecond, 4)
+	}
+
+	if slow.AdaptiveWeight() >= fast.AdaptiveWeight() {
+		t.Fatalf("Expected slow backend to weigh less than fast backend, got %.1f and %.1f", slow.AdaptiveWeight(), fast.AdaptiveWeight())
+	}
+
+	aw := NewAdaptiveWeightStrategy(42)
+	counts := make(map[string]int)
+	for i := 0; i < 10000; i++ {
+		counts[aw.NextBackend(serverPool).ID]++
+	}
+	// A tenfold capacity difference should give roughly a tenfold traffic split
+	if share := float64(counts[fast.ID]) / 10000; share < 0.85 {
+		t.Errorf("Expected fast backend to get over 85%% of traffic, got %.3f", share)
+	}
+}
+
+func TestAdaptiveWeightScalesConfiguredWeight(t *testing.T) {
+	serverPool := newTestPool(t, []int{1, 3}, []int{0, 0})
+	light := serverPool.GetBackendByIndex(0)
+	heavy := serverPool.GetBackendByIndex(1)
+
+	light.RecordCompletion(10*time.Millisecond, 1)
+	heavy.RecordCompletion(10*time.Millisecond, 1)
+
+	if got, want := heavy.AdaptiveWeight(), 3*light.AdaptiveWeight(); got != want {
+		t.Errorf("Expected adaptive weight %.1f, got %.1f", want, got)
+	}
+}
+
+func TestAdaptiveWeightUnmeasuredBackends(t *testing.T) {
+	serverPool := newTestPool(t, []int{1, 1}, []int{0, 0})
+	measured := serverPool.GetBackendByIndex(0)
+	fresh := serverPool.GetBackendByIndex(1)
+
+	if w := fresh.AdaptiveWeight(); w != 0 {
+		t.Errorf("Expected unmeasured backend to have adaptive weight 0, got %.1f", w)
+	}
+
+	measured.RecordCompletion(10*time.Millisecond, 1)
+
+	// The fresh backend is given the average weight, so traffic splits evenly
+	aw := NewAdaptiveWeightStrategy(42)
+	counts := make(map[string]int)
+	for i := 0; i < 10000; i++ {
+		counts[aw.NextBackend(serverPool).ID]++
+	}
+	if share := float64(counts[fresh.ID]) / 10000; share < 0.45 || share > 0.55 {
+		t.Errorf("Expected unmeasured backend to get about half the traffic, got %.3f", share)
+	}
+}
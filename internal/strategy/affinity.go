@@ -0,0 +1,61 @@
+package strategy
+
+import (
+	"net/http"
+
+	"go-balancer/internal/pool"
+)
+
+// RequestStrategy is implemented by strategies that need the request itself
+// to select a backend, not just a key derived from it
+type RequestStrategy interface {
+	LoadBalancingStrategy
+	NextBackendForRequest(serverPool *pool.ServerPool, r *http.Request) *pool.Backend
+}
+
+// AffinityStrategy sends every request carrying the same value of a header
+// (a tenant ID, say) to the same backend by hashing the value onto a
+// consistent-hash ring. Requests without the header are handled by the
+// fallback strategy, so it wraps whichever strategy is configured.
+type AffinityStrategy struct {
+	header      string
+	ring        *ConsistentHashStrategy
+	fallback    LoadBalancingStrategy
+	fallbackKey func(*http.Request) string // Key for a keyed fallback
+}
+
+// NewAffinityStrategy creates a strategy keyed by header that defers to
+// fallback for requests without it. fallbackKey supplies the key when the
+// fallback is a KeyedStrategy.
+func NewAffinityStrategy(header string, fallback LoadBalancingStrategy, fallbackKey func(*http.Request) string) *AffinityStrategy {
+	return &AffinityStrategy{
+		header:      header,
+		ring:        NewConsistentHashStrategy(DefaultVirtualNodes),
+		fallback:    fallback,
+		fallbackKey: fallbackKey,
+	}
+}
+
+// NextBackend selects with the fallback strategy; there is no request to
+// take an affinity value from
+func (a *AffinityStrategy) NextBackend(serverPool *pool.ServerPool) *pool.Backend {
+	return a.fallback.NextBackend(serverPool)
+}
+
+// NextBackendForRequest returns the backend owning the request's header value,
+// or the fallback strategy's choice when the header is absent
+func (a *AffinityStrategy) NextBackendForRequest(serverPool *pool.ServerPool, r *http.Request) *pool.Backend {
+	if value := r.Header.Get(a.header); value != "" {
+		return a.ring.NextBackendForKey(serverPool, value)
+	}
+
+	if keyed, ok := a.fallback.(KeyedStrategy); ok && a.fallbackKey != nil {
+		return keyed.NextBackendForKey(serverPool, a.fallbackKey(r))
+	}
+	return a.fallback.NextBackend(serverPool)
+}
+
+// Name returns the fallback strategy's name, which is the configured one
+func (a *AffinityStrategy) Name() string {
+	return a.fallback.Name()
+}
@@ -0,0 +1,89 @@
+package strategy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTenantRequest(tenant string) *http.Request {
+	req := httptest.NewRequest("GET", "/api", nil)
+	if tenant != "" {
+		req.Header.Set("X-Tenant-ID", tenant)
+	}
+	return req
+}
+
+func TestAffinitySameHeaderSameBackend(t *testing.T) {
+	serverPool := newTestPool(t, []int{1, 1, 1, 1}, []int{0, 0, 0, 0})
+	affinity := NewAffinityStrategy("X-Tenant-ID", NewRoundRobinStrategy(), nil)
+
+	used := make(map[string]bool)
+	for tenant := 0; tenant < 50; tenant++ {
+		name := fmt.Sprintf("tenant-%d", tenant)
+		first := affinity.NextBackendForRequest(serverPool, newTenantRequest(name))
+		if first == nil {
+			t.Fatalf("Expected a backend for %s, got nil", name)
+		}
+		used[first.ID] = true
+
+		for i := 0; i < 10; i++ {
+			if got := affinity.NextBackendForRequest(serverPool, newTenantRequest(name)); got != first {
+				t.Fatalf("Expected %s to stay on %s, got %s", name, first.ID, got.ID)
+			}
+		}
+	}
+
+	if len(used) < 2 {
+		t.Errorf("Expected tenants to spread across backends, all went to %v", used)
+	}
+}
+
+func TestAffinityMissingHeaderFallsBack(t *testing.T) {
+	serverPool := newTestPool(t, []int{1, 1, 1}, []int{0, 0, 0})
+	affinity := NewAffinityStrategy("X-Tenant-ID", NewRoundRobinStrategy(), nil)
+
+	// Without the header requests follow the fallback's round-robin order
+	seen := make(map[string]int)
+	for i := 0; i < 9; i++ {
+		backend := affinity.NextBackendForRequest(serverPool, newTenantRequest(""))
+		if backend == nil {
+			t.Fatalf("Expected a backend, got nil")
+		}
+		seen[backend.ID]++
+	}
+	for id, count := range seen {
+		if count != 3 {
+			t.Errorf("Expected round-robin to send 3 requests to %s, got %d", id, count)
+		}
+	}
+	if len(seen) != 3 {
+		t.Errorf("Expected all 3 backends to be used, got %d", len(seen))
+	}
+
+	if affinity.Name() != RoundRobin {
+		t.Errorf("Expected name of the fallback %s, got %s", RoundRobin, affinity.Name())
+	}
+}
+
+func TestAffinityKeyedFallback(t *testing.T) {
+	serverPool := newTestPool(t, []int{1, 1, 1}, []int{0, 0, 0})
+
+	var keys []string
+	fallbackKey := func(r *http.Request) string {
+		keys = append(keys, r.RemoteAddr)
+		return r.RemoteAddr
+	}
+	affinity := NewAffinityStrategy("X-Tenant-ID", NewConsistentHashStrategy(DefaultVirtualNodes), fallbackKey)
+
+	affinity.NextBackendForRequest(serverPool, newTenantRequest("tenant-1"))
+	if len(keys) != 0 {
+		t.Errorf("Expected the fallback key to be unused with the header present, got %v", keys)
+	}
+
+	affinity.NextBackendForRequest(serverPool, newTenantRequest(""))
+	if len(keys) != 1 {
+		t.Errorf("Expected the fallback key to be used once without the header, got %v", keys)
+	}
+}
@@ -0,0 +1,111 @@
+package strategy
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+
+	"go-balancer/internal/pool"
+)
+
+// Baseline (go1.27, linux/amd64, GOMAXPROCS=1; ns/op, RunParallel):
+//
+//	                                        size=3  size=10  size=100
+//	round-robin/all-healthy                     70       72       127
+//	round-robin/half-healthy                   136       98       142
+//	round-robin/one-healthy                    130      345      3522
+//	weighted-least-connections/all-healthy      65      100       683
+//	consistent-hash/all-healthy                444     1782     13563
+//	consistent-hash/one-healthy                207      230       698
+//
+// Every selection takes the pool's read lock at least once, and round-robin
+// takes it once per probe (GetBackendCount, GetAvailableBackendCount, then
+// GetBackendByIndex per candidate). With few healthy backends in a large pool
+// the probes dominate. Consistent hash rebuilds its membership signature on
+// every call, so it scales with pool size even when the ring is unchanged.
+// These are the costs a snapshot-based pool (one atomic load of an immutable
+// backend slice per selection) would remove; compare against these numbers
+// when changing the pool.
+
+// newBenchPool builds a pool of size backends where only the first healthy are healthy
+func newBenchPool(b *testing.B, size, healthy int) *pool.ServerPool {
+	b.Helper()
+
+	serverPool := pool.NewServerPool()
+	for i := 0; i < size; i++ {
+		if err := serverPool.AddBackend("http://backend" + strconv.Itoa(i) + ":8080"); err != nil {
+			b.Fatalf("Failed to add backend: %v", err)
+		}
+	}
+	for i, backend := range serverPool.GetBackends() {
+		serverPool.SetBackendHealth(backend.ID, i < healthy)
+	}
+	return serverPool
+}
+
+// healthMixes returns the healthy counts benchmarked for a pool size
+func healthMixes(size int) []struct {
+	name    string
+	healthy int
+} {
+	return []struct {
+		name    string
+		healthy int
+	}{
+		{"all-healthy", size},
+		{"half-healthy", size / 2},
+		{"one-healthy", 1},
+	}
+}
+
+func BenchmarkNextBackend(b *testing.B) {
+	for _, name := range []string{RoundRobin, WeightedLeastConnections, ConsistentHash} {
+		for _, size := range []int{3, 10, 100} {
+			for _, mix := range healthMixes(size) {
+				b.Run(fmt.Sprintf("%s/size=%d/%s", name, size, mix.name), func(b *testing.B) {
+					serverPool := newBenchPool(b, size, mix.healthy)
+					s, err := NewStrategy(name)
+					if err != nil {
+						b.Fatalf("Failed to create strategy: %v", err)
+					}
+
+					b.ReportAllocs()
+					b.ResetTimer()
+					b.RunParallel(func(pb *testing.PB) {
+						for pb.Next() {
+							if s.NextBackend(serverPool) == nil {
+								b.Fatal("Expected a backend")
+							}
+						}
+					})
+				})
+			}
+		}
+	}
+}
+
+func BenchmarkConsistentHashNextBackendForKey(b *testing.B) {
+	for _, size := range []int{3, 10, 100} {
+		b.Run(fmt.Sprintf("size=%d", size), func(b *testing.B) {
+			serverPool := newBenchPool(b, size, size)
+			ch := NewConsistentHashStrategy(DefaultVirtualNodes)
+
+			keys := make([]string, 1024)
+			for i := range keys {
+				keys[i] = "client-" + strconv.Itoa(i)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					if ch.NextBackendForKey(serverPool, keys[i%len(keys)]) == nil {
+						b.Fatal("Expected a backend")
+					}
+					i++
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package strategy
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go-balancer/internal/pool"
+)
+
+// DefaultVirtualNodes is the number of ring points per unit of backend weight
+const DefaultVirtualNodes = 100
+
+// KeyedStrategy is implemented by strategies that select a backend from a
+// request-derived key (client IP, session header, ...) rather than from
+// pool state alone
+type KeyedStrategy interface {
+	LoadBalancingStrategy
+	NextBackendForKey(serverPool *pool.ServerPool, key string) *pool.Backend
+}
+
+// ConsistentHashStrategy maps keys onto a hash ring of healthy backends so
+// that membership changes only remap the keys owned by the changed backend
+type ConsistentHashStrategy struct {
+	virtualNodes int
+
+	mu        sync.Mutex
+	signature string // healthy membership the current ring was built from
+	ring      []ringPoint
+}
+
+type ringPoint struct {
+	hash    uint32
+	backend *pool.Backend
+}
+
+// NewConsistentHashStrategy creates a consistent-hash strategy with the given
+// number of virtual nodes per unit of weight
+func NewConsistentHashStrategy(virtualNodes int) *ConsistentHashStrategy {
+	if virtualNodes <= 0 {
+		virtualNodes = DefaultVirtualNodes
+	}
+	return &ConsistentHashStrategy{virtualNodes: virtualNodes}
+}
+
+// NextBackend selects a backend for an empty key. Callers with a request
+// should use NextBackendForKey.
+func (ch *ConsistentHashStrategy) NextBackend(serverPool *pool.ServerPool) *pool.Backend {
+	return ch.NextBackendForKey(serverPool, "")
+}
+
+// NextBackendForKey returns the healthy backend owning key on the ring
+func (ch *ConsistentHashStrategy) NextBackendForKey(serverPool *pool.ServerPool, key string) *pool.Backend {
+	ring := ch.currentRing(serverPool)
+	if len(ring) == 0 {
+		return nil
+	}
+
+	h := hashKey(key)
+	idx := sort.Search(len(ring), func(i int) bool { return ring[i].hash >= h })
+	if idx == len(ring) {
+		idx = 0 // wrap around
+	}
+	return ring[idx].backend
+}
+
+// Name returns the strategy name
+func (ch *ConsistentHashStrategy) Name() string {
+	return ConsistentHash
+}
+
+// currentRing returns the ring for the pool's healthy backends, rebuilding it
+// only when membership has changed since the last call
+func (ch *ConsistentHashStrategy) currentRing(serverPool *pool.ServerPool) []ringPoint {
+	var healthy []*pool.Backend
+	var sig strings.Builder
+	for _, backend := range serverPool.GetBackends() {
+		if backend.Available() {
+			healthy = append(healthy, backend)
+			sig.WriteString(backend.URL.String())
+			sig.WriteByte('*')
+			sig.WriteString(strconv.Itoa(backend.Weight()))
+			sig.WriteByte(',')
+		}
+	}
+
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	if signature := sig.String(); signature != ch.signature || ch.ring == nil {
+		ch.ring = ch.buildRing(healthy)
+		ch.signature = signature
+	}
+	return ch.ring
+}
+
+// buildRing places virtualNodes*weight points per backend on the ring. Points
+// are derived from the backend URL so they survive ID reassignment.
+func (ch *ConsistentHashStrategy) buildRing(backends []*pool.Backend) []ringPoint {
+	ring := make([]ringPoint, 0, len(backends)*ch.virtualNodes)
+	for _, backend := range backends {
+		weight := backend.Weight()
+		if weight <= 0 {
+			weight = 1
+		}
+		base := backend.URL.String()
+		for i := 0; i < ch.virtualNodes*weight; i++ {
+			ring = append(ring, ringPoint{
+				hash:    hashKey(base + "#" + strconv.Itoa(i)),
+				backend: backend,
+			})
+		}
+	}
+
+	sort.Slice(ring, func(i, j int) bool { return ring[i].hash < ring[j].hash })
+	return ring
+}
+
+// hashKey hashes a string onto the ring. FNV alone clusters badly on keys
+// that differ only in a trailing counter, so the result is run through the
+// murmur3 finalizer to spread it out.
+func hashKey(key string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	x := h.Sum32()
+
+	x ^= x >> 16
+	x *= 0x85ebca6b
+	x ^= x >> 13
+	x *= 0xc2b2ae35
+	x ^= x >> 16
+	return x
+}
@@ -0,0 +1,133 @@
+package strategy
+
+import (
+	"fmt"
+	"testing"
+
+	"go-balancer/internal/pool"
+)
+
+const hashTestKeys = 10000
+
+func assignKeys(ch *ConsistentHashStrategy, serverPool *pool.ServerPool) map[string]string {
+	assignments := make(map[string]string, hashTestKeys)
+	for i := 0; i < hashTestKeys; i++ {
+		key := fmt.Sprintf("client-%d", i)
+		backend := ch.NextBackendForKey(serverPool, key)
+		if backend == nil {
+			return nil
+		}
+		assignments[key] = backend.URL.String()
+	}
+	return assignments
+}
+
+func newHashTestPool(t *testing.T, count int) *pool.ServerPool {
+	t.Helper()
+
+	serverPool := pool.NewServerPool()
+	for i := 0; i < count; i++ {
+		if err := serverPool.AddBackend(fmt.Sprintf("http://10.0.0.%d:8080", i+1)); err != nil {
+			t.Fatalf("Failed to add backend: %v", err)
+		}
+		serverPool.SetBackendHealth(serverPool.GetBackendByIndex(i).ID, true)
+	}
+	return serverPool
+}
+
+func TestConsistentHashStableSelection(t *testing.T) {
+	serverPool := newHashTestPool(t, 3)
+	ch := NewConsistentHashStrategy(DefaultVirtualNodes)
+
+	first := ch.NextBackendForKey(serverPool, "203.0.113.7")
+	for i := 0; i < 10; i++ {
+		if backend := ch.NextBackendForKey(serverPool, "203.0.113.7"); backend != first {
+			t.Fatalf("Expected the same key to map to the same backend")
+		}
+	}
+}
+
+func TestConsistentHashMinimalRemapOnAdd(t *testing.T) {
+	serverPool := newHashTestPool(t, 4)
+	ch := NewConsistentHashStrategy(DefaultVirtualNodes)
+	before := assignKeys(ch, serverPool)
+
+	newURL := "http://10.0.0.99:8080"
+	if err := serverPool.AddBackend(newURL); err != nil {
+		t.Fatalf("Failed to add backend: %v", err)
+	}
+	serverPool.SetBackendHealth(serverPool.GetBackendByIndex(4).ID, true)
+	after := assignKeys(ch, serverPool)
+
+	moved := 0
+	for key, owner := range before {
+		if after[key] != owner {
+			moved++
+			if after[key] != newURL {
+				t.Errorf("Key %s moved between existing backends (%s -> %s)", key, owner, after[key])
+			}
+		}
+	}
+
+	// Ideally 1/5 of keys move to the new backend; allow for ring variance
+	if fraction := float64(moved) / hashTestKeys; fraction < 0.10 || fraction > 0.30 {
+		t.Errorf("Expected roughly 20%% of keys to move, got %.1f%%", fraction*100)
+	}
+}
+
+func TestConsistentHashMinimalRemapOnRemove(t *testing.T) {
+	serverPool := newHashTestPool(t, 4)
+	ch := NewConsistentHashStrategy(DefaultVirtualNodes)
+	before := assignKeys(ch, serverPool)
+
+	removed := serverPool.GetBackendByIndex(1)
+	serverPool.RemoveBackend(removed.ID)
+	after := assignKeys(ch, serverPool)
+
+	for key, owner := range before {
+		if owner != removed.URL.String() && after[key] != owner {
+			t.Errorf("Key %s on surviving backend %s was remapped to %s", key, owner, after[key])
+		}
+		if after[key] == removed.URL.String() {
+			t.Errorf("Key %s still maps to removed backend", key)
+		}
+	}
+}
+
+func TestConsistentHashSkipsUnhealthyBackends(t *testing.T) {
+	serverPool := newHashTestPool(t, 3)
+	ch := NewConsistentHashStrategy(DefaultVirtualNodes)
+	before := assignKeys(ch, serverPool)
+
+	down := serverPool.GetBackendByIndex(0)
+	serverPool.SetBackendHealth(down.ID, false)
+	after := assignKeys(ch, serverPool)
+
+	for key, owner := range before {
+		if after[key] == down.URL.String() {
+			t.Fatalf("Key %s mapped to unhealthy backend", key)
+		}
+		if owner != down.URL.String() && after[key] != owner {
+			t.Errorf("Key %s on healthy backend %s was remapped to %s", key, owner, after[key])
+		}
+	}
+
+	// Recovery restores the original mapping
+	serverPool.SetBackendHealth(down.ID, true)
+	restored := assignKeys(ch, serverPool)
+	for key, owner := range before {
+		if restored[key] != owner {
+			t.Fatalf("Key %s not restored after recovery (%s -> %s)", key, owner, restored[key])
+		}
+	}
+}
+
+func TestConsistentHashNoHealthyBackends(t *testing.T) {
+	serverPool := newHashTestPool(t, 2)
+	serverPool.SetBackendHealth("backend-1", false)
+	serverPool.SetBackendHealth("backend-2", false)
+
+	if backend := NewConsistentHashStrategy(DefaultVirtualNodes).NextBackendForKey(serverPool, "key"); backend != nil {
+		t.Errorf("Expected nil with no healthy backends, got %s", backend.ID)
+	}
+}
@@ -0,0 +1,65 @@
+package strategy
+
+import (
+	"math/rand"
+	"sync"
+
+	"go-balancer/internal/pool"
+)
+
+// HealthScoreStrategy picks a healthy backend at random with probability
+// proportional to its weight multiplied by its health score, so backends
+// with rising latency or error rates get progressively less traffic. Scores
+// change with every request, so the effective weights are recomputed on each
+// selection.
+type HealthScoreStrategy struct {
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// NewHealthScoreStrategy creates a score-weighted strategy whose choices are
+// drawn from a generator seeded with seed
+func NewHealthScoreStrategy(seed int64) *HealthScoreStrategy {
+	return &HealthScoreStrategy{rng: rand.New(rand.NewSource(seed))}
+}
+
+// NextBackend returns a healthy backend chosen with probability
+// weight*score/total
+func (hs *HealthScoreStrategy) NextBackend(serverPool *pool.ServerPool) *pool.Backend {
+	var healthy []*pool.Backend
+	var cumulative []float64
+	total := 0.0
+
+	for _, backend := range serverPool.GetBackends() {
+		if !backend.Available() {
+			continue
+		}
+		weight := backend.Weight()
+		if weight <= 0 {
+			weight = 1
+		}
+		total += float64(weight) * backend.HealthScore()
+		healthy = append(healthy, backend)
+		cumulative = append(cumulative, total)
+	}
+
+	if len(healthy) == 0 {
+		return nil
+	}
+
+	hs.mu.Lock()
+	pick := hs.rng.Float64() * total
+	hs.mu.Unlock()
+
+	for i, bound := range cumulative {
+		if pick < bound {
+			return healthy[i]
+		}
+	}
+	return healthy[len(healthy)-1]
+}
+
+// Name returns the strategy name
+func (hs *HealthScoreStrategy) Name() string {
+	return HealthScore
+}
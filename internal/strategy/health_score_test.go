@@ -0,0 +1,87 @@
+package strategy
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHealthScoreShiftsTrafficFromFailingBackend(t *testing.T) {
+	serverPool := newTestPool(t, []int{1, 1}, []int{0, 0})
+	failing := serverPool.GetBackendByIndex(0)
+	steady := serverPool.GetBackendByIndex(1)
+
+	hs := NewHealthScoreStrategy(42)
+	const iterations = 20000
+
+	// Each phase raises the failing backend's error rate; its share of
+	// traffic should fall every time
+	previousShare := 1.0
+	for phase, errorsPerTen := range []int{0, 3, 6, 9} {
+		for i := 0; i < 50; i++ {
+			failing.RecordOutcome(10*time.Millisecond, i%10 < errorsPerTen)
+			steady.RecordOutcome(10*time.Millisecond, false)
+		}
+
+		selected := 0
+		for i := 0; i < iterations; i++ {
+			if hs.NextBackend(serverPool) == failing {
+				selected++
+			}
+		}
+		share := float64(selected) / iterations
+
+		if share >= previousShare {
+			t.Errorf("Phase %d: expected share below %.3f with %d0%% errors, got %.3f", phase, previousShare, errorsPerTen, share)
+		}
+		previousShare = share
+	}
+
+	if previousShare > 0.2 {
+		t.Errorf("Expected a mostly failing backend to get under 20%% of traffic, got %.3f", previousShare)
+	}
+}
+
+func TestHealthScorePrefersFasterBackend(t *testing.T) {
+	serverPool := newTestPool(t, []int{1, 1}, []int{0, 0})
+	slow := serverPool.GetBackendByIndex(0)
+	fast := serverPool.GetBackendByIndex(1)
+
+	for i := 0; i < 50; i++ {
+		slow.RecordOutcome(300*time.Millisecond, false)
+		fast.RecordOutcome(10*time.Millisecond, false)
+	}
+
+	if slow.HealthScore() >= fast.HealthScore() {
+		t.Fatalf("Expected slow backend to score below fast backend, got %.3f and %.3f", slow.HealthScore(), fast.HealthScore())
+	}
+
+	hs := NewHealthScoreStrategy(42)
+	counts := make(map[string]int)
+	for i := 0; i < 10000; i++ {
+		counts[hs.NextBackend(serverPool).ID]++
+	}
+	if counts[slow.ID] >= counts[fast.ID] {
+		t.Errorf("Expected fast backend to get more traffic, got slow=%d fast=%d", counts[slow.ID], counts[fast.ID])
+	}
+}
+
+func TestHealthScoreBounds(t *testing.T) {
+	serverPool := newTestPool(t, []int{1}, []int{0})
+	backend := serverPool.GetBackendByIndex(0)
+
+	if score := backend.HealthScore(); score != 1 {
+		t.Errorf("Expected untested backend to score 1, got %.3f", score)
+	}
+
+	for i := 0; i < 100; i++ {
+		backend.RecordOutcome(time.Second, true)
+	}
+	if score := backend.HealthScore(); score <= 0 {
+		t.Errorf("Expected failing backend to keep a positive score, got %.3f", score)
+	}
+
+	// The failing backend still gets picked when it is the only one
+	if got := NewHealthScoreStrategy(42).NextBackend(serverPool); got != backend {
+		t.Errorf("Expected the only backend to be selected, got %v", got)
+	}
+}
@@ -0,0 +1,58 @@
+package strategy
+
+import (
+	"net/http"
+
+	"go-balancer/internal/pool"
+)
+
+// LocalityAwareStrategy keeps traffic in the balancer's own zone: the wrapped
+// strategy selects among the local backends while any of them is available,
+// and among all backends, remote ones included, only once none is. This
+// avoids cross-zone latency and transfer costs in multi-zone deployments.
+type LocalityAwareStrategy struct {
+	zone     string
+	inner    LoadBalancingStrategy
+	innerKey func(*http.Request) string // Key for a keyed inner strategy
+}
+
+// NewLocalityAwareStrategy creates a strategy preferring backends in zone,
+// selecting with inner. innerKey supplies the key when inner is a
+// KeyedStrategy.
+func NewLocalityAwareStrategy(zone string, inner LoadBalancingStrategy, innerKey func(*http.Request) string) *LocalityAwareStrategy {
+	return &LocalityAwareStrategy{zone: zone, inner: inner, innerKey: innerKey}
+}
+
+// NextBackend selects from the local backends, or from every backend when no
+// local one is available
+func (l *LocalityAwareStrategy) NextBackend(serverPool *pool.ServerPool) *pool.Backend {
+	return l.inner.NextBackend(l.candidates(serverPool))
+}
+
+// NextBackendForRequest is NextBackend for inner strategies that select by
+// request or key
+func (l *LocalityAwareStrategy) NextBackendForRequest(serverPool *pool.ServerPool, r *http.Request) *pool.Backend {
+	candidates := l.candidates(serverPool)
+	if byRequest, ok := l.inner.(RequestStrategy); ok {
+		return byRequest.NextBackendForRequest(candidates, r)
+	}
+	if keyed, ok := l.inner.(KeyedStrategy); ok && l.innerKey != nil {
+		return keyed.NextBackendForKey(candidates, l.innerKey(r))
+	}
+	return l.inner.NextBackend(candidates)
+}
+
+// candidates returns the local backends if any of them is available, and the
+// whole pool otherwise
+func (l *LocalityAwareStrategy) candidates(serverPool *pool.ServerPool) *pool.ServerPool {
+	local := serverPool.Zone(l.zone)
+	if local.GetAvailableBackendCount() > 0 {
+		return local
+	}
+	return serverPool
+}
+
+// Name returns the inner strategy's name, which is the configured one
+func (l *LocalityAwareStrategy) Name() string {
+	return l.inner.Name()
+}
@@ -0,0 +1,82 @@
+package strategy
+
+import (
+	"testing"
+
+	"go-balancer/internal/pool"
+)
+
+// newZonedPool returns a pool of healthy backends in the given zones
+func newZonedPool(t *testing.T, zones []string) *pool.ServerPool {
+	t.Helper()
+
+	serverPool := newTestPool(t, make([]int, len(zones)), make([]int, len(zones)))
+	for i, zone := range zones {
+		backend := serverPool.GetBackendByIndex(i)
+		serverPool.SetBackendWeight(backend.ID, 1)
+		serverPool.SetBackendZone(backend.ID, zone)
+	}
+	return serverPool
+}
+
+func TestLocalityAwarePrefersLocalBackends(t *testing.T) {
+	serverPool := newZonedPool(t, []string{"us-east-1a", "us-east-1b", "us-east-1a", "us-east-1b"})
+	locality := NewLocalityAwareStrategy("us-east-1a", NewRoundRobinStrategy(), nil)
+
+	seen := make(map[string]int)
+	for i := 0; i < 20; i++ {
+		backend := locality.NextBackend(serverPool)
+		if backend == nil {
+			t.Fatalf("Expected a backend, got nil")
+		}
+		if backend.Zone != "us-east-1a" {
+			t.Fatalf("Expected a us-east-1a backend, got %s in %s", backend.ID, backend.Zone)
+		}
+		seen[backend.ID]++
+	}
+	if len(seen) != 2 {
+		t.Errorf("Expected both local backends to be used, got %v", seen)
+	}
+}
+
+func TestLocalityAwareFallsBackToRemoteBackends(t *testing.T) {
+	serverPool := newZonedPool(t, []string{"us-east-1a", "us-east-1b", "us-east-1b"})
+	locality := NewLocalityAwareStrategy("us-east-1a", NewRoundRobinStrategy(), nil)
+	local := serverPool.GetBackendByIndex(0)
+
+	serverPool.SetBackendHealth(local.ID, false)
+	for i := 0; i < 10; i++ {
+		backend := locality.NextBackend(serverPool)
+		if backend == nil {
+			t.Fatalf("Expected a remote backend, got nil")
+		}
+		if backend.Zone != "us-east-1b" {
+			t.Fatalf("Expected a us-east-1b backend, got %s in %s", backend.ID, backend.Zone)
+		}
+	}
+
+	// Traffic returns to the local zone once it recovers
+	serverPool.SetBackendHealth(local.ID, true)
+	if backend := locality.NextBackend(serverPool); backend != local {
+		t.Errorf("Expected the recovered local backend, got %v", backend)
+	}
+}
+
+func TestLocalityAwareDelegatesByRequest(t *testing.T) {
+	serverPool := newZonedPool(t, []string{"us-east-1b", "us-east-1a", "us-east-1a"})
+	affinity := NewAffinityStrategy("X-Tenant-ID", NewRoundRobinStrategy(), nil)
+	locality := NewLocalityAwareStrategy("us-east-1a", affinity, nil)
+
+	first := locality.NextBackendForRequest(serverPool, newTenantRequest("tenant-1"))
+	if first == nil || first.Zone != "us-east-1a" {
+		t.Fatalf("Expected a us-east-1a backend, got %v", first)
+	}
+	for i := 0; i < 5; i++ {
+		if got := locality.NextBackendForRequest(serverPool, newTenantRequest("tenant-1")); got != first {
+			t.Fatalf("Expected tenant-1 to stay on %s, got %v", first.ID, got)
+		}
+	}
+	if locality.Name() != affinity.Name() {
+		t.Errorf("Expected name %q, got %q", affinity.Name(), locality.Name())
+	}
+}
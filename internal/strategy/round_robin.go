@@ -23,8 +23,8 @@ func (rr *RoundRobinStrategy) NextBackend(serverPool *pool.ServerPool) *pool.Bac
 		return nil
 	}
 
-	healthyCount := serverPool.GetHealthyBackendCount()
-	if healthyCount == 0 {
+	availableCount := serverPool.GetAvailableBackendCount()
+	if availableCount == 0 {
 		return nil
 	}
 
@@ -35,17 +35,17 @@ func (rr *RoundRobinStrategy) NextBackend(serverPool *pool.ServerPool) *pool.Bac
 		index := int((next - 1) % int64(backendCount))
 
 		backend := serverPool.GetBackendByIndex(index)
-		if backend != nil && backend.Healthy {
+		if backend != nil && backend.Available() {
 			return backend
 		}
 	}
 
-	// If we get here, no healthy backends were found despite healthyCount > 0
+	// If we get here, no available backends were found despite availableCount > 0
 	// This could happen due to race conditions between health checks and requests
 	return nil
 }
 
 // Name returns the strategy name
 func (rr *RoundRobinStrategy) Name() string {
-	return "round-robin"
+	return RoundRobin
 }
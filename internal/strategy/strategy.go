@@ -1,9 +1,52 @@
 package strategy
 
-import "go-balancer/internal/pool"
+import (
+	"fmt"
+
+	"go-balancer/internal/pool"
+)
 
 // LoadBalancingStrategy defines different load balancing algorithms
 type LoadBalancingStrategy interface {
 	NextBackend(serverPool *pool.ServerPool) *pool.Backend
 	Name() string
 }
+
+// Strategy names accepted in configuration
+const (
+	RoundRobin               = "round-robin"
+	WeightedLeastConnections = "weighted-least-connections"
+	ConsistentHash           = "consistent-hash"
+	WeightedRandom           = "weighted-random"
+	HealthScore              = "health-score"
+	WeightedRoundRobin       = "weighted-round-robin"
+	AdaptiveWeight           = "adaptive-weight"
+)
+
+// NewStrategy creates a strategy by name. An empty name selects round-robin.
+func NewStrategy(name string) (LoadBalancingStrategy, error) {
+	switch name {
+	case "", RoundRobin:
+		return NewRoundRobinStrategy(), nil
+	case WeightedLeastConnections:
+		return NewWeightedLeastConnectionsStrategy(), nil
+	case ConsistentHash:
+		return NewConsistentHashStrategy(DefaultVirtualNodes), nil
+	case WeightedRandom:
+		return NewWeightedRandomStrategy(newSeed()), nil
+	case HealthScore:
+		return NewHealthScoreStrategy(newSeed()), nil
+	case WeightedRoundRobin:
+		return NewWeightedRoundRobinStrategy(), nil
+	case AdaptiveWeight:
+		return NewAdaptiveWeightStrategy(newSeed()), nil
+	default:
+		return nil, fmt.Errorf("unknown load balancing strategy: %s", name)
+	}
+}
+
+// IsValidName reports whether name refers to a known strategy
+func IsValidName(name string) bool {
+	_, err := NewStrategy(name)
+	return err == nil
+}
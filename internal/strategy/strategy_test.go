@@ -0,0 +1,75 @@
+package strategy
+
+import (
+	"testing"
+
+	"go-balancer/internal/pool"
+)
+
+func TestNewStrategy(t *testing.T) {
+	tests := []struct {
+		name         string
+		expectedName string
+		expectErr    bool
+	}{
+		{"", RoundRobin, false},
+		{RoundRobin, RoundRobin, false},
+		{WeightedLeastConnections, WeightedLeastConnections, false},
+		{ConsistentHash, ConsistentHash, false},
+		{WeightedRandom, WeightedRandom, false},
+		{HealthScore, HealthScore, false},
+		{WeightedRoundRobin, WeightedRoundRobin, false},
+		{AdaptiveWeight, AdaptiveWeight, false},
+		{"random-ish", "", true},
+	}
+
+	for _, tt := range tests {
+		s, err := NewStrategy(tt.name)
+		if tt.expectErr {
+			if err == nil {
+				t.Errorf("Expected error for strategy %q", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error for strategy %q: %v", tt.name, err)
+			continue
+		}
+		if s.Name() != tt.expectedName {
+			t.Errorf("Expected strategy %s, got %s", tt.expectedName, s.Name())
+		}
+	}
+}
+
+func TestStrategiesSkipDrainingBackends(t *testing.T) {
+	for _, name := range []string{RoundRobin, WeightedLeastConnections, ConsistentHash, WeightedRandom, HealthScore, WeightedRoundRobin, AdaptiveWeight} {
+		t.Run(name, func(t *testing.T) {
+			serverPool := pool.NewServerPool()
+			for _, url := range []string{"http://backend1:8080", "http://backend2:8080"} {
+				if err := serverPool.AddBackend(url); err != nil {
+					t.Fatalf("Failed to add backend: %v", err)
+				}
+			}
+			for _, backend := range serverPool.GetBackends() {
+				serverPool.SetBackendHealth(backend.ID, true)
+			}
+			draining := serverPool.GetBackendByIndex(0)
+			serverPool.SetBackendDraining(draining.ID, true)
+
+			s, err := NewStrategy(name)
+			if err != nil {
+				t.Fatalf("Failed to create strategy: %v", err)
+			}
+
+			for i := 0; i < 10; i++ {
+				backend := s.NextBackend(serverPool)
+				if backend == nil {
+					t.Fatalf("Expected a backend, got nil")
+				}
+				if backend.ID == draining.ID {
+					t.Errorf("Expected draining backend %s to receive no new requests", draining.ID)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package strategy
+
+import "go-balancer/internal/pool"
+
+// WeightedLeastConnectionsStrategy picks the healthy backend with the lowest
+// active connections relative to its weight, so larger backends absorb more
+// concurrent requests before being deprioritized
+type WeightedLeastConnectionsStrategy struct{}
+
+// NewWeightedLeastConnectionsStrategy creates a new weighted-least-connections strategy
+func NewWeightedLeastConnectionsStrategy() *WeightedLeastConnectionsStrategy {
+	return &WeightedLeastConnectionsStrategy{}
+}
+
+// NextBackend returns the healthy backend minimizing activeConns / weight.
+// Ties go to the higher weight, then to the earlier backend in the pool.
+func (wlc *WeightedLeastConnectionsStrategy) NextBackend(serverPool *pool.ServerPool) *pool.Backend {
+	var best *pool.Backend
+	var bestConns int64
+	var bestWeight int64
+
+	for _, backend := range serverPool.GetBackends() {
+		if !backend.Available() {
+			continue
+		}
+
+		conns := backend.ActiveConnections()
+		weight := int64(backend.Weight())
+		if weight <= 0 {
+			weight = 1
+		}
+
+		if best == nil {
+			best, bestConns, bestWeight = backend, conns, weight
+			continue
+		}
+
+		// Compare conns/weight against bestConns/bestWeight without division
+		lhs := conns * bestWeight
+		rhs := bestConns * weight
+		if lhs < rhs || (lhs == rhs && weight > bestWeight) {
+			best, bestConns, bestWeight = backend, conns, weight
+		}
+	}
+
+	return best
+}
+
+// Name returns the strategy name
+func (wlc *WeightedLeastConnectionsStrategy) Name() string {
+	return WeightedLeastConnections
+}
@@ -0,0 +1,73 @@
+package strategy
+
+import (
+	"fmt"
+	"testing"
+
+	"go-balancer/internal/pool"
+)
+
+// newTestPool builds a pool with the given weights and active connection counts
+func newTestPool(t *testing.T, weights []int, conns []int) *pool.ServerPool {
+	t.Helper()
+
+	serverPool := pool.NewServerPool()
+	for i := range weights {
+		if err := serverPool.AddBackend(fmt.Sprintf("http://localhost:%d", 8080+i)); err != nil {
+			t.Fatalf("Failed to add backend: %v", err)
+		}
+		backend := serverPool.GetBackendByIndex(i)
+		serverPool.SetBackendHealth(backend.ID, true)
+		serverPool.SetBackendWeight(backend.ID, weights[i])
+		for c := 0; c < conns[i]; c++ {
+			backend.IncrementConnections()
+		}
+	}
+	return serverPool
+}
+
+func TestWeightedLeastConnectionsSelection(t *testing.T) {
+	tests := []struct {
+		name       string
+		weights    []int
+		conns      []int
+		unhealthy  []string
+		expectedID string
+	}{
+		{"Idle pool prefers heaviest backend", []int{1, 3, 2}, []int{0, 0, 0}, nil, "backend-2"},
+		{"Fewest connections with equal weights", []int{1, 1, 1}, []int{5, 2, 3}, nil, "backend-2"},
+		{"Weight tolerates more connections", []int{1, 4}, []int{2, 6}, nil, "backend-2"},      // 2/1 vs 6/4
+		{"Light backend wins when ratio is lower", []int{1, 4}, []int{1, 8}, nil, "backend-1"}, // 1/1 vs 8/4
+		{"Ratio tie goes to higher weight", []int{1, 2}, []int{2, 4}, nil, "backend-2"},        // 2/1 vs 4/2
+		{"Full tie goes to earlier backend", []int{2, 2, 2}, []int{1, 1, 1}, nil, "backend-1"}, // all equal
+		{"Unhealthy backends are skipped", []int{1, 1}, []int{5, 0}, []string{"backend-2"}, "backend-1"},
+		{"Non-positive weight treated as 1", []int{0, 1}, []int{0, 1}, nil, "backend-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serverPool := newTestPool(t, tt.weights, tt.conns)
+			for _, id := range tt.unhealthy {
+				serverPool.SetBackendHealth(id, false)
+			}
+
+			backend := NewWeightedLeastConnectionsStrategy().NextBackend(serverPool)
+			if backend == nil {
+				t.Fatalf("Expected a backend, got nil")
+			}
+			if backend.ID != tt.expectedID {
+				t.Errorf("Expected %s, got %s", tt.expectedID, backend.ID)
+			}
+		})
+	}
+}
+
+func TestWeightedLeastConnectionsNoHealthyBackends(t *testing.T) {
+	serverPool := newTestPool(t, []int{1, 2}, []int{0, 0})
+	serverPool.SetBackendHealth("backend-1", false)
+	serverPool.SetBackendHealth("backend-2", false)
+
+	if backend := NewWeightedLeastConnectionsStrategy().NextBackend(serverPool); backend != nil {
+		t.Errorf("Expected nil with no healthy backends, got %s", backend.ID)
+	}
+}
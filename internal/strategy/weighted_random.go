@@ -0,0 +1,98 @@
+package strategy
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"go-balancer/internal/pool"
+)
+
+// WeightedRandomStrategy picks a healthy backend at random with probability
+// proportional to its weight. Unlike weighted round-robin there is no fixed
+// sequence, so independent balancers don't synchronize their choices.
+type WeightedRandomStrategy struct {
+	mu         sync.Mutex
+	rng        *rand.Rand
+	backends   []*pool.Backend // healthy backends the cumulative weights were built from
+	cumulative []int           // cumulative[i] is the total weight of backends[0..i]
+}
+
+// NewWeightedRandomStrategy creates a weighted random strategy whose choices
+// are drawn from a generator seeded with seed
+func NewWeightedRandomStrategy(seed int64) *WeightedRandomStrategy {
+	return &WeightedRandomStrategy{rng: rand.New(rand.NewSource(seed))}
+}
+
+// NextBackend returns a healthy backend chosen with probability weight/total
+func (wr *WeightedRandomStrategy) NextBackend(serverPool *pool.ServerPool) *pool.Backend {
+	var healthy []*pool.Backend
+	for _, backend := range serverPool.GetBackends() {
+		if backend.Available() {
+			healthy = append(healthy, backend)
+		}
+	}
+
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+
+	if wr.changed(healthy) {
+		wr.rebuild(healthy)
+	}
+
+	if len(wr.backends) == 0 {
+		return nil
+	}
+
+	total := wr.cumulative[len(wr.cumulative)-1]
+	pick := wr.rng.Intn(total)
+	idx := sort.Search(len(wr.cumulative), func(i int) bool { return wr.cumulative[i] > pick })
+	return wr.backends[idx]
+}
+
+// changed reports whether healthy membership or weights differ from those the
+// cumulative weights were built from
+func (wr *WeightedRandomStrategy) changed(healthy []*pool.Backend) bool {
+	if len(healthy) != len(wr.backends) {
+		return true
+	}
+	prev := 0
+	for i, backend := range healthy {
+		weight := backend.Weight()
+		if weight <= 0 {
+			weight = 1
+		}
+		if backend != wr.backends[i] || wr.cumulative[i]-prev != weight {
+			return true
+		}
+		prev = wr.cumulative[i]
+	}
+	return false
+}
+
+// rebuild recomputes the cumulative weights for the given healthy backends
+func (wr *WeightedRandomStrategy) rebuild(backends []*pool.Backend) {
+	wr.backends = backends
+	wr.cumulative = make([]int, len(backends))
+
+	total := 0
+	for i, backend := range backends {
+		weight := backend.Weight()
+		if weight <= 0 {
+			weight = 1
+		}
+		total += weight
+		wr.cumulative[i] = total
+	}
+}
+
+// Name returns the strategy name
+func (wr *WeightedRandomStrategy) Name() string {
+	return WeightedRandom
+}
+
+// newSeed seeds strategies created from configuration
+func newSeed() int64 {
+	return time.Now().UnixNano()
+}
@@ -0,0 +1,104 @@
+package strategy
+
+import (
+	"math"
+	"testing"
+)
+
+func TestWeightedRandomDistribution(t *testing.T) {
+	tests := []struct {
+		name      string
+		weights   []int
+		unhealthy []string
+		expected  map[string]float64 // Expected share of selections per backend
+	}{
+		{
+			name:     "Equal weights",
+			weights:  []int{1, 1, 1},
+			expected: map[string]float64{"backend-1": 1.0 / 3, "backend-2": 1.0 / 3, "backend-3": 1.0 / 3},
+		},
+		{
+			name:     "Proportional to weight",
+			weights:  []int{1, 3, 6},
+			expected: map[string]float64{"backend-1": 0.1, "backend-2": 0.3, "backend-3": 0.6},
+		},
+		{
+			name:      "Unhealthy backends get nothing",
+			weights:   []int{1, 3, 6},
+			unhealthy: []string{"backend-3"},
+			expected:  map[string]float64{"backend-1": 0.25, "backend-2": 0.75},
+		},
+		{
+			name:     "Non-positive weight treated as 1",
+			weights:  []int{0, 1},
+			expected: map[string]float64{"backend-1": 0.5, "backend-2": 0.5},
+		},
+	}
+
+	const iterations = 100000
+	const tolerance = 0.01
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serverPool := newTestPool(t, tt.weights, make([]int, len(tt.weights)))
+			for _, id := range tt.unhealthy {
+				serverPool.SetBackendHealth(id, false)
+			}
+
+			wr := NewWeightedRandomStrategy(42)
+			counts := make(map[string]int)
+			for i := 0; i < iterations; i++ {
+				backend := wr.NextBackend(serverPool)
+				if backend == nil {
+					t.Fatalf("Expected a backend, got nil")
+				}
+				counts[backend.ID]++
+			}
+
+			for id, count := range counts {
+				if _, ok := tt.expected[id]; !ok {
+					t.Errorf("Expected %s never to be selected, got %d selections", id, count)
+				}
+			}
+			for id, share := range tt.expected {
+				got := float64(counts[id]) / iterations
+				if math.Abs(got-share) > tolerance {
+					t.Errorf("Expected %s to get %.3f of selections, got %.3f", id, share, got)
+				}
+			}
+		})
+	}
+}
+
+func TestWeightedRandomFollowsPoolChanges(t *testing.T) {
+	serverPool := newTestPool(t, []int{1, 1}, []int{0, 0})
+	wr := NewWeightedRandomStrategy(1)
+
+	// Warm the cumulative weights, then take backend-1 out
+	wr.NextBackend(serverPool)
+	serverPool.SetBackendHealth("backend-1", false)
+
+	for i := 0; i < 100; i++ {
+		if backend := wr.NextBackend(serverPool); backend == nil || backend.ID != "backend-2" {
+			t.Fatalf("Expected backend-2 after backend-1 went unhealthy, got %v", backend)
+		}
+	}
+
+	// A weight change is picked up too
+	serverPool.SetBackendHealth("backend-1", true)
+	serverPool.SetBackendWeight("backend-1", 9)
+
+	counts := make(map[string]int)
+	for i := 0; i < 10000; i++ {
+		counts[wr.NextBackend(serverPool).ID]++
+	}
+	if share := float64(counts["backend-1"]) / 10000; math.Abs(share-0.9) > 0.02 {
+		t.Errorf("Expected backend-1 to get 0.9 of selections after reweighting, got %.3f", share)
+	}
+
+	serverPool.SetBackendHealth("backend-1", false)
+	serverPool.SetBackendHealth("backend-2", false)
+	if backend := wr.NextBackend(serverPool); backend != nil {
+		t.Errorf("Expected nil with no healthy backends, got %s", backend.ID)
+	}
+}
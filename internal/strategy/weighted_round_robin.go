@@ -0,0 +1,63 @@
+package strategy
+
+import (
+	"sync"
+
+	"go-balancer/internal/pool"
+)
+
+// WeightedRoundRobinStrategy implements nginx's smooth weighted round-robin.
+// Every selection adds each available backend's weight to its running
+// (current) weight, picks the backend with the highest current weight and
+// subtracts the total weight from it. Over a cycle each backend is picked in
+// proportion to its weight, but picks are interleaved instead of bursting:
+// weights 5, 1, 1 give a a b a c a a rather than a a a a a b c.
+type WeightedRoundRobinStrategy struct {
+	mu      sync.Mutex
+	current map[string]int // Running weight per backend ID
+}
+
+// NewWeightedRoundRobinStrategy creates a smooth weighted round-robin strategy
+func NewWeightedRoundRobinStrategy() *WeightedRoundRobinStrategy {
+	return &WeightedRoundRobinStrategy{current: make(map[string]int)}
+}
+
+// NextBackend returns the available backend with the highest running weight
+func (wrr *WeightedRoundRobinStrategy) NextBackend(serverPool *pool.ServerPool) *pool.Backend {
+	backends := serverPool.GetBackends()
+
+	wrr.mu.Lock()
+	defer wrr.mu.Unlock()
+
+	var best *pool.Backend
+	total := 0
+	for _, backend := range backends {
+		if !backend.Available() {
+			// Forget the running weight so a returning backend starts fresh
+			delete(wrr.current, backend.ID)
+			continue
+		}
+
+		weight := backend.Weight()
+		if weight <= 0 {
+			weight = 1
+		}
+		wrr.current[backend.ID] += weight
+		total += weight
+
+		if best == nil || wrr.current[backend.ID] > wrr.current[best.ID] {
+			best = backend
+		}
+	}
+
+	if best == nil {
+		return nil
+	}
+	wrr.current[best.ID] -= total
+	return best
+}
+
+// Name returns the strategy name
+func (wrr *WeightedRoundRobinStrategy) Name() string {
+	return WeightedRoundRobin
+}
@@ -0,0 +1,77 @@
+package strategy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWeightedRoundRobinSmoothSequence(t *testing.T) {
+	tests := []struct {
+		name      string
+		weights   []int
+		unhealthy []string
+		expected  []string // One cycle of backend IDs, by index suffix
+	}{
+		{
+			name:     "nginx example 5 1 1",
+			weights:  []int{5, 1, 1},
+			expected: []string{"1", "1", "2", "1", "3", "1", "1"},
+		},
+		{
+			name:     "Equal weights are plain round-robin",
+			weights:  []int{1, 1, 1},
+			expected: []string{"1", "2", "3"},
+		},
+		{
+			name:     "Weights 4 2 1",
+			weights:  []int{4, 2, 1},
+			expected: []string{"1", "2", "1", "3", "1", "2", "1"},
+		},
+		{
+			name:     "Non-positive weight treated as 1",
+			weights:  []int{0, 2},
+			expected: []string{"2", "1", "2"},
+		},
+		{
+			name:      "Unavailable backends are skipped",
+			weights:   []int{5, 1, 1},
+			unhealthy: []string{"backend-1"},
+			expected:  []string{"2", "3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serverPool := newTestPool(t, tt.weights, make([]int, len(tt.weights)))
+			for _, id := range tt.unhealthy {
+				serverPool.SetBackendHealth(id, false)
+			}
+
+			wrr := NewWeightedRoundRobinStrategy()
+			// Two cycles: the pattern must repeat exactly
+			var got []string
+			for i := 0; i < 2*len(tt.expected); i++ {
+				backend := wrr.NextBackend(serverPool)
+				if backend == nil {
+					t.Fatalf("Expected a backend, got nil")
+				}
+				got = append(got, strings.TrimPrefix(backend.ID, "backend-"))
+			}
+
+			want := append(append([]string{}, tt.expected...), tt.expected...)
+			if strings.Join(got, " ") != strings.Join(want, " ") {
+				t.Errorf("Expected sequence %v, got %v", want, got)
+			}
+		})
+	}
+}
+
+func TestWeightedRoundRobinNoAvailableBackends(t *testing.T) {
+	serverPool := newTestPool(t, []int{1, 2}, []int{0, 0})
+	serverPool.SetBackendHealth("backend-1", false)
+	serverPool.SetBackendHealth("backend-2", false)
+
+	if backend := NewWeightedRoundRobinStrategy().NextBackend(serverPool); backend != nil {
+		t.Errorf("Expected nil with no available backends, got %s", backend.ID)
+	}
+}
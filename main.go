@@ -1,27 +1,494 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"maps"
 	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"go-balancer/internal/balancer"
 	"go-balancer/internal/config"
 	"go-balancer/internal/errors"
+	"go-balancer/internal/healthcheck"
+	"go-balancer/internal/listener"
+	"go-balancer/internal/logging"
 )
 
+// headerFlag collects repeated "Name: value" flags into a header map
+type headerFlag map[string]string
+
+func (h headerFlag) String() string {
+	pairs := make([]string, 0, len(h))
+	for name, value := range h {
+		pairs = append(pairs, name+": "+value)
+	}
+	return strings.Join(pairs, ", ")
+}
+
+func (h headerFlag) Set(value string) error {
+	name, headerValue, found := strings.Cut(value, ":")
+	if !found {
+		return fmt.Errorf("header must be in the form \"Name: value\"")
+	}
+	h[strings.TrimSpace(name)] = strings.TrimSpace(headerValue)
+	return nil
+}
+
+// listFlag collects repeated flag values in order
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, strings.TrimSpace(value))
+	return nil
+}
+
+// isFlagSet reports whether the named flag was passed on the command line
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseWeights parses "url=weight" pairs into a map keyed by backend URL
+func parseWeights(value string) (map[string]int, error) {
+	weights := make(map[string]int)
+	for _, pair := range splitList(value) {
+		idx := strings.LastIndex(pair, "=")
+		if idx < 0 {
+			return nil, fmt.Errorf("weight must be in the form url=weight: %q", pair)
+		}
+		weight, err := strconv.Atoi(strings.TrimSpace(pair[idx+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid weight in %q: %w", pair, err)
+		}
+		weights[strings.TrimSpace(pair[:idx])] = weight
+	}
+	return weights, nil
+}
+
+// parseIntervals parses "url=seconds" pairs into a map keyed by backend URL
+func parseIntervals(value string) (map[string]time.Duration, error) {
+	intervals := make(map[string]time.Duration)
+	for _, pair := range splitList(value) {
+		idx := strings.LastIndex(pair, "=")
+		if idx < 0 {
+			return nil, fmt.Errorf("duration must be in the form url=seconds: %q", pair)
+		}
+		seconds, err := strconv.Atoi(strings.TrimSpace(pair[idx+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid seconds in %q: %w", pair, err)
+		}
+		intervals[strings.TrimSpace(pair[:idx])] = time.Duration(seconds) * time.Second
+	}
+	return intervals, nil
+}
+
+// parseMethodTimeouts parses "method=seconds" pairs into a map keyed by
+// upper-case HTTP method
+func parseMethodTimeouts(value string) (map[string]time.Duration, error) {
+	timeouts := make(map[string]time.Duration)
+	for _, pair := range splitList(value) {
+		method, seconds, found := strings.Cut(pair, "=")
+		if !found {
+			return nil, fmt.Errorf("timeout must be in the form method=seconds: %q", pair)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(seconds))
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout in %q: %w", pair, err)
+		}
+		timeouts[strings.ToUpper(strings.TrimSpace(method))] = time.Duration(n) * time.Second
+	}
+	return timeouts, nil
+}
+
+// parseSchemes parses "url=scheme" pairs into a map keyed by backend URL
+func parseSchemes(value string) (map[string]string, error) {
+	schemes := make(map[string]string)
+	for _, pair := range splitList(value) {
+		idx := strings.LastIndex(pair, "=")
+		if idx < 0 {
+			return nil, fmt.Errorf("scheme must be in the form url=scheme: %q", pair)
+		}
+		schemes[strings.TrimSpace(pair[:idx])] = strings.ToLower(strings.TrimSpace(pair[idx+1:]))
+	}
+	return schemes, nil
+}
+
+// parseClientCert parses "url=cert.pem,key.pem" into a backend URL and the
+// client certificate presented to it
+func parseClientCert(spec string) (string, config.ClientCert, error) {
+	idx := strings.LastIndex(spec, "=")
+	if idx < 0 {
+		return "", config.ClientCert{}, fmt.Errorf("client certificate must be in the form url=cert.pem,key.pem: %q", spec)
+	}
+	certFile, keyFile, found := strings.Cut(spec[idx+1:], ",")
+	if !found {
+		return "", config.ClientCert{}, fmt.Errorf("client certificate must be in the form url=cert.pem,key.pem: %q", spec)
+	}
+	cert := config.ClientCert{CertFile: strings.TrimSpace(certFile), KeyFile: strings.TrimSpace(keyFile)}
+	return strings.TrimSpace(spec[:idx]), cert, nil
+}
+
+// parseZones parses "url=zone" pairs into a map keyed by backend URL
+func parseZones(value string) (map[string]string, error) {
+	zones := make(map[string]string)
+	for _, pair := range splitList(value) {
+		idx := strings.LastIndex(pair, "=")
+		if idx < 0 {
+			return nil, fmt.Errorf("zone must be in the form url=zone: %q", pair)
+		}
+		zones[strings.TrimSpace(pair[:idx])] = strings.TrimSpace(pair[idx+1:])
+	}
+	return zones, nil
+}
+
+// parseLabels parses "name=value" pairs into a map of metrics labels
+func parseLabels(value string) (map[string]string, error) {
+	labels := make(map[string]string)
+	for _, pair := range splitList(value) {
+		name, labelValue, found := strings.Cut(pair, "=")
+		if !found {
+			return nil, fmt.Errorf("label must be in the form name=value: %q", pair)
+		}
+		labels[strings.TrimSpace(name)] = strings.TrimSpace(labelValue)
+	}
+	return labels, nil
+}
+
+// parseQueryParams parses "name=value" pairs into a map of query parameters
+func parseQueryParams(value string) (map[string]string, error) {
+	params := make(map[string]string)
+	for _, pair := range splitList(value) {
+		name, paramValue, found := strings.Cut(pair, "=")
+		if !found {
+			return nil, fmt.Errorf("query parameter must be in the form name=value: %q", pair)
+		}
+		params[strings.TrimSpace(name)] = strings.TrimSpace(paramValue)
+	}
+	return params, nil
+}
+
+// newServer builds the HTTP server for the load balancer, applying the
+// server-level limits from cfg
+func newServer(cfg *config.Config, handler http.Handler) *http.Server {
+	server := &http.Server{
+		Addr:           fmt.Sprintf(":%d", cfg.Port),
+		Handler:        handler,
+		MaxHeaderBytes: cfg.WithDefaults().MaxHeaderBytes,
+	}
+
+	// Offer HTTP/2 over TLS through ALPN unless it is disabled. A non-nil,
+	// empty TLSNextProto stops net/http from enabling HTTP/2 on its own.
+	if cfg.TLSCertFile != "" {
+		server.TLSConfig = &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			NextProtos: []string{"h2", "http/1.1"},
+		}
+		if cfg.DisableHTTP2 {
+			server.TLSConfig.NextProtos = []string{"http/1.1"}
+			server.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+		}
+		// Clients without a certificate are still served; backends decide
+		// what an unauthenticated client may do
+		if cfg.TLSClientCAFile != "" {
+			server.TLSConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+	}
+	return server
+}
+
+// proxyMux routes the proxy port: metrics and probes are answered locally and
+// every other request, including ones for /status and /admin/*, goes to lb
+func proxyMux(lb *balancer.LoadBalancer) *http.ServeMux {
+	mux := http.NewServeMux()
+
+	// Handle metrics endpoints; both report on the same metrics
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		lb.GetMetricsProvider().ServeHTTP(w, r)
+	})
+	mux.Handle("/metrics.json", lb.GetJSONMetricsProvider())
+
+	// Handle liveness and readiness probes
+	mux.Handle("/livez", lb.LivenessHandler())
+	mux.Handle("/readyz", lb.ReadinessHandler())
+
+	// Handle all other requests with the load balancer
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		lb.ServeHTTP(w, r)
+	})
+	return mux
+}
+
+// adminMux routes the admin API: backend status and the endpoints that
+// inspect or change the balancer at runtime
+func adminMux(lb *balancer.LoadBalancer) *http.ServeMux {
+	mux := http.NewServeMux()
+
+	// Handle backend status
+	mux.Handle("/status", lb.StatusHandler())
+
+	// Handle admin endpoints
+	mux.Handle("/admin/maintenance", lb.MaintenanceHandler())
+	mux.Handle("/admin/config", lb.ConfigHandler())
+	mux.Handle("/admin/backends/", lb.BackendsHandler())
+	mux.Handle("/admin/traffic-split", lb.TrafficSplitHandler())
+	mux.Handle("/admin/routing", lb.RoutingHandler())
+	return mux
+}
+
+// newAdminServer creates the plain HTTP server for the admin API on
+// cfg.AdminAddress
+func newAdminServer(cfg *config.Config, lb *balancer.LoadBalancer) *http.Server {
+	return &http.Server{
+		Addr:           cfg.AdminAddress,
+		Handler:        adminMux(lb),
+		MaxHeaderBytes: cfg.WithDefaults().MaxHeaderBytes,
+	}
+}
+
+// connectHandler sends CONNECT requests straight to lb, since they name a
+// host rather than a path and the mux can't route them; everything else goes
+// to mux
+func connectHandler(mux *http.ServeMux, lb http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodConnect {
+			lb.ServeHTTP(w, r)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// loadClientCAs reads the PEM bundle of CAs client certificates are verified against
+func loadClientCAs(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no PEM certificates found in %s", path)
+	}
+	return pool, nil
+}
+
+// checkBackendOnce probes backendURL once with the health check settings in
+// cfg, prints the result to w and returns the exit code: 0 if the backend is
+// healthy, 1 otherwise
+func checkBackendOnce(cfg *config.Config, backendURL string, w io.Writer) int {
+	start := time.Now()
+	if err := healthcheck.CheckURL(cfg, backendURL); err != nil {
+		fmt.Fprintf(w, "%s is unhealthy: %v\n", backendURL, err)
+
+		// Show what the probe saw, e.g. the status code or the URL it hit
+		if lbErr, ok := err.(*errors.LoadBalancerError); ok {
+			for _, key := range slices.Sorted(maps.Keys(lbErr.Context)) {
+				if key != "backend" {
+					fmt.Fprintf(w, "  %s: %v\n", key, lbErr.Context[key])
+				}
+			}
+		}
+		return 1
+	}
+	fmt.Fprintf(w, "%s is healthy (%s)\n", backendURL, time.Since(start).Round(time.Millisecond))
+	return 0
+}
+
+// setupLogFile sends the standard logger to cfg.LogFile, rotated by size, and
+// returns a function that closes it again. If the file can't be opened the
+// log stays on stderr.
+func setupLogFile(cfg *config.Config) func() {
+	if cfg.LogFile == "" {
+		return func() {}
+	}
+
+	logFile, err := logging.NewRotatingFile(cfg.LogFile, int64(cfg.LogMaxSize)<<20, cfg.LogMaxBackups)
+	if err != nil {
+		log.Printf("Failed to open log file %s, logging to stderr: %v", cfg.LogFile, err)
+		return func() {}
+	}
+
+	log.SetOutput(logFile)
+	return func() {
+		log.SetOutput(os.Stderr)
+		logFile.Close()
+	}
+}
+
 func main() {
 	// Parse command line flags
+	responseHeaders := headerFlag{}
+	flag.Var(responseHeaders, "response-header", "Header to set on every response, as \"Name: value\" (repeatable)")
+	requestHeaders := headerFlag{}
+	flag.Var(requestHeaders, "request-header", "Header to inject into every backend request, as \"Name: value\" (repeatable)")
+	var drainWindows listFlag
+	flag.Var(&drainWindows, "drain-window", "Drain a backend during a maintenance window, as url=start/end in RFC 3339 (repeatable)")
+
+	var deniedPaths listFlag
+	flag.Var(&deniedPaths, "deny-path", "Reject requests whose path starts with this prefix, or matches regex:<pattern>, with 403 (repeatable)")
+
+	var staticResponses listFlag
+	flag.Var(&staticResponses, "static-response", "Answer a path locally without a backend, as path=status[,content-type[,body]] (repeatable)")
+
+	var backendClientCerts listFlag
+	flag.Var(&backendClientCerts, "backend-client-cert", "Client certificate presented to one backend requiring mutual TLS, as url=cert.pem,key.pem (repeatable)")
+
+	var routes listFlag
+	flag.Var(&routes, "route", "Route matching requests with their own strategy and backends, as [host]/prefix=strategy[@url,url] (repeatable)")
+
 	var (
 		port           = flag.Int("port", 8000, "Port to listen on")
 		backends       = flag.String("backends", "http://localhost:8080,http://localhost:8081,http://localhost:8082", "Comma-separated list of backend servers")
+		healthType     = flag.String("health-type", "http", "Health check type: http, tcp or grpc (the gRPC health RPC over h2c, or h2 for https backends)")
 		healthPath     = flag.String("health-path", "/", "Path to use for health checking")
+		healthMethod   = flag.String("health-method", "GET", "HTTP method to use for health checking (e.g. GET, HEAD, OPTIONS)")
+		healthBody     = flag.String("health-body", "", "Request body sent with health checks; needs -health-method POST, PUT or PATCH")
+		healthBodyType = flag.String("health-content-type", "", "Content-Type of -health-body (default application/json)")
 		healthInterval = flag.Int("health-interval", 10, "Health check interval in seconds")
 		healthTimeout  = flag.Int("health-timeout", 2, "Health check timeout in seconds")
+		strategyName   = flag.String("strategy", "round-robin", "Load balancing strategy (round-robin, weighted-least-connections, consistent-hash, weighted-random, health-score, weighted-round-robin, adaptive-weight)")
+		hashKey        = flag.String("hash-key", "client-ip", "Key for consistent-hash: client-ip or header:<Name>")
+		affinityHeader = flag.String("affinity-header", "", "Send requests with the same value of this header (e.g. X-Tenant-ID) to the same backend")
+		weights        = flag.String("backend-weights", "", "Comma-separated backend weights as url=weight (default weight 1)")
+		localZone      = flag.String("local-zone", "", "Zone this balancer runs in; backends in it are preferred over remote ones")
+		backendZones   = flag.String("backend-zones", "", "Comma-separated backend zones as url=zone")
+		healthConc     = flag.Int("health-concurrency", 10, "Maximum number of concurrent health check probes")
+		healthHeader   = flag.String("health-require-header", "", "Response header a health probe must carry to count as healthy, as Name or Name=value")
+		healthGRPCSvc  = flag.String("health-grpc-service", "", "Service name sent in grpc health checks (empty checks the server as a whole)")
+		healthSNI      = flag.String("health-sni", "", "TLS server name sent and verified by health probes to https backends (default: the backend URL host)")
+		healthCAFile   = flag.String("health-ca-file", "", "PEM CA bundle health probes verify https backends against (default: system roots)")
+		healthRedirect = flag.Bool("health-follow-redirects", false, "Follow redirects from the health endpoint; by default a 3xx response counts as unhealthy")
+		healthGrace    = flag.Int("health-startup-grace", 0, "Seconds after startup during which backends that haven't passed a probe yet stay pending and are re-probed quickly (0 disables)")
+		flapThreshold  = flag.Int("health-flap-threshold", 0, "Times a backend may go down within -health-flap-window before it is held unhealthy for -health-flap-penalty (0 disables damping)")
+		flapWindow     = flag.Int("health-flap-window", 300, "Window in seconds over which a backend's flaps are counted")
+		flapPenalty    = flag.Int("health-flap-penalty", 600, "Seconds a flapping backend is held unhealthy, even while its probes pass")
+		noHealthChecks = flag.Bool("disable-health-checks", false, "Never probe backends; they start healthy and are taken out of rotation only through /admin/backends")
+		healthBurst    = flag.Int("health-startup-burst", 0, "Probes of every backend in quick succession at startup, before the regular interval applies (0 or 1 probes once)")
+		healthBurstMs  = flag.Int("health-startup-burst-interval-ms", 0, "Milliseconds between startup burst probes (default 500)")
+		healthMaxEvery = flag.Int("health-healthy-max-interval", 0, "Longest interval in seconds that a backend which keeps passing probes backs off to (0 disables adaptive intervals)")
+		healthJitter   = flag.Float64("health-jitter", 0, "Fraction of the health check interval by which each probe is randomly delayed (0 disables)")
+		healthPerURL   = flag.String("health-intervals", "", "Comma-separated per-backend health check intervals as url=seconds (default -health-interval)")
+		healthTimeouts = flag.String("health-timeouts", "", "Comma-separated per-backend health check timeouts as url=seconds (default -health-timeout)")
+		failureCodes   = flag.String("failure-status-codes", "5xx", "Comma-separated backend status codes counted as failures (e.g. 5xx,429,500-504)")
+		backendsFile   = flag.String("backends-file", "", "File listing one backend URL per line, watched for changes")
+		backendsEvery  = flag.Int("backends-file-interval", 5, "How often to check the backends file for changes, in seconds")
+		discoverySRV   = flag.String("discovery-srv", "", "DNS SRV name to discover backends from (e.g. _http._tcp.backends.example.com)")
+		discoveryEvery = flag.Int("discovery-interval", 30, "SRV re-resolution interval in seconds")
+		discoveryProto = flag.String("discovery-scheme", "http", "Scheme for discovered backends (http or https)")
+		metricsFormat  = flag.String("metrics-provider", "prometheus", "Metrics exposition format served at /metrics (prometheus or json); JSON is also served at /metrics.json")
+		statsdAddr     = flag.String("statsd-address", "", "host:port of a StatsD server to push metrics to (empty disables)")
+		statsdEvery    = flag.Int("statsd-interval", 10, "StatsD push interval in seconds")
+		metricsPrefix  = flag.String("metrics-prefix", "go_balancer", "Prefix for Prometheus metric names, joined to each name with an underscore")
+		metricsLabels  = flag.String("metrics-labels", "", "Comma-separated name=value labels added to every Prometheus metric, e.g. instance=lb-1,region=us-east")
+		statsdPrefix   = flag.String("statsd-prefix", "go_balancer", "Prefix for StatsD metric names")
+		serveStale     = flag.Bool("serve-stale-on-error", false, "Serve the last good cached GET response when no backend is healthy")
+		healthIdle     = flag.Int("health-idle-timeout", 0, "Idle connection timeout for health checks in seconds (0 = twice the interval)")
+		requestTimeout = flag.Int("request-timeout", 0, "Timeout for handling a whole client request in seconds, including retries (0 = unbounded)")
+		slowRequestMs  = flag.Int("slow-request-ms", 0, "Log and count backend responses taking at least this many milliseconds (0 disables)")
 		backendTimeout = flag.Int("backend-timeout", 30, "Timeout for backend requests in seconds")
+		methodTimeouts = flag.String("method-timeouts", "", "Comma-separated per-method backend timeouts as method=seconds (e.g. POST=60,PUT=60)")
+		trustedProxies = flag.String("trusted-proxies", "", "Comma-separated list of trusted proxy CIDRs for X-Forwarded-For")
+		allowedClients = flag.String("allowed-client-cidrs", "", "Comma-separated client CIDRs allowed to use the balancer; others get 403 (empty allows all)")
+		shadowBackend  = flag.String("shadow-backend", "", "Backend URL that receives a copy of every request; its responses are discarded")
+		connectTunnels = flag.Bool("connect-tunnels", false, "Act as a forward proxy for CONNECT requests, tunneling TCP to -connect-allowed-targets")
+		connectTargets = flag.String("connect-allowed-targets", "", "Comma-separated host:port targets CONNECT may reach (*.domain matches subdomains, host:* any port)")
+		allowedMethods = flag.String("allowed-methods", "", "Comma-separated request methods forwarded to backends; others get 405 (empty allows all)")
+		backupBackends = flag.String("backup-backends", "", "Comma-separated backend URLs used only while no primary backend is healthy")
+		upstreamScheme = flag.String("upstream-scheme", "", "Scheme used to reach every backend regardless of its URL (http or https)")
+		upstreamPerURL = flag.String("upstream-schemes", "", "Comma-separated per-backend scheme overrides as url=scheme")
+		clientCertFile = flag.String("upstream-client-cert", "", "PEM client certificate presented to https backends that require mutual TLS")
+		clientKeyFile  = flag.String("upstream-client-key", "", "PEM private key for -upstream-client-cert")
+		detectProto    = flag.Bool("detect-backend-protocol", false, "Probe each backend once for HTTP/2 support and speak HTTP/2 to plain-HTTP backends that have it")
+		noKeepAlive    = flag.Bool("disable-keepalive", false, "Open a fresh connection to the backend for every request")
+		canaryBackend  = flag.String("canary-backend", "", "Backend URL that receives a share of traffic for canary releases")
+		canaryPercent  = flag.Float64("canary-percent", 0, "Percentage of requests (0-100) sent to the canary backend")
+		canaryMatch    = flag.String("canary-match", "", "Requests always sent to the canary: header:<Name>[=<value>] or cookie:<Name>[=<value>]")
+		greenBackends  = flag.String("green-backends", "", "Comma-separated green backend group for blue/green splits; -backends is the blue group")
+		greenPercent   = flag.Float64("green-percent", 0, "Percentage of requests (0-100) sent to the green backends, e.g. 10 for a 90/10 blue/green split")
+		duplicates     = flag.String("duplicate-backends", "reject", "Adding a backend URL already in the pool (same scheme, host and port): reject or ignore")
+		maxBackends    = flag.Int("max-backends", 0, "Maximum number of backends in the pool, including discovered ones (0 = unlimited)")
+		retryEOF       = flag.Bool("retry-upstream-eof", false, "Retry a request without a body once when the backend closes the connection before anything reached the client")
+		bufferMax      = flag.Int64("response-buffer-max-bytes", 0, "Read responses up to this many bytes in full before sending them, so late backend failures can still be reported or retried (0 streams everything)")
+		retryAfter     = flag.Bool("honor-retry-after", false, "Skip backends that answer 429 or 503 with Retry-After until it elapses")
+		retryAfterMax  = flag.Int("max-retry-after", 60, "Longest backoff in seconds a backend's Retry-After can request")
+		errorBudget    = flag.Float64("error-budget", 0, "Share of failed requests (0-1) over -error-budget-window above which a backend gets no new requests until failures age out (0 disables)")
+		errorBudgetWin = flag.Int("error-budget-window", 60, "Rolling window in seconds over which each backend's error rate is measured")
+		idleRead       = flag.Int("stream-idle-read-timeout", 0, "Seconds a backend may send nothing while its response body is copied before the response is aborted (0 disables)")
+		idleWrite      = flag.Int("stream-idle-write-timeout", 0, "Seconds a write of the response body to the client may make no progress before the response is aborted (0 disables)")
+		dialRetries    = flag.Int("dial-retries", 0, "Extra attempts to connect to a backend, with jittered backoff, before a request fails (0 disables)")
+		noKeepAliveFor = flag.String("disable-keepalive-backends", "", "Comma-separated backend URLs that get a fresh connection for every request")
+		removeHeaders  = flag.String("remove-response-headers", "", "Comma-separated list of upstream response headers to strip (e.g. Server)")
+		servedBy       = flag.Bool("served-by-header", false, "Add an X-Served-By response header naming the backend that served the request")
+		serverTiming   = flag.Bool("server-timing-header", false, "Add a Server-Timing: upstream;dur=<ms> response header with the backend's latency")
+		forceBackend   = flag.Bool("force-backend-header", false, "Route requests with an X-Force-Backend header to the named backend ID, bypassing the strategy (debugging only)")
+		defaultQuery   = flag.String("default-query-params", "", "Comma-separated name=value query parameters added to backend requests when absent")
+		removeQuery    = flag.String("remove-query-params", "", "Comma-separated list of query parameters stripped before forwarding")
+		accessLog      = flag.String("access-log", "", "File to write JSON access logs to, or - for stdout (empty disables)")
+		accessSample   = flag.Int("access-log-sample", 1, "Log 1 in N requests; server errors and slow requests are always logged")
+		logFile        = flag.String("log-file", "", "File to write the server log to instead of stderr, rotated by size")
+		logMaxSize     = flag.Int("log-max-size", 100, "Size in megabytes at which the log file is rotated")
+		logMaxBackups  = flag.Int("log-max-backups", 3, "Rotated log files to keep")
+		accessSlow     = flag.Int("access-log-slow-ms", 0, "Always log requests taking at least this many milliseconds (0 disables)")
+		maxHeaderBytes = flag.Int("max-header-bytes", http.DefaultMaxHeaderBytes, "Maximum size of inbound request headers in bytes")
+		maxRespHeader  = flag.Int64("max-response-header-bytes", config.DefaultMaxResponseHeaderBytes, "Maximum size of backend response headers in bytes; larger ones get 502")
+		tlsCert        = flag.String("tls-cert", "", "PEM certificate file; with -tls-key serves clients over TLS")
+		tlsKey         = flag.String("tls-key", "", "PEM private key file for -tls-cert")
+		noHTTP2        = flag.Bool("disable-http2", false, "Only offer HTTP/1.1 over TLS instead of negotiating HTTP/2")
+		tlsClientCA    = flag.String("tls-client-ca", "", "PEM CA bundle to verify client certificates against; clients may then present one")
+		forwardCert    = flag.Bool("forward-client-cert", false, "Pass the verified client certificate's subject, issuer and serial to backends in X-Client-Cert-* headers")
+		proxyProtocol  = flag.Bool("proxy-protocol", false, "Expect a PROXY protocol v1/v2 header on every connection (e.g. behind an L4 load balancer)")
+		maxConns       = flag.Int("max-connections", 0, "Maximum client connections open at once; further clients wait to be accepted (0 means unlimited)")
+		adminAddr      = flag.String("admin-addr", "127.0.0.1:8001", "host:port serving /status and the /admin API, apart from proxied traffic (empty disables them)")
+		reusePort      = flag.Bool("reuseport", false, "Bind with SO_REUSEPORT so a new instance can take over the port during deploys")
+		shutdownDrain  = flag.Int("shutdown-drain", 0, "Seconds to fail readiness and reject new requests before closing the listener on shutdown")
+		shutdownWait   = flag.Int("shutdown-timeout", 30, "Seconds to wait for in-flight requests on shutdown")
+		rateLimit      = flag.Float64("rate-limit", 0, "Requests per second allowed per client IP (0 disables rate limiting)")
+		rateBurst      = flag.Int("rate-limit-burst", 0, "Requests a client may make in a burst (0 = the rate, at least 1)")
+		rateBody       = flag.String("rate-limit-body", "", "Body of 429 responses (default is a plain message)")
+		rateType       = flag.String("rate-limit-content-type", "", "Content-Type of 429 responses (default text/plain)")
+		errorFormat    = flag.String("error-format", "text", "Proxy error responses: text, json, or auto (JSON for clients accepting application/json)")
+		errorContext   = flag.Bool("error-include-context", false, "Include error context (e.g. the backend ID) in JSON error responses")
+		maxConcurrent  = flag.Int("max-concurrent-requests", 0, "Requests handled at once before new ones get 503 (0 disables the limit)")
+		concurrencyMs  = flag.Int("concurrency-queue-ms", 0, "Milliseconds a request may wait for a free slot when the concurrency limit is reached")
+		maxBackendConn = flag.Int("max-backend-connections", 0, "Requests each backend handles at once; once every backend is at it, new ones get 503 (0 disables the limit)")
+		backendQueueMs = flag.Int("backend-queue-ms", 0, "Milliseconds a request may wait for a backend below -max-backend-connections before getting 503")
+		overrideReqHdr = flag.Bool("override-request-headers", false, "Replace caller-provided values for injected request headers")
+		upstreamUA     = flag.String("upstream-user-agent", "", "User-Agent sent to backends in place of the client's (empty forwards the client's)")
+		appendUA       = flag.Bool("append-user-agent", false, "Append -upstream-user-agent to the client's User-Agent instead of replacing it")
+		requestIDHdr   = flag.String("request-id-header", "X-Request-ID", "Header carrying the per-request ID, generated when the client doesn't send one")
+		checkBackend   = flag.String("check-backend", "", "Health check this backend URL once, print the result and exit (0 healthy, 1 unhealthy) without starting the server")
 	)
 	flag.Parse()
 
@@ -31,14 +498,291 @@ func main() {
 		backendList[i] = strings.TrimSpace(backend)
 	}
 
+	// With discovery or a backends file, only use static backends if they were given explicitly
+	if (*discoverySRV != "" || *backendsFile != "") && !isFlagSet("backends") {
+		backendList = nil
+	}
+
+	// Parse backend weights string into map
+	backendWeights, err := parseWeights(*weights)
+	if err != nil {
+		log.Printf("Invalid -backend-weights: %v", err)
+		return
+	}
+
+	// Parse per-backend health check intervals into map
+	healthIntervals, err := parseIntervals(*healthPerURL)
+	if err != nil {
+		log.Printf("Invalid -health-intervals: %v", err)
+		return
+	}
+
+	// Parse per-backend health check timeouts into map
+	healthProbeTimeouts, err := parseIntervals(*healthTimeouts)
+	if err != nil {
+		log.Printf("Invalid -health-timeouts: %v", err)
+		return
+	}
+
+	perMethodTimeouts, err := parseMethodTimeouts(*methodTimeouts)
+	if err != nil {
+		log.Printf("Invalid -method-timeouts: %v", err)
+		return
+	}
+
+	// Parse per-backend upstream schemes into map
+	upstreamSchemes, err := parseSchemes(*upstreamPerURL)
+	if err != nil {
+		log.Printf("Invalid -upstream-schemes: %v", err)
+		return
+	}
+
+	// Parse per-backend client certificates into a map keyed by backend URL
+	clientCerts := make(map[string]config.ClientCert)
+	for _, spec := range backendClientCerts {
+		backendURL, cert, err := parseClientCert(spec)
+		if err != nil {
+			log.Printf("Invalid -backend-client-cert: %v", err)
+			return
+		}
+		clientCerts[backendURL] = cert
+	}
+
+	// Parse backend zones string into map
+	zones, err := parseZones(*backendZones)
+	if err != nil {
+		log.Printf("Invalid -backend-zones: %v", err)
+		return
+	}
+
+	// Parse default query parameters string into map
+	defaultQueryParams, err := parseQueryParams(*defaultQuery)
+	if err != nil {
+		log.Printf("Invalid -default-query-params: %v", err)
+		return
+	}
+
+	// Parse static metrics labels into a map keyed by label name
+	staticLabels, err := parseLabels(*metricsLabels)
+	if err != nil {
+		log.Printf("Invalid -metrics-labels: %v", err)
+		return
+	}
+
+	// Parse static responses into a map keyed by path
+	staticByPath := make(map[string]config.StaticResponse)
+	for _, spec := range staticResponses {
+		path, response, err := config.ParseStaticResponse(spec)
+		if err != nil {
+			log.Printf("Invalid -static-response: %v", err)
+			return
+		}
+		staticByPath[path] = response
+	}
+
+	// Split the required health check header into name and value
+	healthHeaderName, healthHeaderValue, _ := strings.Cut(*healthHeader, "=")
+	healthHeaderName = strings.TrimSpace(healthHeaderName)
+	healthHeaderValue = strings.TrimSpace(healthHeaderValue)
+
 	// Create config
 	cfg := &config.Config{
 		Port:                *port,
 		Backends:            backendList,
 		HealthCheckPath:     *healthPath,
+		HealthCheckMethod:   strings.ToUpper(*healthMethod),
 		HealthCheckInterval: time.Duration(*healthInterval) * time.Second,
 		HealthCheckTimeout:  time.Duration(*healthTimeout) * time.Second,
 		BackendTimeout:      time.Duration(*backendTimeout) * time.Second,
+		MethodTimeouts:      perMethodTimeouts,
+		TrustedProxies:      splitList(*trustedProxies),
+		AllowedClientCIDRs:  splitList(*allowedClients),
+
+		DisableHealthChecks: *noHealthChecks,
+
+		HealthCheckBody:        *healthBody,
+		HealthCheckContentType: strings.TrimSpace(*healthBodyType),
+
+		BackupBackends: splitList(*backupBackends),
+
+		ShadowBackend: strings.TrimSpace(*shadowBackend),
+
+		CanaryBackend: strings.TrimSpace(*canaryBackend),
+		CanaryPercent: *canaryPercent,
+		CanaryMatch:   strings.TrimSpace(*canaryMatch),
+
+		GreenBackends: splitList(*greenBackends),
+		GreenPercent:  *greenPercent,
+
+		MaxBackends: *maxBackends,
+
+		DuplicateBackends: strings.ToLower(strings.TrimSpace(*duplicates)),
+
+		UpstreamScheme:  strings.ToLower(strings.TrimSpace(*upstreamScheme)),
+		UpstreamSchemes: upstreamSchemes,
+
+		UpstreamClientCert: config.ClientCert{
+			CertFile: strings.TrimSpace(*clientCertFile),
+			KeyFile:  strings.TrimSpace(*clientKeyFile),
+		},
+		UpstreamClientCerts: clientCerts,
+
+		DetectBackendProtocol: *detectProto,
+
+		DisableKeepAlive:         *noKeepAlive,
+		DisableKeepAliveBackends: splitList(*noKeepAliveFor),
+		DialRetries:              *dialRetries,
+
+		RetryUpstreamEOF:       *retryEOF,
+		ResponseBufferMaxBytes: *bufferMax,
+
+		StreamIdleReadTimeout:  time.Duration(*idleRead) * time.Second,
+		StreamIdleWriteTimeout: time.Duration(*idleWrite) * time.Second,
+
+		HonorRetryAfter:    *retryAfter,
+		MaxRetryAfterDelay: time.Duration(*retryAfterMax) * time.Second,
+
+		ErrorBudgetThreshold: *errorBudget,
+		ErrorBudgetWindow:    time.Duration(*errorBudgetWin) * time.Second,
+
+		StaticResponses: staticByPath,
+
+		AllowedMethods: splitList(strings.ToUpper(*allowedMethods)),
+		DeniedPaths:    deniedPaths,
+
+		ConnectTunnels:        *connectTunnels,
+		ConnectAllowedTargets: splitList(*connectTargets),
+
+		RequestTimeout: time.Duration(*requestTimeout) * time.Second,
+
+		SlowRequestThreshold: time.Duration(*slowRequestMs) * time.Millisecond,
+
+		HealthCheckIdleTimeout: time.Duration(*healthIdle) * time.Second,
+		HealthCheckConcurrency: *healthConc,
+		HealthCheckType:        *healthType,
+
+		HealthCheckGRPCService: *healthGRPCSvc,
+
+		HealthCheckJitter: *healthJitter,
+
+		HealthCheckIntervals: healthIntervals,
+		HealthCheckTimeouts:  healthProbeTimeouts,
+
+		HealthCheckRequireHeader:      healthHeaderName,
+		HealthCheckRequireHeaderValue: healthHeaderValue,
+
+		HealthCheckFollowRedirects: *healthRedirect,
+
+		HealthCheckSNI:    strings.TrimSpace(*healthSNI),
+		HealthCheckCAFile: *healthCAFile,
+
+		HealthCheckStartupGrace: time.Duration(*healthGrace) * time.Second,
+
+		HealthCheckStartupBurst:         *healthBurst,
+		HealthCheckStartupBurstInterval: time.Duration(*healthBurstMs) * time.Millisecond,
+
+		HealthCheckHealthyMaxInterval: time.Duration(*healthMaxEvery) * time.Second,
+
+		HealthCheckFlapThreshold: *flapThreshold,
+		HealthCheckFlapWindow:    time.Duration(*flapWindow) * time.Second,
+		HealthCheckFlapPenalty:   time.Duration(*flapPenalty) * time.Second,
+
+		Strategy:       *strategyName,
+		BackendWeights: backendWeights,
+		HashKey:        *hashKey,
+
+		AffinityHeader: strings.TrimSpace(*affinityHeader),
+
+		LocalZone:    strings.TrimSpace(*localZone),
+		BackendZones: zones,
+
+		Routes: routes,
+
+		DiscoverySRV:      *discoverySRV,
+		DiscoveryInterval: time.Duration(*discoveryEvery) * time.Second,
+		DiscoveryScheme:   *discoveryProto,
+
+		BackendsFile:         *backendsFile,
+		BackendsFileInterval: time.Duration(*backendsEvery) * time.Second,
+
+		MetricsProvider: *metricsFormat,
+		MetricsPrefix:   strings.TrimSpace(*metricsPrefix),
+		MetricsLabels:   staticLabels,
+
+		StatsDAddress:  *statsdAddr,
+		StatsDInterval: time.Duration(*statsdEvery) * time.Second,
+		StatsDPrefix:   *statsdPrefix,
+
+		DrainWindows: drainWindows,
+
+		AdminAddress: strings.TrimSpace(*adminAddr),
+
+		ReusePort:       *reusePort,
+		ShutdownTimeout: time.Duration(*shutdownWait) * time.Second,
+
+		ProxyProtocol: *proxyProtocol,
+
+		MaxConnections: *maxConns,
+
+		MaxHeaderBytes: *maxHeaderBytes,
+
+		MaxResponseHeaderBytes: *maxRespHeader,
+
+		TLSCertFile:  *tlsCert,
+		TLSKeyFile:   *tlsKey,
+		DisableHTTP2: *noHTTP2,
+
+		TLSClientCAFile:   strings.TrimSpace(*tlsClientCA),
+		ForwardClientCert: *forwardCert,
+
+		AccessLog:              *accessLog,
+		AccessLogSampleRate:    *accessSample,
+		AccessLogSlowThreshold: time.Duration(*accessSlow) * time.Millisecond,
+
+		LogFile:       *logFile,
+		LogMaxSize:    *logMaxSize,
+		LogMaxBackups: *logMaxBackups,
+
+		ShutdownDrainPeriod: time.Duration(*shutdownDrain) * time.Second,
+
+		RateLimit:      *rateLimit,
+		RateLimitBurst: *rateBurst,
+
+		RateLimitResponseBody:        *rateBody,
+		RateLimitResponseContentType: *rateType,
+
+		ErrorFormat:         strings.ToLower(strings.TrimSpace(*errorFormat)),
+		ErrorIncludeContext: *errorContext,
+
+		MaxConcurrentRequests:   *maxConcurrent,
+		ConcurrencyQueueTimeout: time.Duration(*concurrencyMs) * time.Millisecond,
+
+		MaxBackendConnections: *maxBackendConn,
+		BackendQueueTimeout:   time.Duration(*backendQueueMs) * time.Millisecond,
+
+		ServeStaleOnError:  *serveStale,
+		FailureStatusCodes: splitList(*failureCodes),
+
+		ResponseHeaders:       responseHeaders,
+		RemoveResponseHeaders: splitList(*removeHeaders),
+		ServedByHeader:        *servedBy,
+		ServerTimingHeader:    *serverTiming,
+		ForceBackendHeader:    *forceBackend,
+
+		RequestHeaders:         requestHeaders,
+		OverrideRequestHeaders: *overrideReqHdr,
+		RequestIDHeader:        strings.TrimSpace(*requestIDHdr),
+
+		UpstreamUserAgent: strings.TrimSpace(*upstreamUA),
+		AppendUserAgent:   *appendUA,
+
+		DefaultQueryParams: defaultQueryParams,
+		RemoveQueryParams:  splitList(*removeQuery),
+	}
+
+	// Probe a single backend with the health check settings and exit
+	if *checkBackend != "" {
+		os.Exit(checkBackendOnce(cfg.WithDefaults(), strings.TrimSpace(*checkBackend), os.Stdout))
 	}
 
 	// Validate configuration
@@ -57,6 +801,9 @@ func main() {
 		return
 	}
 
+	// Move the log to its file now that the configuration is known good
+	defer setupLogFile(cfg.WithDefaults())()
+
 	// Create load balancer
 	lb, err := balancer.NewLoadBalancer(cfg)
 	if err != nil {
@@ -74,34 +821,120 @@ func main() {
 		return
 	}
 
-	// Create HTTP server with both load balancer and metrics
-	mux := http.NewServeMux()
+	loadBalancerServer := newServer(cfg, connectHandler(proxyMux(lb), lb))
 
-	// Handle metrics endpoint
-	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
-		lb.GetMetricsProvider().ServeHTTP(w, r)
-	})
-
-	// Handle all other requests with the load balancer
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		lb.ServeHTTP(w, r)
-	})
+	// Load the certificate up front so a bad pair fails before binding
+	if loadBalancerServer.TLSConfig != nil {
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			log.Fatalf("Failed to load TLS certificate: %v", err)
+		}
+		loadBalancerServer.TLSConfig.Certificates = []tls.Certificate{cert}
 
-	loadBalancerServer := &http.Server{
-		Addr:    fmt.Sprintf(":%d", cfg.Port),
-		Handler: mux,
+		if cfg.TLSClientCAFile != "" {
+			clientCAs, err := loadClientCAs(cfg.TLSClientCAFile)
+			if err != nil {
+				log.Fatalf("Failed to load TLS client CA file: %v", err)
+			}
+			loadBalancerServer.TLSConfig.ClientCAs = clientCAs
+		}
 	}
 
 	log.Printf("Load balancer starting on port %d", cfg.Port)
 	log.Printf("Forwarding requests to backends: %v", cfg.Backends)
-	log.Printf("Health checks: every %s, timeout %s, path %s",
-		cfg.HealthCheckInterval, cfg.HealthCheckTimeout, cfg.HealthCheckPath)
+	if cfg.DisableHealthChecks {
+		log.Printf("Health checks: disabled")
+	} else {
+		log.Printf("Health checks: every %s, timeout %s, %s %s",
+			cfg.HealthCheckInterval, cfg.HealthCheckTimeout, cfg.HealthCheckMethod, cfg.HealthCheckPath)
+	}
 	log.Printf("Backend request timeout: %s", cfg.BackendTimeout)
+	for method, timeout := range cfg.MethodTimeouts {
+		log.Printf("Backend request timeout for %s: %s", method, timeout)
+	}
+
+	// Bind the listening socket; with reuseport a previous instance may still hold the port
+	ln, err := listener.Listen(context.Background(), loadBalancerServer.Addr, cfg.ReusePort)
+	if err != nil {
+		log.Fatalf("Server failed to start: %v", err)
+	}
+	if cfg.ReusePort {
+		log.Printf("Listening with SO_REUSEPORT")
+	}
+
+	// Cap open connections to bound file descriptor use; over the cap, clients
+	// wait in the accept backlog
+	if cfg.MaxConnections > 0 {
+		ln = listener.NewLimitListener(ln, cfg.MaxConnections)
+		log.Printf("Accepting at most %d connections at once", cfg.MaxConnections)
+	}
+
+	// Take the client address from the PROXY header so r.RemoteAddr (and with
+	// it client IP hashing and X-Forwarded-For) reflects the real client
+	if cfg.ProxyProtocol {
+		ln = listener.NewProxyProtocolListener(ln, listener.DefaultProxyHeaderTimeout)
+		log.Printf("PROXY protocol enabled on the listener")
+	}
 
 	// Start the load balancer server
-	if err := loadBalancerServer.ListenAndServe(); err != nil {
+	serveErr := make(chan error, 2)
+	go func() {
+		if loadBalancerServer.TLSConfig != nil {
+			log.Printf("Serving TLS (protocols %v)", loadBalancerServer.TLSConfig.NextProtos)
+			serveErr <- loadBalancerServer.ServeTLS(ln, "", "")
+			return
+		}
+		serveErr <- loadBalancerServer.Serve(ln)
+	}()
+
+	// Serve the admin API on its own listener so clients of the proxy can't
+	// reach it
+	var adminServer *http.Server
+	if cfg.AdminAddress != "" {
+		adminServer = newAdminServer(cfg, lb)
+		adminLn, err := listener.Listen(context.Background(), adminServer.Addr, cfg.ReusePort)
+		if err != nil {
+			log.Fatalf("Admin server failed to start: %v", err)
+		}
+		log.Printf("Admin API listening on %s", adminLn.Addr())
+		go func() {
+			serveErr <- adminServer.Serve(adminLn)
+		}()
+	}
+
+	// On SIGINT/SIGTERM stop accepting (handing the port to any other instance
+	// bound with reuseport), let in-flight requests finish, then stop background work
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	select {
+	case err := <-serveErr:
 		if err != http.ErrServerClosed {
-			log.Fatalf("Server failed to start: %v", err)
+			log.Fatalf("Server failed: %v", err)
+		}
+	case sig := <-signals:
+		shutdownTimeout := cfg.WithDefaults().ShutdownTimeout
+		log.Printf("Received %s, shutting down (timeout %s)", sig, shutdownTimeout)
+
+		// Fail readiness and turn away new requests first, giving upstream load
+		// balancers the drain period to notice before the listener closes
+		lb.BeginShutdown()
+		loadBalancerServer.SetKeepAlivesEnabled(false)
+		if cfg.ShutdownDrainPeriod > 0 {
+			log.Printf("Draining for %s", cfg.ShutdownDrainPeriod)
+			time.Sleep(cfg.ShutdownDrainPeriod)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := loadBalancerServer.Shutdown(ctx); err != nil {
+			log.Printf("Graceful shutdown incomplete: %v", err)
+		}
+		if adminServer != nil {
+			adminServer.Shutdown(ctx)
 		}
 	}
+
+	lb.Stop()
+	log.Println("Load balancer stopped")
 }
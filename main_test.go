@@ -0,0 +1,521 @@
+package main
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go-balancer/internal/balancer"
+	"go-balancer/internal/config"
+)
+
+func TestServerMaxHeaderBytes(t *testing.T) {
+	cfg := &config.Config{MaxHeaderBytes: 1024}
+	server := newServer(cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	go server.Serve(ln)
+	defer server.Close()
+
+	url := "http://" + ln.Addr().String() + "/"
+
+	tests := []struct {
+		name         string
+		headerSize   int
+		expectedCode int
+	}{
+		{"Small headers", 512, http.StatusOK},
+		// net/http allows some slack past MaxHeaderBytes, so go well beyond it
+		{"Oversized headers", 16 << 10, http.StatusRequestHeaderFieldsTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", url, nil)
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+			req.Header.Set("X-Padding", strings.Repeat("a", tt.headerSize))
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.expectedCode {
+				t.Errorf("Expected status %d, got %d", tt.expectedCode, resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestConnectHandler(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	lb := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	server := httptest.NewServer(connectHandler(mux, lb))
+	defer server.Close()
+
+	// The mux would answer a CONNECT request, which has no path, with 404
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+	io.WriteString(conn, "CONNECT db.internal:5432 HTTP/1.1\r\nHost: db.internal:5432\r\n\r\n")
+	resp, err := http.ReadResponse(bufio.NewReader(conn), &http.Request{Method: http.MethodConnect})
+	if err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+	if resp.StatusCode != http.StatusTeapot {
+		t.Errorf("Expected CONNECT to reach the load balancer, got status %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get(server.URL + "/")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected other requests to reach the mux, got status %d", resp.StatusCode)
+	}
+}
+
+func TestServerDefaultMaxHeaderBytes(t *testing.T) {
+	server := newServer(&config.Config{}, http.NotFoundHandler())
+	if server.MaxHeaderBytes != http.DefaultMaxHeaderBytes {
+		t.Errorf("Expected default max header bytes %d, got %d", http.DefaultMaxHeaderBytes, server.MaxHeaderBytes)
+	}
+}
+
+// writeTestCert writes a self-signed certificate for 127.0.0.1 and its key,
+// returning the file paths. The certificate also works as a client
+// certificate, and as the CA that verifies it.
+func writeTestCert(t *testing.T) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "go-balancer test"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+	return certFile, keyFile
+}
+
+// serveTLS starts server on a local TLS listener and returns its base URL
+func serveTLS(t *testing.T, server *http.Server, certFile, keyFile string) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("Failed to load certificate: %v", err)
+	}
+	server.TLSConfig.Certificates = []tls.Certificate{cert}
+	go server.ServeTLS(ln, "", "")
+	t.Cleanup(func() { server.Close() })
+
+	return "https://" + ln.Addr().String()
+}
+
+// newTLSClient returns a client that trusts any certificate and attempts HTTP/2
+func newTLSClient() *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+			ForceAttemptHTTP2: true,
+		},
+		Timeout: 5 * time.Second,
+	}
+}
+
+func TestServerTLSProtocolNegotiation(t *testing.T) {
+	certFile, keyFile := writeTestCert(t)
+
+	tests := []struct {
+		name          string
+		disableHTTP2  bool
+		expectedProto string
+	}{
+		{"HTTP/2 negotiated with ALPN", false, "HTTP/2.0"},
+		{"HTTP/2 disabled", true, "HTTP/1.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{TLSCertFile: certFile, TLSKeyFile: keyFile, DisableHTTP2: tt.disableHTTP2}
+			server := newServer(cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(r.Proto))
+			}))
+			url := serveTLS(t, server, certFile, keyFile)
+
+			resp, err := newTLSClient().Get(url + "/")
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.Proto != tt.expectedProto {
+				t.Errorf("Expected client protocol %s, got %s", tt.expectedProto, resp.Proto)
+			}
+			body := make([]byte, 16)
+			n, _ := resp.Body.Read(body)
+			if got := string(body[:n]); got != tt.expectedProto {
+				t.Errorf("Expected handler to see protocol %s, got %s", tt.expectedProto, got)
+			}
+		})
+	}
+}
+
+func TestServerHTTP2StreamsThroughBalancer(t *testing.T) {
+	release := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/stream" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.Write([]byte("first\n"))
+		w.(http.Flusher).Flush()
+		<-release
+		w.Write([]byte("second\n"))
+	}))
+	defer backend.Close()
+	defer close(release)
+
+	certFile, keyFile := writeTestCert(t)
+	cfg := &config.Config{
+		Port:                8000,
+		Backends:            []string{backend.URL},
+		HealthCheckPath:     "/",
+		HealthCheckInterval: 10 * time.Second,
+		HealthCheckTimeout:  2 * time.Second,
+		BackendTimeout:      30 * time.Second,
+		TLSCertFile:         certFile,
+		TLSKeyFile:          keyFile,
+	}
+	lb, err := balancer.NewLoadBalancer(cfg)
+	if err != nil {
+		t.Fatalf("Load balancer creation failed: %v", err)
+	}
+	defer lb.Stop()
+
+	// Backends start unhealthy until the first probe round completes
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		recorder := httptest.NewRecorder()
+		lb.ReadinessHandler().ServeHTTP(recorder, httptest.NewRequest("GET", "/readyz", nil))
+		if recorder.Code == http.StatusOK {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Backend never became healthy")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	url := serveTLS(t, newServer(cfg, lb), certFile, keyFile)
+
+	resp, err := newTLSClient().Get(url + "/stream")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.Proto != "HTTP/2.0" {
+		t.Errorf("Expected HTTP/2.0, got %s", resp.Proto)
+	}
+
+	// The first chunk must arrive while the backend is still holding the rest
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil {
+		t.Fatalf("Failed to read streamed chunk: %v", err)
+	}
+	if line != "first\n" {
+		t.Errorf("Expected first chunk %q, got %q", "first\n", line)
+	}
+}
+
+func TestServerForwardsClientCert(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Client-Cert-Subject")))
+	}))
+	defer backend.Close()
+
+	certFile, keyFile := writeTestCert(t)
+	cfg := &config.Config{
+		Port:                8000,
+		Backends:            []string{backend.URL},
+		HealthCheckPath:     "/",
+		HealthCheckInterval: 10 * time.Second,
+		HealthCheckTimeout:  2 * time.Second,
+		BackendTimeout:      30 * time.Second,
+		TLSCertFile:         certFile,
+		TLSKeyFile:          keyFile,
+		TLSClientCAFile:     certFile,
+		ForwardClientCert:   true,
+	}
+	lb, err := balancer.NewLoadBalancer(cfg)
+	if err != nil {
+		t.Fatalf("Load balancer creation failed: %v", err)
+	}
+	defer lb.Stop()
+
+	// Backends start unhealthy until the first probe round completes
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		recorder := httptest.NewRecorder()
+		lb.ReadinessHandler().ServeHTTP(recorder, httptest.NewRequest("GET", "/readyz", nil))
+		if recorder.Code == http.StatusOK {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Backend never became healthy")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	server := newServer(cfg, lb)
+	clientCAs, err := loadClientCAs(cfg.TLSClientCAFile)
+	if err != nil {
+		t.Fatalf("Failed to load client CAs: %v", err)
+	}
+	server.TLSConfig.ClientCAs = clientCAs
+	url := serveTLS(t, server, certFile, keyFile)
+
+	clientCert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("Failed to load client certificate: %v", err)
+	}
+
+	tests := []struct {
+		name            string
+		certificates    []tls.Certificate
+		spoofed         string
+		expectedSubject string
+	}{
+		{"Client certificate", []tls.Certificate{clientCert}, "", "CN=go-balancer test"},
+		{"Spoofed header replaced", []tls.Certificate{clientCert}, "CN=admin", "CN=go-balancer test"},
+		{"No client certificate", nil, "CN=admin", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTLSClient()
+			client.Transport.(*http.Transport).TLSClientConfig.Certificates = tt.certificates
+
+			req, _ := http.NewRequest("GET", url+"/whoami", nil)
+			if tt.spoofed != "" {
+				req.Header.Set("X-Client-Cert-Subject", tt.spoofed)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+			defer resp.Body.Close()
+
+			body, _ := io.ReadAll(resp.Body)
+			if got := string(body); got != tt.expectedSubject {
+				t.Errorf("Expected backend to see subject %q, got %q", tt.expectedSubject, got)
+			}
+		})
+	}
+}
+
+func TestAdminEndpointsOnlyOnAdminMux(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("backend " + r.URL.Path))
+	}))
+	defer backend.Close()
+
+	cfg := &config.Config{
+		Port:                8000,
+		Backends:            []string{backend.URL},
+		HealthCheckPath:     "/",
+		HealthCheckInterval: 10 * time.Second,
+		HealthCheckTimeout:  2 * time.Second,
+		BackendTimeout:      30 * time.Second,
+		AdminAddress:        "127.0.0.1:0",
+	}
+	lb, err := balancer.NewLoadBalancer(cfg)
+	if err != nil {
+		t.Fatalf("Load balancer creation failed: %v", err)
+	}
+	defer lb.Stop()
+
+	// Backends start unhealthy until the first probe round completes
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		recorder := httptest.NewRecorder()
+		lb.ReadinessHandler().ServeHTTP(recorder, httptest.NewRequest("GET", "/readyz", nil))
+		if recorder.Code == http.StatusOK {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Backend never became healthy")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	proxy, admin := proxyMux(lb), newAdminServer(cfg, lb).Handler
+	for _, path := range []string{"/status", "/admin/config", "/admin/maintenance", "/admin/backends/backend-1", "/admin/traffic-split", "/admin/routing"} {
+		// On the proxy port admin paths are ordinary requests for the backends
+		recorder := httptest.NewRecorder()
+		proxy.ServeHTTP(recorder, httptest.NewRequest("GET", "http://localhost:8000"+path, nil))
+		if got := recorder.Body.String(); got != "backend "+path {
+			t.Errorf("Expected %s on the proxy port to reach the backend, got %d %q", path, recorder.Code, got)
+		}
+
+		recorder = httptest.NewRecorder()
+		admin.ServeHTTP(recorder, httptest.NewRequest("GET", "http://127.0.0.1:8001"+path, nil))
+		if got := recorder.Body.String(); got == "404 page not found\n" || strings.HasPrefix(got, "backend ") {
+			t.Errorf("Expected %s to be served by the admin API, got %d %q", path, recorder.Code, recorder.Body.String())
+		}
+	}
+
+	// The admin API doesn't proxy anything else
+	recorder := httptest.NewRecorder()
+	admin.ServeHTTP(recorder, httptest.NewRequest("GET", "http://127.0.0.1:8001/api", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("Expected other paths on the admin API to get 404, got %d", recorder.Code)
+	}
+}
+
+func TestSetupLogFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "balancer.log")
+	closeLog := setupLogFile(&config.Config{LogFile: path, LogMaxSize: 1, LogMaxBackups: 1})
+
+	log.Printf("written to the log file")
+	closeLog()
+	log.Printf("written to stderr again")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Expected log file to be created: %v", err)
+	}
+	if !strings.Contains(string(data), "written to the log file") {
+		t.Errorf("Expected log line in the file, got %q", string(data))
+	}
+	if strings.Contains(string(data), "stderr again") {
+		t.Errorf("Expected the log to go back to stderr once closed")
+	}
+}
+
+func TestSetupLogFileFallsBackToStderr(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "balancer.log")
+	closeLog := setupLogFile(&config.Config{LogFile: path, LogMaxSize: 1})
+	defer closeLog()
+
+	if out := log.Writer(); out != os.Stderr {
+		t.Errorf("Expected log output to stay on stderr, got %T", out)
+	}
+}
+
+func TestCheckBackendOnce(t *testing.T) {
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer healthy.Close()
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	// A listener closed straight away leaves an address nothing answers on
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	unreachable := "http://" + ln.Addr().String()
+	ln.Close()
+
+	tests := []struct {
+		name         string
+		url          string
+		expectedCode int
+		expectedText string
+	}{
+		{"Reachable healthy backend", healthy.URL, 0, "is healthy"},
+		{"Unhealthy status", failing.URL, 1, "status_code: 503"},
+		{"Unreachable backend", unreachable, 1, "is unhealthy"},
+		{"Invalid URL", "not-a-url", 1, "is unhealthy"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := (&config.Config{
+				HealthCheckPath:     "/health",
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  time.Second,
+			}).WithDefaults()
+
+			var out strings.Builder
+			if code := checkBackendOnce(cfg, tt.url, &out); code != tt.expectedCode {
+				t.Errorf("Expected exit code %d, got %d (output %q)", tt.expectedCode, code, out.String())
+			}
+			if !strings.Contains(out.String(), tt.expectedText) {
+				t.Errorf("Expected output to contain %q, got %q", tt.expectedText, out.String())
+			}
+		})
+	}
+}